@@ -0,0 +1,174 @@
+// Package chatcontext builds the messages sent to the LLM for a chat turn: a system prompt,
+// the running summary of older turns and as much recent history as fits a token budget.
+package chatcontext
+
+import (
+	"fmt"
+	"strings"
+
+	"lambda-server/llm"
+)
+
+// SummaryMaxWords bounds the running summary of a session
+const SummaryMaxWords = 200
+
+// messageOverhead approximates the tokens each message adds for its role and separators
+const messageOverhead = 4
+
+// Input describes one chat turn
+type Input struct {
+	System   string        // System prompt
+	Summary  string        // Running summary of turns no longer in History
+	Memory   string        // Passages retrieved from the user's journals and earlier conversations
+	History  []llm.Message // Recent turns, oldest first
+	Message  string        // The new user message
+	Budget   int           // Token budget for the whole prompt
+	MaxTurns int           // Most history messages to keep; 0 for no limit
+}
+
+// Window is the prompt for a chat turn
+type Window struct {
+	Messages []llm.Message // System prompt, summary, recent history and the new message
+	Overflow []llm.Message // Older history left out; fold it into the summary
+	Tokens   int           // Estimated tokens of Messages
+}
+
+// EstimateTokens approximates the token count of text at four characters per token
+func EstimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// messageTokens estimates the tokens a message takes in the prompt
+func messageTokens(msg llm.Message) int {
+	return EstimateTokens(msg.Content) + messageOverhead
+}
+
+// EstimateMessagesTokens approximates the prompt tokens of messages
+func EstimateMessagesTokens(messages []llm.Message) int {
+	tokens := 0
+	for _, msg := range messages {
+		tokens += messageTokens(msg)
+	}
+	return tokens
+}
+
+// Build assembles the prompt, keeping the newest history that fits the budget and MaxTurns. The system
+// prompt, summary, memory and new message are always included, even when they alone exceed it.
+// The kept history always starts with a user turn, so no reply appears without its question.
+func Build(in Input) Window {
+	head := []llm.Message{}
+	if in.System != "" {
+		head = append(head, llm.Message{Role: llm.RoleSystem, Content: in.System})
+	}
+	if in.Summary != "" {
+		head = append(head, llm.Message{Role: llm.RoleSystem, Content: "Summary of the earlier conversation: " + in.Summary})
+	}
+	if in.Memory != "" {
+		head = append(head, llm.Message{Role: llm.RoleSystem, Content: in.Memory})
+	}
+	message := llm.Message{Role: llm.RoleUser, Content: in.Message}
+
+	used := messageTokens(message)
+	for _, msg := range head {
+		used += messageTokens(msg)
+	}
+
+	cut := len(in.History)
+	for cut > 0 && (in.MaxTurns <= 0 || len(in.History)-cut < in.MaxTurns) {
+		cost := messageTokens(in.History[cut-1])
+		if used+cost > in.Budget {
+			break
+		}
+		used += cost
+		cut--
+	}
+	for cut < len(in.History) && in.History[cut].Role != llm.RoleUser {
+		used -= messageTokens(in.History[cut])
+		cut++
+	}
+
+	messages := make([]llm.Message, 0, len(head)+len(in.History)-cut+1)
+	messages = append(messages, head...)
+	messages = append(messages, in.History[cut:]...)
+	messages = append(messages, message)
+	return Window{
+		Messages: messages,
+		Overflow: in.History[:cut],
+		Tokens:   used,
+	}
+}
+
+// SummaryMessages builds the request that folds overflowing turns into the running summary
+func SummaryMessages(previous string, overflow []llm.Message) []llm.Message {
+	if previous == "" {
+		previous = "(none yet)"
+	}
+	return []llm.Message{
+		{
+			Role: llm.RoleSystem,
+			Content: fmt.Sprintf("You keep a running summary of a conversation between a user and a supportive "+
+				"wellbeing assistant. Merge the new messages into the current summary. Keep what the user shared "+
+				"about their situation and feelings, concerns raised, suggestions made and anything they committed "+
+				"to. Write in the third person, at most %d words, and reply with the summary only.", SummaryMaxWords),
+		},
+		{
+			Role:    llm.RoleUser,
+			Content: "Current summary:\n" + previous + "\n\nNew messages:\n" + transcript(overflow),
+		},
+	}
+}
+
+// transcript writes messages out as "User: ..." and "Assistant: ..." lines
+func transcript(messages []llm.Message) string {
+	var b strings.Builder
+	for _, msg := range messages {
+		speaker := "Assistant"
+		if msg.Role == llm.RoleUser {
+			speaker = "User"
+		}
+		fmt.Fprintf(&b, "%s: %s\n", speaker, msg.Content)
+	}
+	return b.String()
+}
+
+// TitleMessages builds the request for a short title of a conversation from its first exchange
+func TitleMessages(message, reply string) []llm.Message {
+	return []llm.Message{
+		{
+			Role: llm.RoleSystem,
+			Content: "Write a short title, at most six words, for a conversation between a user and a supportive " +
+				"wellbeing assistant that starts with the messages below. Reply with the title only, without quotes.",
+		},
+		{Role: llm.RoleUser, Content: "User: " + message + "\nAssistant: " + reply},
+	}
+}
+
+// CleanTitle tidies a generated title, falling back to the start of the first message when the
+// title is empty
+func CleanTitle(title, message string, maxLen int) string {
+	title = strings.TrimSpace(title)
+	if line, _, found := strings.Cut(title, "\n"); found {
+		title = line
+	}
+	title = strings.TrimPrefix(strings.TrimPrefix(title, "Title:"), "title:")
+	title = strings.Trim(strings.TrimSpace(title), "\"'*.# ")
+	if title == "" {
+		title = strings.Join(strings.Fields(message), " ")
+	}
+	return Preview(title, maxLen)
+}
+
+// Preview shortens text to at most maxLen characters, cutting at a word boundary where it can
+// and marking the cut with an ellipsis
+func Preview(text string, maxLen int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= maxLen {
+		return text
+	}
+	cut := string(runes[:maxLen-1])
+	if i := strings.LastIndex(cut, " "); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,.;:") + "…"
+}
@@ -0,0 +1,161 @@
+package chatcontext
+
+import (
+	"strings"
+	"testing"
+
+	"lambda-server/llm"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// turn returns a message that costs exactly tokens tokens including overhead
+func turn(role string, tokens int) llm.Message {
+	return llm.Message{Role: role, Content: strings.Repeat("abcd", tokens-messageOverhead)}
+}
+
+func TestBuildFitsEverything(t *testing.T) {
+	history := []llm.Message{turn(llm.RoleUser, 10), turn(llm.RoleAssistant, 10)}
+	window := Build(Input{System: "sys", History: history, Message: "hi", Budget: 1000})
+
+	require.Len(t, window.Messages, 4)
+	assert.Equal(t, llm.RoleSystem, window.Messages[0].Role)
+	assert.Equal(t, history, window.Messages[1:3])
+	assert.Equal(t, llm.Message{Role: llm.RoleUser, Content: "hi"}, window.Messages[3])
+	assert.Empty(t, window.Overflow)
+}
+
+func TestBuildKeepsNewestWithinBudget(t *testing.T) {
+	history := []llm.Message{
+		turn(llm.RoleUser, 20), turn(llm.RoleAssistant, 20),
+		turn(llm.RoleUser, 20), turn(llm.RoleAssistant, 20),
+	}
+	// New message "hi" costs 5; room for two more turns of 20
+	window := Build(Input{History: history, Message: "hi", Budget: 45})
+
+	assert.Equal(t, history[2:], window.Messages[:2])
+	assert.Equal(t, history[:2], window.Overflow)
+	assert.LessOrEqual(t, window.Tokens, 45)
+}
+
+func TestBuildStartsWithUserTurn(t *testing.T) {
+	history := []llm.Message{
+		turn(llm.RoleUser, 20), turn(llm.RoleAssistant, 20), turn(llm.RoleUser, 20), turn(llm.RoleAssistant, 20),
+	}
+	// Room for three history messages, but the oldest of them would be an orphaned reply
+	window := Build(Input{History: history, Message: "hi", Budget: 65})
+
+	assert.Equal(t, history[2:], window.Messages[:2])
+	assert.Equal(t, history[:2], window.Overflow)
+	assert.Equal(t, 45, window.Tokens)
+}
+
+func TestBuildMaxTurns(t *testing.T) {
+	history := []llm.Message{
+		turn(llm.RoleUser, 10), turn(llm.RoleAssistant, 10), turn(llm.RoleUser, 10), turn(llm.RoleAssistant, 10),
+	}
+	window := Build(Input{History: history, Message: "hi", Budget: 1000, MaxTurns: 2})
+
+	assert.Equal(t, history[2:], window.Messages[:2])
+	assert.Equal(t, history[:2], window.Overflow)
+}
+
+func TestBuildIncludesSummary(t *testing.T) {
+	window := Build(Input{System: "sys", Summary: "User is stressed about exams.", Message: "hi", Budget: 1000})
+
+	require.Len(t, window.Messages, 3)
+	assert.Equal(t, llm.RoleSystem, window.Messages[1].Role)
+	assert.Contains(t, window.Messages[1].Content, "stressed about exams")
+}
+
+func TestBuildIncludesMemoryAfterSummary(t *testing.T) {
+	history := []llm.Message{turn(llm.RoleUser, 20), turn(llm.RoleAssistant, 20)}
+	window := Build(Input{Summary: "summary", Memory: "[1] Journal entry: exams", History: history, Message: "hi", Budget: 40})
+
+	require.Len(t, window.Messages, 3)
+	assert.Equal(t, "[1] Journal entry: exams", window.Messages[1].Content)
+	assert.Equal(t, history, window.Overflow) // The memory takes room from the history
+}
+
+func TestBuildOverBudgetKeepsMessage(t *testing.T) {
+	history := []llm.Message{turn(llm.RoleUser, 20), turn(llm.RoleAssistant, 20)}
+	window := Build(Input{System: strings.Repeat("x", 400), History: history, Message: "hi", Budget: 10})
+
+	require.Len(t, window.Messages, 2)
+	assert.Equal(t, "hi", window.Messages[1].Content)
+	assert.Equal(t, history, window.Overflow)
+}
+
+func TestSummaryMessages(t *testing.T) {
+	messages := SummaryMessages("", []llm.Message{
+		{Role: llm.RoleUser, Content: "I can't sleep"},
+		{Role: llm.RoleAssistant, Content: "That sounds hard"},
+	})
+	require.Len(t, messages, 2)
+	assert.Contains(t, messages[1].Content, "(none yet)")
+	assert.Contains(t, messages[1].Content, "User: I can't sleep\nAssistant: That sounds hard\n")
+}
+
+func TestEstimateTokens(t *testing.T) {
+	assert.Equal(t, 0, EstimateTokens(""))
+	assert.Equal(t, 1, EstimateTokens("abc"))
+	assert.Equal(t, 2, EstimateTokens("abcde"))
+}
+
+func TestCleanTitle(t *testing.T) {
+	assert.Equal(t, "Coping with exam stress", CleanTitle(" \"Coping with exam stress.\"\n", "", 80))
+	assert.Equal(t, "Sleep troubles", CleanTitle("Title: Sleep troubles", "", 80))
+	assert.Equal(t, "I can't sleep at night", CleanTitle("", " I can't   sleep at night ", 80))
+	assert.Equal(t, "I have been…", CleanTitle("", "I have been feeling low", 15))
+}
+
+func TestPreview(t *testing.T) {
+	assert.Equal(t, "short text", Preview("short\n text", 20))
+	assert.Equal(t, "abcdefghi…", Preview("abcdefghijklmnop", 10))
+	assert.Len(t, []rune(Preview(strings.Repeat("word ", 50), 30)), 25)
+}
+
+func TestJournalDraftMessages(t *testing.T) {
+	messages := JournalDraftMessages("User was anxious.", []llm.Message{
+		{Role: llm.RoleUser, Content: "I want to sleep better"},
+		{Role: llm.RoleAssistant, Content: "Try a wind-down routine"},
+	})
+
+	require.Len(t, messages, 2)
+	assert.Contains(t, messages[0].Content, `"actionItems"`)
+	assert.Equal(t, "Summary of the earlier conversation: User was anxious.\n\n"+
+		"User: I want to sleep better\nAssistant: Try a wind-down routine\n", messages[1].Content)
+}
+
+func TestParseJournalDraft(t *testing.T) {
+	reply := "```json\n" + `{"title": "\"Sleep plan\"", "summary": " I talked about sleep. ",` +
+		` "insights": ["- Screens keep me up", " "], "actionItems": ["No phone after 10pm"]}` + "\n```"
+
+	draft, err := ParseJournalDraft(reply, "Fallback", 80)
+	require.NoError(t, err)
+	assert.Equal(t, "Sleep plan", draft.Title)
+	assert.Equal(t, "I talked about sleep.", draft.Summary)
+	assert.Equal(t, []string{"Screens keep me up"}, draft.Insights)
+	assert.Equal(t, []string{"No phone after 10pm"}, draft.ActionItems)
+	assert.Equal(t, "I talked about sleep.\n\n## Key insights\n\n- Screens keep me up\n\n## Action items\n\n- [ ] No phone after 10pm", draft.Content)
+}
+
+func TestParseJournalDraftFallsBackToText(t *testing.T) {
+	draft, err := ParseJournalDraft("I talked about sleep.", "Better sleep", 80)
+	require.NoError(t, err)
+	assert.Equal(t, "Better sleep", draft.Title)
+	assert.Equal(t, "I talked about sleep.", draft.Content)
+	assert.Empty(t, draft.Insights)
+
+	_, err = ParseJournalDraft(`{"title": "x", "summary": ""}`, "Better sleep", 80)
+	assert.Error(t, err)
+}
+
+func TestNewest(t *testing.T) {
+	history := []llm.Message{turn(llm.RoleUser, 10), turn(llm.RoleAssistant, 10), turn(llm.RoleUser, 10)}
+
+	assert.Equal(t, history[1:], Newest(history, 25))
+	assert.Equal(t, history, Newest(history, 30))
+	assert.Empty(t, Newest(history, 5))
+}
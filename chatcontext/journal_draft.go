@@ -0,0 +1,105 @@
+package chatcontext
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"lambda-server/llm"
+	"lambda-server/models"
+)
+
+// Bounds of a journal draft
+const (
+	DraftSummaryMaxWords = 250
+	DraftMaxItems        = 6 // Most insights and most action items kept
+)
+
+// JournalDraftMessages builds the request that turns a conversation into a journal entry
+// written by the user: a summary, key insights and action items, as JSON. summary is the
+// session's running summary of turns older than history, if any.
+func JournalDraftMessages(summary string, history []llm.Message) []llm.Message {
+	conversation := transcript(history)
+	if summary != "" {
+		conversation = "Summary of the earlier conversation: " + summary + "\n\n" + conversation
+	}
+	return []llm.Message{
+		{
+			Role: llm.RoleSystem,
+			Content: fmt.Sprintf("Turn the conversation below, between a user and a supportive wellbeing assistant, "+
+				"into a draft journal entry the user can keep. Write in the first person, as the user, in a warm and "+
+				"plain voice, and only include what the conversation supports. Reply with JSON only, in the form "+
+				`{"title": "...", "summary": "...", "insights": ["..."], "actionItems": ["..."]}: `+
+				"a title of at most six words, a summary of at most %d words of what the user talked through, up to "+
+				"%d key insights they reached, and up to %d concrete action items they chose or agreed to. Use "+
+				"empty lists when there are none.", DraftSummaryMaxWords, DraftMaxItems, DraftMaxItems),
+		},
+		{Role: llm.RoleUser, Content: conversation},
+	}
+}
+
+// ParseJournalDraft reads the model's reply to JournalDraftMessages. A reply that is not the
+// requested JSON is kept whole as the summary, so a model that ignores the format still
+// yields a usable draft. fallbackTitle is used when the model gives no title.
+func ParseJournalDraft(reply, fallbackTitle string, titleMaxLen int) (models.JournalDraft, error) {
+	var draft models.JournalDraft
+	reply = strings.TrimSpace(reply)
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start || json.Unmarshal([]byte(reply[start:end+1]), &draft) != nil {
+		draft = models.JournalDraft{Summary: strings.Trim(reply, "`")}
+	}
+	draft.Summary = strings.TrimSpace(draft.Summary)
+	if draft.Summary == "" {
+		return draft, fmt.Errorf("the model returned no summary")
+	}
+	draft.Title = CleanTitle(draft.Title, fallbackTitle, titleMaxLen)
+	draft.Insights = cleanItems(draft.Insights)
+	draft.ActionItems = cleanItems(draft.ActionItems)
+	draft.Content = draftContent(draft)
+	return draft, nil
+}
+
+// cleanItems trims list items, dropping empty ones and any bullet the model added
+func cleanItems(items []string) []string {
+	cleaned := []string{}
+	for _, item := range items {
+		item = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(item), "-*•"))
+		if item != "" && len(cleaned) < DraftMaxItems {
+			cleaned = append(cleaned, item)
+		}
+	}
+	return cleaned
+}
+
+// draftContent lays a draft out as the Markdown body of a journal entry
+func draftContent(draft models.JournalDraft) string {
+	var b strings.Builder
+	b.WriteString(draft.Summary)
+	if len(draft.Insights) > 0 {
+		b.WriteString("\n\n## Key insights\n")
+		for _, insight := range draft.Insights {
+			b.WriteString("\n- " + insight)
+		}
+	}
+	if len(draft.ActionItems) > 0 {
+		b.WriteString("\n\n## Action items\n")
+		for _, item := range draft.ActionItems {
+			b.WriteString("\n- [ ] " + item)
+		}
+	}
+	return b.String()
+}
+
+// Newest returns the newest messages that fit in budget tokens, oldest first
+func Newest(messages []llm.Message, budget int) []llm.Message {
+	cut := len(messages)
+	for cut > 0 {
+		cost := messageTokens(messages[cut-1])
+		if cost > budget {
+			break
+		}
+		budget -= cost
+		cut--
+	}
+	return messages[cut:]
+}
@@ -6,15 +6,78 @@ const (
 	JournalsTable      string = "mindmuse_journal"
 	JournalQueryLimit  int    = 20
 	MindMuseScoreTable string = "mindmuse_score"
+	JournalTagsTable   string = "mindmuse_journal_tags"
+	AttachmentsTable   string = "mindmuse_journal_attachments"
 
 	// Add chat table name
 	ChatTable string = "mindmuse_chat"
 
+	// Chat message senders
+	ChatSenderUser string = "user"
+	ChatSenderAI   string = "ai"
+
 	// DynamoDB Key Names for Journals
-	DynamoDbKeyUserId    string = "UserId"
-	DynamoDbKeyJournalId string = "JournalId"
+	DynamoDbKeyUserId         string = "UserId"
+	DynamoDbKeyJournalId      string = "JournalId"
+	DynamoDbKeyCreatedAt      string = "CreatedAt"
+	DynamoDbKeyDeletedAt      string = "deletedAt"
+	DynamoDbKeyTag            string = "Tag"
+	DynamoDbKeyJournalVersion string = "version"
+
+	// DynamoDB Key Names for Attachments
+	DynamoDbKeyAttachmentId string = "AttachmentId"
+)
+
+// Journal trash settings
+const (
+	// Env var holding the number of days a trashed journal is kept before it is purged
+	JournalTrashRetentionDaysEnv string = "JOURNAL_TRASH_RETENTION_DAYS"
+	JournalTrashRetentionDays    int    = 30
+	// Sparse GSI holding only trashed entries: trashed (always JournalTrashedValue) + deletedAt
+	JournalTrashIndex   string = "trashed-deletedAt-index"
+	DynamoDbKeyTrashed  string = "trashed"
+	JournalTrashedValue string = "trash"
+)
+
+// Journal metadata limits
+const (
+	JournalMaxTags      int = 10
+	JournalMaxTagLength int = 32
+	JournalMinMood      int = 1
+	JournalMaxMood      int = 5
+)
+
+// JournalEmotionLabels lists the emotion labels that can be attached to a journal entry
+var JournalEmotionLabels = []string{
+	"happy", "calm", "grateful", "hopeful", "excited", "loved",
+	"sad", "anxious", "angry", "stressed", "lonely", "tired", "confused", "overwhelmed",
+}
+
+// Journal attachment settings
+const (
+	AttachmentKindPhoto string = "photo"
+	AttachmentKindVoice string = "voice"
+
+	AttachmentStatusPending string = "pending" // Upload URL issued, bytes not received yet
+	AttachmentStatusReady   string = "ready"
+
+	AttachmentMaxPhotoBytes    int64  = 10 << 20
+	AttachmentMaxVoiceBytes    int64  = 25 << 20
+	AttachmentMaxPerJournal    int    = 10
+	AttachmentURLExpiryMins    int    = 15
+	AttachmentBlobPath         string = "/api/attachments/blob"
+	AttachmentStorageDirEnv    string = "ATTACHMENT_STORAGE_DIR"
+	AttachmentBaseURLEnv       string = "ATTACHMENT_BASE_URL"
+	AttachmentSigningSecretEnv string = "ATTACHMENT_SIGNING_SECRET"
+	AttachmentS3BucketEnv      string = "ATTACHMENT_S3_BUCKET"
 )
 
+// AttachmentContentTypes maps each attachment kind to the MIME types accepted for it
+var AttachmentContentTypes = map[string][]string{
+	AttachmentKindPhoto: {"image/jpeg", "image/png", "image/webp"},
+	AttachmentKindVoice: {"audio/mpeg", "audio/mp4", "audio/x-m4a", "audio/aac", "audio/wav", "audio/ogg", "audio/webm", "audio/3gpp"},
+}
+
 // To identify if project is running locally or on cloud
 const (
 	IsRunningLocally string = "is_running_locally"
@@ -26,5 +89,338 @@ const (
 const (
 	QueryParamJournalId string = "journalId"
 	QueryParamUserId    string = "userId"
+	QueryParamTag       string = "tag"
+	QueryParamMood      string = "mood"
 	ContextKeyUserId    string = "userId"
+	QueryParamFormat    string = "format"
+	QueryParamFrom      string = "from"
+	QueryParamTo        string = "to"
+
+	// Journal import query parameters
+	QueryParamImportSource string = "source"
+	QueryParamJobId        string = "jobId"
+	QueryParamFileName     string = "fileName"
+
+	// Journaling prompt query parameters
+	QueryParamPromptId string = "promptId"
+	QueryParamCategory string = "category"
+	QueryParamLocale   string = "locale"
+
+	// Journal stats query parameter and time zone header
+	QueryParamYear string = "year"
+	HeaderTimezone string = "X-Timezone"
+
+	// Attachment path and signed URL query parameters
+	QueryParamAttachmentId  string = "attachmentId"
+	QueryParamBlobKey       string = "key"
+	QueryParamBlobOp        string = "op"
+	QueryParamBlobExpires   string = "expires"
+	QueryParamBlobSignature string = "sig"
+)
+
+// Journal import settings
+const (
+	ImportJobsTable         string = "mindmuse_import_jobs"
+	DynamoDbKeyJobId        string = "JobId"
+	ImportStatusUploading   string = "uploading" // Upload URL issued, archive not confirmed yet
+	ImportStatusQueued      string = "queued"
+	ImportStatusRunning     string = "running"
+	ImportStatusCompleted   string = "completed"
+	ImportStatusFailed      string = "failed"
+	ImportMaxArchiveBytes   int64  = 50 << 20
+	ImportMaxReportedErrors int    = 100 // Per-item errors kept on the job record
+	ImportBatchSize         int    = 25  // DynamoDB BatchWriteItem limit
+	ImportStaleJobMins      int    = 15  // A running job without progress for this long is picked up again
+	ImportURLExpiryMins     int    = 15
+	ImportFileNameMaxLen    int    = 255
+	ImportJobRetentionDays  int    = 7 // Finished and never-started jobs expire through DynamoDB TTL
+	ImportArchivePrefix     string = "imports/"
+	// Queued and running jobs carry pending, which puts them in a sparse index
+	DynamoDbKeyPending string = "pending"
+	ImportPendingValue string = "pending"
+	ImportPendingIndex string = "pending-updatedAt-index"
+)
+
+// Journaling prompt settings
+const (
+	PromptsTable             string = "mindmuse_prompts"
+	PromptHistoryTable       string = "mindmuse_prompt_history"
+	DynamoDbKeyPromptId      string = "PromptId"
+	DynamoDbKeyDate          string = "Date"
+	PromptCategoryCBT        string = "cbt"
+	PromptCategoryGratitude  string = "gratitude"
+	PromptCategoryReflection string = "reflection"
+	PromptDefaultLocale      string = "en"
+	PromptRepeatWindowDays   int    = 90 // A prompt is not shown again to the same user within this window
+	PromptMoodLookbackDays   int    = 7  // Journal moods from this many days shape the daily prompt
+	PromptMaxTextLength      int    = 500
+
+	// Average mood (1-5) at or below which supportive CBT prompts are preferred, and at or above which gratitude prompts are
+	PromptLowMood  float64 = 2.5
+	PromptHighMood float64 = 4
+	// MindMuse score below which supportive CBT prompts are preferred
+	PromptLowScore float64 = 40
+)
+
+// PromptCategories lists the prompt library categories
+var PromptCategories = []string{PromptCategoryCBT, PromptCategoryGratitude, PromptCategoryReflection}
+
+// User roles
+const (
+	UserRoleAdmin string = "admin"
+)
+
+// Journal activity stats settings
+const (
+	JournalStatsTable     string = "mindmuse_journal_stats"
+	DynamoDbKeyStatKey    string = "StatKey"
+	JournalStatDayPrefix  string = "DAY#"  // DAY#YYYYMMDD: entries and words written that day
+	JournalStatHourPrefix string = "HOUR#" // HOUR#hh: entries written in that hour of the day
+	JournalStatTotalKey   string = "TOTAL" // Entries and words across all days
+	JournalStatClaimMins  int    = 5       // A rebuild claim older than this may be taken over
+)
+
+// Offline sync settings
+const (
+	MoodTable                string = "mindmuse_mood"
+	SyncBatchesTable         string = "mindmuse_sync_batches"
+	DynamoDbKeyBatchId       string = "BatchId"
+	DynamoDbKeyMoodUserId    string = "UserID"
+	DynamoDbKeyMoodTimestamp string = "Timestamp"
+	JournalUpdatedAtIndex    string = "UserId-updatedAt-index" // GSI: UserId + updatedAt
+	MoodUpdatedAtIndex       string = "UserID-updatedAt-index" // GSI: UserID + updatedAt
+
+	SyncOpCreate string = "create"
+	SyncOpUpdate string = "update"
+	SyncOpDelete string = "delete"
+
+	SyncStatusApplied  string = "applied"  // The change was written
+	SyncStatusConflict string = "conflict" // The server copy was kept, see the returned record
+	SyncStatusRejected string = "rejected" // The change was invalid or referred to a missing record
+
+	SyncResolutionClientWins string = "client_wins"
+	SyncResolutionServerWins string = "server_wins"
+
+	SyncBatchProcessing string = "processing"
+	SyncBatchDone       string = "done"
+
+	SyncPageSize           int   = 200 // Server changes returned per table per response
+	SyncMaxChanges         int   = 500 // Client changes accepted per batch
+	SyncClockSkewSeconds   int64 = 5   // Overlap between sync windows, covering writes committed late
+	SyncBatchRetentionDays int   = 7   // How long a batch result is kept for resends
+	SyncBatchStaleSeconds  int   = 900 // Lambda's longest timeout; a batch still processing after this was abandoned
+)
+
+// Sentiment analysis settings
+const (
+	QueryParamInterval string = "interval" // Trend interval, "day" or "week"
+
+	SentimentBackfillBatch    int = 500 // Texts analyzed per run of the analyze-sentiment job
+	SentimentTrendDefaultDays int = 30
+	SentimentTrendMaxDays     int = 366
+
+	// Texts waiting for analysis carry sentimentPending, which puts them in a sparse index
+	DynamoDbKeySentimentPending  string = "sentimentPending"
+	SentimentPendingValue        string = "pending"
+	JournalSentimentPendingIndex string = "sentimentPending-updatedAt-index"
+	ChatSentimentPendingIndex    string = "sentimentPending-timestamp-index"
+
+	// Per-day totals of the sentiment of each user's chat messages, read by the trends endpoint
+	ChatSentimentDaysTable string = "mindmuse_chat_sentiment_days" // Partition Key: UserId, Sort Key: Day
+	DynamoDbKeyDay         string = "Day"
+)
+
+// Chat streaming settings
+const (
+	// Set to "true" when the Lambda Function URL uses InvokeMode RESPONSE_STREAM
+	LambdaResponseStreamingEnv string = "LAMBDA_RESPONSE_STREAMING"
+
+	// Server-Sent Event names of POST /chat/stream
+	ChatEventToken string = "token" // A piece of the reply
+	ChatEventDone  string = "done"  // The reply is complete and stored
+	ChatEventError string = "error" // The reply failed; nothing more follows
+)
+
+// Chat context settings
+const (
+	ChatHistoryFetchLimit int32 = 50 // Most recent unsummarized messages read per chat request
+	ChatContextMaxTurns   int   = 40 // Most history messages sent; older ones are summarized
+)
+
+// Chat session settings
+const (
+	ChatSessionKeyPrefix   string = "#SESSION#"                  // Sort key prefix of session records in the chat table; '#' sorts before any message key
+	ChatSessionsIndex      string = "userId-lastMessageAt-index" // GSI: userId + lastMessageAt, only session records have lastMessageAt
+	QueryParamSessionId    string = "sessionId"
+	QueryParamArchived     string = "archived"
+	QueryParamLimit        string = "limit"
+	QueryParamCursor       string = "cursor"
+	ChatSessionPageSize    int32  = 20
+	ChatMessagePageSize    int32  = 50
+	ChatMaxPageSize        int32  = 100
+	ChatSessionTitleMaxLen int    = 80
+	ChatMessagePreviewLen  int    = 120
+	ChatSessionDeleteBatch int    = 25  // DynamoDB BatchWriteItem limit
+	ChatDraftHistoryLimit  int32  = 200 // Most recent messages a journal draft is written from
+)
+
+// Chat session IDs are chosen by the client. Message sort keys are "<sessionId>#<timestamp>",
+// so IDs cannot contain '#', or one session's key range would reach into another's.
+const (
+	ChatSessionIdMaxLen int = 100
+	// Running summaries stored before session records moved to the chat table; read only by the
+	// migrate-chat-sessions job. Partition Key: userId, Sort Key: sessionId
+	LegacyChatSessionsTable string = "mindmuse_chat_sessions"
+)
+
+// Chat persona settings
+const (
+	PersonasTable          string = "mindmuse_personas" // Partition Key: PersonaId, Sort Key: Version
+	DynamoDbKeyPersonaId   string = "PersonaId"
+	DynamoDbKeyVersion     string = "Version"
+	QueryParamPersonaId    string = "personaId"
+	DefaultPersonaId       string = "supportive-listener"
+	PersonaMaxPromptLength int    = 4000
+	PersonaMaxGuardrails   int    = 20
+	PersonaMaxRuleLength   int    = 500
+	PersonaMaxTokens       int    = 4096
+
+	// Reply lengths a persona can ask for
+	PersonaLengthBrief    string = "brief"
+	PersonaLengthBalanced string = "balanced"
+	PersonaLengthDetailed string = "detailed"
+)
+
+// PersonaLengths lists the reply lengths a persona can ask for
+var PersonaLengths = []string{PersonaLengthBrief, PersonaLengthBalanced, PersonaLengthDetailed}
+
+// Crisis detection settings
+const (
+	SOSTable                  string = "mindmuse_sos" // Partition Key: UserID, Sort Key: Timestamp
+	DynamoDbKeySOSUserId      string = "UserID"
+	SOSStatusOpen             string = "open" // Alerts raised before the lifecycle below; treated as raised
+	SOSSourceChat             string = "chat"
+	SOSSourceJournal          string = "journal"
+	SOSSourceManual           string = "manual"                    // Raised by the user with POST /sos
+	SOSCooldownHours          int    = 6                           // A new high-risk text within this window reuses the open SOS and notifies no one again
+	RiskLLMReviewEnv          string = "RISK_LLM_REVIEW"           // "true" asks the chat LLM for a second opinion on flagged texts
+	RiskNotifyWebhookURLEnv   string = "RISK_NOTIFY_WEBHOOK_URL"   // Where emergency contact alerts are posted
+	RiskNotifyWebhookTokenEnv string = "RISK_NOTIFY_WEBHOOK_TOKEN" // Bearer token for that webhook
+
+	// Server-Sent Event of POST /chat/stream sent before the reply when a message shows risk
+	ChatEventCrisis string = "crisis"
+)
+
+// Reply moderation settings
+const (
+	ModerationTable      string = "mindmuse_moderation" // Partition Key: Date (YYYYMMDD, UTC), Sort Key: Id
+	QueryParamDate       string = "date"
+	QueryParamAction     string = "action"
+	ModerationLogPageMax int32  = 500 // Most logs returned by one review request
+
+	// Server-Sent Event of POST /chat/stream sent before "done" when moderation changed the
+	// streamed reply; the client should show the event's aiResponse instead
+	ChatEventModerated string = "moderated"
+)
+
+// Chat rate limit and quota settings
+const (
+	RateLimitsTable    string = "mindmuse_rate_limits" // Partition Key: Key
+	UsageTable         string = "mindmuse_usage"       // Partition Key: userId, Sort Key: Date (YYYYMMDD, UTC)
+	DynamoDbKeyKey     string = "Key"
+	RateLimitStoreEnv  string = "RATE_LIMIT_STORE" // "dynamodb" or "memory"; unset uses memory locally and DynamoDB in Lambda
+	RateLimitStoreDB   string = "dynamodb"
+	RateLimitStoreMem  string = "memory"
+	RateLimitTTLHours  int    = 24 // Idle buckets are full again long before this, so DynamoDB may expire them
+	RateLimitConflicts int    = 5  // Attempts at a bucket update before giving up under contention
+
+	// Plans; users without one are on the free plan
+	PlanFree string = "free"
+	PlanPlus string = "plus"
+	PlanPro  string = "pro"
+
+	// Response headers describing the caller's limits
+	HeaderRateLimitLimit     string = "X-RateLimit-Limit"     // Requests per minute
+	HeaderRateLimitRemaining string = "X-RateLimit-Remaining" // Requests that may be made right now
+	HeaderRateLimitReset     string = "X-RateLimit-Reset"     // Seconds until the burst allowance is full again
+	HeaderRetryAfter         string = "Retry-After"
+	HeaderQuotaMessages      string = "X-Quota-Messages-Limit"
+	HeaderQuotaMessagesLeft  string = "X-Quota-Messages-Remaining"
+	HeaderQuotaTokens        string = "X-Quota-Tokens-Limit"
+	HeaderQuotaTokensLeft    string = "X-Quota-Tokens-Remaining"
+	HeaderQuotaReset         string = "X-Quota-Reset" // Unix time the daily quotas reset (midnight UTC)
+)
+
+// Plans lists the plans a user can be on
+var Plans = []string{PlanFree, PlanPlus, PlanPro}
+
+// Reply feedback settings
+const (
+	FeedbackTable         string = "mindmuse_feedback" // Partition Key: Date (YYYYMMDD, UTC, of the reply), Sort Key: Id
+	QueryParamMessageId   string = "messageId"
+	FeedbackCommentMaxLen int    = 1000
+	FeedbackExportMaxDays int    = 92 // Longest range one export covers
+	FeedbackExportDefault int    = 30 // Days exported when no range is given
+	FeedbackFormatJSON    string = "json"
+	FeedbackFormatCSV     string = "csv"
+)
+
+// Chat idempotency settings
+const (
+	IdempotencyTable          string = "mindmuse_idempotency" // Partition Key: Key; TTL attribute expiresAt
+	HeaderIdempotencyKey      string = "Idempotency-Key"
+	HeaderIdempotentReplayed  string = "Idempotent-Replayed" // Set to "true" on a response replayed for a retried request
+	IdempotencyKeyMaxLen      int    = 255
+	IdempotencyTTLHours       int    = 24  // How long a key is remembered
+	IdempotencyPendingSeconds int    = 300 // A claim older than this whose request never finished may be taken over
+	IdempotencyPending        string = "pending"
+	IdempotencyComplete       string = "complete"
+)
+
+// Chat retrieval settings
+const (
+	RetrievalTopK          int = 4    // Passages added to a chat prompt
+	RetrievalPassageWords  int = 80   // Journal entries are split into passages of about this many words
+	RetrievalMaxJournals   int = 1000 // Newest entries indexed per user
+	RetrievalSnippetLen    int = 200  // Characters of a passage returned with a citation
+	RetrievalCacheMinutes  int = 5    // How long a user's index is reused before it is rebuilt
+	RetrievalTimeoutMillis int = 1500 // Chat goes ahead without passages if retrieval takes longer
+)
+
+// Realtime chat settings
+const (
+	RealtimeConnectionsTable    string = "mindmuse_realtime_connections" // Partition Key: ConnectionId; TTL attribute expiresAt
+	RealtimeUserIndex           string = "userId-index"                  // GSI: userId
+	DynamoDbKeyConnectionId     string = "ConnectionId"
+	RealtimeCallbackURLEnv      string = "REALTIME_CALLBACK_URL" // API Gateway @connections endpoint; derived from the event when unset
+	RealtimeMessageMaxBytes     int    = 16 * 1024
+	RealtimeQueueSize           int    = 64  // Events waiting for a slow client before its connection is closed
+	RealtimeHeartbeatSeconds    int    = 25  // Ping interval; a connection silent for two intervals is closed
+	RealtimeWriteTimeoutSeconds int    = 10  // A single write taking longer closes the connection
+	RealtimeConnectionTTLHours  int    = 2   // API Gateway closes WebSocket connections after 2 hours
+	RealtimeFlushMillis         int    = 250 // API Gateway: tokens are batched and posted this often
+	RealtimeReminderMinutes     int    = 15  // Local server: how often connected users are checked for reminders
+	MoodReminderHour            int    = 18  // Users who have not logged their mood are reminded after this local hour
+)
+
+// SOS lifecycle settings
+const (
+	SOSStatusRaised         string = "raised"       // Contacts are alerted once the cancel window passes
+	SOSStatusNotified       string = "notified"     // Contacts were alerted
+	SOSStatusAcknowledged   string = "acknowledged" // A contact said they are on it
+	SOSStatusResolved       string = "resolved"
+	SOSStatusCancelled      string = "cancelled" // A false alarm stopped before contacts were alerted
+	SOSActorUser            string = "user"
+	SOSActorContact         string = "contact"
+	SOSActorSystem          string = "system"
+	SOSCancelWindowSeconds  int    = 30 // How long a user has to cancel an SOS they raised before contacts are alerted
+	SOSMessageMaxLen        int    = 500
+	SOSNoteMaxLen           int    = 500
+	SOSPageSize             int32  = 20
+	SOSAckTTLHours          int    = 72 // How long the acknowledge link sent to contacts works
+	SOSDueBatch             int    = 100
+	SOSDueIndex             string = "Status-notifyAt-index" // GSI: Status + notifyAt; sparse, only alerts waiting for their cancel window have notifyAt
+	SOSAcknowledgeURLEnv    string = "SOS_ACKNOWLEDGE_URL"   // Web page contacts open to acknowledge; it posts the token to /sos/acknowledge
+	QueryParamSOSTimestamp  string = "timestamp"
+	DynamoDbKeySOSTimestamp string = "Timestamp"
 )
@@ -0,0 +1,200 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"lambda-server/constants"
+	"lambda-server/models"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// CreateAttachment stores a new attachment record and links it to its journal entry in one transaction.
+// The journal entry must exist and must not be in trash.
+func CreateAttachment(ctx context.Context, journal *models.Journal, attachment models.Attachment) error {
+	item, err := attributevalue.MarshalMap(attachment)
+	if err != nil {
+		return fmt.Errorf("failed to marshal attachment: %w", err)
+	}
+
+	_, err = GetInitializedClient().TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Put: &types.Put{
+					TableName: aws.String(constants.AttachmentsTable),
+					Item:      item,
+				},
+			},
+			{
+				Update: &types.Update{
+					TableName:           aws.String(constants.JournalsTable),
+					Key:                 journalKey(journal.UserId, journal.CreatedAt),
+					UpdateExpression:    aws.String("SET #ids = list_append(if_not_exists(#ids, :empty), :id) ADD #version :one"),
+					ConditionExpression: aws.String("attribute_exists(#uid) AND attribute_not_exists(#deletedAt)"),
+					ExpressionAttributeNames: map[string]string{
+						"#ids":       "attachmentIds",
+						"#uid":       constants.DynamoDbKeyUserId,
+						"#deletedAt": constants.DynamoDbKeyDeletedAt,
+						"#version":   constants.DynamoDbKeyJournalVersion,
+					},
+					ExpressionAttributeValues: map[string]types.AttributeValue{
+						":one":   &types.AttributeValueMemberN{Value: "1"},
+						":empty": &types.AttributeValueMemberL{Value: []types.AttributeValue{}},
+						":id": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+							&types.AttributeValueMemberS{Value: attachment.AttachmentId},
+						}},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create attachment: %w", err)
+	}
+	return nil
+}
+
+// GetAttachment retrieves an attachment record by userId and attachmentId
+func GetAttachment(ctx context.Context, userId string, attachmentId string) (*models.Attachment, error) {
+	result, err := GetInitializedClient().GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(constants.AttachmentsTable),
+		Key:       attachmentKey(userId, attachmentId),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachment: %w", err)
+	}
+	if result.Item == nil {
+		return nil, fmt.Errorf("attachment not found")
+	}
+	var attachment models.Attachment
+	if err := attributevalue.UnmarshalMap(result.Item, &attachment); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal attachment: %w", err)
+	}
+	return &attachment, nil
+}
+
+// MarkAttachmentReady records the detected content type and size of an uploaded attachment
+func MarkAttachmentReady(ctx context.Context, userId string, attachmentId string, contentType string, size int64) error {
+	_, err := GetInitializedClient().UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(constants.AttachmentsTable),
+		Key:                 attachmentKey(userId, attachmentId),
+		UpdateExpression:    aws.String("SET #status = :ready, #contentType = :contentType, #size = :size, #updatedAt = :now"),
+		ConditionExpression: aws.String("attribute_exists(#uid)"),
+		ExpressionAttributeNames: map[string]string{
+			"#uid":         constants.DynamoDbKeyUserId,
+			"#status":      "status",
+			"#contentType": "contentType",
+			"#size":        "size",
+			"#updatedAt":   "updatedAt",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":ready":       &types.AttributeValueMemberS{Value: constants.AttachmentStatusReady},
+			":contentType": &types.AttributeValueMemberS{Value: contentType},
+			":size":        &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", size)},
+			":now":         &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", time.Now().Unix())},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update attachment: %w", err)
+	}
+	return nil
+}
+
+// GetJournalAttachments retrieves every attachment record of a journal entry
+func GetJournalAttachments(ctx context.Context, userId string, journalId string) ([]models.Attachment, error) {
+	attachments := []models.Attachment{}
+	paginator := dynamodb.NewQueryPaginator(GetInitializedClient(), &dynamodb.QueryInput{
+		TableName:              aws.String(constants.AttachmentsTable),
+		KeyConditionExpression: aws.String("#uid = :uid"),
+		FilterExpression:       aws.String("#jid = :jid"),
+		ExpressionAttributeNames: map[string]string{
+			"#uid": constants.DynamoDbKeyUserId,
+			"#jid": constants.DynamoDbKeyJournalId,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid": &types.AttributeValueMemberS{Value: userId},
+			":jid": &types.AttributeValueMemberS{Value: journalId},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query attachments: %w", err)
+		}
+		var items []models.Attachment
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal attachments: %w", err)
+		}
+		attachments = append(attachments, items...)
+	}
+	return attachments, nil
+}
+
+// DetachAttachment deletes an attachment record and removes it from its journal entry in one transaction
+func DetachAttachment(ctx context.Context, journal *models.Journal, attachmentId string) error {
+	remaining := []string{}
+	for _, id := range journal.AttachmentIds {
+		if id != attachmentId {
+			remaining = append(remaining, id)
+		}
+	}
+	ids, err := attributevalue.Marshal(remaining)
+	if err != nil {
+		return fmt.Errorf("failed to marshal attachment ids: %w", err)
+	}
+
+	_, err = GetInitializedClient().TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Delete: &types.Delete{
+					TableName: aws.String(constants.AttachmentsTable),
+					Key:       attachmentKey(journal.UserId, attachmentId),
+				},
+			},
+			{
+				Update: &types.Update{
+					TableName:        aws.String(constants.JournalsTable),
+					Key:              journalKey(journal.UserId, journal.CreatedAt),
+					UpdateExpression: aws.String("SET #ids = :ids ADD #version :one"),
+					ExpressionAttributeNames: map[string]string{
+						"#ids":     "attachmentIds",
+						"#version": constants.DynamoDbKeyJournalVersion,
+					},
+					ExpressionAttributeValues: map[string]types.AttributeValue{
+						":ids": ids,
+						":one": &types.AttributeValueMemberN{Value: "1"},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+	return nil
+}
+
+// DeleteAttachmentRecord deletes an attachment record without touching its journal entry.
+// Used when the journal entry itself is being removed.
+func DeleteAttachmentRecord(ctx context.Context, userId string, attachmentId string) error {
+	_, err := GetInitializedClient().DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(constants.AttachmentsTable),
+		Key:       attachmentKey(userId, attachmentId),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+	return nil
+}
+
+// attachmentKey builds the primary key of an attachment item
+func attachmentKey(userId string, attachmentId string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		constants.DynamoDbKeyUserId:       &types.AttributeValueMemberS{Value: userId},
+		constants.DynamoDbKeyAttachmentId: &types.AttributeValueMemberS{Value: attachmentId},
+	}
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lambda-server/constants"
+	"lambda-server/models"
+	"lambda-server/utils"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrChatMessageExists is returned when a message ID is already taken in its session
+var ErrChatMessageExists = errors.New("chat message already exists")
+
+// StoreChatMessages stores the messages of an exchange in one transaction, so either all of
+// them are stored or none is. Stored messages are never overwritten.
+func StoreChatMessages(ctx context.Context, messages ...*models.ChatMessage) error {
+	items := make([]types.TransactWriteItem, 0, len(messages))
+	for _, msg := range messages {
+		key, err := utils.ChatMessageSortKey(msg.SessionId, msg.ID())
+		if err != nil {
+			return err
+		}
+		msg.SessionIdTimestamp = key
+		item, err := attributevalue.MarshalMap(msg)
+		if err != nil {
+			return fmt.Errorf("failed to marshal chat message: %w", err)
+		}
+		items = append(items, types.TransactWriteItem{Put: &types.Put{
+			TableName:           aws.String(constants.ChatTable),
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(sessionId_timestamp)"),
+		}})
+	}
+	_, err := GetInitializedClient().TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+	if err != nil {
+		if isConditionFailure(err) {
+			return ErrChatMessageExists
+		}
+		return fmt.Errorf("failed to store chat messages: %w", err)
+	}
+	return nil
+}
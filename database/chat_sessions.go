@@ -0,0 +1,460 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lambda-server/constants"
+	"lambda-server/models"
+	"lambda-server/sentiment"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Chat session errors
+var (
+	ErrChatSessionNotFound = errors.New("chat session not found")
+	ErrChatSessionExists   = errors.New("chat session already exists")
+)
+
+// GetChatSession retrieves a chat session, or nil when none has been stored yet
+func GetChatSession(ctx context.Context, userId, sessionId string) (*models.ChatSession, error) {
+	result, err := GetInitializedClient().GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(constants.ChatTable),
+		Key:       chatSessionKey(userId, sessionId),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat session: %w", err)
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+	var session models.ChatSession
+	if err := attributevalue.UnmarshalMap(result.Item, &session); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal chat session: %w", err)
+	}
+	return &session, nil
+}
+
+// CreateChatSession stores a new chat session, returning ErrChatSessionExists if the ID is taken
+func CreateChatSession(ctx context.Context, session models.ChatSession) error {
+	session.SortKey = constants.ChatSessionKeyPrefix + session.SessionId
+	item, err := attributevalue.MarshalMap(session)
+	if err != nil {
+		return fmt.Errorf("failed to marshal chat session: %w", err)
+	}
+	_, err = GetInitializedClient().PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(constants.ChatTable),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(userId)"),
+	})
+	if err != nil {
+		if isConditionFailure(err) {
+			return ErrChatSessionExists
+		}
+		return fmt.Errorf("failed to put chat session: %w", err)
+	}
+	return nil
+}
+
+// ListChatSessions retrieves a page of a user's chat sessions, most recently active first.
+// Archived sessions are listed only when archived is true, and then only they are.
+func ListChatSessions(ctx context.Context, userId string, archived bool, limit int32, cursor string) ([]models.ChatSession, string, error) {
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	filter := "attribute_not_exists(#archived) OR #archived = :false"
+	if archived {
+		filter = "#archived = :true"
+	}
+	values := map[string]types.AttributeValue{
+		":uid": &types.AttributeValueMemberS{Value: userId},
+	}
+	if archived {
+		values[":true"] = &types.AttributeValueMemberBOOL{Value: true}
+	} else {
+		values[":false"] = &types.AttributeValueMemberBOOL{Value: false}
+	}
+	result, err := GetInitializedClient().Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(constants.ChatTable),
+		IndexName:                 aws.String(constants.ChatSessionsIndex),
+		KeyConditionExpression:    aws.String("userId = :uid"),
+		FilterExpression:          aws.String(filter),
+		ExpressionAttributeNames:  map[string]string{"#archived": "archived"},
+		ExpressionAttributeValues: values,
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int32(limit),
+		ExclusiveStartKey:         startKey,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query chat sessions: %w", err)
+	}
+	sessions := []models.ChatSession{}
+	if err := attributevalue.UnmarshalListOfMaps(result.Items, &sessions); err != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal chat sessions: %w", err)
+	}
+	next, err := encodeCursor(result.LastEvaluatedKey)
+	return sessions, next, err
+}
+
+// GetChatSessionSummaries retrieves every chat session of a user that has a running summary
+func GetChatSessionSummaries(ctx context.Context, userId string) ([]models.ChatSession, error) {
+	sessions := []models.ChatSession{}
+	paginator := dynamodb.NewQueryPaginator(GetInitializedClient(), &dynamodb.QueryInput{
+		TableName:              aws.String(constants.ChatTable),
+		KeyConditionExpression: aws.String("userId = :uid AND begins_with(sessionId_timestamp, :prefix)"),
+		FilterExpression:       aws.String("attribute_exists(summary)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid":    &types.AttributeValueMemberS{Value: userId},
+			":prefix": &types.AttributeValueMemberS{Value: constants.ChatSessionKeyPrefix},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query chat sessions: %w", err)
+		}
+		var items []models.ChatSession
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal chat sessions: %w", err)
+		}
+		sessions = append(sessions, items...)
+	}
+	return sessions, nil
+}
+
+// UpdateChatSession renames and/or archives a chat session and returns the updated session
+func UpdateChatSession(ctx context.Context, userId, sessionId string, title *string, archived *bool) (*models.ChatSession, error) {
+	update := "SET updatedAt = :now"
+	names := map[string]string{}
+	values := map[string]types.AttributeValue{
+		":now": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", time.Now().Unix())},
+	}
+	if title != nil {
+		update += ", #title = :title"
+		names["#title"] = "title"
+		values[":title"] = &types.AttributeValueMemberS{Value: *title}
+	}
+	if archived != nil {
+		update += ", #archived = :archived"
+		names["#archived"] = "archived"
+		values[":archived"] = &types.AttributeValueMemberBOOL{Value: *archived}
+	}
+	if len(names) == 0 {
+		names = nil
+	}
+	result, err := GetInitializedClient().UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(constants.ChatTable),
+		Key:                       chatSessionKey(userId, sessionId),
+		UpdateExpression:          aws.String(update),
+		ConditionExpression:       aws.String("attribute_exists(userId)"),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ReturnValues:              types.ReturnValueAllNew,
+	})
+	if err != nil {
+		if isConditionFailure(err) {
+			return nil, ErrChatSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to update chat session: %w", err)
+	}
+	var session models.ChatSession
+	if err := attributevalue.UnmarshalMap(result.Attributes, &session); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal chat session: %w", err)
+	}
+	return &session, nil
+}
+
+// SetGeneratedChatSessionTitle stores a generated title unless the session already has one,
+// so it never replaces a title the user chose. It returns false when nothing was written.
+func SetGeneratedChatSessionTitle(ctx context.Context, userId, sessionId, title string) (bool, error) {
+	_, err := GetInitializedClient().UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(constants.ChatTable),
+		Key:                 chatSessionKey(userId, sessionId),
+		UpdateExpression:    aws.String("SET #title = :title"),
+		ConditionExpression: aws.String("attribute_exists(userId) AND (attribute_not_exists(#title) OR #title = :empty)"),
+		ExpressionAttributeNames: map[string]string{
+			"#title": "title",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":title": &types.AttributeValueMemberS{Value: title},
+			":empty": &types.AttributeValueMemberS{Value: ""},
+		},
+	})
+	if err != nil {
+		if isConditionFailure(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to set chat session title: %w", err)
+	}
+	return true, nil
+}
+
+// RecordChatActivity updates a session after messages are stored: the last message time and
+// preview, and the message count. Sessions the client made up without creating them are
+// created here.
+func RecordChatActivity(ctx context.Context, userId, sessionId, preview string, at int64, messages int) error {
+	_, err := GetInitializedClient().UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(constants.ChatTable),
+		Key:       chatSessionKey(userId, sessionId),
+		UpdateExpression: aws.String("SET sessionId = :sid, lastMessageAt = :at, lastMessagePreview = :preview, updatedAt = :at, " +
+			"createdAt = if_not_exists(createdAt, :at) ADD messageCount :count"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":sid":     &types.AttributeValueMemberS{Value: sessionId},
+			":at":      &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", at)},
+			":preview": &types.AttributeValueMemberS{Value: preview},
+			":count":   &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", messages)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record chat activity: %w", err)
+	}
+	return nil
+}
+
+// SaveChatSummary stores the running summary of a session, creating the session if needed.
+// cursor is the sort key of the newest message folded into the summary. It returns false without
+// writing when a summary covering later messages is already stored, so concurrent
+// summarizations cannot move the summary backwards.
+func SaveChatSummary(ctx context.Context, userId, sessionId, summary, cursor string) (bool, error) {
+	now := time.Now().Unix()
+	_, err := GetInitializedClient().UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(constants.ChatTable),
+		Key:                 chatSessionKey(userId, sessionId),
+		UpdateExpression:    aws.String("SET sessionId = :sid, summary = :summary, summaryCursor = :cursor, updatedAt = :now, createdAt = if_not_exists(createdAt, :now) REMOVE summarizedThrough"),
+		ConditionExpression: aws.String("attribute_not_exists(summaryCursor) OR summaryCursor < :cursor"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":sid":     &types.AttributeValueMemberS{Value: sessionId},
+			":summary": &types.AttributeValueMemberS{Value: summary},
+			":cursor":  &types.AttributeValueMemberS{Value: cursor},
+			":now":     &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", now)},
+		},
+	})
+	if err != nil {
+		if isConditionFailure(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to save chat summary: %w", err)
+	}
+	return true, nil
+}
+
+// MigrateLegacyChatSummaries copies the running summaries stored in the old chat sessions table
+// onto the session records in the chat table and returns how many it copied. A summary is
+// skipped when the record already holds one covering later messages. A missing old table has
+// nothing to migrate.
+func MigrateLegacyChatSummaries(ctx context.Context) (int, error) {
+	paginator := dynamodb.NewScanPaginator(GetInitializedClient(), &dynamodb.ScanInput{
+		TableName:            aws.String(constants.LegacyChatSessionsTable),
+		ProjectionExpression: aws.String("userId, sessionId, summary, summarizedThrough"),
+	})
+	migrated := 0
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			var missing *types.ResourceNotFoundException
+			if errors.As(err, &missing) {
+				return migrated, nil
+			}
+			return migrated, fmt.Errorf("failed to scan legacy chat sessions: %w", err)
+		}
+		var sessions []models.ChatSession
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &sessions); err != nil {
+			return migrated, fmt.Errorf("failed to unmarshal legacy chat sessions: %w", err)
+		}
+		for _, session := range sessions {
+			if session.Summary == "" || session.SummarizedThroughKey() == "" {
+				continue
+			}
+			saved, err := SaveChatSummary(ctx, session.UserId, session.SessionId, session.Summary, session.SummarizedThroughKey())
+			if err != nil {
+				return migrated, err
+			}
+			if saved {
+				migrated++
+			}
+		}
+	}
+	return migrated, nil
+}
+
+// SetChatSessionPersona records the persona picked for a chat session, creating the session if needed
+func SetChatSessionPersona(ctx context.Context, userId, sessionId, personaId string) error {
+	now := time.Now().Unix()
+	_, err := GetInitializedClient().UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(constants.ChatTable),
+		Key:              chatSessionKey(userId, sessionId),
+		UpdateExpression: aws.String("SET sessionId = :sid, personaId = :persona, updatedAt = :now, createdAt = if_not_exists(createdAt, :now)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":sid":     &types.AttributeValueMemberS{Value: sessionId},
+			":persona": &types.AttributeValueMemberS{Value: personaId},
+			":now":     &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", now)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set chat session persona: %w", err)
+	}
+	return nil
+}
+
+// GetChatMessagesPage retrieves a page of a session's messages. Pages run from the newest
+// messages back; the messages within a page are oldest first.
+func GetChatMessagesPage(ctx context.Context, userId, sessionId string, limit int32, cursor string) ([]models.ChatMessage, string, error) {
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	result, err := GetInitializedClient().Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(constants.ChatTable),
+		KeyConditionExpression:    aws.String("userId = :uid AND sessionId_timestamp BETWEEN :from AND :to"),
+		ExpressionAttributeValues: chatMessageRange(userId, sessionId),
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int32(limit),
+		ExclusiveStartKey:         startKey,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query chat messages: %w", err)
+	}
+	messages := []models.ChatMessage{}
+	if err := attributevalue.UnmarshalListOfMaps(result.Items, &messages); err != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal chat messages: %w", err)
+	}
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	next, err := encodeCursor(result.LastEvaluatedKey)
+	return messages, next, err
+}
+
+// DeleteChatSession deletes every message of a session and then the session itself, so an
+// interrupted delete can be retried. Messages counted in the chat sentiment totals are deleted
+// together with taking them out of their day.
+func DeleteChatSession(ctx context.Context, userId, sessionId string) error {
+	client := GetInitializedClient()
+	paginator := dynamodb.NewQueryPaginator(client, &dynamodb.QueryInput{
+		TableName:                 aws.String(constants.ChatTable),
+		KeyConditionExpression:    aws.String("userId = :uid AND sessionId_timestamp BETWEEN :from AND :to"),
+		ExpressionAttributeValues: chatMessageRange(userId, sessionId),
+		ProjectionExpression:      aws.String("userId, sessionId_timestamp, sentiment, sentimentDay"),
+	})
+	keys := []map[string]types.AttributeValue{}
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to query chat messages: %w", err)
+		}
+		var messages []models.ChatMessage
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &messages); err != nil {
+			return fmt.Errorf("failed to unmarshal chat messages: %w", err)
+		}
+		for i, msg := range messages {
+			key := map[string]types.AttributeValue{
+				"userId":              page.Items[i]["userId"],
+				"sessionId_timestamp": page.Items[i]["sessionId_timestamp"],
+			}
+			if msg.SentimentDay == "" || msg.Sentiment == nil {
+				keys = append(keys, key)
+				continue
+			}
+			if err := deleteCountedChatMessage(ctx, key, msg); err != nil {
+				return err
+			}
+		}
+	}
+
+	for start := 0; start < len(keys); start += constants.ChatSessionDeleteBatch {
+		requests := []types.WriteRequest{}
+		for _, key := range keys[start:min(start+constants.ChatSessionDeleteBatch, len(keys))] {
+			requests = append(requests, types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: key}})
+		}
+		// Retry throttled deletes a few times with a short backoff
+		for attempt := 0; len(requests) > 0; attempt++ {
+			if attempt == 5 {
+				return fmt.Errorf("failed to delete chat messages: %d deletes left unprocessed", len(requests))
+			}
+			if attempt > 0 {
+				time.Sleep(time.Duration(attempt*100) * time.Millisecond)
+			}
+			result, err := client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+				RequestItems: map[string][]types.WriteRequest{constants.ChatTable: requests},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to delete chat messages: %w", err)
+			}
+			requests = result.UnprocessedItems[constants.ChatTable]
+		}
+	}
+
+	_, err := client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(constants.ChatTable),
+		Key:       chatSessionKey(userId, sessionId),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete chat session: %w", err)
+	}
+	return nil
+}
+
+// deleteCountedChatMessage deletes a message whose sentiment is counted in a day's totals and
+// takes it out of them in the same transaction
+func deleteCountedChatMessage(ctx context.Context, key map[string]types.AttributeValue, msg models.ChatMessage) error {
+	delta := models.SentimentDay{UserId: msg.UserId, Day: msg.SentimentDay}
+	sentiment.AddToDay(&delta, *msg.Sentiment, -1)
+	_, err := GetInitializedClient().TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Delete: &types.Delete{
+					TableName:           aws.String(constants.ChatTable),
+					Key:                 key,
+					ConditionExpression: aws.String("#day = :day AND #sentiment.#analyzedAt = :analyzedAt"),
+					ExpressionAttributeNames: map[string]string{
+						"#day":        "sentimentDay",
+						"#sentiment":  "sentiment",
+						"#analyzedAt": "analyzedAt",
+					},
+					ExpressionAttributeValues: map[string]types.AttributeValue{
+						":day":        &types.AttributeValueMemberS{Value: msg.SentimentDay},
+						":analyzedAt": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", msg.Sentiment.AnalyzedAt)},
+					},
+				},
+			},
+			sentimentDayWrite(delta),
+		},
+	})
+	if err != nil {
+		if isConditionFailure(err) {
+			// Analyzed again since it was read; the retried delete sees the new analysis
+			return fmt.Errorf("chat message %s changed while it was being deleted", msg.SessionIdTimestamp)
+		}
+		return fmt.Errorf("failed to delete chat message: %w", err)
+	}
+	return nil
+}
+
+// chatMessageRange holds the key condition values matching every message of a session
+func chatMessageRange(userId, sessionId string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		":uid":  &types.AttributeValueMemberS{Value: userId},
+		":from": &types.AttributeValueMemberS{Value: sessionId + "#"},
+		":to":   &types.AttributeValueMemberS{Value: sessionId + "#~"}, // '~' sorts after every digit
+	}
+}
+
+func chatSessionKey(userId, sessionId string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"userId":              &types.AttributeValueMemberS{Value: userId},
+		"sessionId_timestamp": &types.AttributeValueMemberS{Value: constants.ChatSessionKeyPrefix + sessionId},
+	}
+}
+
+func chatMessageKey(userId, sortKey string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"userId":              &types.AttributeValueMemberS{Value: userId},
+		"sessionId_timestamp": &types.AttributeValueMemberS{Value: sortKey},
+	}
+}
@@ -0,0 +1,137 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lambda-server/constants"
+	"lambda-server/models"
+	"lambda-server/utils"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrAIMessageNotFound is returned when feedback is given on a message that is not a stored AI reply
+var ErrAIMessageNotFound = errors.New("AI message not found")
+
+// GetAIChatMessage retrieves an AI reply of a session by its message ID
+func GetAIChatMessage(ctx context.Context, userId, sessionId, messageId string) (*models.ChatMessage, error) {
+	sortKey, err := utils.ChatMessageSortKey(sessionId, messageId)
+	if err != nil {
+		return nil, ErrAIMessageNotFound
+	}
+	result, err := GetInitializedClient().GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(constants.ChatTable),
+		Key:       chatMessageKey(userId, sortKey),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat message: %w", err)
+	}
+	if result.Item == nil {
+		return nil, ErrAIMessageNotFound
+	}
+	var message models.ChatMessage
+	if err := attributevalue.UnmarshalMap(result.Item, &message); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal chat message: %w", err)
+	}
+	if message.Sender != constants.ChatSenderAI {
+		return nil, ErrAIMessageNotFound
+	}
+	return &message, nil
+}
+
+// SetChatMessageFeedback stores the user's rating of an AI reply and its evaluation copy in one
+// transaction, replacing earlier ones, and returns the updated message
+func SetChatMessageFeedback(ctx context.Context, message models.ChatMessage, feedback models.MessageFeedback, record models.FeedbackRecord) (*models.ChatMessage, error) {
+	value, err := attributevalue.Marshal(feedback)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal feedback: %w", err)
+	}
+	item, err := attributevalue.MarshalMap(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal feedback record: %w", err)
+	}
+	err = writeFeedbackTransaction(ctx, message, "SET feedback = :feedback", map[string]types.AttributeValue{":feedback": value},
+		types.TransactWriteItem{Put: &types.Put{
+			TableName: aws.String(constants.FeedbackTable),
+			Item:      item,
+		}})
+	if err != nil {
+		return nil, err
+	}
+	message.Feedback = &feedback
+	return &message, nil
+}
+
+// DeleteChatMessageFeedback removes the rating of an AI reply and its evaluation copy, kept
+// under date and id, in one transaction and returns the updated message
+func DeleteChatMessageFeedback(ctx context.Context, message models.ChatMessage, date, id string) (*models.ChatMessage, error) {
+	err := writeFeedbackTransaction(ctx, message, "REMOVE feedback", map[string]types.AttributeValue{},
+		types.TransactWriteItem{Delete: &types.Delete{
+			TableName: aws.String(constants.FeedbackTable),
+			Key: map[string]types.AttributeValue{
+				constants.DynamoDbKeyDate: &types.AttributeValueMemberS{Value: date},
+				"Id":                      &types.AttributeValueMemberS{Value: id},
+			},
+		}})
+	if err != nil {
+		return nil, err
+	}
+	message.Feedback = nil
+	return &message, nil
+}
+
+// writeFeedbackTransaction applies update to an AI reply together with the write of its
+// feedback record, failing with ErrAIMessageNotFound when the reply is gone
+func writeFeedbackTransaction(ctx context.Context, message models.ChatMessage, update string, values map[string]types.AttributeValue, record types.TransactWriteItem) error {
+	values[":ai"] = &types.AttributeValueMemberS{Value: constants.ChatSenderAI}
+	_, err := GetInitializedClient().TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Update: &types.Update{
+				TableName:                 aws.String(constants.ChatTable),
+				Key:                       chatMessageKey(message.UserId, message.SessionIdTimestamp),
+				UpdateExpression:          aws.String(update),
+				ConditionExpression:       aws.String("attribute_exists(userId) AND sender = :ai"),
+				ExpressionAttributeValues: values,
+			}},
+			record,
+		},
+	})
+	if err != nil {
+		if isConditionFailure(err) {
+			return ErrAIMessageNotFound
+		}
+		return fmt.Errorf("failed to update feedback: %w", err)
+	}
+	return nil
+}
+
+// GetFeedbackRecords retrieves every feedback record of a day (YYYYMMDD, UTC)
+func GetFeedbackRecords(ctx context.Context, date string) ([]models.FeedbackRecord, error) {
+	records := []models.FeedbackRecord{}
+	paginator := dynamodb.NewQueryPaginator(GetInitializedClient(), &dynamodb.QueryInput{
+		TableName:              aws.String(constants.FeedbackTable),
+		KeyConditionExpression: aws.String("#date = :date"),
+		ExpressionAttributeNames: map[string]string{
+			"#date": constants.DynamoDbKeyDate,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":date": &types.AttributeValueMemberS{Value: date},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query feedback records: %w", err)
+		}
+		var items []models.FeedbackRecord
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal feedback records: %w", err)
+		}
+		records = append(records, items...)
+	}
+	return records, nil
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"lambda-server/constants"
+	"lambda-server/models"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ClaimIdempotencyKey claims a key for a request. It returns nil when the claim succeeded, and
+// otherwise the record of the request already holding the key. A pending claim older than
+// IdempotencyPendingSeconds is taken over, since its request died without finishing.
+func ClaimIdempotencyKey(ctx context.Context, key, requestHash string, now time.Time) (*models.IdempotencyRecord, error) {
+	client := GetInitializedClient()
+	item, err := attributevalue.MarshalMap(models.IdempotencyRecord{
+		Key:         key,
+		RequestHash: requestHash,
+		Status:      constants.IdempotencyPending,
+		CreatedAt:   now.Unix(),
+		ExpiresAt:   now.Add(time.Duration(constants.IdempotencyTTLHours) * time.Hour).Unix(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal idempotency record: %w", err)
+	}
+	stale := now.Add(-time.Duration(constants.IdempotencyPendingSeconds) * time.Second).Unix()
+	for attempt := 0; attempt < 2; attempt++ {
+		_, err = client.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName:           aws.String(constants.IdempotencyTable),
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(#key) OR (#status = :pending AND createdAt < :stale)"),
+			ExpressionAttributeNames: map[string]string{
+				"#key":    constants.DynamoDbKeyKey,
+				"#status": "status",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pending": &types.AttributeValueMemberS{Value: constants.IdempotencyPending},
+				":stale":   &types.AttributeValueMemberN{Value: strconv.FormatInt(stale, 10)},
+			},
+		})
+		if err == nil {
+			return nil, nil
+		}
+		if !isConditionFailure(err) {
+			return nil, fmt.Errorf("failed to claim idempotency key: %w", err)
+		}
+		result, err := client.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName:      aws.String(constants.IdempotencyTable),
+			Key:            idempotencyKey(key),
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get idempotency record: %w", err)
+		}
+		if result.Item == nil {
+			continue // Released between the two calls
+		}
+		var record models.IdempotencyRecord
+		if err := attributevalue.UnmarshalMap(result.Item, &record); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal idempotency record: %w", err)
+		}
+		return &record, nil
+	}
+	return nil, fmt.Errorf("idempotency key %s is under contention", key)
+}
+
+// CompleteIdempotencyKey stores the response of the request holding a key
+func CompleteIdempotencyKey(ctx context.Context, key string, response []byte) error {
+	_, err := GetInitializedClient().UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(constants.IdempotencyTable),
+		Key:              idempotencyKey(key),
+		UpdateExpression: aws.String("SET #status = :complete, #response = :response"),
+		ExpressionAttributeNames: map[string]string{
+			"#status":   "status",
+			"#response": "response",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":complete": &types.AttributeValueMemberS{Value: constants.IdempotencyComplete},
+			":response": &types.AttributeValueMemberS{Value: string(response)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey frees a key whose request did not finish, so a retry runs it again.
+// Completed keys are kept.
+func ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	_, err := GetInitializedClient().DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:                aws.String(constants.IdempotencyTable),
+		Key:                      idempotencyKey(key),
+		ConditionExpression:      aws.String("#status = :pending"),
+		ExpressionAttributeNames: map[string]string{"#status": "status"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pending": &types.AttributeValueMemberS{Value: constants.IdempotencyPending},
+		},
+	})
+	if err != nil && !isConditionFailure(err) {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+func idempotencyKey(key string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		constants.DynamoDbKeyKey: &types.AttributeValueMemberS{Value: key},
+	}
+}
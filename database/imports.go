@@ -0,0 +1,250 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lambda-server/constants"
+	"lambda-server/models"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// batchWriteMaxAttempts bounds the retries of unprocessed items in batchWrite
+const batchWriteMaxAttempts = 6
+
+// SaveImportJob creates or overwrites an import job record
+func SaveImportJob(ctx context.Context, job models.ImportJob) error {
+	item, err := attributevalue.MarshalMap(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal import job: %w", err)
+	}
+	_, err = GetInitializedClient().PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(constants.ImportJobsTable),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put import job: %w", err)
+	}
+	return nil
+}
+
+// GetImportJob retrieves an import job by userId and jobId
+func GetImportJob(ctx context.Context, userId string, jobId string) (*models.ImportJob, error) {
+	result, err := GetInitializedClient().GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(constants.ImportJobsTable),
+		Key:       importJobKey(userId, jobId),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get import job: %w", err)
+	}
+	if result.Item == nil {
+		return nil, fmt.Errorf("import job not found")
+	}
+	var job models.ImportJob
+	if err := attributevalue.UnmarshalMap(result.Item, &job); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal import job: %w", err)
+	}
+	return &job, nil
+}
+
+// ClaimImportJob moves a queued import job to running. A running job whose worker stopped
+// updating it before staleBefore (e.g. a frozen Lambda) can be claimed again.
+// It returns false if another worker holds the job, so each job is processed by one worker at a time.
+func ClaimImportJob(ctx context.Context, userId string, jobId string, staleBefore time.Time) (bool, error) {
+	_, err := GetInitializedClient().UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(constants.ImportJobsTable),
+		Key:                 importJobKey(userId, jobId),
+		UpdateExpression:    aws.String("SET #status = :running, #updatedAt = :now"),
+		ConditionExpression: aws.String("#status = :queued OR (#status = :running AND #updatedAt < :stale)"),
+		ExpressionAttributeNames: map[string]string{
+			"#status":    "status",
+			"#updatedAt": "updatedAt",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":running": &types.AttributeValueMemberS{Value: constants.ImportStatusRunning},
+			":queued":  &types.AttributeValueMemberS{Value: constants.ImportStatusQueued},
+			":now":     &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", time.Now().Unix())},
+			":stale":   &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", staleBefore.Unix())},
+		},
+	})
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to claim import job: %w", err)
+	}
+	return true, nil
+}
+
+// QueueImportJob moves an import job whose archive has been uploaded to queued, putting it in
+// the pending index and clearing its expiry. It returns false if the job is not waiting for its
+// archive, so a job is queued only once.
+func QueueImportJob(ctx context.Context, userId string, jobId string) (bool, error) {
+	_, err := GetInitializedClient().UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(constants.ImportJobsTable),
+		Key:                 importJobKey(userId, jobId),
+		UpdateExpression:    aws.String("SET #status = :queued, #pending = :pending, #updatedAt = :now REMOVE #expiresAt"),
+		ConditionExpression: aws.String("#status = :uploading"),
+		ExpressionAttributeNames: map[string]string{
+			"#status":    "status",
+			"#pending":   constants.DynamoDbKeyPending,
+			"#updatedAt": "updatedAt",
+			"#expiresAt": "expiresAt",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":queued":    &types.AttributeValueMemberS{Value: constants.ImportStatusQueued},
+			":uploading": &types.AttributeValueMemberS{Value: constants.ImportStatusUploading},
+			":pending":   &types.AttributeValueMemberS{Value: constants.ImportPendingValue},
+			":now":       &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", time.Now().Unix())},
+		},
+	})
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to queue import job: %w", err)
+	}
+	return true, nil
+}
+
+// GetPendingImportJobs retrieves the import jobs of all users that are queued, or running
+// without progress since staleBefore. It reads the sparse pending index, which only holds
+// queued and running jobs, so finished jobs are never read.
+func GetPendingImportJobs(ctx context.Context, staleBefore time.Time) ([]models.ImportJob, error) {
+	jobs := []models.ImportJob{}
+	paginator := dynamodb.NewQueryPaginator(GetInitializedClient(), &dynamodb.QueryInput{
+		TableName:              aws.String(constants.ImportJobsTable),
+		IndexName:              aws.String(constants.ImportPendingIndex),
+		KeyConditionExpression: aws.String("#pending = :pending"),
+		FilterExpression:       aws.String("#status = :queued OR (#status = :running AND #updatedAt < :stale)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pending":   constants.DynamoDbKeyPending,
+			"#status":    "status",
+			"#updatedAt": "updatedAt",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pending": &types.AttributeValueMemberS{Value: constants.ImportPendingValue},
+			":queued":  &types.AttributeValueMemberS{Value: constants.ImportStatusQueued},
+			":running": &types.AttributeValueMemberS{Value: constants.ImportStatusRunning},
+			":stale":   &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", staleBefore.Unix())},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query pending import jobs: %w", err)
+		}
+		var items []models.ImportJob
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal import jobs: %w", err)
+		}
+		jobs = append(jobs, items...)
+	}
+	return jobs, nil
+}
+
+// GetUserJournalContents retrieves the CreatedAt, content and import fingerprint of every journal
+// entry of a user, including the ones in trash, for duplicate detection during imports
+func GetUserJournalContents(ctx context.Context, userId string) ([]models.Journal, error) {
+	journals := []models.Journal{}
+	paginator := dynamodb.NewQueryPaginator(GetInitializedClient(), &dynamodb.QueryInput{
+		TableName:              aws.String(constants.JournalsTable),
+		KeyConditionExpression: aws.String("#uid = :uid"),
+		ProjectionExpression:   aws.String("#createdAt, #content, #fingerprint"),
+		ExpressionAttributeNames: map[string]string{
+			"#uid":         constants.DynamoDbKeyUserId,
+			"#createdAt":   constants.DynamoDbKeyCreatedAt,
+			"#content":     "content",
+			"#fingerprint": "importFingerprint",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid": &types.AttributeValueMemberS{Value: userId},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query table: %w", err)
+		}
+		var items []models.Journal
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal items: %w", err)
+		}
+		journals = append(journals, items...)
+	}
+	return journals, nil
+}
+
+// BatchCreateJournalEntries writes new journal entries of one user in batches of up to 25 items.
+// Tag and activity counters are updated after each batch.
+// Callers must make sure the entries' CreatedAt values do not collide with existing entries.
+func BatchCreateJournalEntries(ctx context.Context, userId string, entries []models.Journal) error {
+	for start := 0; start < len(entries); start += constants.ImportBatchSize {
+		batch := entries[start:min(start+constants.ImportBatchSize, len(entries))]
+		deltas := map[string]int{}
+
+		requests := make([]types.WriteRequest, 0, len(batch))
+		for _, entry := range batch {
+			item, err := attributevalue.MarshalMap(entry)
+			if err != nil {
+				return fmt.Errorf("failed to marshal journal entry: %w", err)
+			}
+			requests = append(requests, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+			for _, tag := range entry.Tags {
+				deltas[tag]++
+			}
+		}
+		if err := batchWrite(ctx, constants.JournalsTable, requests); err != nil {
+			return err
+		}
+
+		if err := AdjustTagCounts(ctx, userId, deltas); err != nil {
+			return err
+		}
+		if err := AdjustJournalStats(ctx, userId, batch, 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// batchWrite runs write requests against one table in batches of up to 25,
+// retrying unprocessed items with exponential backoff
+func batchWrite(ctx context.Context, table string, requests []types.WriteRequest) error {
+	for start := 0; start < len(requests); start += constants.ImportBatchSize {
+		pending := map[string][]types.WriteRequest{
+			table: requests[start:min(start+constants.ImportBatchSize, len(requests))],
+		}
+		for attempt := 0; len(pending) > 0; attempt++ {
+			if attempt == batchWriteMaxAttempts {
+				return fmt.Errorf("failed to write %d items to %s after %d attempts", len(pending[table]), table, attempt)
+			}
+			if attempt > 0 {
+				time.Sleep(time.Duration(50<<attempt) * time.Millisecond)
+			}
+			result, err := GetInitializedClient().BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+				RequestItems: pending,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to batch write to %s: %w", table, err)
+			}
+			pending = result.UnprocessedItems
+		}
+	}
+	return nil
+}
+
+// importJobKey builds the primary key of an import job item
+func importJobKey(userId string, jobId string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		constants.DynamoDbKeyUserId: &types.AttributeValueMemberS{Value: userId},
+		constants.DynamoDbKeyJobId:  &types.AttributeValueMemberS{Value: jobId},
+	}
+}
@@ -0,0 +1,277 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"lambda-server/constants"
+	"lambda-server/models"
+	"lambda-server/utils"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// statDelta is a change to one stats counter
+type statDelta struct {
+	count int
+	words int
+}
+
+// GetJournalStats retrieves every activity counter of a user (all days, hours and the total).
+// There is one day item per day with entries, so this stays small compared to the entries themselves.
+func GetJournalStats(ctx context.Context, userId string) ([]models.JournalStat, error) {
+	return queryJournalStats(ctx, userId, false)
+}
+
+// RebuildJournalStats recomputes a user's counters from their live entries and marks them as backfilled.
+// It is run once for users whose entries predate the counters; afterwards they are kept up to date
+// incrementally. The rebuild is claimed on the total item so only one runs at a time, and the
+// counters are corrected with ADD deltas, so counter updates made while it runs are not overwritten. A request that
+// finds the rebuild claimed gets the counters as they are.
+func RebuildJournalStats(ctx context.Context, userId string) ([]models.JournalStat, error) {
+	claimedAt := time.Now().Unix()
+	claimed, err := claimJournalStatsRebuild(ctx, userId, claimedAt)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return GetJournalStats(ctx, userId)
+	}
+
+	deltas := map[string]*statDelta{}
+	err = IterateUserJournals(ctx, userId, 0, time.Now().Unix(), func(journal models.Journal) error {
+		addJournalStatDeltas(deltas, journal, 1)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := queryJournalStats(ctx, userId, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, stat := range existing {
+		if deltas[stat.StatKey] == nil {
+			deltas[stat.StatKey] = &statDelta{}
+		}
+		deltas[stat.StatKey].count -= stat.Count
+		deltas[stat.StatKey].words -= stat.Words
+	}
+
+	// The total is corrected last, with the backfilled flag, so an interrupted rebuild is retried
+	total := deltas[constants.JournalStatTotalKey]
+	delete(deltas, constants.JournalStatTotalKey)
+	if total == nil {
+		total = &statDelta{}
+	}
+	if err := applyStatWrites(ctx, statWrites(userId, deltas)); err != nil {
+		return nil, err
+	}
+	if err := finishJournalStatsRebuild(ctx, userId, claimedAt, total); err != nil {
+		return nil, err
+	}
+
+	return queryJournalStats(ctx, userId, true)
+}
+
+// claimJournalStatsRebuild marks the user's total item as being rebuilt. It returns false if the
+// counters are already backfilled or another rebuild claimed them less than JournalStatClaimMins ago.
+func claimJournalStatsRebuild(ctx context.Context, userId string, claimedAt int64) (bool, error) {
+	stale := claimedAt - int64(constants.JournalStatClaimMins*60)
+	_, err := GetInitializedClient().UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(constants.JournalStatsTable),
+		Key:                 journalStatKey(userId, constants.JournalStatTotalKey),
+		UpdateExpression:    aws.String("SET #rebuildingAt = :now"),
+		ConditionExpression: aws.String("attribute_not_exists(#backfilled) AND (attribute_not_exists(#rebuildingAt) OR #rebuildingAt < :stale)"),
+		ExpressionAttributeNames: map[string]string{
+			"#backfilled":   "backfilled",
+			"#rebuildingAt": "rebuildingAt",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now":   &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", claimedAt)},
+			":stale": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", stale)},
+		},
+	})
+	if err != nil {
+		if isConditionFailure(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to claim journal stats rebuild: %w", err)
+	}
+	return true, nil
+}
+
+// finishJournalStatsRebuild corrects the total, marks the counters as backfilled and releases the
+// claim, provided the claim was not taken over by another rebuild in the meantime
+func finishJournalStatsRebuild(ctx context.Context, userId string, claimedAt int64, total *statDelta) error {
+	_, err := GetInitializedClient().UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(constants.JournalStatsTable),
+		Key:                 journalStatKey(userId, constants.JournalStatTotalKey),
+		UpdateExpression:    aws.String("SET #backfilled = :true REMOVE #rebuildingAt ADD #count :count, #words :words"),
+		ConditionExpression: aws.String("#rebuildingAt = :claimedAt"),
+		ExpressionAttributeNames: map[string]string{
+			"#backfilled":   "backfilled",
+			"#rebuildingAt": "rebuildingAt",
+			"#count":        "count",
+			"#words":        "words",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":true":      &types.AttributeValueMemberBOOL{Value: true},
+			":claimedAt": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", claimedAt)},
+			":count":     &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", total.count)},
+			":words":     &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", total.words)},
+		},
+	})
+	if err != nil {
+		if isConditionFailure(err) {
+			return fmt.Errorf("journal stats rebuild for %s was taken over by another rebuild", userId)
+		}
+		return fmt.Errorf("failed to finish journal stats rebuild: %w", err)
+	}
+	return nil
+}
+
+// AdjustJournalStats applies the counters of several entries outside of a transaction,
+// for bulk writes such as imports. sign is 1 to add the entries and -1 to remove them.
+func AdjustJournalStats(ctx context.Context, userId string, journals []models.Journal, sign int) error {
+	deltas := map[string]*statDelta{}
+	for _, journal := range journals {
+		addJournalStatDeltas(deltas, journal, sign)
+	}
+	return applyStatWrites(ctx, statWrites(userId, deltas))
+}
+
+// applyStatWrites runs counter updates one by one, outside of a transaction
+func applyStatWrites(ctx context.Context, writes []types.TransactWriteItem) error {
+	for _, write := range writes {
+		_, err := GetInitializedClient().UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 write.Update.TableName,
+			Key:                       write.Update.Key,
+			UpdateExpression:          write.Update.UpdateExpression,
+			ExpressionAttributeNames:  write.Update.ExpressionAttributeNames,
+			ExpressionAttributeValues: write.Update.ExpressionAttributeValues,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update journal stats: %w", err)
+		}
+	}
+	return nil
+}
+
+// journalStatWrites builds the counter updates that add (sign 1) or remove (sign -1) an entry
+func journalStatWrites(journal models.Journal, sign int) []types.TransactWriteItem {
+	deltas := map[string]*statDelta{}
+	addJournalStatDeltas(deltas, journal, sign)
+	return statWrites(journal.UserId, deltas)
+}
+
+// journalWordWrites builds the counter updates for an entry whose content changed from oldContent
+func journalWordWrites(journal models.Journal, oldContent string) []types.TransactWriteItem {
+	words := utils.CountWords(journal.Content) - utils.CountWords(oldContent)
+	if words == 0 {
+		return nil
+	}
+	day, _ := journalStatBuckets(journal)
+	return statWrites(journal.UserId, map[string]*statDelta{
+		day:                           {words: words},
+		constants.JournalStatTotalKey: {words: words},
+	})
+}
+
+// addJournalStatDeltas adds an entry's contribution to the day, hour and total counters
+func addJournalStatDeltas(deltas map[string]*statDelta, journal models.Journal, sign int) {
+	words := sign * utils.CountWords(journal.Content)
+	day, hour := journalStatBuckets(journal)
+	for _, key := range []string{day, hour, constants.JournalStatTotalKey} {
+		if deltas[key] == nil {
+			deltas[key] = &statDelta{}
+		}
+		deltas[key].count += sign
+		if key != hour {
+			deltas[key].words += words
+		}
+	}
+}
+
+// journalStatBuckets returns the day and hour counter keys of an entry, in the time zone it was written in
+func journalStatBuckets(journal models.Journal) (string, string) {
+	written := time.Unix(journal.CreatedAt, 0).In(utils.LoadLocation(journal.TimeZone))
+	day := journal.Date
+	if day == "" {
+		day = written.Format("20060102")
+	}
+	return constants.JournalStatDayPrefix + day, fmt.Sprintf("%s%02d", constants.JournalStatHourPrefix, written.Hour())
+}
+
+// statWrites builds the counter updates for a set of deltas, in a stable order
+func statWrites(userId string, deltas map[string]*statDelta) []types.TransactWriteItem {
+	keys := make([]string, 0, len(deltas))
+	for key, delta := range deltas {
+		if delta.count != 0 || delta.words != 0 {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	writes := make([]types.TransactWriteItem, 0, len(keys))
+	for _, key := range keys {
+		writes = append(writes, types.TransactWriteItem{
+			Update: &types.Update{
+				TableName:        aws.String(constants.JournalStatsTable),
+				Key:              journalStatKey(userId, key),
+				UpdateExpression: aws.String("ADD #count :count, #words :words"),
+				ExpressionAttributeNames: map[string]string{
+					"#count": "count",
+					"#words": "words",
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":count": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", deltas[key].count)},
+					":words": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", deltas[key].words)},
+				},
+			},
+		})
+	}
+	return writes
+}
+
+// queryJournalStats reads every counter of a user, strongly consistent when the counters are
+// about to be corrected
+func queryJournalStats(ctx context.Context, userId string, consistent bool) ([]models.JournalStat, error) {
+	stats := []models.JournalStat{}
+	paginator := dynamodb.NewQueryPaginator(GetInitializedClient(), &dynamodb.QueryInput{
+		TableName:              aws.String(constants.JournalStatsTable),
+		KeyConditionExpression: aws.String("#uid = :uid"),
+		ConsistentRead:         aws.Bool(consistent),
+		ExpressionAttributeNames: map[string]string{
+			"#uid": constants.DynamoDbKeyUserId,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid": &types.AttributeValueMemberS{Value: userId},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query journal stats: %w", err)
+		}
+		var items []models.JournalStat
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal journal stats: %w", err)
+		}
+		stats = append(stats, items...)
+	}
+	return stats, nil
+}
+
+// journalStatKey builds the primary key of a stats item
+func journalStatKey(userId string, statKey string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		constants.DynamoDbKeyUserId:  &types.AttributeValueMemberS{Value: userId},
+		constants.DynamoDbKeyStatKey: &types.AttributeValueMemberS{Value: statKey},
+	}
+}
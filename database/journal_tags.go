@@ -0,0 +1,283 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lambda-server/constants"
+	"lambda-server/models"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// GetUserTagCounts retrieves the per-tag entry counts of a user from the tag counter table
+func GetUserTagCounts(ctx context.Context, userId string) ([]models.TagCount, error) {
+	tags := []models.TagCount{}
+	paginator := dynamodb.NewQueryPaginator(GetInitializedClient(), &dynamodb.QueryInput{
+		TableName:              aws.String(constants.JournalTagsTable),
+		KeyConditionExpression: aws.String("#uid = :uid"),
+		FilterExpression:       aws.String("#count > :zero"),
+		ExpressionAttributeNames: map[string]string{
+			"#uid":   constants.DynamoDbKeyUserId,
+			"#count": "count",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid":  &types.AttributeValueMemberS{Value: userId},
+			":zero": &types.AttributeValueMemberN{Value: "0"},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query tag counts: %w", err)
+		}
+		var items []models.TagCount
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tag counts: %w", err)
+		}
+		tags = append(tags, items...)
+	}
+	return tags, nil
+}
+
+// RetagJournals replaces every tag in sources with target on all of a user's journal entries,
+// including the ones in trash. Renaming a tag is a merge with a single source.
+// Each entry is written in one transaction with its tag count changes, conditional on the
+// version it was read at, so edits made meanwhile are not lost and the counts stay exact.
+// It returns the number of entries that were changed.
+func RetagJournals(ctx context.Context, userId string, sources []string, target string) (int, error) {
+	if len(sources) == 0 {
+		return 0, nil
+	}
+
+	names := map[string]string{
+		"#uid":  constants.DynamoDbKeyUserId,
+		"#tags": "tags",
+	}
+	values := map[string]types.AttributeValue{
+		":uid": &types.AttributeValueMemberS{Value: userId},
+	}
+	filterExpression := ""
+	for i, source := range sources {
+		placeholder := fmt.Sprintf(":tag%d", i)
+		if i > 0 {
+			filterExpression += " OR "
+		}
+		filterExpression += "contains(#tags, " + placeholder + ")"
+		values[placeholder] = &types.AttributeValueMemberS{Value: source}
+	}
+
+	isSource := map[string]bool{}
+	for _, source := range sources {
+		isSource[source] = true
+	}
+
+	changed := 0
+	paginator := dynamodb.NewQueryPaginator(GetInitializedClient(), &dynamodb.QueryInput{
+		TableName:                 aws.String(constants.JournalsTable),
+		KeyConditionExpression:    aws.String("#uid = :uid"),
+		FilterExpression:          aws.String(filterExpression),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return changed, fmt.Errorf("failed to query journals: %w", err)
+		}
+		var journals []models.Journal
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &journals); err != nil {
+			return changed, fmt.Errorf("failed to unmarshal journals: %w", err)
+		}
+
+		for _, journal := range journals {
+			retagged, err := retagJournal(ctx, journal, isSource, target)
+			if err != nil {
+				return changed, err
+			}
+			if retagged {
+				changed++
+			}
+		}
+	}
+
+	return changed, nil
+}
+
+// retagJournal replaces the source tags of one entry, reading it again and retrying if it
+// changed since it was read. It reports whether the entry still had a source tag to replace.
+func retagJournal(ctx context.Context, journal models.Journal, isSource map[string]bool, target string) (bool, error) {
+	for attempt := 1; ; attempt++ {
+		newTags := []string{}
+		seen := map[string]bool{}
+		found := false
+		for _, tag := range journal.Tags {
+			if isSource[tag] {
+				tag = target
+				found = true
+			}
+			if !seen[tag] {
+				seen[tag] = true
+				newTags = append(newTags, tag)
+			}
+		}
+		if !found {
+			return false, nil
+		}
+
+		tagList, err := attributevalue.Marshal(newTags)
+		if err != nil {
+			return false, fmt.Errorf("failed to marshal tags: %w", err)
+		}
+		condition, names, values := journalVersionCondition(journal)
+		if values == nil {
+			values = map[string]types.AttributeValue{}
+		}
+		names["#tags"] = "tags"
+		names["#updatedAt"] = "updatedAt"
+		names["#deletedAt"] = constants.DynamoDbKeyDeletedAt
+		values[":tags"] = tagList
+		values[":now"] = &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", time.Now().Unix())}
+		values[":one"] = &types.AttributeValueMemberN{Value: "1"}
+		// Trashed entries do not count towards the tags, so the trash state must hold too
+		trashCondition := "attribute_not_exists(#deletedAt)"
+		if journal.DeletedAt != 0 {
+			trashCondition = "attribute_exists(#deletedAt)"
+		}
+		writes := []types.TransactWriteItem{{
+			Update: &types.Update{
+				TableName:                 aws.String(constants.JournalsTable),
+				Key:                       journalKey(journal.UserId, journal.CreatedAt),
+				UpdateExpression:          aws.String("SET #tags = :tags, #updatedAt = :now ADD #version :one"),
+				ConditionExpression:       aws.String(condition + " AND " + trashCondition),
+				ExpressionAttributeNames:  names,
+				ExpressionAttributeValues: values,
+			},
+		}}
+		if journal.DeletedAt == 0 {
+			writes = append(writes, tagCountWrites(journal.UserId, tagDeltas(journal.Tags, newTags))...)
+		}
+
+		err = writeJournalTransaction(ctx, writes)
+		if err == nil {
+			return true, nil
+		}
+		if !isConditionFailure(err) {
+			return false, fmt.Errorf("failed to update journal tags: %w", err)
+		}
+		if attempt == journalWriteAttempts {
+			return false, ErrJournalChanged
+		}
+		latest, err := getJournalByKey(ctx, journal.UserId, journal.CreatedAt)
+		if errors.Is(err, ErrJournalNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		journal = *latest
+	}
+}
+
+// AdjustTagCounts applies aggregated tag count changes outside of a journal transaction,
+// for bulk operations that touch more entries than a transaction can hold
+func AdjustTagCounts(ctx context.Context, userId string, deltas map[string]int) error {
+	for _, write := range tagCountWrites(userId, deltas) {
+		_, err := GetInitializedClient().UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 write.Update.TableName,
+			Key:                       write.Update.Key,
+			UpdateExpression:          write.Update.UpdateExpression,
+			ExpressionAttributeNames:  write.Update.ExpressionAttributeNames,
+			ExpressionAttributeValues: write.Update.ExpressionAttributeValues,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update tag count: %w", err)
+		}
+	}
+	return nil
+}
+
+// tagDeltas returns how much each tag count changes when an entry's tags go from oldTags to newTags
+func tagDeltas(oldTags, newTags []string) map[string]int {
+	deltas := map[string]int{}
+	for _, tag := range oldTags {
+		deltas[tag]--
+	}
+	for _, tag := range newTags {
+		deltas[tag]++
+	}
+	for tag, delta := range deltas {
+		if delta == 0 {
+			delete(deltas, tag)
+		}
+	}
+	return deltas
+}
+
+// tagCountWrites builds the counter updates for a set of tag deltas, in a stable order
+func tagCountWrites(userId string, deltas map[string]int) []types.TransactWriteItem {
+	tags := make([]string, 0, len(deltas))
+	for tag := range deltas {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	writes := make([]types.TransactWriteItem, 0, len(tags))
+	for _, tag := range tags {
+		writes = append(writes, types.TransactWriteItem{
+			Update: &types.Update{
+				TableName: aws.String(constants.JournalTagsTable),
+				Key: map[string]types.AttributeValue{
+					constants.DynamoDbKeyUserId: &types.AttributeValueMemberS{Value: userId},
+					constants.DynamoDbKeyTag:    &types.AttributeValueMemberS{Value: tag},
+				},
+				UpdateExpression: aws.String("ADD #count :delta"),
+				ExpressionAttributeNames: map[string]string{
+					"#count": "count",
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":delta": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", deltas[tag])},
+				},
+			},
+		})
+	}
+	return writes
+}
+
+// writeJournalTransaction applies journal writes atomically.
+// A single write skips the transaction to avoid its extra capacity cost.
+func writeJournalTransaction(ctx context.Context, writes []types.TransactWriteItem) error {
+	if len(writes) == 1 && writes[0].Put != nil {
+		put := writes[0].Put
+		_, err := GetInitializedClient().PutItem(ctx, &dynamodb.PutItemInput{
+			TableName:                 put.TableName,
+			Item:                      put.Item,
+			ConditionExpression:       put.ConditionExpression,
+			ExpressionAttributeNames:  put.ExpressionAttributeNames,
+			ExpressionAttributeValues: put.ExpressionAttributeValues,
+		})
+		return err
+	}
+	if len(writes) == 1 && writes[0].Update != nil {
+		update := writes[0].Update
+		_, err := GetInitializedClient().UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 update.TableName,
+			Key:                       update.Key,
+			UpdateExpression:          update.UpdateExpression,
+			ConditionExpression:       update.ConditionExpression,
+			ExpressionAttributeNames:  update.ExpressionAttributeNames,
+			ExpressionAttributeValues: update.ExpressionAttributeValues,
+		})
+		return err
+	}
+
+	_, err := GetInitializedClient().TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: writes,
+	})
+	return err
+}
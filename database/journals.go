@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"lambda-server/constants"
 	"lambda-server/models"
@@ -13,20 +14,43 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
+// Journal errors
+var (
+	// ErrJournalNotFound is returned when a user has no journal entry with the requested ID
+	ErrJournalNotFound = errors.New("journal entry not found")
+	// ErrJournalInTrash is returned when an entry in the trash is edited or moved to trash again
+	ErrJournalInTrash = errors.New("journal entry is in trash")
+	// ErrJournalNotInTrash is returned when an entry is restored or permanently deleted without being in the trash
+	ErrJournalNotInTrash = errors.New("journal entry is not in trash")
+	// ErrJournalChanged is returned when an entry kept changing under a write that was retried
+	ErrJournalChanged = errors.New("journal entry was changed by another request")
+)
+
+// journalWriteAttempts bounds how often a read-modify-write of an entry is retried after
+// another request changed it in between
+const journalWriteAttempts = 3
+
 // CreateJournalEntry creates a new journal entry in DynamoDB
 func CreateJournalEntry(ctx context.Context, entry models.Journal) error {
+	// The analyze-sentiment job picks the entry up if the background analysis misses it
+	entry.SentimentPending = constants.SentimentPendingValue
 	// Convert the journal entry to DynamoDB attribute values
 	item, err := attributevalue.MarshalMap(entry)
 	if err != nil {
 		return fmt.Errorf("failed to marshal journal entry: %w", err)
 	}
 
-	// Put the item in the table
-	_, err = GetInitializedClient().PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(constants.JournalsTable),
-		Item:      item,
-	})
-	if err != nil {
+	// Put the item in the table together with the tag and activity counter increments
+	writes := []types.TransactWriteItem{{
+		Put: &types.Put{
+			TableName: aws.String(constants.JournalsTable),
+			Item:      item,
+		},
+	}}
+	writes = append(writes, tagCountWrites(entry.UserId, tagDeltas(nil, entry.Tags))...)
+	writes = append(writes, journalStatWrites(entry, 1)...)
+
+	if err := writeJournalTransaction(ctx, writes); err != nil {
 		return fmt.Errorf("failed to put item: %w", err)
 	}
 
@@ -54,7 +78,7 @@ func GetJournalByID(ctx context.Context, userId string, journalId string) (*mode
 		return nil, fmt.Errorf("failed to query GSI: %w", err)
 	}
 	if len(result.Items) == 0 {
-		return nil, fmt.Errorf("journal entry not found")
+		return nil, ErrJournalNotFound
 	}
 	var journal models.Journal
 	err = attributevalue.UnmarshalMap(result.Items[0], &journal)
@@ -64,93 +88,457 @@ func GetJournalByID(ctx context.Context, userId string, journalId string) (*mode
 	return &journal, nil
 }
 
-// UpdateJournalEntry updates an existing journal entry using the GSI to find createdAt
-func UpdateJournalEntry(ctx context.Context, userId string, journalId string, title, content string) error {
+// UpdateJournalEntry updates an existing journal entry using the GSI to find createdAt.
+// Metadata fields left nil in the request keep their current values. The entry and its tag and
+// word counts are written in one transaction that only succeeds if the entry still has the
+// version it was read at; if another request changed it in between, it is read again and the
+// edit reapplied.
+func UpdateJournalEntry(ctx context.Context, userId string, journalId string, req models.JournalUpdateRequest) error {
+	return updateJournalEntry(ctx, userId, journalId, req, false)
+}
+
+// RestoreAndUpdateJournalEntry updates a journal entry like UpdateJournalEntry, restoring it from
+// the trash in the same conditional write if it is there
+func RestoreAndUpdateJournalEntry(ctx context.Context, userId string, journalId string, req models.JournalUpdateRequest) error {
+	return updateJournalEntry(ctx, userId, journalId, req, true)
+}
+
+func updateJournalEntry(ctx context.Context, userId string, journalId string, req models.JournalUpdateRequest, restore bool) error {
 	journal, err := GetJournalByID(ctx, userId, journalId)
 	if err != nil {
 		return err
 	}
-	key := map[string]types.AttributeValue{
-		constants.DynamoDbKeyUserId: &types.AttributeValueMemberS{Value: userId},
-		"CreatedAt":                 &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", journal.CreatedAt)},
+	for attempt := 1; ; attempt++ {
+		err := updateJournal(ctx, *journal, req, restore)
+		if err == nil || !isConditionFailure(err) {
+			return err
+		}
+		if attempt == journalWriteAttempts {
+			return ErrJournalChanged
+		}
+		if journal, err = getJournalByKey(ctx, userId, journal.CreatedAt); err != nil {
+			return err
+		}
 	}
+}
 
-	updateExpression := "SET #title = :title, #content = :content, #updatedAt = :updatedAt"
-	expressionAttributeNames := map[string]string{
-		"#title":     "Title",
-		"#content":   "Content",
-		"#updatedAt": "UpdatedAt",
+// updateJournal applies an edit to the entry as read, failing on its condition if the entry
+// changed since. A trashed entry is only edited when restore is set, and is then restored.
+func updateJournal(ctx context.Context, journal models.Journal, req models.JournalUpdateRequest, restore bool) error {
+	trashed := journal.DeletedAt != 0
+	if trashed && !restore {
+		return ErrJournalInTrash
 	}
-	expressionAttributeValues := map[string]types.AttributeValue{
-		":title":     &types.AttributeValueMemberS{Value: title},
-		":content":   &types.AttributeValueMemberS{Value: content},
-		":updatedAt": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", time.Now().Unix())},
+
+	oldTags := journal.Tags
+	oldContent := journal.Content
+	condition, names, values := journalVersionCondition(journal)
+	journal.Title = req.Title
+	journal.Content = req.Content
+	if req.Tags != nil {
+		journal.Tags = req.Tags
 	}
+	if req.Mood != nil {
+		journal.Mood = *req.Mood
+	}
+	if req.Emotions != nil {
+		journal.Emotions = req.Emotions
+	}
+	if req.Pinned != nil {
+		journal.Pinned = *req.Pinned
+	}
+	if req.ExcludeFromChat != nil {
+		journal.ExcludeFromChat = *req.ExcludeFromChat
+	}
+	journal.UpdatedAt = time.Now().Unix()
+	journal.Version++
+	journal.SentimentPending = constants.SentimentPendingValue
+	// The put replaces the whole item, which also drops the trashed attribute
+	journal.DeletedAt = 0
 
-	_, err = GetInitializedClient().UpdateItem(ctx, &dynamodb.UpdateItemInput{
-		TableName:                 aws.String(constants.JournalsTable),
-		Key:                       key,
-		UpdateExpression:          aws.String(updateExpression),
-		ExpressionAttributeNames:  expressionAttributeNames,
-		ExpressionAttributeValues: expressionAttributeValues,
-	})
+	item, err := attributevalue.MarshalMap(journal)
 	if err != nil {
+		return fmt.Errorf("failed to marshal journal entry: %w", err)
+	}
+
+	names["#deletedAt"] = constants.DynamoDbKeyDeletedAt
+	trashCondition := "attribute_not_exists(#deletedAt)"
+	if trashed {
+		trashCondition = "attribute_exists(#deletedAt)"
+	}
+	writes := []types.TransactWriteItem{{
+		Put: &types.Put{
+			TableName:                 aws.String(constants.JournalsTable),
+			Item:                      item,
+			ConditionExpression:       aws.String(trashCondition + " AND " + condition),
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+		},
+	}}
+	if trashed {
+		// Trashed entries do not count towards the tags and stats, so the restored copy is added back
+		writes = append(writes, tagCountWrites(journal.UserId, tagDeltas(nil, journal.Tags))...)
+		writes = append(writes, journalStatWrites(journal, 1)...)
+	} else {
+		writes = append(writes, tagCountWrites(journal.UserId, tagDeltas(oldTags, journal.Tags))...)
+		writes = append(writes, journalWordWrites(journal, oldContent)...)
+	}
+
+	if err := writeJournalTransaction(ctx, writes); err != nil {
+		if isConditionFailure(err) {
+			return err
+		}
 		return fmt.Errorf("failed to update item: %w", err)
 	}
 
 	return nil
 }
 
-// DeleteJournalEntry deletes a journal entry from DynamoDB using the GSI to find createdAt
+// journalVersionCondition returns the condition that an entry still has the version it was read
+// at, with its attribute names and values (nil when there are none, as DynamoDB rejects empty maps)
+func journalVersionCondition(journal models.Journal) (string, map[string]string, map[string]types.AttributeValue) {
+	names := map[string]string{"#version": constants.DynamoDbKeyJournalVersion}
+	if journal.Version == 0 {
+		return "attribute_not_exists(#version)", names, nil
+	}
+	return "#version = :version", names, map[string]types.AttributeValue{
+		":version": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", journal.Version)},
+	}
+}
+
+// getJournalByKey reads a journal entry from the table itself, which unlike the GSI is
+// strongly consistent, so a retried write sees the change that made it fail
+func getJournalByKey(ctx context.Context, userId string, createdAt int64) (*models.Journal, error) {
+	result, err := GetInitializedClient().GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(constants.JournalsTable),
+		Key:            journalKey(userId, createdAt),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get item: %w", err)
+	}
+	if result.Item == nil {
+		return nil, ErrJournalNotFound
+	}
+	var journal models.Journal
+	if err := attributevalue.UnmarshalMap(result.Item, &journal); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal journal: %w", err)
+	}
+	return &journal, nil
+}
+
+// DeleteJournalEntry moves a journal entry to the trash by setting its deletedAt marker, and the
+// trashed attribute that puts it in the trash index. The item stays in the table until it is
+// restored, permanently deleted or purged.
 func DeleteJournalEntry(ctx context.Context, userId string, journalId string) error {
+	return setJournalTrashed(ctx, userId, journalId, true)
+}
+
+// RestoreJournalEntry takes a journal entry out of the trash
+func RestoreJournalEntry(ctx context.Context, userId string, journalId string) error {
+	return setJournalTrashed(ctx, userId, journalId, false)
+}
+
+// setJournalTrashed moves a journal entry into or out of the trash along with its tag and stat
+// counts. Like an edit, the write only succeeds if the entry still has the version it was read
+// at, so the counts change by what the entry holds; if another request changed it in between,
+// it is read again and the move retried.
+func setJournalTrashed(ctx context.Context, userId string, journalId string, trash bool) error {
+	found, err := GetJournalByID(ctx, userId, journalId)
+	if err != nil {
+		return err
+	}
+	// The GSI may lag behind the table; the counts are taken from the consistent copy
+	journal, err := getJournalByKey(ctx, userId, found.CreatedAt)
+	if err != nil {
+		return err
+	}
+	for attempt := 1; ; attempt++ {
+		err := trashJournal(ctx, *journal, trash)
+		if err == nil || !isConditionFailure(err) {
+			return err
+		}
+		if attempt == journalWriteAttempts {
+			return ErrJournalChanged
+		}
+		if journal, err = getJournalByKey(ctx, userId, journal.CreatedAt); err != nil {
+			return err
+		}
+	}
+}
+
+// trashJournal moves the entry as read into or out of the trash, failing on its condition if
+// the entry changed since
+func trashJournal(ctx context.Context, journal models.Journal, trash bool) error {
+	if trash && journal.DeletedAt != 0 {
+		return ErrJournalInTrash
+	}
+	if !trash && journal.DeletedAt == 0 {
+		return ErrJournalNotInTrash
+	}
+
+	condition, names, values := journalVersionCondition(journal)
+	if values == nil {
+		values = map[string]types.AttributeValue{}
+	}
+	names["#deletedAt"] = constants.DynamoDbKeyDeletedAt
+	names["#updatedAt"] = "updatedAt"
+	names["#trashed"] = constants.DynamoDbKeyTrashed
+	values[":now"] = &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", time.Now().Unix())}
+	values[":one"] = &types.AttributeValueMemberN{Value: "1"}
+
+	update := &types.Update{
+		TableName:                 aws.String(constants.JournalsTable),
+		Key:                       journalKey(journal.UserId, journal.CreatedAt),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}
+	var writes []types.TransactWriteItem
+	if trash {
+		values[":trashed"] = &types.AttributeValueMemberS{Value: constants.JournalTrashedValue}
+		update.UpdateExpression = aws.String("SET #deletedAt = :now, #updatedAt = :now, #trashed = :trashed ADD #version :one")
+		update.ConditionExpression = aws.String("attribute_not_exists(#deletedAt) AND " + condition)
+		// Trashed entries no longer count towards the user's tags and activity stats
+		writes = append(writes, tagCountWrites(journal.UserId, tagDeltas(journal.Tags, nil))...)
+		writes = append(writes, journalStatWrites(journal, -1)...)
+	} else {
+		update.UpdateExpression = aws.String("REMOVE #deletedAt, #trashed SET #updatedAt = :now ADD #version :one")
+		update.ConditionExpression = aws.String("attribute_exists(#deletedAt) AND " + condition)
+		writes = append(writes, tagCountWrites(journal.UserId, tagDeltas(nil, journal.Tags))...)
+		writes = append(writes, journalStatWrites(journal, 1)...)
+	}
+	writes = append([]types.TransactWriteItem{{Update: update}}, writes...)
+
+	if err := writeJournalTransaction(ctx, writes); err != nil {
+		if isConditionFailure(err) {
+			return err
+		}
+		if trash {
+			return fmt.Errorf("failed to move item to trash: %w", err)
+		}
+		return fmt.Errorf("failed to restore item: %w", err)
+	}
+	return nil
+}
+
+// PermanentlyDeleteJournalEntry removes a trashed journal entry from DynamoDB.
+// Only entries that are already in the trash can be permanently deleted.
+func PermanentlyDeleteJournalEntry(ctx context.Context, userId string, journalId string) error {
 	journal, err := GetJournalByID(ctx, userId, journalId)
 	if err != nil {
 		return err
 	}
-	key := map[string]types.AttributeValue{
-		constants.DynamoDbKeyUserId: &types.AttributeValueMemberS{Value: userId},
-		"CreatedAt":                 &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", journal.CreatedAt)},
+	if journal.DeletedAt == 0 {
+		return ErrJournalNotInTrash
 	}
 
 	_, err = GetInitializedClient().DeleteItem(ctx, &dynamodb.DeleteItemInput{
-		TableName: aws.String(constants.JournalsTable),
-		Key:       key,
+		TableName:           aws.String(constants.JournalsTable),
+		Key:                 journalKey(userId, journal.CreatedAt),
+		ConditionExpression: aws.String("attribute_exists(#deletedAt)"),
+		ExpressionAttributeNames: map[string]string{
+			"#deletedAt": constants.DynamoDbKeyDeletedAt,
+		},
 	})
 	if err != nil {
+		if isConditionFailure(err) {
+			return ErrJournalNotInTrash
+		}
 		return fmt.Errorf("failed to delete item: %w", err)
 	}
 
 	return nil
 }
 
-// GetUserJournals retrieves up to a limit of journal entries for a user, ordered by most recent (reverse chronological)
-func GetUserJournals(ctx context.Context, userId string) ([]models.Journal, error) {
+// GetUserJournals retrieves up to a limit of journal entries for a user, ordered by most recent (reverse chronological).
+// Entries in the trash are excluded, and the optional filter narrows the result by tag or mood.
+func GetUserJournals(ctx context.Context, userId string, filter models.JournalFilter) ([]models.Journal, error) {
 	limit := constants.JournalQueryLimit
-	result, err := GetInitializedClient().Query(ctx, &dynamodb.QueryInput{
+	journals := []models.Journal{}
+	var startKey map[string]types.AttributeValue
+
+	filterExpression := "attribute_not_exists(#deletedAt)"
+	names := map[string]string{
+		"#uid":       constants.DynamoDbKeyUserId,
+		"#deletedAt": constants.DynamoDbKeyDeletedAt,
+	}
+	values := map[string]types.AttributeValue{
+		":uid": &types.AttributeValueMemberS{Value: userId},
+	}
+	if filter.Tag != "" {
+		filterExpression += " AND contains(#tags, :tag)"
+		names["#tags"] = "tags"
+		values[":tag"] = &types.AttributeValueMemberS{Value: filter.Tag}
+	}
+	if filter.Mood != 0 {
+		filterExpression += " AND #mood = :mood"
+		names["#mood"] = "mood"
+		values[":mood"] = &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", filter.Mood)}
+	}
+
+	// The filter is applied after Limit, so keep paging until enough live entries are collected
+	for {
+		result, err := GetInitializedClient().Query(ctx, &dynamodb.QueryInput{
+			TableName:                 aws.String(constants.JournalsTable),
+			KeyConditionExpression:    aws.String("#uid = :uid"),
+			FilterExpression:          aws.String(filterExpression),
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+			ScanIndexForward:          aws.Bool(false), // descending order
+			Limit:                     aws.Int32(int32(limit)),
+			ExclusiveStartKey:         startKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to query table: %w", err)
+		}
+
+		for _, item := range result.Items {
+			var journal models.Journal
+			err := attributevalue.UnmarshalMap(item, &journal)
+			if err != nil {
+				return nil, fmt.Errorf("failed to unmarshal item: %w", err)
+			}
+			journals = append(journals, journal)
+			if len(journals) == limit {
+				return journals, nil
+			}
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return journals, nil
+		}
+		startKey = result.LastEvaluatedKey
+	}
+}
+
+// IterateUserJournals calls fn for every live journal entry of a user created within [from, to] (unix seconds),
+// in chronological order. It pages through the whole range instead of stopping at JournalQueryLimit.
+func IterateUserJournals(ctx context.Context, userId string, from, to int64, fn func(models.Journal) error) error {
+	paginator := dynamodb.NewQueryPaginator(GetInitializedClient(), &dynamodb.QueryInput{
+		TableName:              aws.String(constants.JournalsTable),
+		KeyConditionExpression: aws.String("#uid = :uid AND #createdAt BETWEEN :from AND :to"),
+		FilterExpression:       aws.String("attribute_not_exists(#deletedAt)"),
+		ExpressionAttributeNames: map[string]string{
+			"#uid":       constants.DynamoDbKeyUserId,
+			"#createdAt": constants.DynamoDbKeyCreatedAt,
+			"#deletedAt": constants.DynamoDbKeyDeletedAt,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid":  &types.AttributeValueMemberS{Value: userId},
+			":from": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", from)},
+			":to":   &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", to)},
+		},
+		ScanIndexForward: aws.Bool(true), // chronological order
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to query table: %w", err)
+		}
+		for _, item := range page.Items {
+			var journal models.Journal
+			if err := attributevalue.UnmarshalMap(item, &journal); err != nil {
+				return fmt.Errorf("failed to unmarshal item: %w", err)
+			}
+			if err := fn(journal); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// GetUserTrashedJournals retrieves every journal entry a user has in the trash, most recently created first
+func GetUserTrashedJournals(ctx context.Context, userId string) ([]models.Journal, error) {
+	journals := []models.Journal{}
+	paginator := dynamodb.NewQueryPaginator(GetInitializedClient(), &dynamodb.QueryInput{
 		TableName:              aws.String(constants.JournalsTable),
 		KeyConditionExpression: aws.String("#uid = :uid"),
+		FilterExpression:       aws.String("attribute_exists(#deletedAt)"),
 		ExpressionAttributeNames: map[string]string{
-			"#uid": constants.DynamoDbKeyUserId,
+			"#uid":       constants.DynamoDbKeyUserId,
+			"#deletedAt": constants.DynamoDbKeyDeletedAt,
 		},
 		ExpressionAttributeValues: map[string]types.AttributeValue{
 			":uid": &types.AttributeValueMemberS{Value: userId},
 		},
-		ScanIndexForward: aws.Bool(false), // descending order
-		Limit:            aws.Int32(int32(limit)),
+		ScanIndexForward: aws.Bool(false),
 	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to query table: %w", err)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query table: %w", err)
+		}
+		var items []models.Journal
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal items: %w", err)
+		}
+		journals = append(journals, items...)
 	}
+	return journals, nil
+}
 
-	var journals []models.Journal
-	for _, item := range result.Items {
-		var journal models.Journal
-		err := attributevalue.UnmarshalMap(item, &journal)
+// PurgeTrashedJournals permanently deletes every journal entry that was moved to trash before the cutoff.
+// Trashed entries are found through the trash index, which holds nothing else. It returns the
+// purged entries (keys, JournalId and attachmentIds only) so callers can clean up related data.
+func PurgeTrashedJournals(ctx context.Context, cutoff time.Time) ([]models.Journal, error) {
+	purged := []models.Journal{}
+	names := map[string]string{
+		"#uid":           constants.DynamoDbKeyUserId,
+		"#createdAt":     constants.DynamoDbKeyCreatedAt,
+		"#jid":           constants.DynamoDbKeyJournalId,
+		"#attachmentIds": "attachmentIds",
+		"#deletedAt":     constants.DynamoDbKeyDeletedAt,
+		"#trashed":       constants.DynamoDbKeyTrashed,
+	}
+	cutoffValue := &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", cutoff.Unix())}
+	paginator := dynamodb.NewQueryPaginator(GetInitializedClient(), &dynamodb.QueryInput{
+		TableName:                aws.String(constants.JournalsTable),
+		IndexName:                aws.String(constants.JournalTrashIndex),
+		KeyConditionExpression:   aws.String("#trashed = :trashed AND #deletedAt < :cutoff"),
+		ProjectionExpression:     aws.String("#uid, #createdAt, #jid, #attachmentIds"),
+		ExpressionAttributeNames: names,
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":trashed": &types.AttributeValueMemberS{Value: constants.JournalTrashedValue},
+			":cutoff":  cutoffValue,
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("failed to unmarshal item: %w", err)
+			return purged, fmt.Errorf("failed to query trash index: %w", err)
+		}
+		for _, item := range page.Items {
+			var journal models.Journal
+			if err := attributevalue.UnmarshalMap(item, &journal); err != nil {
+				return purged, fmt.Errorf("failed to unmarshal item: %w", err)
+			}
+			// The index is eventually consistent, so skip entries restored since it was read
+			_, err := GetInitializedClient().DeleteItem(ctx, &dynamodb.DeleteItemInput{
+				TableName:                aws.String(constants.JournalsTable),
+				Key:                      journalKey(journal.UserId, journal.CreatedAt),
+				ConditionExpression:      aws.String("#deletedAt < :cutoff"),
+				ExpressionAttributeNames: map[string]string{"#deletedAt": constants.DynamoDbKeyDeletedAt},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":cutoff": cutoffValue,
+				},
+			})
+			if isConditionFailure(err) {
+				continue
+			}
+			if err != nil {
+				return purged, fmt.Errorf("failed to purge item: %w", err)
+			}
+			purged = append(purged, journal)
 		}
-		journals = append(journals, journal)
 	}
+	return purged, nil
+}
 
-	return journals, nil
+// journalKey builds the primary key of a journal item
+func journalKey(userId string, createdAt int64) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		constants.DynamoDbKeyUserId:    &types.AttributeValueMemberS{Value: userId},
+		constants.DynamoDbKeyCreatedAt: &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", createdAt)},
+	}
 }
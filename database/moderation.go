@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"lambda-server/constants"
+	"lambda-server/models"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// SaveModerationLog stores a moderated reply for review
+func SaveModerationLog(ctx context.Context, entry models.ModerationLog) error {
+	item, err := attributevalue.MarshalMap(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal moderation log: %w", err)
+	}
+	_, err = GetInitializedClient().PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(constants.ModerationTable),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put moderation log: %w", err)
+	}
+	return nil
+}
+
+// GetModerationLogs retrieves up to limit moderation logs of a day, newest first, optionally
+// only those with the given action
+func GetModerationLogs(ctx context.Context, date, action string, limit int32) ([]models.ModerationLog, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(constants.ModerationTable),
+		KeyConditionExpression: aws.String("#date = :date"),
+		ExpressionAttributeNames: map[string]string{
+			"#date": constants.DynamoDbKeyDate,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":date": &types.AttributeValueMemberS{Value: date},
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+	if action != "" {
+		input.FilterExpression = aws.String("#decision.#action = :action")
+		input.ExpressionAttributeNames["#decision"] = "decision"
+		input.ExpressionAttributeNames["#action"] = "action"
+		input.ExpressionAttributeValues[":action"] = &types.AttributeValueMemberS{Value: action}
+	}
+
+	logs := []models.ModerationLog{}
+	paginator := dynamodb.NewQueryPaginator(GetInitializedClient(), input)
+	for paginator.HasMorePages() && int32(len(logs)) < limit {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query moderation logs: %w", err)
+		}
+		var items []models.ModerationLog
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal moderation logs: %w", err)
+		}
+		logs = append(logs, items...)
+	}
+	if int32(len(logs)) > limit {
+		logs = logs[:limit]
+	}
+	return logs, nil
+}
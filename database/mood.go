@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"lambda-server/constants"
+	"lambda-server/models"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// GetMoodEntry retrieves a mood entry by userId and timestamp, or nil when there is none
+func GetMoodEntry(ctx context.Context, userId string, timestamp int64) (*models.MoodEntry, error) {
+	result, err := GetInitializedClient().GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(constants.MoodTable),
+		Key:       moodKey(userId, timestamp),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mood entry: %w", err)
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+	var entry models.MoodEntry
+	if err := attributevalue.UnmarshalMap(result.Item, &entry); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal mood entry: %w", err)
+	}
+	return &entry, nil
+}
+
+// CreateMoodEntry stores a new mood entry. It returns false without writing when the user
+// already has an entry with the same timestamp.
+func CreateMoodEntry(ctx context.Context, entry models.MoodEntry) (bool, error) {
+	item, err := attributevalue.MarshalMap(entry)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal mood entry: %w", err)
+	}
+	_, err = GetInitializedClient().PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(constants.MoodTable),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(#uid)"),
+		ExpressionAttributeNames: map[string]string{
+			"#uid": constants.DynamoDbKeyMoodUserId,
+		},
+	})
+	if err != nil {
+		if isConditionFailure(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to put mood entry: %w", err)
+	}
+	return true, nil
+}
+
+// DeleteMoodEntry marks a mood entry as deleted. The item is kept as a tombstone so other
+// devices learn about the deletion on their next sync.
+func DeleteMoodEntry(ctx context.Context, userId string, timestamp int64) error {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	_, err := GetInitializedClient().UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(constants.MoodTable),
+		Key:                 moodKey(userId, timestamp),
+		UpdateExpression:    aws.String("SET #deletedAt = :now, #updatedAt = :now"),
+		ConditionExpression: aws.String("attribute_exists(#uid)"),
+		ExpressionAttributeNames: map[string]string{
+			"#uid":       constants.DynamoDbKeyMoodUserId,
+			"#deletedAt": constants.DynamoDbKeyDeletedAt,
+			"#updatedAt": "updatedAt",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now": &types.AttributeValueMemberN{Value: now},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete mood entry: %w", err)
+	}
+	return nil
+}
+
+// HasMoodEntrySince reports whether the user logged a mood entry, not since deleted, at or
+// after since (unix seconds)
+func HasMoodEntrySince(ctx context.Context, userId string, since int64) (bool, error) {
+	paginator := dynamodb.NewQueryPaginator(GetInitializedClient(), &dynamodb.QueryInput{
+		TableName:              aws.String(constants.MoodTable),
+		KeyConditionExpression: aws.String("#uid = :uid AND #ts >= :since"),
+		FilterExpression:       aws.String("attribute_not_exists(#deletedAt)"),
+		ExpressionAttributeNames: map[string]string{
+			"#uid":       constants.DynamoDbKeyMoodUserId,
+			"#ts":        constants.DynamoDbKeyMoodTimestamp,
+			"#deletedAt": constants.DynamoDbKeyDeletedAt,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid":   &types.AttributeValueMemberS{Value: userId},
+			":since": &types.AttributeValueMemberN{Value: strconv.FormatInt(since, 10)},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return false, fmt.Errorf("failed to query mood entries: %w", err)
+		}
+		if len(page.Items) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// moodKey builds the primary key of a mood entry item
+func moodKey(userId string, timestamp int64) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		constants.DynamoDbKeyMoodUserId:    &types.AttributeValueMemberS{Value: userId},
+		constants.DynamoDbKeyMoodTimestamp: &types.AttributeValueMemberN{Value: strconv.FormatInt(timestamp, 10)},
+	}
+}
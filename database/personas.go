@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lambda-server/constants"
+	"lambda-server/models"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// ErrPersonaVersionExists is returned when another edit already stored the same persona version
+var ErrPersonaVersionExists = errors.New("persona version already exists")
+
+// GetStoredPersonas retrieves every persona version saved through the admin API.
+// The library is small, so it is read with a scan.
+func GetStoredPersonas(ctx context.Context) ([]models.Persona, error) {
+	personas := []models.Persona{}
+	paginator := dynamodb.NewScanPaginator(GetInitializedClient(), &dynamodb.ScanInput{
+		TableName: aws.String(constants.PersonasTable),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan personas: %w", err)
+		}
+		var items []models.Persona
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal personas: %w", err)
+		}
+		personas = append(personas, items...)
+	}
+	return personas, nil
+}
+
+// CreatePersonaVersion stores a new persona version. Versions are never overwritten, so it
+// returns ErrPersonaVersionExists when a concurrent edit stored the same version first.
+func CreatePersonaVersion(ctx context.Context, persona models.Persona) error {
+	item, err := attributevalue.MarshalMap(persona)
+	if err != nil {
+		return fmt.Errorf("failed to marshal persona: %w", err)
+	}
+	_, err = GetInitializedClient().PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(constants.PersonasTable),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(#pid)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pid": constants.DynamoDbKeyPersonaId,
+		},
+	})
+	if err != nil {
+		if isConditionFailure(err) {
+			return ErrPersonaVersionExists
+		}
+		return fmt.Errorf("failed to put persona: %w", err)
+	}
+	return nil
+}
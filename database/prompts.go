@@ -0,0 +1,134 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lambda-server/constants"
+	"lambda-server/models"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// GetStoredPrompts retrieves every prompt saved through the admin API.
+// The library is small, so it is read with a scan.
+func GetStoredPrompts(ctx context.Context) ([]models.Prompt, error) {
+	prompts := []models.Prompt{}
+	paginator := dynamodb.NewScanPaginator(GetInitializedClient(), &dynamodb.ScanInput{
+		TableName: aws.String(constants.PromptsTable),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan prompts: %w", err)
+		}
+		var items []models.Prompt
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal prompts: %w", err)
+		}
+		prompts = append(prompts, items...)
+	}
+	return prompts, nil
+}
+
+// SavePrompt creates or replaces a prompt
+func SavePrompt(ctx context.Context, prompt models.Prompt) error {
+	item, err := attributevalue.MarshalMap(prompt)
+	if err != nil {
+		return fmt.Errorf("failed to marshal prompt: %w", err)
+	}
+	_, err = GetInitializedClient().PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(constants.PromptsTable),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put prompt: %w", err)
+	}
+	return nil
+}
+
+// DeletePrompt removes a stored prompt
+func DeletePrompt(ctx context.Context, promptId string) error {
+	_, err := GetInitializedClient().DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(constants.PromptsTable),
+		Key: map[string]types.AttributeValue{
+			constants.DynamoDbKeyPromptId: &types.AttributeValueMemberS{Value: promptId},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete prompt: %w", err)
+	}
+	return nil
+}
+
+// GetPromptHistory retrieves the daily prompts shown to a user on or after fromDate (YYYYMMDD)
+func GetPromptHistory(ctx context.Context, userId string, fromDate string) ([]models.PromptHistory, error) {
+	history := []models.PromptHistory{}
+	paginator := dynamodb.NewQueryPaginator(GetInitializedClient(), &dynamodb.QueryInput{
+		TableName:              aws.String(constants.PromptHistoryTable),
+		KeyConditionExpression: aws.String("#uid = :uid AND #date >= :from"),
+		ExpressionAttributeNames: map[string]string{
+			"#uid":  constants.DynamoDbKeyUserId,
+			"#date": constants.DynamoDbKeyDate,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid":  &types.AttributeValueMemberS{Value: userId},
+			":from": &types.AttributeValueMemberS{Value: fromDate},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query prompt history: %w", err)
+		}
+		var items []models.PromptHistory
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal prompt history: %w", err)
+		}
+		history = append(history, items...)
+	}
+	return history, nil
+}
+
+// RecordDailyPrompt stores the prompt of the day for a user. If another request already
+// recorded one for that day, the existing entry is returned instead so both agree.
+func RecordDailyPrompt(ctx context.Context, entry models.PromptHistory) (*models.PromptHistory, error) {
+	item, err := attributevalue.MarshalMap(entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal prompt history: %w", err)
+	}
+	_, err = GetInitializedClient().PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(constants.PromptHistoryTable),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(#date)"),
+		ExpressionAttributeNames: map[string]string{
+			"#date": constants.DynamoDbKeyDate,
+		},
+	})
+	if err == nil {
+		return &entry, nil
+	}
+	var conditionErr *types.ConditionalCheckFailedException
+	if !errors.As(err, &conditionErr) {
+		return nil, fmt.Errorf("failed to put prompt history: %w", err)
+	}
+
+	result, err := GetInitializedClient().GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(constants.PromptHistoryTable),
+		Key: map[string]types.AttributeValue{
+			constants.DynamoDbKeyUserId: &types.AttributeValueMemberS{Value: entry.UserId},
+			constants.DynamoDbKeyDate:   &types.AttributeValueMemberS{Value: entry.Date},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prompt history: %w", err)
+	}
+	var existing models.PromptHistory
+	if err := attributevalue.UnmarshalMap(result.Item, &existing); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal prompt history: %w", err)
+	}
+	return &existing, nil
+}
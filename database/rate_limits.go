@@ -0,0 +1,134 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"lambda-server/constants"
+	"lambda-server/models"
+	"lambda-server/ratelimit"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// RateLimitStore keeps token buckets in DynamoDB so every Lambda instance shares them. Each
+// update is conditional on the state it was computed from and is retried on conflict; a bucket
+// that keeps conflicting reports ratelimit.ErrContention.
+type RateLimitStore struct{}
+
+// rateLimitItem is the stored state of a bucket
+type rateLimitItem struct {
+	Key       string  `dynamodbav:"Key"`
+	Tokens    float64 `dynamodbav:"tokens"`
+	UpdatedAt int64   `dynamodbav:"updatedAt"` // Unix milliseconds
+	ExpiresAt int64   `dynamodbav:"expiresAt"` // DynamoDB TTL, unix seconds
+}
+
+// Take takes cost tokens from the bucket under key
+func (RateLimitStore) Take(ctx context.Context, key string, bucket ratelimit.Bucket, cost float64) (ratelimit.Result, error) {
+	client := GetInitializedClient()
+	for attempt := 0; attempt < constants.RateLimitConflicts; attempt++ {
+		result, err := client.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName:      aws.String(constants.RateLimitsTable),
+			Key:            map[string]types.AttributeValue{constants.DynamoDbKeyKey: &types.AttributeValueMemberS{Value: key}},
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil {
+			return ratelimit.Result{}, fmt.Errorf("failed to get rate limit: %w", err)
+		}
+		var state *ratelimit.State
+		var previous int64
+		if result.Item != nil {
+			var item rateLimitItem
+			if err := attributevalue.UnmarshalMap(result.Item, &item); err != nil {
+				return ratelimit.Result{}, fmt.Errorf("failed to unmarshal rate limit: %w", err)
+			}
+			previous = item.UpdatedAt
+			state = &ratelimit.State{Tokens: item.Tokens, UpdatedAt: time.UnixMilli(item.UpdatedAt)}
+		}
+
+		now := time.Now()
+		next, outcome := bucket.Take(state, now, cost)
+		if !outcome.Allowed {
+			return outcome, nil // Nothing taken, so nothing to store
+		}
+		item, err := attributevalue.MarshalMap(rateLimitItem{
+			Key:       key,
+			Tokens:    next.Tokens,
+			UpdatedAt: next.UpdatedAt.UnixMilli(),
+			ExpiresAt: now.Add(time.Duration(constants.RateLimitTTLHours) * time.Hour).Unix(),
+		})
+		if err != nil {
+			return ratelimit.Result{}, fmt.Errorf("failed to marshal rate limit: %w", err)
+		}
+		input := &dynamodb.PutItemInput{
+			TableName:                aws.String(constants.RateLimitsTable),
+			Item:                     item,
+			ConditionExpression:      aws.String("attribute_not_exists(#key)"),
+			ExpressionAttributeNames: map[string]string{"#key": constants.DynamoDbKeyKey},
+		}
+		if state != nil {
+			input.ConditionExpression = aws.String("updatedAt = :previous")
+			input.ExpressionAttributeNames = nil
+			input.ExpressionAttributeValues = map[string]types.AttributeValue{
+				":previous": &types.AttributeValueMemberN{Value: strconv.FormatInt(previous, 10)},
+			}
+		}
+		if _, err := client.PutItem(ctx, input); err != nil {
+			if isConditionFailure(err) {
+				continue // Another request took from the bucket first
+			}
+			return ratelimit.Result{}, fmt.Errorf("failed to put rate limit: %w", err)
+		}
+		return outcome, nil
+	}
+	return ratelimit.Result{}, fmt.Errorf("%w: %s", ratelimit.ErrContention, key)
+}
+
+// GetDailyUsage retrieves a user's chat usage on a day (YYYYMMDD), zero when there is none
+func GetDailyUsage(ctx context.Context, userId, date string) (models.DailyUsage, error) {
+	usage := models.DailyUsage{UserId: userId, Date: date}
+	result, err := GetInitializedClient().GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(constants.UsageTable),
+		Key:       usageKey(userId, date),
+	})
+	if err != nil {
+		return usage, fmt.Errorf("failed to get usage: %w", err)
+	}
+	if result.Item == nil {
+		return usage, nil
+	}
+	if err := attributevalue.UnmarshalMap(result.Item, &usage); err != nil {
+		return usage, fmt.Errorf("failed to unmarshal usage: %w", err)
+	}
+	return usage, nil
+}
+
+// RecordUsage adds a chat message and the tokens its reply cost to a user's usage on a day
+func RecordUsage(ctx context.Context, userId, date string, usage models.TokenUsage) error {
+	_, err := GetInitializedClient().UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(constants.UsageTable),
+		Key:              usageKey(userId, date),
+		UpdateExpression: aws.String("ADD messages :one, promptTokens :prompt, completionTokens :completion"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":one":        &types.AttributeValueMemberN{Value: "1"},
+			":prompt":     &types.AttributeValueMemberN{Value: strconv.Itoa(usage.PromptTokens)},
+			":completion": &types.AttributeValueMemberN{Value: strconv.Itoa(usage.CompletionTokens)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record usage: %w", err)
+	}
+	return nil
+}
+
+func usageKey(userId, date string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"userId":                  &types.AttributeValueMemberS{Value: userId},
+		constants.DynamoDbKeyDate: &types.AttributeValueMemberS{Value: date},
+	}
+}
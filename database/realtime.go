@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"lambda-server/constants"
+	"lambda-server/models"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// SaveRealtimeConnection stores a client connected to the WebSocket API
+func SaveRealtimeConnection(ctx context.Context, conn models.RealtimeConnection) error {
+	item, err := attributevalue.MarshalMap(conn)
+	if err != nil {
+		return fmt.Errorf("failed to marshal realtime connection: %w", err)
+	}
+	_, err = GetInitializedClient().PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(constants.RealtimeConnectionsTable),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put realtime connection: %w", err)
+	}
+	return nil
+}
+
+// GetRealtimeConnection retrieves a connection, or nil when it is gone
+func GetRealtimeConnection(ctx context.Context, connectionId string) (*models.RealtimeConnection, error) {
+	result, err := GetInitializedClient().GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(constants.RealtimeConnectionsTable),
+		Key:            realtimeConnectionKey(connectionId),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get realtime connection: %w", err)
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+	var conn models.RealtimeConnection
+	if err := attributevalue.UnmarshalMap(result.Item, &conn); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal realtime connection: %w", err)
+	}
+	return &conn, nil
+}
+
+// DeleteRealtimeConnection forgets a connection; deleting a missing one is not an error
+func DeleteRealtimeConnection(ctx context.Context, connectionId string) error {
+	_, err := GetInitializedClient().DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(constants.RealtimeConnectionsTable),
+		Key:       realtimeConnectionKey(connectionId),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete realtime connection: %w", err)
+	}
+	return nil
+}
+
+// GetUserRealtimeConnections returns the open connections of a user
+func GetUserRealtimeConnections(ctx context.Context, userId string) ([]models.RealtimeConnection, error) {
+	conns := []models.RealtimeConnection{}
+	paginator := dynamodb.NewQueryPaginator(GetInitializedClient(), &dynamodb.QueryInput{
+		TableName:              aws.String(constants.RealtimeConnectionsTable),
+		IndexName:              aws.String(constants.RealtimeUserIndex),
+		KeyConditionExpression: aws.String("userId = :uid"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid": &types.AttributeValueMemberS{Value: userId},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query realtime connections: %w", err)
+		}
+		var items []models.RealtimeConnection
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal realtime connections: %w", err)
+		}
+		conns = append(conns, items...)
+	}
+	return conns, nil
+}
+
+// CancelRealtimeReply records that the client cancelled the reply to a chat message, for the
+// invocation generating it to notice
+func CancelRealtimeReply(ctx context.Context, connectionId, id string) error {
+	return setRealtimeConnectionField(ctx, connectionId, "cancelledId", id)
+}
+
+// SetRealtimeReminded records the local day a connection was last sent a mood reminder
+func SetRealtimeReminded(ctx context.Context, connectionId, day string) error {
+	return setRealtimeConnectionField(ctx, connectionId, "remindedDay", day)
+}
+
+// setRealtimeConnectionField sets a string attribute of a connection that still exists
+func setRealtimeConnectionField(ctx context.Context, connectionId, name, value string) error {
+	_, err := GetInitializedClient().UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(constants.RealtimeConnectionsTable),
+		Key:                 realtimeConnectionKey(connectionId),
+		UpdateExpression:    aws.String("SET #field = :value"),
+		ConditionExpression: aws.String("attribute_exists(#id)"),
+		ExpressionAttributeNames: map[string]string{
+			"#field": name,
+			"#id":    constants.DynamoDbKeyConnectionId,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":value": &types.AttributeValueMemberS{Value: value},
+		},
+	})
+	if err != nil && !isConditionFailure(err) {
+		return fmt.Errorf("failed to update realtime connection: %w", err)
+	}
+	return nil
+}
+
+func realtimeConnectionKey(connectionId string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		constants.DynamoDbKeyConnectionId: &types.AttributeValueMemberS{Value: connectionId},
+	}
+}
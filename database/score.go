@@ -8,6 +8,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
 // CreateMindMuseScoreEntry inserts a new score entry into the mindMuse_score table
@@ -22,4 +23,30 @@ func CreateMindMuseScoreEntry(ctx context.Context, score models.MindMuseScore) e
 		Item:      item,
 	})
 	return err
-} 
\ No newline at end of file
+} 
+// GetLatestMindMuseScore retrieves the most recent score entry of a user, or nil if there is none
+func GetLatestMindMuseScore(ctx context.Context, userId string) (*models.MindMuseScore, error) {
+	result, err := GetInitializedClient().Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(constants.MindMuseScoreTable),
+		KeyConditionExpression: aws.String("#uid = :uid"),
+		ExpressionAttributeNames: map[string]string{
+			"#uid": "userId",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid": &types.AttributeValueMemberS{Value: userId},
+		},
+		ScanIndexForward: aws.Bool(false), // newest first
+		Limit:            aws.Int32(1),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Items) == 0 {
+		return nil, nil
+	}
+	var score models.MindMuseScore
+	if err := attributevalue.UnmarshalMap(result.Items[0], &score); err != nil {
+		return nil, err
+	}
+	return &score, nil
+}
@@ -0,0 +1,305 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"lambda-server/constants"
+	"lambda-server/models"
+	"lambda-server/sentiment"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// SetJournalSentiment stores the analysis of a journal entry and takes it out of the pending
+// index. The write is skipped when the entry changed after updatedAt, since the analysis no
+// longer matches its content; the change left the entry pending.
+func SetJournalSentiment(ctx context.Context, userId string, createdAt int64, updatedAt int64, analysis models.Sentiment) error {
+	value, err := attributevalue.Marshal(analysis)
+	if err != nil {
+		return fmt.Errorf("failed to marshal sentiment: %w", err)
+	}
+	_, err = GetInitializedClient().UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(constants.JournalsTable),
+		Key:                 journalKey(userId, createdAt),
+		UpdateExpression:    aws.String("SET #sentiment = :sentiment REMOVE #pending"),
+		ConditionExpression: aws.String("#updatedAt = :updatedAt"),
+		ExpressionAttributeNames: map[string]string{
+			"#sentiment": "sentiment",
+			"#updatedAt": "updatedAt",
+			"#pending":   constants.DynamoDbKeySentimentPending,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":sentiment": value,
+			":updatedAt": &types.AttributeValueMemberN{Value: strconv.FormatInt(updatedAt, 10)},
+		},
+	})
+	if err != nil && !isConditionFailure(err) {
+		return fmt.Errorf("failed to update journal sentiment: %w", err)
+	}
+	return nil
+}
+
+// SetChatMessageSentiment stores the analysis of a chat message, takes it out of the pending
+// index and moves its contribution to the day totals from its previous analysis (msg.Sentiment,
+// counted in msg.SentimentDay) to the new one, counted in day, in one transaction. The write is
+// skipped when the message was analyzed again, or deleted, since it was read.
+func SetChatMessageSentiment(ctx context.Context, msg models.ChatMessage, analysis models.Sentiment, day string) error {
+	value, err := attributevalue.Marshal(analysis)
+	if err != nil {
+		return fmt.Errorf("failed to marshal sentiment: %w", err)
+	}
+	names := map[string]string{
+		"#uid":       "userId",
+		"#sentiment": "sentiment",
+		"#pending":   constants.DynamoDbKeySentimentPending,
+		"#day":       "sentimentDay",
+	}
+	values := map[string]types.AttributeValue{
+		":sentiment": value,
+		":day":       &types.AttributeValueMemberS{Value: day},
+	}
+	condition := "attribute_exists(#uid) AND attribute_not_exists(#day)"
+	counted := msg.SentimentDay != "" && msg.Sentiment != nil
+	if counted {
+		condition = "#day = :oldDay AND #sentiment.#analyzedAt = :analyzedAt"
+		names["#analyzedAt"] = "analyzedAt"
+		values[":oldDay"] = &types.AttributeValueMemberS{Value: msg.SentimentDay}
+		values[":analyzedAt"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(msg.Sentiment.AnalyzedAt, 10)}
+	}
+
+	writes := []types.TransactWriteItem{{
+		Update: &types.Update{
+			TableName: aws.String(constants.ChatTable),
+			Key: map[string]types.AttributeValue{
+				"userId":              &types.AttributeValueMemberS{Value: msg.UserId},
+				"sessionId_timestamp": &types.AttributeValueMemberS{Value: msg.SessionIdTimestamp},
+			},
+			UpdateExpression:          aws.String("SET #sentiment = :sentiment, #day = :day REMOVE #pending"),
+			ConditionExpression:       aws.String(condition),
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+		},
+	}}
+	deltas := map[string]*models.SentimentDay{day: {UserId: msg.UserId, Day: day}}
+	sentiment.AddToDay(deltas[day], analysis, 1)
+	if counted {
+		if deltas[msg.SentimentDay] == nil {
+			deltas[msg.SentimentDay] = &models.SentimentDay{UserId: msg.UserId, Day: msg.SentimentDay}
+		}
+		sentiment.AddToDay(deltas[msg.SentimentDay], *msg.Sentiment, -1)
+	}
+	for _, delta := range deltas {
+		writes = append(writes, sentimentDayWrite(*delta))
+	}
+
+	if _, err := GetInitializedClient().TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: writes}); err != nil {
+		if isConditionFailure(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to update chat message sentiment: %w", err)
+	}
+	return nil
+}
+
+// sentimentDayWrite adds delta to a day's chat sentiment totals, creating them if needed
+func sentimentDayWrite(delta models.SentimentDay) types.TransactWriteItem {
+	number := func(value float64) types.AttributeValue {
+		return &types.AttributeValueMemberN{Value: strconv.FormatFloat(value, 'f', -1, 64)}
+	}
+	return types.TransactWriteItem{
+		Update: &types.Update{
+			TableName: aws.String(constants.ChatSentimentDaysTable),
+			Key:       sentimentDayKey(delta.UserId, delta.Day),
+			UpdateExpression: aws.String("ADD #count :count, #compound :compound, #sadness :sadness, #anxiety :anxiety, " +
+				"#anger :anger, #joy :joy, #positive :positive, #negative :negative, #neutral :neutral"),
+			ExpressionAttributeNames: map[string]string{
+				"#count":    "count",
+				"#compound": "compound",
+				"#sadness":  "sadness",
+				"#anxiety":  "anxiety",
+				"#anger":    "anger",
+				"#joy":      "joy",
+				"#positive": "positive",
+				"#negative": "negative",
+				"#neutral":  "neutral",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":count":    number(float64(delta.Count)),
+				":compound": number(delta.Compound),
+				":sadness":  number(delta.Sadness),
+				":anxiety":  number(delta.Anxiety),
+				":anger":    number(delta.Anger),
+				":joy":      number(delta.Joy),
+				":positive": number(float64(delta.Positive)),
+				":negative": number(float64(delta.Negative)),
+				":neutral":  number(float64(delta.Neutral)),
+			},
+		},
+	}
+}
+
+// GetJournalsNeedingSentiment retrieves up to limit journal entries of any user that wait for
+// analysis, from the sparse pending index
+func GetJournalsNeedingSentiment(ctx context.Context, limit int) ([]models.Journal, error) {
+	items, err := queryPendingSentiment(ctx, constants.JournalsTable, constants.JournalSentimentPendingIndex, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query journals: %w", err)
+	}
+	journals := []models.Journal{}
+	if err := attributevalue.UnmarshalListOfMaps(items, &journals); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal journals: %w", err)
+	}
+	return journals, nil
+}
+
+// GetChatMessagesNeedingSentiment retrieves up to limit user chat messages that wait for
+// analysis, from the sparse pending index
+func GetChatMessagesNeedingSentiment(ctx context.Context, limit int) ([]models.ChatMessage, error) {
+	items, err := queryPendingSentiment(ctx, constants.ChatTable, constants.ChatSentimentPendingIndex, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chat messages: %w", err)
+	}
+	messages := []models.ChatMessage{}
+	if err := attributevalue.UnmarshalListOfMaps(items, &messages); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal chat messages: %w", err)
+	}
+	return messages, nil
+}
+
+// queryPendingSentiment reads up to limit items of a table's pending sentiment index, oldest first
+func queryPendingSentiment(ctx context.Context, table, index string, limit int) ([]map[string]types.AttributeValue, error) {
+	items := []map[string]types.AttributeValue{}
+	paginator := dynamodb.NewQueryPaginator(GetInitializedClient(), &dynamodb.QueryInput{
+		TableName:              aws.String(table),
+		IndexName:              aws.String(index),
+		KeyConditionExpression: aws.String("#pending = :pending"),
+		ExpressionAttributeNames: map[string]string{
+			"#pending": constants.DynamoDbKeySentimentPending,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pending": &types.AttributeValueMemberS{Value: constants.SentimentPendingValue},
+		},
+		Limit: aws.Int32(int32(limit)),
+	})
+	for paginator.HasMorePages() && len(items) < limit {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page.Items...)
+	}
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items, nil
+}
+
+// MarkOutdatedSentiment scans both tables once and marks as pending the journal entries and user
+// chat messages that were never analyzed, were analyzed by an older analyzer version or, for
+// messages, are not in the day totals yet. It is run by hand after the analyzer version is bumped
+// and returns the number of items marked.
+func MarkOutdatedSentiment(ctx context.Context, version int) (int, error) {
+	versionValue := &types.AttributeValueMemberN{Value: strconv.Itoa(version)}
+	journals, err := markPendingSentiment(ctx, constants.JournalsTable, journalKeyAttributes,
+		"attribute_not_exists(#sentiment) OR #sentiment.#version < :version OR #sentiment.#analyzedAt < #updatedAt",
+		map[string]string{"#sentiment": "sentiment", "#version": "version", "#analyzedAt": "analyzedAt", "#updatedAt": "updatedAt"},
+		map[string]types.AttributeValue{":version": versionValue})
+	if err != nil {
+		return journals, err
+	}
+	messages, err := markPendingSentiment(ctx, constants.ChatTable, chatKeyAttributes,
+		"#sender = :user AND (attribute_not_exists(#sentiment) OR #sentiment.#version < :version OR attribute_not_exists(#day))",
+		map[string]string{"#sentiment": "sentiment", "#version": "version", "#sender": "sender", "#day": "sentimentDay"},
+		map[string]types.AttributeValue{
+			":version": versionValue,
+			":user":    &types.AttributeValueMemberS{Value: constants.ChatSenderUser},
+		})
+	return journals + messages, err
+}
+
+// Key attributes of the tables MarkOutdatedSentiment goes through
+var (
+	journalKeyAttributes = []string{constants.DynamoDbKeyUserId, constants.DynamoDbKeyCreatedAt}
+	chatKeyAttributes    = []string{"userId", "sessionId_timestamp"}
+)
+
+// markPendingSentiment sets sentimentPending on every item of a table matching filter
+func markPendingSentiment(ctx context.Context, table string, keyAttributes []string, filter string, names map[string]string, values map[string]types.AttributeValue) (int, error) {
+	client := GetInitializedClient()
+	paginator := dynamodb.NewScanPaginator(client, &dynamodb.ScanInput{
+		TableName:                 aws.String(table),
+		FilterExpression:          aws.String(filter),
+		ProjectionExpression:      aws.String(strings.Join(keyAttributes, ", ")),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	})
+	marked := 0
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return marked, fmt.Errorf("failed to scan %s: %w", table, err)
+		}
+		for _, key := range page.Items {
+			_, err := client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+				TableName:        aws.String(table),
+				Key:              key,
+				UpdateExpression: aws.String("SET #pending = :pending"),
+				ExpressionAttributeNames: map[string]string{
+					"#pending": constants.DynamoDbKeySentimentPending,
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":pending": &types.AttributeValueMemberS{Value: constants.SentimentPendingValue},
+				},
+			})
+			if err != nil {
+				return marked, fmt.Errorf("failed to mark %s item for analysis: %w", table, err)
+			}
+			marked++
+		}
+	}
+	return marked, nil
+}
+
+// GetChatSentimentDays retrieves a user's chat sentiment totals for the days from fromDay to
+// toDay (inclusive, YYYYMMDD)
+func GetChatSentimentDays(ctx context.Context, userId, fromDay, toDay string) ([]models.SentimentDay, error) {
+	days := []models.SentimentDay{}
+	paginator := dynamodb.NewQueryPaginator(GetInitializedClient(), &dynamodb.QueryInput{
+		TableName:              aws.String(constants.ChatSentimentDaysTable),
+		KeyConditionExpression: aws.String("#uid = :uid AND #day BETWEEN :from AND :to"),
+		ExpressionAttributeNames: map[string]string{
+			"#uid": constants.DynamoDbKeyUserId,
+			"#day": constants.DynamoDbKeyDay,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid":  &types.AttributeValueMemberS{Value: userId},
+			":from": &types.AttributeValueMemberS{Value: fromDay},
+			":to":   &types.AttributeValueMemberS{Value: toDay},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query chat sentiment days: %w", err)
+		}
+		var items []models.SentimentDay
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal chat sentiment days: %w", err)
+		}
+		days = append(days, items...)
+	}
+	return days, nil
+}
+
+// sentimentDayKey builds the primary key of a chat sentiment day
+func sentimentDayKey(userId, day string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		constants.DynamoDbKeyUserId: &types.AttributeValueMemberS{Value: userId},
+		constants.DynamoDbKeyDay:    &types.AttributeValueMemberS{Value: day},
+	}
+}
@@ -0,0 +1,215 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lambda-server/constants"
+	"lambda-server/models"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// SOS errors
+var (
+	ErrSOSNotFound = errors.New("SOS not found")
+	ErrSOSExists   = errors.New("an SOS was already raised at this time")
+	// ErrSOSConflict is returned when an SOS is no longer in a status it can be moved from
+	ErrSOSConflict = errors.New("SOS status changed")
+)
+
+// SOSTransition moves an SOS to the status of Event, recording Event in its history
+type SOSTransition struct {
+	From  []string // Statuses, as stored, the SOS may be moved from
+	Event models.SOSEvent
+}
+
+func sosKey(userId string, timestamp int64) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		constants.DynamoDbKeySOSUserId:    &types.AttributeValueMemberS{Value: userId},
+		constants.DynamoDbKeySOSTimestamp: &types.AttributeValueMemberN{Value: strconv.FormatInt(timestamp, 10)},
+	}
+}
+
+// GetLatestSOS retrieves the user's most recent SOS record, or nil when there is none
+func GetLatestSOS(ctx context.Context, userId string) (*models.SOS, error) {
+	alerts, _, err := ListSOS(ctx, userId, 1, "")
+	if err != nil {
+		return nil, err
+	}
+	if len(alerts) == 0 {
+		return nil, nil
+	}
+	return &alerts[0], nil
+}
+
+// GetSOS retrieves an SOS record, returning ErrSOSNotFound when there is none
+func GetSOS(ctx context.Context, userId string, timestamp int64) (*models.SOS, error) {
+	result, err := GetInitializedClient().GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(constants.SOSTable),
+		Key:            sosKey(userId, timestamp),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get SOS record: %w", err)
+	}
+	if result.Item == nil {
+		return nil, ErrSOSNotFound
+	}
+	var sos models.SOS
+	if err := attributevalue.UnmarshalMap(result.Item, &sos); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal SOS record: %w", err)
+	}
+	return &sos, nil
+}
+
+// ListSOS retrieves a page of a user's SOS records, newest first
+func ListSOS(ctx context.Context, userId string, limit int32, cursor string) ([]models.SOS, string, error) {
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	result, err := GetInitializedClient().Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(constants.SOSTable),
+		KeyConditionExpression: aws.String("#uid = :uid"),
+		ExpressionAttributeNames: map[string]string{
+			"#uid": constants.DynamoDbKeySOSUserId,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid": &types.AttributeValueMemberS{Value: userId},
+		},
+		ScanIndexForward:  aws.Bool(false),
+		Limit:             aws.Int32(limit),
+		ExclusiveStartKey: startKey,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query SOS records: %w", err)
+	}
+	alerts := []models.SOS{}
+	if err := attributevalue.UnmarshalListOfMaps(result.Items, &alerts); err != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal SOS records: %w", err)
+	}
+	next, err := encodeCursor(result.LastEvaluatedKey)
+	return alerts, next, err
+}
+
+// CreateSOS stores a new SOS record, returning ErrSOSExists if the user already has one with
+// the same timestamp
+func CreateSOS(ctx context.Context, sos models.SOS) error {
+	item, err := attributevalue.MarshalMap(sos)
+	if err != nil {
+		return fmt.Errorf("failed to marshal SOS record: %w", err)
+	}
+	_, err = GetInitializedClient().PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(constants.SOSTable),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(#uid)"),
+		ExpressionAttributeNames: map[string]string{
+			"#uid": constants.DynamoDbKeySOSUserId,
+		},
+	})
+	if err != nil {
+		if isConditionFailure(err) {
+			return ErrSOSExists
+		}
+		return fmt.Errorf("failed to put SOS record: %w", err)
+	}
+	return nil
+}
+
+// TransitionSOS changes the status of an SOS if it is still in one of t.From, appends t.Event
+// to its history and clears any pending notification. It returns the updated record, or
+// ErrSOSConflict when the SOS has moved on or does not exist.
+func TransitionSOS(ctx context.Context, userId string, timestamp int64, t SOSTransition) (*models.SOS, error) {
+	event, err := attributevalue.Marshal([]models.SOSEvent{t.Event})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal SOS event: %w", err)
+	}
+	values := map[string]types.AttributeValue{
+		":status": &types.AttributeValueMemberS{Value: t.Event.Status},
+		":at":     &types.AttributeValueMemberN{Value: strconv.FormatInt(t.Event.At, 10)},
+		":event":  event,
+		":empty":  &types.AttributeValueMemberL{Value: []types.AttributeValue{}},
+	}
+	from := make([]string, len(t.From))
+	for i, status := range t.From {
+		from[i] = ":from" + strconv.Itoa(i)
+		values[from[i]] = &types.AttributeValueMemberS{Value: status}
+	}
+	result, err := GetInitializedClient().UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(constants.SOSTable),
+		Key:       sosKey(userId, timestamp),
+		UpdateExpression: aws.String("SET #status = :status, updatedAt = :at, " +
+			"history = list_append(if_not_exists(history, :empty), :event) REMOVE notifyAt"),
+		ConditionExpression:       aws.String("#status IN (" + strings.Join(from, ", ") + ")"),
+		ExpressionAttributeNames:  map[string]string{"#status": "Status"},
+		ExpressionAttributeValues: values,
+		ReturnValues:              types.ReturnValueAllNew,
+	})
+	if err != nil {
+		if isConditionFailure(err) {
+			return nil, ErrSOSConflict
+		}
+		return nil, fmt.Errorf("failed to update SOS status: %w", err)
+	}
+	var sos models.SOS
+	if err := attributevalue.UnmarshalMap(result.Attributes, &sos); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal SOS record: %w", err)
+	}
+	return &sos, nil
+}
+
+// SetSOSContactsNotified records how many emergency contacts were alerted about an SOS
+func SetSOSContactsNotified(ctx context.Context, userId string, timestamp int64, count int) error {
+	_, err := GetInitializedClient().UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(constants.SOSTable),
+		Key:              sosKey(userId, timestamp),
+		UpdateExpression: aws.String("SET contactsNotified = :count"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":count": &types.AttributeValueMemberN{Value: strconv.Itoa(count)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update SOS contacts notified: %w", err)
+	}
+	return nil
+}
+
+// GetDueSOS retrieves up to limit raised SOS records of any user whose cancel window ended by
+// now, through the sparse SOSDueIndex
+func GetDueSOS(ctx context.Context, now int64, limit int) ([]models.SOS, error) {
+	alerts := []models.SOS{}
+	paginator := dynamodb.NewQueryPaginator(GetInitializedClient(), &dynamodb.QueryInput{
+		TableName:              aws.String(constants.SOSTable),
+		IndexName:              aws.String(constants.SOSDueIndex),
+		KeyConditionExpression: aws.String("#status = :raised AND notifyAt <= :now"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "Status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":raised": &types.AttributeValueMemberS{Value: constants.SOSStatusRaised},
+			":now":    &types.AttributeValueMemberN{Value: strconv.FormatInt(now, 10)},
+		},
+		Limit: aws.Int32(int32(limit)),
+	})
+	for paginator.HasMorePages() && len(alerts) < limit {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query due SOS records: %w", err)
+		}
+		var items []models.SOS
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal SOS records: %w", err)
+		}
+		alerts = append(alerts, items...)
+	}
+	if len(alerts) > limit {
+		alerts = alerts[:limit]
+	}
+	return alerts, nil
+}
@@ -0,0 +1,295 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"lambda-server/constants"
+	"lambda-server/models"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrJournalSlotTaken is returned when another entry of the user already has the same CreatedAt
+var ErrJournalSlotTaken = errors.New("another journal entry has the same createdAt")
+
+// ErrSyncBatchInProgress is returned when the same batch is still being applied by another request
+var ErrSyncBatchInProgress = errors.New("sync batch is still being processed")
+
+// CreateJournalEntryIfAbsent creates a journal entry like CreateJournalEntry, but fails with
+// ErrJournalSlotTaken instead of overwriting an entry with the same CreatedAt
+func CreateJournalEntryIfAbsent(ctx context.Context, entry models.Journal) error {
+	entry.SentimentPending = constants.SentimentPendingValue
+	item, err := attributevalue.MarshalMap(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal entry: %w", err)
+	}
+
+	writes := []types.TransactWriteItem{{
+		Put: &types.Put{
+			TableName:           aws.String(constants.JournalsTable),
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(#uid)"),
+			ExpressionAttributeNames: map[string]string{
+				"#uid": constants.DynamoDbKeyUserId,
+			},
+		},
+	}}
+	writes = append(writes, tagCountWrites(entry.UserId, tagDeltas(nil, entry.Tags))...)
+	writes = append(writes, journalStatWrites(entry, 1)...)
+
+	if err := writeJournalTransaction(ctx, writes); err != nil {
+		if isConditionFailure(err) {
+			return ErrJournalSlotTaken
+		}
+		return fmt.Errorf("failed to put item: %w", err)
+	}
+	return nil
+}
+
+// GetJournalsUpdatedSince retrieves one page of a user's journal entries, including trashed ones,
+// whose updatedAt is at or after since. The returned cursor is empty on the last page.
+func GetJournalsUpdatedSince(ctx context.Context, userId string, since int64, cursor string) ([]models.Journal, string, error) {
+	items, next, err := queryUpdatedSince(ctx, constants.JournalsTable, constants.JournalUpdatedAtIndex, constants.DynamoDbKeyUserId, userId, since, cursor)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query changed journals: %w", err)
+	}
+	journals := []models.Journal{}
+	if err := attributevalue.UnmarshalListOfMaps(items, &journals); err != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal journals: %w", err)
+	}
+	return journals, next, nil
+}
+
+// GetMoodEntriesUpdatedSince retrieves one page of a user's mood entries, including deleted ones,
+// whose updatedAt is at or after since. The returned cursor is empty on the last page.
+func GetMoodEntriesUpdatedSince(ctx context.Context, userId string, since int64, cursor string) ([]models.MoodEntry, string, error) {
+	items, next, err := queryUpdatedSince(ctx, constants.MoodTable, constants.MoodUpdatedAtIndex, constants.DynamoDbKeyMoodUserId, userId, since, cursor)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query changed mood entries: %w", err)
+	}
+	entries := []models.MoodEntry{}
+	if err := attributevalue.UnmarshalListOfMaps(items, &entries); err != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal mood entries: %w", err)
+	}
+	return entries, next, nil
+}
+
+// ClaimSyncBatch marks a batch as being processed. If the batch was already applied, its
+// record is returned so the stored results can be replayed; a batch that is still being
+// processed yields ErrSyncBatchInProgress. A claim older than SyncBatchStaleSeconds is taken
+// over, since the request holding it can no longer be running.
+func ClaimSyncBatch(ctx context.Context, userId string, batchId string) (*models.SyncBatch, error) {
+	client := GetInitializedClient()
+	now := time.Now()
+	batch := models.SyncBatch{
+		UserId:    userId,
+		BatchId:   batchId,
+		Status:    constants.SyncBatchProcessing,
+		CreatedAt: now.Unix(),
+		ExpiresAt: now.AddDate(0, 0, constants.SyncBatchRetentionDays).Unix(),
+	}
+	item, err := attributevalue.MarshalMap(batch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal sync batch: %w", err)
+	}
+	stale := now.Add(-time.Duration(constants.SyncBatchStaleSeconds) * time.Second).Unix()
+	for attempt := 0; attempt < 2; attempt++ {
+		_, err = client.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName:           aws.String(constants.SyncBatchesTable),
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(#bid) OR (#status = :processing AND #createdAt < :stale)"),
+			ExpressionAttributeNames: map[string]string{
+				"#bid":       constants.DynamoDbKeyBatchId,
+				"#status":    "status",
+				"#createdAt": "createdAt",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":processing": &types.AttributeValueMemberS{Value: constants.SyncBatchProcessing},
+				":stale":      &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", stale)},
+			},
+		})
+		if err == nil {
+			return nil, nil
+		}
+		if !isConditionFailure(err) {
+			return nil, fmt.Errorf("failed to put sync batch: %w", err)
+		}
+
+		result, err := client.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName:      aws.String(constants.SyncBatchesTable),
+			Key:            syncBatchKey(userId, batchId),
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get sync batch: %w", err)
+		}
+		if result.Item == nil {
+			continue // Released between the two calls
+		}
+		var existing models.SyncBatch
+		if err := attributevalue.UnmarshalMap(result.Item, &existing); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal sync batch: %w", err)
+		}
+		if existing.Status != constants.SyncBatchDone {
+			return nil, ErrSyncBatchInProgress
+		}
+		return &existing, nil
+	}
+	return nil, ErrSyncBatchInProgress
+}
+
+// CompleteSyncBatch stores the results of an applied batch for replay on resends
+func CompleteSyncBatch(ctx context.Context, userId string, batchId string, results []models.SyncResult) error {
+	stored, err := attributevalue.Marshal(results)
+	if err != nil {
+		return fmt.Errorf("failed to marshal sync results: %w", err)
+	}
+	_, err = GetInitializedClient().UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(constants.SyncBatchesTable),
+		Key:              syncBatchKey(userId, batchId),
+		UpdateExpression: aws.String("SET #status = :done, #results = :results"),
+		ExpressionAttributeNames: map[string]string{
+			"#status":  "status",
+			"#results": "results",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":done":    &types.AttributeValueMemberS{Value: constants.SyncBatchDone},
+			":results": stored,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete sync batch: %w", err)
+	}
+	return nil
+}
+
+// ReleaseSyncBatch removes the record of a batch that failed part way, so the client can retry it
+func ReleaseSyncBatch(ctx context.Context, userId string, batchId string) error {
+	_, err := GetInitializedClient().DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(constants.SyncBatchesTable),
+		Key:       syncBatchKey(userId, batchId),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release sync batch: %w", err)
+	}
+	return nil
+}
+
+// queryUpdatedSince reads one page of a table's updatedAt index for a user
+func queryUpdatedSince(ctx context.Context, table, index, userKey, userId string, since int64, cursor string) ([]map[string]types.AttributeValue, string, error) {
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	result, err := GetInitializedClient().Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(table),
+		IndexName:              aws.String(index),
+		KeyConditionExpression: aws.String("#uid = :uid AND #updatedAt >= :since"),
+		ExpressionAttributeNames: map[string]string{
+			"#uid":       userKey,
+			"#updatedAt": "updatedAt",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid":   &types.AttributeValueMemberS{Value: userId},
+			":since": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", since)},
+		},
+		ExclusiveStartKey: startKey,
+		Limit:             aws.Int32(int32(constants.SyncPageSize)),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	next, err := encodeCursor(result.LastEvaluatedKey)
+	if err != nil {
+		return nil, "", err
+	}
+	return result.Items, next, nil
+}
+
+// encodeCursor turns a LastEvaluatedKey into an opaque string. Index keys only hold
+// string and number attributes, so each value is stored with a type prefix.
+func encodeCursor(key map[string]types.AttributeValue) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+	values := map[string]string{}
+	for name, value := range key {
+		switch v := value.(type) {
+		case *types.AttributeValueMemberS:
+			values[name] = "S:" + v.Value
+		case *types.AttributeValueMemberN:
+			values[name] = "N:" + v.Value
+		default:
+			return "", fmt.Errorf("unsupported key attribute %q", name)
+		}
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// ErrInvalidCursor is returned for a page cursor that was not produced by encodeCursor
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// decodeCursor reverses encodeCursor
+func decodeCursor(cursor string) (map[string]types.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	values := map[string]string{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	key := map[string]types.AttributeValue{}
+	for name, value := range values {
+		switch {
+		case strings.HasPrefix(value, "S:"):
+			key[name] = &types.AttributeValueMemberS{Value: value[2:]}
+		case strings.HasPrefix(value, "N:"):
+			key[name] = &types.AttributeValueMemberN{Value: value[2:]}
+		default:
+			return nil, ErrInvalidCursor
+		}
+	}
+	return key, nil
+}
+
+// isConditionFailure reports whether a write failed on its condition expression, either as a
+// single item write or as part of a cancelled transaction
+func isConditionFailure(err error) bool {
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return true
+	}
+	var cancelled *types.TransactionCanceledException
+	if errors.As(err, &cancelled) {
+		for _, reason := range cancelled.CancellationReasons {
+			if aws.ToString(reason.Code) == "ConditionalCheckFailed" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// syncBatchKey builds the primary key of a sync batch item
+func syncBatchKey(userId string, batchId string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		constants.DynamoDbKeyUserId:  &types.AttributeValueMemberS{Value: userId},
+		constants.DynamoDbKeyBatchId: &types.AttributeValueMemberS{Value: batchId},
+	}
+}
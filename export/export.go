@@ -0,0 +1,123 @@
+// Package export renders journal entries as Markdown, JSON or PDF documents.
+// Writers stream: entries are written as they arrive, in the order given, so an
+// export never has to hold the whole journal in memory.
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"lambda-server/models"
+)
+
+// Supported export formats
+const (
+	FormatMarkdown = "md"
+	FormatJSON     = "json"
+	FormatPDF      = "pdf"
+)
+
+// Meta describes the export as a whole and is rendered on the cover page
+type Meta struct {
+	Title       string
+	Name        string // Journal owner's name, may be empty
+	From        string // First day of the range (YYYY-MM-DD), empty for "beginning"
+	To          string // Last day of the range (YYYY-MM-DD)
+	GeneratedAt time.Time
+	Location    *time.Location // Zone used to render entry times, defaults to UTC
+}
+
+// Writer renders an export. Begin must be called once, then Entry for each journal
+// entry in chronological order, then End.
+type Writer interface {
+	Begin(meta Meta) error
+	Entry(journal models.Journal) error
+	End() error
+}
+
+// NewWriter returns a writer for the given format
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatMarkdown:
+		return &markdownWriter{w: w}, nil
+	case FormatJSON:
+		return &jsonWriter{w: w}, nil
+	case FormatPDF:
+		return newPDFWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// ContentType returns the MIME type of an export format
+func ContentType(format string) string {
+	switch format {
+	case FormatMarkdown:
+		return "text/markdown; charset=utf-8"
+	case FormatJSON:
+		return "application/json; charset=utf-8"
+	case FormatPDF:
+		return "application/pdf"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// location returns the zone entries are rendered in
+func (m Meta) location() *time.Location {
+	if m.Location == nil {
+		return time.UTC
+	}
+	return m.Location
+}
+
+// rangeLabel describes the exported date range
+func (m Meta) rangeLabel() string {
+	switch {
+	case m.From == "" && m.To == "":
+		return "All entries"
+	case m.From == "":
+		return "Entries up to " + m.To
+	case m.To == "":
+		return "Entries from " + m.From
+	default:
+		return "Entries from " + m.From + " to " + m.To
+	}
+}
+
+// dayHeading formats a journal Date (YYYYMMDD) as a readable heading, e.g. "Monday, 19 October 2026"
+func dayHeading(date string) string {
+	day, err := time.Parse("20060102", date)
+	if err != nil {
+		return date
+	}
+	return day.Format("Monday, 2 January 2006")
+}
+
+// entryDetails returns the secondary line shown under an entry title
+func entryDetails(journal models.Journal, loc *time.Location) string {
+	details := []string{time.Unix(journal.CreatedAt, 0).In(loc).Format("15:04")}
+	if journal.Pinned {
+		details = append(details, "Pinned")
+	}
+	if journal.Mood != 0 {
+		details = append(details, fmt.Sprintf("Mood: %d/5", journal.Mood))
+	}
+	if len(journal.Emotions) > 0 {
+		details = append(details, "Feeling: "+strings.Join(journal.Emotions, ", "))
+	}
+	if len(journal.Tags) > 0 {
+		details = append(details, "Tags: "+strings.Join(journal.Tags, ", "))
+	}
+	return strings.Join(details, " · ")
+}
+
+// entryTitle returns the title of an entry, with a placeholder for untitled ones
+func entryTitle(journal models.Journal) string {
+	if strings.TrimSpace(journal.Title) == "" {
+		return "Untitled"
+	}
+	return journal.Title
+}
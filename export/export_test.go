@@ -0,0 +1,97 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"lambda-server/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sampleJournals() []models.Journal {
+	return []models.Journal{
+		{JournalID: "j1", Date: "20261018", CreatedAt: 1760781600, UpdatedAt: 1760781600, Title: "Morning", Content: "Slept well.", Tags: []string{"sleep"}},
+		{JournalID: "j2", Date: "20261018", CreatedAt: 1760810400, UpdatedAt: 1760810400, Title: "", Content: "Long (walk) \\ café", Mood: 4},
+		{JournalID: "j3", Date: "20261019", CreatedAt: 1760868000, UpdatedAt: 1760868000, Title: "Next day", Content: strings.Repeat("word ", 2000)},
+	}
+}
+
+func render(t *testing.T, format string, journals []models.Journal) string {
+	var out bytes.Buffer
+	writer, err := NewWriter(format, &out)
+	assert.Nil(t, err)
+	assert.Nil(t, writer.Begin(Meta{Title: "MindMuse Journal", Name: "Asha", From: "2026-10-01", To: "2026-10-31", GeneratedAt: time.Unix(1760900000, 0)}))
+	for _, journal := range journals {
+		assert.Nil(t, writer.Entry(journal))
+	}
+	assert.Nil(t, writer.End())
+	return out.String()
+}
+
+func TestMarkdownGroupsEntriesByDay(t *testing.T) {
+	out := render(t, FormatMarkdown, sampleJournals())
+
+	assert.True(t, strings.HasPrefix(out, "# MindMuse Journal\n"))
+	assert.Equal(t, 1, strings.Count(out, "## Sunday, 18 October 2026"))
+	assert.Equal(t, 1, strings.Count(out, "## Monday, 19 October 2026"))
+	assert.Contains(t, out, "### Untitled")
+	assert.Contains(t, out, "Mood: 4/5")
+	assert.Equal(t, out, render(t, FormatMarkdown, sampleJournals()), "output must be stable")
+}
+
+func TestJSONExportIsValid(t *testing.T) {
+	var doc struct {
+		Export  map[string]string `json:"export"`
+		Entries []jsonEntry       `json:"entries"`
+		Count   int               `json:"count"`
+	}
+	assert.Nil(t, json.Unmarshal([]byte(render(t, FormatJSON, sampleJournals())), &doc))
+	assert.Equal(t, 3, doc.Count)
+	assert.Equal(t, "Asha", doc.Export["name"])
+	assert.Equal(t, "j2", doc.Entries[1].JournalId)
+	assert.Equal(t, []string{}, doc.Entries[1].Tags)
+
+	assert.Nil(t, json.Unmarshal([]byte(render(t, FormatJSON, nil)), &doc))
+	assert.Equal(t, 0, doc.Count)
+}
+
+func TestPDFCrossReferenceTable(t *testing.T) {
+	out := render(t, FormatPDF, sampleJournals())
+	assert.True(t, strings.HasPrefix(out, "%PDF-1.4"))
+	assert.True(t, strings.HasSuffix(out, "%%EOF\n"))
+
+	// startxref must point at the xref table, and every entry at its object
+	match := regexp.MustCompile(`startxref\n(\d+)\n`).FindStringSubmatch(out)
+	assert.Len(t, match, 2)
+	xrefOffset, _ := strconv.Atoi(match[1])
+	assert.True(t, strings.HasPrefix(out[xrefOffset:], "xref\n"))
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllStringSubmatch(out[xrefOffset:], -1)
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(entry[1])
+		assert.True(t, strings.HasPrefix(out[offset:], strconv.Itoa(i+1)+" 0 obj"), "object %d", i+1)
+	}
+
+	// The long entry spills over several pages after the cover
+	pages := regexp.MustCompile(`/Count (\d+)`).FindStringSubmatch(out)
+	count, _ := strconv.Atoi(pages[1])
+	assert.Greater(t, count, 3)
+	assert.Contains(t, out, `Long \(walk\) \\ caf`)
+}
+
+func TestPDFFlagsTextItCannotEncode(t *testing.T) {
+	out := render(t, FormatPDF, sampleJournals())
+	assert.NotContains(t, out, "cannot be shown in PDF", "Latin text is encoded in full")
+
+	journals := sampleJournals()
+	journals[0].Content = "आज अच्छा दिन था"
+	out = render(t, FormatPDF, journals)
+	assert.Equal(t, 1, strings.Count(out, "Some characters in this entry cannot be shown"))
+	assert.Contains(t, out, "Entries with characters that cannot be shown in PDF: 1.")
+}
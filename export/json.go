@@ -0,0 +1,98 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"lambda-server/models"
+)
+
+// jsonEntry is the stable, documented shape of an exported entry
+type jsonEntry struct {
+	JournalId string   `json:"journalId"`
+	Date      string   `json:"date"`
+	CreatedAt string   `json:"createdAt"`
+	UpdatedAt string   `json:"updatedAt"`
+	Title     string   `json:"title"`
+	Content   string   `json:"content"`
+	Tags      []string `json:"tags"`
+	Mood      int      `json:"mood,omitempty"`
+	Emotions  []string `json:"emotions"`
+	Pinned    bool     `json:"pinned"`
+}
+
+// jsonWriter streams a single JSON document: the export metadata followed by an entries array
+type jsonWriter struct {
+	w     io.Writer
+	meta  Meta
+	count int
+	err   error
+}
+
+func (j *jsonWriter) Begin(meta Meta) error {
+	j.meta = meta
+	header, err := json.Marshal(map[string]string{
+		"title":       meta.Title,
+		"name":        meta.Name,
+		"from":        meta.From,
+		"to":          meta.To,
+		"generatedAt": meta.GeneratedAt.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+	// Reopen the header object so the entries array can be streamed into it
+	j.write(fmt.Sprintf("{\n  \"export\": %s,\n  \"entries\": [", header))
+	return j.err
+}
+
+func (j *jsonWriter) Entry(journal models.Journal) error {
+	entry := jsonEntry{
+		JournalId: journal.JournalID,
+		Date:      journal.Date,
+		CreatedAt: time.Unix(journal.CreatedAt, 0).In(j.meta.location()).Format(time.RFC3339),
+		UpdatedAt: time.Unix(journal.UpdatedAt, 0).In(j.meta.location()).Format(time.RFC3339),
+		Title:     journal.Title,
+		Content:   journal.Content,
+		Tags:      nonNil(journal.Tags),
+		Mood:      journal.Mood,
+		Emotions:  nonNil(journal.Emotions),
+		Pinned:    journal.Pinned,
+	}
+	data, err := json.MarshalIndent(entry, "    ", "  ")
+	if err != nil {
+		return err
+	}
+	if j.count > 0 {
+		j.write(",")
+	}
+	j.write("\n    " + string(data))
+	j.count++
+	return j.err
+}
+
+func (j *jsonWriter) End() error {
+	if j.count > 0 {
+		j.write("\n  ")
+	}
+	j.write(fmt.Sprintf("],\n  \"count\": %d\n}\n", j.count))
+	return j.err
+}
+
+// write writes a string, remembering the first error
+func (j *jsonWriter) write(s string) {
+	if j.err != nil {
+		return
+	}
+	_, j.err = io.WriteString(j.w, s)
+}
+
+// nonNil keeps empty lists as [] instead of null in the output
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
@@ -0,0 +1,62 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"lambda-server/models"
+)
+
+// markdownWriter renders a cover section followed by entries grouped under a heading per day
+type markdownWriter struct {
+	w       io.Writer
+	meta    Meta
+	lastDay string
+	count   int
+	err     error
+}
+
+func (m *markdownWriter) Begin(meta Meta) error {
+	m.meta = meta
+	m.printf("# %s\n\n", meta.Title)
+	if meta.Name != "" {
+		m.printf("**%s**\n\n", meta.Name)
+	}
+	m.printf("%s\n\n", meta.rangeLabel())
+	m.printf("_Exported on %s_\n\n---\n", meta.GeneratedAt.In(meta.location()).Format("2 January 2006"))
+	return m.err
+}
+
+func (m *markdownWriter) Entry(journal models.Journal) error {
+	if journal.Date != m.lastDay {
+		m.printf("\n## %s\n", dayHeading(journal.Date))
+		m.lastDay = journal.Date
+	}
+	m.printf("\n### %s\n\n", entryTitle(journal))
+	m.printf("_%s_\n\n", entryDetails(journal, m.meta.location()))
+	m.printf("%s\n", strings.TrimRight(normalizeNewlines(journal.Content), "\n"))
+	m.count++
+	return m.err
+}
+
+func (m *markdownWriter) End() error {
+	if m.count == 0 {
+		m.printf("\n_No journal entries in this range._\n")
+	}
+	return m.err
+}
+
+// printf writes formatted output, remembering the first error
+func (m *markdownWriter) printf(format string, args ...interface{}) {
+	if m.err != nil {
+		return
+	}
+	_, m.err = fmt.Fprintf(m.w, format, args...)
+}
+
+// normalizeNewlines converts Windows and old Mac line endings to \n
+func normalizeNewlines(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.ReplaceAll(text, "\r", "\n")
+}
@@ -0,0 +1,344 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"lambda-server/models"
+)
+
+// A4 page geometry in points
+const (
+	pdfPageWidth  = 595.28
+	pdfPageHeight = 841.89
+	pdfMargin     = 56.0
+	pdfTextWidth  = pdfPageWidth - 2*pdfMargin
+)
+
+// Fixed object numbers; page objects are allocated from pdfFirstFreeObject on
+const (
+	pdfCatalogObject   = 1
+	pdfPagesObject     = 2
+	pdfRegularFont     = 3
+	pdfBoldFont        = 4
+	pdfFirstFreeObject = 5
+)
+
+// Notes for entries with characters outside Windows-1252, which are drawn as '?'
+const (
+	pdfLossyEntryNote = "Some characters in this entry cannot be shown in PDF and appear as '?'. Export as Markdown or JSON to keep them."
+	pdfLossySummary   = "Entries with characters that cannot be shown in PDF: %d. Those characters appear as '?'; export as Markdown or JSON to keep the full text."
+)
+
+// pdfWriter streams a PDF 1.4 document using the built-in Helvetica fonts, so no font
+// files are needed. Each page is written out as soon as it is full; the page tree and
+// cross-reference table are written at the end.
+type pdfWriter struct {
+	w       *countingWriter
+	offsets map[int]int64
+	nextID  int
+	pageIDs []int
+	meta    Meta
+
+	page    bytes.Buffer // content stream of the current page
+	pageNum int
+	inPage  bool
+	y       float64
+	muted   bool // whether text is currently drawn in gray, carried over page breaks
+
+	lastDay string
+	count   int
+	lossy   int // entries with characters the standard fonts cannot draw
+}
+
+// countingWriter tracks the byte offset needed for the cross-reference table
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
+
+func newPDFWriter(w io.Writer) *pdfWriter {
+	return &pdfWriter{
+		w:       &countingWriter{w: w},
+		offsets: map[int]int64{},
+		nextID:  pdfFirstFreeObject,
+	}
+}
+
+func (p *pdfWriter) Begin(meta Meta) error {
+	p.meta = meta
+	io.WriteString(p.w, "%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	p.object(pdfCatalogObject, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pdfPagesObject))
+	p.object(pdfRegularFont, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	p.object(pdfBoldFont, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	// Cover page
+	p.startPage()
+	p.y = pdfPageHeight * 0.62
+	p.centered(meta.Title, true, 28)
+	p.y -= 30
+	if meta.Name != "" {
+		p.centered(meta.Name, false, 16)
+		p.y -= 24
+	}
+	p.centered(meta.rangeLabel(), false, 12)
+	p.y -= 18
+	p.gray(func() {
+		p.centered("Exported on "+meta.GeneratedAt.In(meta.location()).Format("2 January 2006"), false, 10)
+	})
+	p.finishPage()
+	return p.w.err
+}
+
+func (p *pdfWriter) Entry(journal models.Journal) error {
+	if !p.inPage {
+		p.startPage()
+	}
+	if journal.Date != p.lastDay {
+		p.ensureSpace(60)
+		if p.lastDay != "" {
+			p.y -= 10
+		}
+		p.line(dayHeading(journal.Date), true, 15, 22)
+		p.rule()
+		p.lastDay = journal.Date
+	}
+
+	p.ensureSpace(40)
+	for _, line := range wrapText(entryTitle(journal), true, 12) {
+		p.line(line, true, 12, 16)
+	}
+	p.gray(func() {
+		for _, line := range wrapText(entryDetails(journal, p.meta.location()), false, 9) {
+			p.line(line, false, 9, 13)
+		}
+	})
+	// The standard fonts only cover Windows-1252. Rather than drop other scripts silently,
+	// the entry says so and points at the formats that keep every character.
+	if winAnsiLossy(journal.Title) || winAnsiLossy(journal.Content) || winAnsiLossy(entryDetails(journal, p.meta.location())) {
+		p.lossy++
+		p.gray(func() {
+			for _, line := range wrapText(pdfLossyEntryNote, false, 9) {
+				p.line(line, false, 9, 13)
+			}
+		})
+	}
+	p.y -= 4
+	for _, line := range wrapText(normalizeNewlines(journal.Content), false, 11) {
+		p.line(line, false, 11, 15)
+	}
+	p.y -= 14
+	p.count++
+	return p.w.err
+}
+
+func (p *pdfWriter) End() error {
+	if p.count == 0 {
+		p.startPage()
+		p.line("No journal entries in this range.", false, 11, 15)
+	}
+	if p.lossy > 0 {
+		p.ensureSpace(40)
+		p.y -= 10
+		p.rule()
+		for _, line := range wrapText(fmt.Sprintf(pdfLossySummary, p.lossy), false, 10) {
+			p.line(line, false, 10, 14)
+		}
+	}
+	if p.inPage {
+		p.finishPage()
+	}
+
+	kids := make([]string, len(p.pageIDs))
+	for i, id := range p.pageIDs {
+		kids[i] = fmt.Sprintf("%d 0 R", id)
+	}
+	p.object(pdfPagesObject, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+
+	infoID := p.allocate()
+	p.object(infoID, fmt.Sprintf("<< /Title %s /Producer (MindMuse) >>", pdfString(p.meta.Title)))
+
+	xrefOffset := p.w.n
+	fmt.Fprintf(p.w, "xref\n0 %d\n0000000000 65535 f \n", p.nextID)
+	for id := 1; id < p.nextID; id++ {
+		fmt.Fprintf(p.w, "%010d 00000 n \n", p.offsets[id])
+	}
+	fmt.Fprintf(p.w, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		p.nextID, pdfCatalogObject, infoID, xrefOffset)
+	return p.w.err
+}
+
+// allocate reserves the next free object number
+func (p *pdfWriter) allocate() int {
+	id := p.nextID
+	p.nextID++
+	return id
+}
+
+// object writes an indirect object and records its offset
+func (p *pdfWriter) object(id int, body string) {
+	p.offsets[id] = p.w.n
+	fmt.Fprintf(p.w, "%d 0 obj\n%s\nendobj\n", id, body)
+}
+
+func (p *pdfWriter) startPage() {
+	p.page.Reset()
+	p.pageNum++
+	p.inPage = true
+	p.y = pdfPageHeight - pdfMargin
+	if p.muted {
+		p.page.WriteString("0.4 g\n")
+	}
+}
+
+// finishPage writes the current page's content stream and page object
+func (p *pdfWriter) finishPage() {
+	// The cover page has no number
+	if p.pageNum > 1 {
+		label := toWinAnsi(strconv.Itoa(p.pageNum - 1))
+		x := (pdfPageWidth - textWidth(label, false, 9)) / 2
+		fmt.Fprintf(&p.page, "0.5 g BT /F1 9 Tf %.2f %.2f Td %s Tj ET 0 g\n", x, pdfMargin/2, pdfLiteral(label))
+	}
+
+	contentID := p.allocate()
+	var stream bytes.Buffer
+	fmt.Fprintf(&stream, "<< /Length %d >>\nstream\n", p.page.Len())
+	stream.Write(p.page.Bytes())
+	stream.WriteString("\nendstream")
+	p.object(contentID, stream.String())
+
+	pageID := p.allocate()
+	p.object(pageID, fmt.Sprintf(
+		"<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 %d 0 R /F2 %d 0 R >> >> /Contents %d 0 R >>",
+		pdfPagesObject, pdfPageWidth, pdfPageHeight, pdfRegularFont, pdfBoldFont, contentID))
+	p.pageIDs = append(p.pageIDs, pageID)
+	p.inPage = false
+}
+
+// ensureSpace starts a new page if fewer than height points are left on the current one
+func (p *pdfWriter) ensureSpace(height float64) {
+	if !p.inPage {
+		p.startPage()
+		return
+	}
+	if p.y-height < pdfMargin {
+		p.finishPage()
+		p.startPage()
+	}
+}
+
+// line draws a single line at the left margin and moves down by leading
+func (p *pdfWriter) line(text string, bold bool, size, leading float64) {
+	p.ensureSpace(leading)
+	p.y -= leading
+	p.draw(toWinAnsi(text), bold, size, pdfMargin)
+}
+
+// centered draws a horizontally centered line at the current position
+func (p *pdfWriter) centered(text string, bold bool, size float64) {
+	encoded := toWinAnsi(text)
+	p.draw(encoded, bold, size, (pdfPageWidth-textWidth(encoded, bold, size))/2)
+}
+
+func (p *pdfWriter) draw(text []byte, bold bool, size, x float64) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(&p.page, "BT /%s %.1f Tf %.2f %.2f Td %s Tj ET\n", font, size, x, p.y, pdfLiteral(text))
+}
+
+// rule draws a thin horizontal line under a heading
+func (p *pdfWriter) rule() {
+	p.y -= 6
+	fmt.Fprintf(&p.page, "0.8 G 0.5 w %.2f %.2f m %.2f %.2f l S 0 G\n", pdfMargin, p.y, pdfPageWidth-pdfMargin, p.y)
+	p.y -= 6
+}
+
+// gray draws everything in fn in a muted color
+func (p *pdfWriter) gray(fn func()) {
+	p.muted = true
+	p.page.WriteString("0.4 g\n")
+	fn()
+	p.muted = false
+	p.page.WriteString("0 g\n")
+}
+
+// wrapText splits text into lines that fit the text width, keeping blank lines between paragraphs
+func wrapText(text string, bold bool, size float64) []string {
+	lines := []string{}
+	for _, paragraph := range strings.Split(text, "\n") {
+		words := strings.Fields(paragraph)
+		if len(words) == 0 {
+			lines = append(lines, "")
+			continue
+		}
+		current := ""
+		for _, word := range words {
+			for _, piece := range splitLongWord(word, bold, size) {
+				candidate := piece
+				if current != "" {
+					candidate = current + " " + piece
+				}
+				if current != "" && textWidth(toWinAnsi(candidate), bold, size) > pdfTextWidth {
+					lines = append(lines, current)
+					current = piece
+				} else {
+					current = candidate
+				}
+			}
+		}
+		lines = append(lines, current)
+	}
+	return lines
+}
+
+// splitLongWord breaks a word that is wider than a whole line into line-sized pieces
+func splitLongWord(word string, bold bool, size float64) []string {
+	if textWidth(toWinAnsi(word), bold, size) <= pdfTextWidth {
+		return []string{word}
+	}
+	pieces := []string{}
+	current := []rune{}
+	for _, r := range word {
+		if len(current) > 0 && textWidth(toWinAnsi(string(append(current, r))), bold, size) > pdfTextWidth {
+			pieces = append(pieces, string(current))
+			current = current[:0]
+		}
+		current = append(current, r)
+	}
+	return append(pieces, string(current))
+}
+
+// pdfLiteral encodes WinAnsi bytes as a PDF literal string
+func pdfLiteral(text []byte) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, c := range text {
+		if c == '(' || c == ')' || c == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// pdfString encodes a Go string as a PDF literal string
+func pdfString(text string) string {
+	return pdfLiteral(toWinAnsi(text))
+}
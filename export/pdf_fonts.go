@@ -0,0 +1,86 @@
+package export
+
+// Glyph widths (in 1/1000 em) of the standard Helvetica fonts for the printable ASCII
+// range 0x20-0x7E, taken from the Adobe core font metrics. Other WinAnsi characters
+// use fallbackGlyphWidth, which is close enough for line wrapping.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // space - /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, // 0 - 9
+	278, 278, 584, 584, 584, 556, 1015, // : - @
+	667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, // A - M
+	722, 778, 667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, // N - Z
+	278, 278, 278, 469, 556, 333, // [ - `
+	556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, // a - m
+	556, 556, 556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, // n - z
+	334, 260, 334, 584, // { - ~
+}
+
+var helveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278, // space - /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, // 0 - 9
+	333, 333, 584, 584, 584, 611, 975, // : - @
+	722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, // A - M
+	722, 778, 667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, // N - Z
+	333, 278, 333, 584, 556, 333, // [ - `
+	556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, // a - m
+	611, 611, 611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, // n - z
+	389, 280, 389, 584, // { - ~
+}
+
+const fallbackGlyphWidth = 556
+
+// winAnsiSpecials maps the non-Latin-1 characters of Windows-1252 to their byte codes
+var winAnsiSpecials = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B, 'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// toWinAnsi encodes text for the standard PDF fonts. Characters outside Windows-1252
+// cannot be drawn by these fonts and are replaced with '?'.
+func toWinAnsi(text string) []byte {
+	out := make([]byte, 0, len(text))
+	for _, r := range text {
+		switch {
+		case r == '\t':
+			out = append(out, ' ', ' ', ' ', ' ')
+		case r >= 0x20 && r <= 0x7E, r >= 0xA0 && r <= 0xFF:
+			out = append(out, byte(r))
+		case winAnsiSpecials[r] != 0:
+			out = append(out, winAnsiSpecials[r])
+		case r < 0x20:
+			// Drop control characters
+		default:
+			out = append(out, '?')
+		}
+	}
+	return out
+}
+
+// winAnsiLossy reports whether text has characters toWinAnsi replaces with '?'
+func winAnsiLossy(text string) bool {
+	for _, r := range text {
+		if r > 0xFF && winAnsiSpecials[r] == 0 || r >= 0x7F && r < 0xA0 {
+			return true
+		}
+	}
+	return false
+}
+
+// textWidth returns the width in points of WinAnsi-encoded text at the given font size
+func textWidth(text []byte, bold bool, size float64) float64 {
+	widths := &helveticaWidths
+	if bold {
+		widths = &helveticaBoldWidths
+	}
+	total := 0
+	for _, b := range text {
+		if b >= 0x20 && b <= 0x7E {
+			total += widths[b-0x20]
+		} else {
+			total += fallbackGlyphWidth
+		}
+	}
+	return float64(total) * size / 1000
+}
@@ -0,0 +1,126 @@
+// Package feedback validates users' ratings of AI replies and aggregates them by the persona
+// version and model that produced each reply, for evaluating changes to either
+package feedback
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"lambda-server/models"
+)
+
+// Ratings
+const (
+	RatingUp   = "up"
+	RatingDown = "down"
+)
+
+// Categories of what was wrong with a reply
+const (
+	CategoryUnhelpful  = "unhelpful"
+	CategoryUnsafe     = "unsafe"
+	CategoryInaccurate = "inaccurate"
+)
+
+// Categories lists the feedback categories, in export column order
+var Categories = []string{CategoryUnhelpful, CategoryUnsafe, CategoryInaccurate}
+
+// Validate normalizes a feedback request and checks its rating, category and comment length
+func Validate(req models.MessageFeedbackRequest, maxComment int) (models.MessageFeedbackRequest, error) {
+	req.Rating = strings.ToLower(strings.TrimSpace(req.Rating))
+	req.Category = strings.ToLower(strings.TrimSpace(req.Category))
+	req.Comment = strings.TrimSpace(req.Comment)
+	if req.Rating != RatingUp && req.Rating != RatingDown {
+		return req, fmt.Errorf("rating must be %q or %q", RatingUp, RatingDown)
+	}
+	if req.Category != "" && !slices.Contains(Categories, req.Category) {
+		return req, fmt.Errorf("category must be one of %s", strings.Join(Categories, ", "))
+	}
+	if len([]rune(req.Comment)) > maxComment {
+		return req, fmt.Errorf("comment must be at most %d characters", maxComment)
+	}
+	return req, nil
+}
+
+// Aggregate groups feedback records by persona, persona version and model, ordered by
+// persona, newest version first, then model
+func Aggregate(records []models.FeedbackRecord) []models.FeedbackGroup {
+	type key struct {
+		personaId string
+		version   int
+		model     string
+	}
+	groups := map[key]*models.FeedbackGroup{}
+	for _, record := range records {
+		k := key{record.PersonaId, record.PersonaVersion, record.Model}
+		group, ok := groups[k]
+		if !ok {
+			group = &models.FeedbackGroup{PersonaId: k.personaId, PersonaVersion: k.version, Model: k.model}
+			groups[k] = group
+		}
+		group.Total++
+		if record.Rating == RatingUp {
+			group.Up++
+		} else {
+			group.Down++
+		}
+		if record.Category != "" {
+			if group.Categories == nil {
+				group.Categories = map[string]int{}
+			}
+			group.Categories[record.Category]++
+		}
+		if record.HasComment {
+			group.Comments++
+		}
+	}
+
+	result := make([]models.FeedbackGroup, 0, len(groups))
+	for _, group := range groups {
+		group.HelpfulRate = float64(group.Up) / float64(group.Total)
+		result = append(result, *group)
+	}
+	slices.SortFunc(result, func(a, b models.FeedbackGroup) int {
+		if a.PersonaId != b.PersonaId {
+			return strings.Compare(a.PersonaId, b.PersonaId)
+		}
+		if a.PersonaVersion != b.PersonaVersion {
+			return b.PersonaVersion - a.PersonaVersion
+		}
+		return strings.Compare(a.Model, b.Model)
+	})
+	return result
+}
+
+// WriteCSV writes feedback groups as CSV, one row per group with a column per category
+func WriteCSV(w io.Writer, groups []models.FeedbackGroup) error {
+	out := csv.NewWriter(w)
+	header := []string{"personaId", "personaVersion", "model", "total", "up", "down", "helpfulRate"}
+	header = append(header, Categories...)
+	if err := out.Write(append(header, "comments")); err != nil {
+		return err
+	}
+	for _, group := range groups {
+		row := []string{
+			group.PersonaId,
+			strconv.Itoa(group.PersonaVersion),
+			group.Model,
+			strconv.Itoa(group.Total),
+			strconv.Itoa(group.Up),
+			strconv.Itoa(group.Down),
+			strconv.FormatFloat(group.HelpfulRate, 'f', 4, 64),
+		}
+		for _, category := range Categories {
+			row = append(row, strconv.Itoa(group.Categories[category]))
+		}
+		if err := out.Write(append(row, strconv.Itoa(group.Comments))); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,58 @@
+package feedback
+
+import (
+	"strings"
+	"testing"
+
+	"lambda-server/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	req, err := Validate(models.MessageFeedbackRequest{Rating: " Down ", Category: "UNSAFE", Comment: "  too vague "}, 20)
+	require.NoError(t, err)
+	assert.Equal(t, models.MessageFeedbackRequest{Rating: RatingDown, Category: CategoryUnsafe, Comment: "too vague"}, req)
+
+	_, err = Validate(models.MessageFeedbackRequest{Rating: "meh"}, 20)
+	assert.Error(t, err)
+	_, err = Validate(models.MessageFeedbackRequest{Rating: RatingUp, Category: "rude"}, 20)
+	assert.Error(t, err)
+	_, err = Validate(models.MessageFeedbackRequest{Rating: RatingUp, Comment: strings.Repeat("é", 21)}, 20)
+	assert.Error(t, err)
+}
+
+func TestAggregate(t *testing.T) {
+	records := []models.FeedbackRecord{
+		{PersonaId: "coach", PersonaVersion: 1, Model: "m1", Rating: RatingUp},
+		{PersonaId: "coach", PersonaVersion: 2, Model: "m1", Rating: RatingUp, HasComment: true},
+		{PersonaId: "coach", PersonaVersion: 2, Model: "m1", Rating: RatingDown, Category: CategoryInaccurate},
+		{PersonaId: "coach", PersonaVersion: 2, Model: "m1", Rating: RatingDown, Category: CategoryInaccurate},
+		{PersonaId: "coach", PersonaVersion: 2, Model: "m1", Rating: RatingUp},
+		{PersonaId: "", PersonaVersion: 0, Model: "m2", Rating: RatingDown},
+	}
+
+	groups := Aggregate(records)
+	require.Len(t, groups, 3)
+	assert.Equal(t, "", groups[0].PersonaId)
+	assert.Equal(t, models.FeedbackGroup{
+		PersonaId: "coach", PersonaVersion: 2, Model: "m1",
+		Total: 4, Up: 2, Down: 2, HelpfulRate: 0.5,
+		Categories: map[string]int{CategoryInaccurate: 2}, Comments: 1,
+	}, groups[1])
+	assert.Equal(t, 1, groups[2].PersonaVersion)
+	assert.Equal(t, 1.0, groups[2].HelpfulRate)
+	assert.Empty(t, Aggregate(nil))
+}
+
+func TestWriteCSV(t *testing.T) {
+	var out strings.Builder
+	err := WriteCSV(&out, []models.FeedbackGroup{{
+		PersonaId: "coach", PersonaVersion: 2, Model: "m1", Total: 3, Up: 2, Down: 1, HelpfulRate: 2.0 / 3,
+		Categories: map[string]int{CategoryUnsafe: 1},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, "personaId,personaVersion,model,total,up,down,helpfulRate,unhelpful,unsafe,inaccurate,comments\n"+
+		"coach,2,m1,3,2,1,0.6667,0,1,0,0\n", out.String())
+}
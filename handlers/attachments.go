@@ -0,0 +1,534 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"lambda-server/constants"
+	"lambda-server/database"
+	"lambda-server/models"
+	"lambda-server/storage"
+	"lambda-server/utils"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateJournalAttachment handles POST /journals/:journalId/attachments
+// It registers a pending attachment and returns a signed URL the client uploads the bytes to.
+// The attachment becomes ready once the client completes it with
+// POST /journals/:journalId/attachments/:attachmentId/complete.
+func CreateJournalAttachment(c *gin.Context) {
+	journalId := c.Param(constants.QueryParamJournalId)
+	userId := c.GetString("userId")
+	if journalId == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Missing journalId in path",
+		})
+		return
+	}
+	store, ok := blobStore(c)
+	if !ok {
+		return
+	}
+
+	var req models.AttachmentCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request body",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	kind := attachmentKind(req.ContentType)
+	if kind == "" {
+		c.JSON(http.StatusUnsupportedMediaType, models.ErrorResponse{
+			Error: "Unsupported attachment type",
+		})
+		return
+	}
+	if req.Size <= 0 || req.Size > attachmentMaxBytes(kind) {
+		c.JSON(http.StatusRequestEntityTooLarge, models.ErrorResponse{
+			Error: fmt.Sprintf("Attachment size must be between 1 and %d bytes", attachmentMaxBytes(kind)),
+		})
+		return
+	}
+	ctx := context.Background()
+
+	journal, err := database.GetJournalByID(ctx, userId, journalId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	if journal.DeletedAt != 0 {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: "Cannot attach files to a journal entry in trash",
+		})
+		return
+	}
+	if len(journal.AttachmentIds) >= constants.AttachmentMaxPerJournal {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: fmt.Sprintf("A journal entry can have at most %d attachments", constants.AttachmentMaxPerJournal),
+		})
+		return
+	}
+
+	now := time.Now().Unix()
+	attachmentId := utils.GenerateAttachmentID()
+	attachment := models.Attachment{
+		UserId:       userId,
+		AttachmentId: attachmentId,
+		JournalId:    journalId,
+		Kind:         kind,
+		FileName:     req.FileName,
+		ContentType:  req.ContentType,
+		Size:         req.Size,
+		StorageKey:   attachmentStorageKey(userId, journalId, attachmentId),
+		Status:       constants.AttachmentStatusPending,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	if err := database.CreateAttachment(ctx, journal, attachment); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to create attachment",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	expiry := time.Duration(constants.AttachmentURLExpiryMins) * time.Minute
+	uploadURL, err := store.SignedUploadURL(ctx, attachment.StorageKey, attachment.ContentType, expiry)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to sign upload URL",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.AttachmentResponse{
+		Attachment: attachment,
+		UploadURL:  uploadURL,
+		ExpiresAt:  time.Now().Add(expiry).Unix(),
+		Message:    "Upload the file with a PUT request to uploadUrl using its content type, then complete the attachment",
+	})
+}
+
+// GetJournalAttachments handles GET /journals/:journalId/attachments
+func GetJournalAttachments(c *gin.Context) {
+	journalId := c.Param(constants.QueryParamJournalId)
+	userId := c.GetString("userId")
+	if journalId == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Missing journalId in path",
+		})
+		return
+	}
+	store, ok := blobStore(c)
+	if !ok {
+		return
+	}
+	ctx := context.Background()
+
+	attachments, err := database.GetJournalAttachments(ctx, userId, journalId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to fetch attachments",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	expiry := time.Duration(constants.AttachmentURLExpiryMins) * time.Minute
+	responses := []models.AttachmentResponse{}
+	for _, attachment := range attachments {
+		response := models.AttachmentResponse{Attachment: attachment}
+		if attachment.Status == constants.AttachmentStatusReady {
+			downloadURL, err := store.SignedDownloadURL(ctx, attachment.StorageKey, attachment.ContentType, expiry)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+					Error:   "Failed to sign download URL",
+					Details: err.Error(),
+				})
+				return
+			}
+			response.DownloadURL = downloadURL
+			response.ExpiresAt = time.Now().Add(expiry).Unix()
+		}
+		responses = append(responses, response)
+	}
+
+	c.JSON(http.StatusOK, models.AttachmentListResponse{
+		Attachments: responses,
+		Count:       len(responses),
+	})
+}
+
+// DeleteJournalAttachment handles DELETE /journals/:journalId/attachments/:attachmentId
+func DeleteJournalAttachment(c *gin.Context) {
+	journalId := c.Param(constants.QueryParamJournalId)
+	attachmentId := c.Param(constants.QueryParamAttachmentId)
+	userId := c.GetString("userId")
+	if journalId == "" || attachmentId == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Missing journalId or attachmentId in path",
+		})
+		return
+	}
+	store, ok := blobStore(c)
+	if !ok {
+		return
+	}
+	ctx := context.Background()
+
+	attachment, err := database.GetAttachment(ctx, userId, attachmentId)
+	if err != nil || attachment.JournalId != journalId {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "attachment not found",
+		})
+		return
+	}
+	journal, err := database.GetJournalByID(ctx, userId, journalId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	if err := database.DetachAttachment(ctx, journal, attachmentId); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to delete attachment",
+			Details: err.Error(),
+		})
+		return
+	}
+	if err := store.Delete(ctx, attachment.StorageKey); err != nil {
+		log.Printf("Failed to delete blob %s: %v\n", attachment.StorageKey, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Attachment deleted successfully"})
+}
+
+// CompleteJournalAttachment handles POST /journals/:journalId/attachments/:attachmentId/complete
+// after the client has uploaded the bytes to the signed URL. Uploads to S3 do not pass through
+// the API, so the stored object is checked here against the allowed types and size for the
+// attachment kind; an object that fails the check is deleted and the attachment stays pending.
+func CompleteJournalAttachment(c *gin.Context) {
+	journalId := c.Param(constants.QueryParamJournalId)
+	attachmentId := c.Param(constants.QueryParamAttachmentId)
+	userId := c.GetString("userId")
+	if journalId == "" || attachmentId == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Missing journalId or attachmentId in path",
+		})
+		return
+	}
+	store, ok := blobStore(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	attachment, err := database.GetAttachment(ctx, userId, attachmentId)
+	if err != nil || attachment.JournalId != journalId {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "attachment not found"})
+		return
+	}
+	// The local store checks uploads as they arrive, so they are already ready
+	if attachment.Status == constants.AttachmentStatusReady {
+		c.JSON(http.StatusOK, models.AttachmentResponse{
+			Attachment: *attachment,
+			Message:    "Attachment uploaded successfully",
+		})
+		return
+	}
+
+	blob, err := store.Get(ctx, attachment.StorageKey)
+	if errors.Is(err, storage.ErrBlobNotFound) {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Upload the file to uploadUrl before completing the attachment"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to read upload", Details: err.Error()})
+		return
+	}
+	limit := attachmentMaxBytes(attachment.Kind)
+	data, err := io.ReadAll(io.LimitReader(blob, limit+1))
+	blob.Close()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to read upload", Details: err.Error()})
+		return
+	}
+	if int64(len(data)) > limit {
+		rejectAttachmentUpload(ctx, store, attachment)
+		c.JSON(http.StatusRequestEntityTooLarge, models.ErrorResponse{
+			Error: fmt.Sprintf("Attachment is larger than %d bytes", limit),
+		})
+		return
+	}
+
+	finishAttachmentUpload(c, store, attachment, data, true)
+}
+
+// UploadAttachmentBlob handles PUT /attachments/blob with a signed upload URL of the local store.
+// The same URLs take import archives, whose keys start with imports/.
+func UploadAttachmentBlob(c *gin.Context) {
+	store, ok := blobStore(c)
+	if !ok {
+		return
+	}
+	key, ok := verifyBlobURL(c, store, storage.OpUpload)
+	if !ok {
+		return
+	}
+	if strings.HasPrefix(key, constants.ImportArchivePrefix) {
+		uploadImportArchive(c, store, key)
+		return
+	}
+	userId, attachmentId, ok := parseAttachmentStorageKey(key)
+	if !ok {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid attachment key"})
+		return
+	}
+	ctx := c.Request.Context()
+
+	attachment, err := database.GetAttachment(ctx, userId, attachmentId)
+	if err != nil || attachment.StorageKey != key {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "attachment not found"})
+		return
+	}
+	if attachment.Status != constants.AttachmentStatusPending {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Attachment has already been uploaded"})
+		return
+	}
+
+	limit := attachmentMaxBytes(attachment.Kind)
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, limit))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, models.ErrorResponse{
+				Error: fmt.Sprintf("Attachment is larger than %d bytes", limit),
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Failed to read upload", Details: err.Error()})
+		return
+	}
+
+	finishAttachmentUpload(c, store, attachment, data, false)
+}
+
+// finishAttachmentUpload checks the uploaded bytes against the allowed types for the attachment
+// kind, strips location metadata from images, stores the result unless it is already stored as
+// is, and marks the attachment ready. Rejected bytes that are already stored are deleted.
+func finishAttachmentUpload(c *gin.Context, store storage.BlobStore, attachment *models.Attachment, data []byte, stored bool) {
+	ctx := c.Request.Context()
+	if len(data) == 0 {
+		if stored {
+			rejectAttachmentUpload(ctx, store, attachment)
+		}
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Upload is empty"})
+		return
+	}
+
+	// Trust the bytes, not the declared content type
+	detected := mimetype.Detect(data)
+	contentType := allowedAttachmentType(attachment.Kind, detected)
+	if contentType == "" {
+		if stored {
+			rejectAttachmentUpload(ctx, store, attachment)
+		}
+		c.JSON(http.StatusUnsupportedMediaType, models.ErrorResponse{
+			Error:   "Uploaded file type is not allowed",
+			Details: detected.String(),
+		})
+		return
+	}
+
+	if attachment.Kind == constants.AttachmentKindPhoto {
+		var err error
+		data, err = utils.StripImageLocation(data, contentType)
+		if err != nil {
+			if stored {
+				rejectAttachmentUpload(ctx, store, attachment)
+			}
+			c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{Error: "Failed to process image", Details: err.Error()})
+			return
+		}
+		stored = false
+	}
+
+	if !stored {
+		if err := store.Put(ctx, attachment.StorageKey, bytes.NewReader(data)); err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to store attachment", Details: err.Error()})
+			return
+		}
+	}
+	if err := database.MarkAttachmentReady(ctx, attachment.UserId, attachment.AttachmentId, contentType, int64(len(data))); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to update attachment", Details: err.Error()})
+		return
+	}
+
+	attachment.Status = constants.AttachmentStatusReady
+	attachment.ContentType = contentType
+	attachment.Size = int64(len(data))
+	c.JSON(http.StatusOK, models.AttachmentResponse{
+		Attachment: *attachment,
+		Message:    "Attachment uploaded successfully",
+	})
+}
+
+// rejectAttachmentUpload deletes stored bytes that failed the checks so the client can upload again
+func rejectAttachmentUpload(ctx context.Context, store storage.BlobStore, attachment *models.Attachment) {
+	if err := store.Delete(ctx, attachment.StorageKey); err != nil {
+		log.Printf("Failed to delete rejected blob %s: %v\n", attachment.StorageKey, err)
+	}
+}
+
+// DownloadAttachmentBlob handles GET /attachments/blob with a signed download URL of the local store
+func DownloadAttachmentBlob(c *gin.Context) {
+	store, ok := blobStore(c)
+	if !ok {
+		return
+	}
+	key, ok := verifyBlobURL(c, store, storage.OpDownload)
+	if !ok {
+		return
+	}
+	userId, attachmentId, ok := parseAttachmentStorageKey(key)
+	if !ok {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid attachment key"})
+		return
+	}
+	ctx := c.Request.Context()
+
+	attachment, err := database.GetAttachment(ctx, userId, attachmentId)
+	if err != nil || attachment.StorageKey != key || attachment.Status != constants.AttachmentStatusReady {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "attachment not found"})
+		return
+	}
+
+	blob, err := store.Get(ctx, key)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "attachment not found"})
+		return
+	}
+	defer blob.Close()
+
+	c.Header("Cache-Control", "private, max-age=300")
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, blob, nil)
+}
+
+// DeleteJournalAttachments removes every attachment (records and blobs) of a journal entry.
+// It is called when the entry is permanently deleted or purged from trash.
+func DeleteJournalAttachments(ctx context.Context, userId string, journalId string) error {
+	attachments, err := database.GetJournalAttachments(ctx, userId, journalId)
+	if err != nil {
+		return err
+	}
+	if len(attachments) == 0 {
+		return nil
+	}
+	store, err := storage.Default()
+	if err != nil {
+		return err
+	}
+	for _, attachment := range attachments {
+		if err := store.Delete(ctx, attachment.StorageKey); err != nil {
+			return err
+		}
+		if err := database.DeleteAttachmentRecord(ctx, userId, attachment.AttachmentId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blobStore returns the default blob store, answering 503 when it is not configured
+func blobStore(c *gin.Context) (storage.BlobStore, bool) {
+	store, err := storage.Default()
+	if err != nil {
+		log.Printf("Blob store unavailable: %v\n", err)
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+			Error: "File storage is not available",
+		})
+		return nil, false
+	}
+	return store, true
+}
+
+// verifyBlobURL checks the signature of a blob URL for the expected operation and returns its key
+func verifyBlobURL(c *gin.Context, store storage.BlobStore, op string) (string, bool) {
+	key := c.Query(constants.QueryParamBlobKey)
+	expires, err := strconv.ParseInt(c.Query(constants.QueryParamBlobExpires), 10, 64)
+	if key == "" || err != nil || c.Query(constants.QueryParamBlobOp) != op {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid signed URL"})
+		return "", false
+	}
+
+	verifier, ok := store.(storage.SignatureVerifier)
+	if !ok {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Blob store does not serve signed URLs"})
+		return "", false
+	}
+	if err := verifier.VerifySignature(op, key, expires, c.Query(constants.QueryParamBlobSignature)); err != nil {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "Invalid signed URL", Details: err.Error()})
+		return "", false
+	}
+	return key, true
+}
+
+// attachmentKind maps a declared content type to an attachment kind, or "" if it is not allowed
+func attachmentKind(contentType string) string {
+	for kind, allowed := range constants.AttachmentContentTypes {
+		if mimetype.EqualsAny(contentType, allowed...) {
+			return kind
+		}
+	}
+	return ""
+}
+
+// allowedAttachmentType returns the allowed MIME type matching the detected one, or "" if none does
+func allowedAttachmentType(kind string, detected *mimetype.MIME) string {
+	for _, allowed := range constants.AttachmentContentTypes[kind] {
+		if detected.Is(allowed) {
+			return allowed
+		}
+	}
+	return ""
+}
+
+// attachmentMaxBytes returns the size limit for an attachment kind
+func attachmentMaxBytes(kind string) int64 {
+	if kind == constants.AttachmentKindVoice {
+		return constants.AttachmentMaxVoiceBytes
+	}
+	return constants.AttachmentMaxPhotoBytes
+}
+
+// attachmentStorageKey builds the blob key of an attachment: userId/journalId/attachmentId
+func attachmentStorageKey(userId, journalId, attachmentId string) string {
+	return userId + "/" + journalId + "/" + attachmentId
+}
+
+// parseAttachmentStorageKey extracts the userId and attachmentId from a blob key
+func parseAttachmentStorageKey(key string) (string, string, bool) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[2], true
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"time"
 
+	"lambda-server/constants"
 	"lambda-server/helpers"
 	"lambda-server/models"
 	"lambda-server/utils"
@@ -97,6 +98,14 @@ func HandleRegister(c *gin.Context) {
 			})
 			return
 		}
+		timeZone, locale, prefErr := registrationPreferences(c, authReq.Credentials)
+		if prefErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": prefErr.Error(),
+			})
+			return
+		}
 		user, err = registerEmail(
 			authReq.Credentials["email"],
 			authReq.Credentials["password"],
@@ -104,6 +113,8 @@ func HandleRegister(c *gin.Context) {
 			authReq.Credentials["phone"],
 			authReq.Credentials["countryCode"],
 			dob,
+			timeZone,
+			locale,
 		)
 	default:
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -255,6 +266,31 @@ func UpdateCurrentUser(c *gin.Context) {
 		u.Dob = *req.Dob
 		updated = true
 	}
+	if req.TimeZone != nil {
+		if err := utils.ValidateTimeZone(*req.TimeZone); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		u.TimeZone = *req.TimeZone
+		updated = true
+	}
+	if req.Locale != nil {
+		locale, err := utils.NormalizeLocale(*req.Locale)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		u.Locale = locale
+		updated = true
+	}
+	if req.NotifyContactsOnRisk != nil {
+		u.NotifyContactsOnRisk = *req.NotifyContactsOnRisk
+		updated = true
+	}
+	if req.ChatUsesJournals != nil {
+		u.ChatUsesJournals = *req.ChatUsesJournals
+		updated = true
+	}
 
 	if req.Password != nil {
 		hash, err := bcrypt.GenerateFromPassword([]byte(*req.Password), bcrypt.DefaultCost)
@@ -364,7 +400,7 @@ func authenticateEmail(email, password string) (*models.User, error) {
 }
 
 // registerEmail creates a new user with email/password
-func registerEmail(email, password, name, phone, countryCode, dob string) (*models.User, error) {
+func registerEmail(email, password, name, phone, countryCode, dob, timeZone, locale string) (*models.User, error) {
 	// Check if user already exists
 	if _, err := helpers.GetUserByEmail(email); err == nil {
 		return nil, errors.New("user already exists with this email")
@@ -384,6 +420,8 @@ func registerEmail(email, password, name, phone, countryCode, dob string) (*mode
 		Phone:           phone,
 		CountryCode:     countryCode,
 		Dob:             dob,
+		TimeZone:        timeZone,
+		Locale:          locale,
 		PasswordHash:    string(hashedPassword),
 		AuthMethods:     []string{"email"},
 		IsEmailVerified: false, // In production, send verification email
@@ -400,6 +438,30 @@ func registerEmail(email, password, name, phone, countryCode, dob string) (*mode
 	return user, nil
 }
 
+// registrationPreferences returns the time zone and locale for a new account. Values sent in the
+// credentials must be valid; otherwise they are taken from the client's headers when those are valid.
+func registrationPreferences(c *gin.Context, credentials map[string]string) (string, string, error) {
+	timeZone := credentials["timeZone"]
+	if timeZone != "" {
+		if err := utils.ValidateTimeZone(timeZone); err != nil {
+			return "", "", err
+		}
+	} else if header := c.GetHeader(constants.HeaderTimezone); utils.ValidateTimeZone(header) == nil {
+		timeZone = header
+	}
+
+	locale := credentials["locale"]
+	if locale != "" {
+		normalized, err := utils.NormalizeLocale(locale)
+		if err != nil {
+			return "", "", err
+		}
+		locale = normalized
+	} else if header, err := utils.NormalizeLocale(utils.PreferredLocale(c.GetHeader("Accept-Language"))); err == nil {
+		locale = header
+	}
+	return timeZone, locale, nil
+}
 
 func removeSensitiveInformationFromUser(user *models.User) {
 	user.PasswordHash = ""
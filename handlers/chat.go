@@ -1,139 +1,137 @@
 package handlers
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
+	"log"
 	"net/http"
-	"os"
-	"time"
+	"sync"
 
-	"lambda-server/helpers"
+	"lambda-server/llm"
 	"lambda-server/models"
 
 	"github.com/gin-gonic/gin"
 )
 
-// Placeholder for Hugging Face API key
-var HuggingFaceAPIKey = os.Getenv("HUGGINGFACE_API_KEY")
-
 // ChatRequest represents the incoming chat request from frontend
 // Includes userId, sessionId, and the user's message
 type ChatRequest struct {
 	UserId    string `json:"userId" binding:"required"`
 	SessionId string `json:"sessionId" binding:"required"`
 	Message   string `json:"message" binding:"required"`
+	Model     string `json:"model,omitempty"`     // Optional; must be the default model or listed in LLM_ALLOWED_MODELS
+	PersonaId string `json:"personaId,omitempty"` // Optional; switches the session to this persona
 }
 
 type ChatResponse struct {
-	AIResponse string `json:"aiResponse"`
+	AIResponse      string                     `json:"aiResponse"`
+	Timestamp       int64                      `json:"timestamp,omitempty"`       // Timestamp of the stored reply; unset when it was not stored
+	MessageId       string                     `json:"messageId,omitempty"`       // ID of the stored reply, for rating it; unset when it was not stored
+	Risk            *models.RiskAssessment     `json:"risk,omitempty"`            // Set when the message suggests a risk of suicide or self-harm
+	CrisisResources *models.CrisisResources    `json:"crisisResources,omitempty"` // Services to show alongside Risk
+	Moderation      *models.ModerationDecision `json:"moderation,omitempty"`      // Set when moderation changed the reply
+	Citations       []models.Citation          `json:"citations,omitempty"`       // Passages of the user's journals and earlier conversations the reply may cite as [n]
+	Degraded        bool                       `json:"degraded,omitempty"`        // The AI was unavailable and AIResponse is a canned reply; nothing was stored
+}
+
+// cannedChatReply is sent when no LLM provider can answer, so the user gets a kind reply
+// instead of an error
+const cannedChatReply = "I'm sorry, I'm having trouble responding right now. What you're going through matters, " +
+	"so please try again in a little while. If you need to talk to someone now, reaching out to someone you trust " +
+	"or a local helpline can help."
+
+// The chat LLM provider, created from the LLM_* environment variables on first use
+var (
+	chatLLMOnce   sync.Once
+	chatLLM       llm.LLMProvider
+	chatLLMConfig llm.Config
+	chatLLMErr    error
+)
+
+// chatProvider returns the configured chat LLM provider and its configuration
+func chatProvider() (llm.LLMProvider, llm.Config, error) {
+	chatLLMOnce.Do(func() {
+		if chatLLM != nil {
+			return // set by SetChatProvider
+		}
+		chatLLMConfig = llm.ConfigFromEnv()
+		chatLLM, chatLLMErr = llm.NewResilient(chatLLMConfig)
+	})
+	return chatLLM, chatLLMConfig, chatLLMErr
+}
+
+// SetChatProvider replaces the chat LLM provider, for example with llm.NewMockProvider in tests
+func SetChatProvider(provider llm.LLMProvider, cfg llm.Config) {
+	chatLLMOnce.Do(func() {})
+	chatLLM, chatLLMConfig, chatLLMErr = provider, cfg, nil
 }
 
 // HandleChat handles the chat POST endpoint
+// A request retried with the same Idempotency-Key header gets the original response instead
+// of a new reply; canned replies, replies that could not be stored and errors are not replayed.
 func HandleChat(c *gin.Context) {
 	var req ChatRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
 		return
 	}
-
-	// Fetch last N messages for context (e.g., last 10)
-	const contextLimit = 10
-	chatHistory, err := helpers.GetChatHistoryBySession(req.UserId, req.SessionId, contextLimit)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch chat history", "details": err.Error()})
+	if err := checkChatUser(c, req); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden", "details": err.Error()})
 		return
 	}
-
-	// Build prompt for Hugging Face (append all previous messages)
-	prompt := ""
-	for _, msg := range chatHistory {
-		if msg.Sender == "user" {
-			prompt += "User: " + msg.Message + "\n"
-		} else {
-			prompt += "AI: " + msg.Message + "\n"
-		}
-	}
-	prompt += "User: " + req.Message + "\nAI:"
-
-	// Call Hugging Face API
-	aiResponse, err := callHuggingFaceAPI(prompt)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get AI response", "details": err.Error()})
+	claim, replay, ok := claimIdempotency(c, req.UserId, req)
+	if !ok {
 		return
 	}
-
-	timestamp := time.Now().Unix()
-	// Store user message
-	userMsg := &models.ChatMessage{
-		UserId:    req.UserId,
-		SessionId: req.SessionId,
-		Timestamp: timestamp,
-		Sender:    "user",
-		Message:   req.Message,
-	}
-	helpers.StoreChatMessage(userMsg)
-
-	// Store AI response
-	aiMsg := &models.ChatMessage{
-		UserId:    req.UserId,
-		SessionId: req.SessionId,
-		Timestamp: timestamp + 1, // ensure ordering
-		Sender:    "ai",
-		Message:   aiResponse,
-	}
-	helpers.StoreChatMessage(aiMsg)
-
-	c.JSON(http.StatusOK, ChatResponse{AIResponse: aiResponse})
-}
-
-// callHuggingFaceAPI sends the prompt to Hugging Face and returns the AI's response
-func callHuggingFaceAPI(prompt string) (string, error) {
-	apiURL := "https://router.huggingface.co/v1/chat/completions" // Inference Providers router endpoint
-
-	// Prepare messages array for chat format
-	messages := []map[string]string{
-		{"role": "user", "content": prompt},
-	}
-	body, _ := json.Marshal(map[string]interface{}{
-		"messages": messages,
-		"model":    "moonshotai/Kimi-K2-Instruct:novita",
-	})
-
-	req, err := http.NewRequestWithContext(context.Background(), "POST", apiURL, ioutil.NopCloser(bytes.NewReader(body)))
-	if err != nil {
-		return "", err
+	if replay != nil {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", replay)
+		return
 	}
-	req.Header.Set("Authorization", "Bearer "+HuggingFaceAPIKey)
-	req.Header.Set("Content-Type", "application/json")
+	defer claim.release()
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
+	turn, turnErr := prepareChatTurn(c.Request.Context(), req, riskUser(c, req.UserId))
+	if turnErr != nil {
+		c.JSON(turnErr.status, gin.H{"error": turnErr.message, "details": turnErr.err.Error()})
+		return
 	}
-	defer resp.Body.Close()
+	provider, model, chat := turn.provider, turn.model, turn.chat
+	check, escalated := chat.checkRisk(c.Request.Context(), requestLocale(c), req)
+	defer func() { <-escalated }()
 
-	respBody, err := ioutil.ReadAll(resp.Body)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), turn.cfg.Timeout)
+	defer cancel()
+	completion, err := provider.Complete(ctx, chat.request(model))
 	if err != nil {
-		return "", err
+		log.Printf("Chat reply for session %s failed, sending the canned reply: %v\n", req.SessionId, err)
+		c.JSON(http.StatusOK, ChatResponse{
+			AIResponse:      cannedChatReply,
+			Risk:            check.responseRisk(),
+			CrisisResources: check.resources,
+			Degraded:        true,
+		})
+		return
 	}
-
-	// Debug: print raw Hugging Face response
-	fmt.Println("Hugging Face raw response:", string(respBody))
-
-	// Parse Inference Providers response
-	var hfResp struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
+	aiResponse := chat.moderate(req, completion.Content)
+	chat.setUsage(completion, completion.Content)
+	chat.setModel(completion, model)
+	recordChatUsage(req, chat)
+	summarizeOverflowInBackground(provider, model, req, chat)
+
+	resp := ChatResponse{
+		AIResponse:      aiResponse,
+		Risk:            check.responseRisk(),
+		CrisisResources: check.resources,
+		Moderation:      chat.moderated(),
+		Citations:       chat.citations,
 	}
-	if err := json.Unmarshal(respBody, &hfResp); err == nil && len(hfResp.Choices) > 0 {
-		return hfResp.Choices[0].Message.Content, nil
+	// The reply is sent even if it could not be stored; the missing messageId tells the client.
+	// Only a stored reply is replayed, so a retry of one that was not gets the key released and
+	// stores the exchange.
+	if aiMsg, err := storeChatExchange(req, chat, turn.sentAt, aiResponse, false); err != nil {
+		log.Printf("Failed to store chat exchange for session %s: %v\n", req.SessionId, err)
+	} else {
+		resp.Timestamp, resp.MessageId = aiMsg.Timestamp, aiMsg.MessageId
+		recordChatActivity(provider, model, req, chat, aiMsg.Timestamp, aiResponse)
+		claim.complete(resp)
 	}
-	return "", os.ErrInvalid
+	c.JSON(http.StatusOK, resp)
 }
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"lambda-server/chatcontext"
+	"lambda-server/constants"
+	"lambda-server/database"
+	"lambda-server/helpers"
+	"lambda-server/llm"
+	"lambda-server/models"
+	"lambda-server/personas"
+	"lambda-server/retrieval"
+	"lambda-server/utils"
+)
+
+// summaryTimeout bounds a background summarization of a session
+const summaryTimeout = time.Minute
+
+// chatContext is the prompt for a chat turn and the history that no longer fits in it
+type chatContext struct {
+	messages  []llm.Message
+	user      *models.User         // The user chatting; nil if they could not be loaded
+	session   *models.ChatSession  // Stored session, nil before its first exchange
+	citations []models.Citation    // Passages retrieved into the prompt, numbered as the reply cites them
+	persona   models.Persona       // Persona the prompt was built from
+	summary   string               // Summary stored for the session
+	overflow  []models.ChatMessage // Oldest unsummarized messages left out of Messages, in order from the summary cursor
+
+	moderation    *models.ModerationDecision // Set by moderate once the reply is complete
+	moderatedFrom string                     // The reply before a block or disclaimer, for the review log
+	usage         *models.TokenUsage         // Set by setUsage once the reply is complete
+	model         string                     // Set by setModel once the reply is complete
+}
+
+// prepareChatContext builds the prompt for a new message: the persona's system prompt, the
+// session's running summary, passages retrieved from the user's journals and earlier
+// conversations when they opted in, and the newest unsummarized messages that fit the token
+// budget. A persona picked in the request is remembered for the rest of the session.
+func prepareChatContext(ctx context.Context, cfg llm.Config, req ChatRequest, user *models.User) (*chatContext, error) {
+	session, err := database.GetChatSession(ctx, req.UserId, req.SessionId)
+	if err != nil {
+		return nil, err
+	}
+	persona, err := resolveChatPersona(ctx, req.PersonaId, session)
+	if err != nil {
+		return nil, err
+	}
+	if req.PersonaId != "" && (session == nil || session.PersonaId != req.PersonaId) {
+		if err := database.SetChatSessionPersona(ctx, req.UserId, req.SessionId, req.PersonaId); err != nil {
+			return nil, err
+		}
+	}
+	var summary, cursor string
+	if session != nil {
+		summary, cursor = session.Summary, session.SummarizedThroughKey()
+	}
+
+	history, err := helpers.GetChatHistorySince(req.UserId, req.SessionId, cursor, constants.ChatHistoryFetchLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch chat history: %w", err)
+	}
+
+	var memory []retrieval.Result
+	if user != nil && user.ChatUsesJournals {
+		memory = retrieveMemory(ctx, req)
+	}
+
+	budget := cfg.ContextTokens
+	if budget <= 0 {
+		budget = llm.DefaultContextTokens
+	}
+	window := chatcontext.Build(chatcontext.Input{
+		System:   personas.SystemPrompt(*persona),
+		Summary:  summary,
+		Memory:   retrieval.Prompt(memory),
+		History:  chatHistoryMessages(history),
+		Message:  req.Message,
+		Budget:   budget,
+		MaxTurns: constants.ChatContextMaxTurns,
+	})
+	overflow := history[:len(window.Overflow)]
+	if int32(len(history)) == constants.ChatHistoryFetchLimit {
+		// Older unsummarized messages may not have been read; they are summarized first
+		overflow, err = oldestChatOverflow(req, cursor, history[len(window.Overflow):])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &chatContext{
+		messages:  window.Messages,
+		user:      user,
+		session:   session,
+		citations: retrieval.Citations(memory, constants.RetrievalSnippetLen),
+		persona:   *persona,
+		summary:   summary,
+		overflow:  overflow,
+	}, nil
+}
+
+// oldestChatOverflow reads the oldest messages after the summary cursor that come before the
+// ones kept in the context window, so the summary catches up from where it stopped in order
+func oldestChatOverflow(req ChatRequest, cursor string, kept []models.ChatMessage) ([]models.ChatMessage, error) {
+	oldest, err := helpers.GetOldestChatHistorySince(req.UserId, req.SessionId, cursor, constants.ChatHistoryFetchLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch chat history: %w", err)
+	}
+	overflow := []models.ChatMessage{}
+	for _, msg := range oldest {
+		if len(kept) > 0 && msg.SessionIdTimestamp >= kept[0].SessionIdTimestamp {
+			break
+		}
+		overflow = append(overflow, msg)
+	}
+	return overflow, nil
+}
+
+// summarizeOverflowInBackground folds the messages that fell out of the context window into
+// the session's running summary without holding up the response. If it fails, the same
+// messages overflow again on the next request and are retried then.
+func summarizeOverflowInBackground(provider llm.LLMProvider, model string, req ChatRequest, chat *chatContext) {
+	if len(chat.overflow) == 0 {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), summaryTimeout)
+		defer cancel()
+		if err := summarizeOverflow(ctx, provider, model, req, chat); err != nil {
+			log.Printf("Summarizing chat session %s failed: %v\n", req.SessionId, err)
+		}
+	}()
+}
+
+func summarizeOverflow(ctx context.Context, provider llm.LLMProvider, model string, req ChatRequest, chat *chatContext) error {
+	completion, err := provider.Complete(ctx, llm.Request{
+		Model:    model,
+		Messages: chatcontext.SummaryMessages(chat.summary, chatHistoryMessages(chat.overflow)),
+	})
+	if err != nil {
+		return err
+	}
+	summary := strings.TrimSpace(completion.Content)
+	if summary == "" {
+		return fmt.Errorf("empty summary")
+	}
+	through := chat.overflow[len(chat.overflow)-1].SessionIdTimestamp
+	_, err = database.SaveChatSummary(ctx, req.UserId, req.SessionId, summary, through)
+	return err
+}
+
+// request builds the LLM request for the turn, applying the persona's reply style
+func (chat *chatContext) request(model string) llm.Request {
+	return llm.Request{
+		Model:       model,
+		Messages:    chat.messages,
+		MaxTokens:   chat.persona.Style.MaxTokens,
+		Temperature: chat.persona.Style.Temperature,
+	}
+}
+
+// chatMessage builds a message of the exchange with a new ID, stamped with the persona version that produced it.
+// User messages also record the user's time zone, which picks the day their sentiment counts towards.
+func (chat *chatContext) chatMessage(req ChatRequest, sentAt time.Time, sender, text string) *models.ChatMessage {
+	id, timestamp := utils.NewChatMessageID(sentAt)
+	msg := &models.ChatMessage{
+		UserId:         req.UserId,
+		SessionId:      req.SessionId,
+		Timestamp:      timestamp,
+		MessageId:      id,
+		Sender:         sender,
+		Message:        text,
+		PersonaId:      chat.persona.PersonaId,
+		PersonaVersion: chat.persona.Version,
+	}
+	if sender == constants.ChatSenderUser {
+		// Queued for the analyze-sentiment job in case the background analysis misses it
+		msg.SentimentPending = constants.SentimentPendingValue
+		msg.TimeZone = utils.UserLocation(chat.user, "").String()
+	}
+	return msg
+}
+
+// checkRisk checks the user's message for risk before the reply is generated. At medium risk
+// and above the model is told to put the user's safety first, and a high-risk message is
+// escalated alongside the request. The handler must wait on the returned channel before it returns.
+func (chat *chatContext) checkRisk(ctx context.Context, locale string, req ChatRequest) (riskCheck, <-chan struct{}) {
+	user := chat.user
+	check := checkRisk(ctx, user, locale, req.Message)
+	if check.resources != nil {
+		last := len(chat.messages) - 1
+		message := chat.messages[last]
+		chat.messages = append(chat.messages[:last], safetyNote(check), message)
+	}
+	return check, escalateRiskAsync(user, req.UserId, constants.SOSSourceChat, req.SessionId, check)
+}
+
+// chatHistoryMessages maps stored chat messages to LLM messages
+func chatHistoryMessages(chatHistory []models.ChatMessage) []llm.Message {
+	messages := make([]llm.Message, 0, len(chatHistory))
+	for _, msg := range chatHistory {
+		role := llm.RoleAssistant
+		if msg.Sender == constants.ChatSenderUser {
+			role = llm.RoleUser
+		}
+		messages = append(messages, llm.Message{Role: role, Content: msg.Message})
+	}
+	return messages
+}
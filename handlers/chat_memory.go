@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"lambda-server/constants"
+	"lambda-server/database"
+	"lambda-server/models"
+	"lambda-server/retrieval"
+)
+
+// memoryCache keeps each user's retrieval index between messages on this instance
+var memoryCache = retrieval.NewCache(time.Duration(constants.RetrievalCacheMinutes) * time.Minute)
+
+// retrieveMemory finds the passages of the user's journals and earlier conversations most
+// relevant to the message. Chat goes ahead without them if retrieval fails or is slow.
+func retrieveMemory(ctx context.Context, req ChatRequest) []retrieval.Result {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(constants.RetrievalTimeoutMillis)*time.Millisecond)
+	defer cancel()
+	index, err := memoryCache.Get(req.UserId, func() (*retrieval.Index, error) {
+		return buildMemoryIndex(ctx, req.UserId)
+	})
+	if err != nil {
+		log.Printf("Retrieval for user %s failed, chatting without it: %v\n", req.UserId, err)
+		return nil
+	}
+	// Extra candidates make up for passages dropped below
+	candidates := index.Search(req.Message, 2*constants.RetrievalTopK)
+	results, stale, err := currentPassages(ctx, req, candidates)
+	if stale {
+		memoryCache.Forget(req.UserId)
+	}
+	if err != nil {
+		log.Printf("Retrieval for user %s failed, chatting without it: %v\n", req.UserId, err)
+		return nil
+	}
+	if len(results) > constants.RetrievalTopK {
+		results = results[:constants.RetrievalTopK]
+	}
+	return results
+}
+
+// buildMemoryIndex indexes the user's newest journal entries that are not excluded from chat,
+// and the summaries of their chat sessions
+func buildMemoryIndex(ctx context.Context, userId string) (*retrieval.Index, error) {
+	journals := []models.Journal{}
+	err := database.IterateUserJournals(ctx, userId, 0, time.Now().Unix(), func(journal models.Journal) error {
+		if journal.ExcludeFromChat {
+			return nil
+		}
+		journals = append(journals, journal)
+		if len(journals) > constants.RetrievalMaxJournals {
+			journals = journals[1:]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := database.GetChatSessionSummaries(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	passages := []retrieval.Passage{}
+	for _, journal := range journals {
+		passages = append(passages, retrieval.JournalPassages(journal, constants.RetrievalPassageWords)...)
+	}
+	for _, session := range sessions {
+		passages = append(passages, retrieval.SessionPassage(session))
+	}
+	return retrieval.NewIndex(passages), nil
+}
+
+// currentPassages drops candidates the index should no longer hold: the session being chatted
+// in, whose history is already in the prompt, and journal entries deleted, trashed or excluded
+// from chat since the index was built. stale reports whether the index is out of date.
+func currentPassages(ctx context.Context, req ChatRequest, candidates []retrieval.Result) (results []retrieval.Result, stale bool, err error) {
+	allowed := map[string]bool{}
+	for _, candidate := range candidates {
+		passage := candidate.Passage
+		if passage.Source == retrieval.SourceChat {
+			if passage.SessionId != req.SessionId {
+				results = append(results, candidate)
+			}
+			continue
+		}
+		ok, checked := allowed[passage.JournalId]
+		if !checked {
+			journal, err := database.GetJournalByID(ctx, req.UserId, passage.JournalId)
+			if err != nil && !errors.Is(err, database.ErrJournalNotFound) {
+				return nil, stale, err
+			}
+			ok = err == nil && journal.DeletedAt == 0 && !journal.ExcludeFromChat
+			allowed[passage.JournalId] = ok
+			stale = stale || !ok
+		}
+		if ok {
+			results = append(results, candidate)
+		}
+	}
+	return results, stale, nil
+}
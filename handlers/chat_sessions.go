@@ -0,0 +1,345 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"lambda-server/chatcontext"
+	"lambda-server/constants"
+	"lambda-server/database"
+	"lambda-server/llm"
+	"lambda-server/models"
+	"lambda-server/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// titleTimeout bounds a background title generation for a session
+const titleTimeout = 30 * time.Second
+
+// CreateChatSession handles POST /chat/sessions
+func CreateChatSession(c *gin.Context) {
+	var req models.ChatSessionCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request body",
+			Details: err.Error(),
+		})
+		return
+	}
+	userId := c.GetString("userId")
+	title, err := validateChatSessionTitle(req.Title, true)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid title",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	ctx := context.Background()
+	if req.PersonaId != "" {
+		if _, err := resolveChatPersona(ctx, req.PersonaId, nil); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, errPersonaUnavailable) {
+				status = http.StatusBadRequest
+			}
+			c.JSON(status, models.ErrorResponse{
+				Error:   "Invalid persona",
+				Details: err.Error(),
+			})
+			return
+		}
+	}
+
+	now := time.Now().Unix()
+	session := models.ChatSession{
+		UserId:        userId,
+		SessionId:     utils.GenerateChatSessionID(),
+		Title:         title,
+		PersonaId:     req.PersonaId,
+		LastMessageAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := database.CreateChatSession(ctx, session); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to create chat session",
+			Details: err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusCreated, models.ChatSessionResponse{
+		Session: session,
+		Message: "Chat session created successfully",
+	})
+}
+
+// ListChatSessions handles GET /chat/sessions. Archived sessions are listed with archived=true.
+func ListChatSessions(c *gin.Context) {
+	userId := c.GetString("userId")
+	archived := false
+	if value := c.Query(constants.QueryParamArchived); value != "" {
+		var err error
+		if archived, err = strconv.ParseBool(value); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid archived parameter",
+				Details: err.Error(),
+			})
+			return
+		}
+	}
+	limit, err := pageLimit(c, constants.ChatSessionPageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid limit parameter",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	sessions, cursor, err := database.ListChatSessions(context.Background(), userId, archived, limit, c.Query(constants.QueryParamCursor))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, database.ErrInvalidCursor) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, models.ErrorResponse{
+			Error:   "Failed to list chat sessions",
+			Details: err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, models.ChatSessionListResponse{
+		Sessions: sessions,
+		Count:    len(sessions),
+		Cursor:   cursor,
+	})
+}
+
+// GetChatSession handles GET /chat/sessions/:sessionId
+func GetChatSession(c *gin.Context) {
+	userId := c.GetString("userId")
+	sessionId, ok := chatSessionParam(c)
+	if !ok {
+		return
+	}
+	session, err := database.GetChatSession(context.Background(), userId, sessionId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to get chat session",
+			Details: err.Error(),
+		})
+		return
+	}
+	if session == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Chat session not found",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, models.ChatSessionResponse{Session: *session})
+}
+
+// UpdateChatSession handles PATCH /chat/sessions/:sessionId to rename or archive a session
+func UpdateChatSession(c *gin.Context) {
+	var req models.ChatSessionUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request body",
+			Details: err.Error(),
+		})
+		return
+	}
+	userId := c.GetString("userId")
+	sessionId, ok := chatSessionParam(c)
+	if !ok {
+		return
+	}
+	if req.Title == nil && req.Archived == nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Nothing to update; set title and/or archived",
+		})
+		return
+	}
+	if req.Title != nil {
+		title, err := validateChatSessionTitle(*req.Title, false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid title",
+				Details: err.Error(),
+			})
+			return
+		}
+		req.Title = &title
+	}
+
+	session, err := database.UpdateChatSession(context.Background(), userId, sessionId, req.Title, req.Archived)
+	if errors.Is(err, database.ErrChatSessionNotFound) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Chat session not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to update chat session",
+			Details: err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, models.ChatSessionResponse{
+		Session: *session,
+		Message: "Chat session updated successfully",
+	})
+}
+
+// DeleteChatSession handles DELETE /chat/sessions/:sessionId. It deletes the session and all
+// of its messages, and succeeds for sessions that are already gone.
+func DeleteChatSession(c *gin.Context) {
+	userId := c.GetString("userId")
+	sessionId, ok := chatSessionParam(c)
+	if !ok {
+		return
+	}
+	if err := database.DeleteChatSession(context.Background(), userId, sessionId); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to delete chat session",
+			Details: err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Chat session deleted successfully"})
+}
+
+// GetChatSessionMessages handles GET /chat/sessions/:sessionId/messages. The first page holds
+// the newest messages; the cursor pages back through older ones. Each page is oldest first.
+func GetChatSessionMessages(c *gin.Context) {
+	userId := c.GetString("userId")
+	sessionId, ok := chatSessionParam(c)
+	if !ok {
+		return
+	}
+	limit, err := pageLimit(c, constants.ChatMessagePageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid limit parameter",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	messages, cursor, err := database.GetChatMessagesPage(context.Background(), userId, sessionId, limit, c.Query(constants.QueryParamCursor))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, database.ErrInvalidCursor) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, models.ErrorResponse{
+			Error:   "Failed to get chat messages",
+			Details: err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, models.ChatMessageListResponse{
+		Messages: messages,
+		Count:    len(messages),
+		Cursor:   cursor,
+	})
+}
+
+// chatSessionParam returns the :sessionId of a session route, answering 400 when it is not a
+// valid session ID
+func chatSessionParam(c *gin.Context) (string, bool) {
+	sessionId := c.Param(constants.QueryParamSessionId)
+	if err := validateChatSessionId(sessionId); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid sessionId",
+			Details: err.Error(),
+		})
+		return "", false
+	}
+	return sessionId, true
+}
+
+// validateChatSessionId checks a session ID chosen by the client. It cannot contain '#', which
+// separates the session ID from the timestamp in message sort keys.
+func validateChatSessionId(sessionId string) error {
+	if sessionId == "" {
+		return fmt.Errorf("sessionId must not be empty")
+	}
+	if len(sessionId) > constants.ChatSessionIdMaxLen {
+		return fmt.Errorf("sessionId must be at most %d characters", constants.ChatSessionIdMaxLen)
+	}
+	if strings.Contains(sessionId, "#") {
+		return fmt.Errorf("sessionId must not contain '#'")
+	}
+	return nil
+}
+
+// validateChatSessionTitle trims a title and checks its length. An empty title is allowed
+// only where one is generated later.
+func validateChatSessionTitle(title string, allowEmpty bool) (string, error) {
+	title = strings.Join(strings.Fields(title), " ")
+	if title == "" && !allowEmpty {
+		return "", fmt.Errorf("title must not be empty")
+	}
+	if len([]rune(title)) > constants.ChatSessionTitleMaxLen {
+		return "", fmt.Errorf("title must be at most %d characters", constants.ChatSessionTitleMaxLen)
+	}
+	return title, nil
+}
+
+// pageLimit reads the limit query parameter, defaulting to fallback and capped at ChatMaxPageSize
+func pageLimit(c *gin.Context, fallback int32) (int32, error) {
+	value := c.Query(constants.QueryParamLimit)
+	if value == "" {
+		return fallback, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 {
+		return 0, fmt.Errorf("limit must be a positive number")
+	}
+	if limit > int(constants.ChatMaxPageSize) {
+		limit = int(constants.ChatMaxPageSize)
+	}
+	return int32(limit), nil
+}
+
+// recordChatActivity updates the session after an exchange is stored and names sessions that
+// have no title yet from their first exchange, without holding up the response
+func recordChatActivity(provider llm.LLMProvider, model string, req ChatRequest, chat *chatContext, timestamp int64, reply string) {
+	ctx := context.Background()
+	preview := chatcontext.Preview(reply, constants.ChatMessagePreviewLen)
+	if err := database.RecordChatActivity(ctx, req.UserId, req.SessionId, preview, timestamp+1, 2); err != nil {
+		log.Printf("Failed to record activity for chat session %s: %v\n", req.SessionId, err)
+		return
+	}
+	if chat.session != nil && chat.session.Title != "" {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), titleTimeout)
+		defer cancel()
+		var generated string
+		completion, err := provider.Complete(ctx, llm.Request{
+			Model:    model,
+			Messages: chatcontext.TitleMessages(req.Message, reply),
+		})
+		if err != nil {
+			log.Printf("Generating a title for chat session %s failed: %v\n", req.SessionId, err)
+		} else {
+			generated = completion.Content
+		}
+		title := chatcontext.CleanTitle(generated, req.Message, constants.ChatSessionTitleMaxLen)
+		if _, err := database.SetGeneratedChatSessionTitle(context.Background(), req.UserId, req.SessionId, title); err != nil {
+			log.Printf("Saving the title of chat session %s failed: %v\n", req.SessionId, err)
+		}
+	}()
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"lambda-server/constants"
+	"lambda-server/database"
+	"lambda-server/llm"
+	"lambda-server/models"
+	"lambda-server/realtime"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChatStreamDone is the data of the final "done" event of a streamed reply
+type ChatStreamDone struct {
+	AIResponse string            `json:"aiResponse"`
+	Timestamp  int64             `json:"timestamp,omitempty"` // Timestamp of the stored reply; unset when Degraded
+	MessageId  string            `json:"messageId,omitempty"` // ID of the stored reply; unset when Degraded
+	Degraded   bool              `json:"degraded,omitempty"`  // The AI was unavailable and AIResponse is a canned reply; nothing was stored
+	Partial    bool              `json:"partial,omitempty"`   // Generation was stopped early; AIResponse is what was stored
+	Citations  []models.Citation `json:"citations,omitempty"` // Passages the reply may cite as [n]
+}
+
+// HandleChatStream handles POST /chat/stream
+// It takes the same body as POST /chat and relays the reply as Server-Sent Events: a "token"
+// event per piece of text, then "done" with the full reply once it is stored, or "error".
+// When no provider can answer at all, the canned reply is sent as one token and a "done"
+// event with degraded set.
+// Moderation runs on the complete reply, so a "moderated" event before "done" replaces
+// text already shown when it changed the reply.
+// If the client disconnects mid-stream, generation stops and the partial reply is stored
+// with partial set.
+// A request retried with the same Idempotency-Key header gets the stored reply as one token
+// and the original "done" event.
+func HandleChatStream(c *gin.Context) {
+	var req ChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+	if err := checkChatUser(c, req); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden", "details": err.Error()})
+		return
+	}
+	claim, replay, ok := claimIdempotency(c, req.UserId, req)
+	if !ok {
+		return
+	}
+	if replay != nil {
+		replayChatStream(c, replay)
+		return
+	}
+	defer claim.release()
+
+	turn, turnErr := prepareChatTurn(c.Request.Context(), req, riskUser(c, req.UserId))
+	if turnErr != nil {
+		c.JSON(turnErr.status, gin.H{"error": turnErr.message, "details": turnErr.err.Error()})
+		return
+	}
+	startChatStream(c)
+	flushStream(c)
+	if done := turn.stream(c.Request.Context(), requestLocale(c), sseEmitter(c)); done != nil && !done.Degraded {
+		claim.complete(*done)
+	}
+}
+
+// chatTurn is a chat message being answered
+type chatTurn struct {
+	req      ChatRequest
+	provider llm.LLMProvider
+	cfg      llm.Config
+	model    string
+	chat     *chatContext
+	sentAt   time.Time
+}
+
+// chatTurnError is a problem with a chat request found before the reply starts
+type chatTurnError struct {
+	status  int
+	message string
+	err     error
+}
+
+// prepareChatTurn resolves the provider, model and context for answering a chat message
+func prepareChatTurn(ctx context.Context, req ChatRequest, user *models.User) (*chatTurn, *chatTurnError) {
+	if err := validateChatSessionId(req.SessionId); err != nil {
+		return nil, &chatTurnError{http.StatusBadRequest, "Invalid sessionId", err}
+	}
+	turn := &chatTurn{req: req, sentAt: time.Now()}
+	var err error
+	turn.provider, turn.cfg, err = chatProvider()
+	if err != nil {
+		return nil, &chatTurnError{http.StatusInternalServerError, "Chat is not configured", err}
+	}
+	turn.model, err = turn.cfg.ResolveModel(req.Model)
+	if err != nil {
+		return nil, &chatTurnError{http.StatusBadRequest, "Invalid model", err}
+	}
+	turn.chat, err = prepareChatContext(ctx, turn.cfg, req, user)
+	if errors.Is(err, errPersonaUnavailable) {
+		return nil, &chatTurnError{http.StatusBadRequest, "Invalid persona", err}
+	}
+	if err != nil {
+		return nil, &chatTurnError{http.StatusInternalServerError, "Failed to prepare chat context", err}
+	}
+	return turn, nil
+}
+
+// chatEmitter delivers an event of a streamed reply to the client
+type chatEmitter func(event string, data any)
+
+// sseEmitter sends the events of a streamed reply as Server-Sent Events, dropping them once
+// the client has disconnected
+func sseEmitter(c *gin.Context) chatEmitter {
+	return func(event string, data any) {
+		if c.Request.Context().Err() != nil {
+			return
+		}
+		c.SSEvent(event, data)
+		flushStream(c)
+	}
+}
+
+// stream generates, moderates and stores the reply, emitting the events of POST /chat/stream.
+// When client ends, because the client went away or cancelled the reply, generation stops and
+// what was generated so far is stored with partial set. It returns the "done" event, or nil
+// when the reply failed or nothing of it was generated.
+func (turn *chatTurn) stream(client context.Context, locale string, emit chatEmitter) *ChatStreamDone {
+	req, chat := turn.req, turn.chat
+	check, escalated := chat.checkRisk(client, locale, req)
+	defer func() { <-escalated }()
+	if check.resources != nil {
+		emit(constants.ChatEventCrisis, gin.H{"risk": check.assessment, "crisisResources": check.resources})
+	}
+
+	// Ending the client context also cancels the upstream call
+	ctx, cancel := context.WithTimeout(client, turn.cfg.StreamTimeout)
+	defer cancel()
+
+	var reply strings.Builder
+	resp, err := turn.provider.Stream(ctx, chat.request(turn.model), func(token string) {
+		reply.WriteString(token)
+		emit(constants.ChatEventToken, realtime.Token{Content: token})
+	})
+
+	stopped := client.Err() != nil
+	if err != nil && !stopped && reply.Len() == 0 {
+		log.Printf("Chat stream for session %s failed, sending the canned reply: %v\n", req.SessionId, err)
+		done := &ChatStreamDone{AIResponse: cannedChatReply, Degraded: true}
+		emit(constants.ChatEventToken, realtime.Token{Content: cannedChatReply})
+		emit(constants.ChatEventDone, *done)
+		return done
+	}
+	if err != nil && !stopped {
+		log.Printf("Chat stream for session %s failed: %v\n", req.SessionId, err)
+		emit(constants.ChatEventError, gin.H{"error": "Failed to get AI response", "details": err.Error()})
+		return nil
+	}
+	if stopped && reply.Len() == 0 {
+		return nil
+	}
+	summarizeOverflowInBackground(turn.provider, turn.model, req, chat)
+
+	aiResponse := chat.moderate(req, reply.String())
+	chat.setUsage(resp, reply.String())
+	chat.setModel(resp, turn.model)
+	recordChatUsage(req, chat)
+	if moderated := chat.moderated(); moderated != nil {
+		emit(constants.ChatEventModerated, gin.H{"moderation": moderated, "aiResponse": aiResponse})
+	}
+
+	aiMsg, err := storeChatExchange(req, chat, turn.sentAt, aiResponse, stopped)
+	if err != nil {
+		log.Printf("Failed to store chat exchange for session %s: %v\n", req.SessionId, err)
+		emit(constants.ChatEventError, gin.H{"error": "Failed to store chat messages", "details": err.Error()})
+		return nil
+	}
+	recordChatActivity(turn.provider, turn.model, req, chat, aiMsg.Timestamp, aiResponse)
+	done := &ChatStreamDone{
+		AIResponse: aiResponse,
+		Timestamp:  aiMsg.Timestamp,
+		MessageId:  aiMsg.MessageId,
+		Partial:    stopped,
+		Citations:  chat.citations,
+	}
+	emit(constants.ChatEventDone, *done)
+	return done
+}
+
+// startChatStream sends the headers of an event stream
+func startChatStream(c *gin.Context) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+}
+
+// replayChatStream sends the reply of an earlier request with the same Idempotency-Key
+func replayChatStream(c *gin.Context, replay []byte) {
+	var done ChatStreamDone
+	if err := json.Unmarshal(replay, &done); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replay the original response", "details": err.Error()})
+		return
+	}
+	startChatStream(c)
+	c.SSEvent(constants.ChatEventToken, gin.H{"content": done.AIResponse})
+	c.SSEvent(constants.ChatEventDone, done)
+	flushStream(c)
+}
+
+// storeChatExchange stores the user message, sent at sentAt, and the (possibly partial) AI
+// reply together, and returns the stored reply. The write does not use the request context,
+// so a disconnected client does not cancel it.
+func storeChatExchange(req ChatRequest, chat *chatContext, sentAt time.Time, reply string, partial bool) (*models.ChatMessage, error) {
+	userMsg := chat.chatMessage(req, sentAt, constants.ChatSenderUser, req.Message)
+	aiMsg := chat.chatMessage(req, time.Now(), constants.ChatSenderAI, reply)
+	aiMsg.Partial = partial
+	aiMsg.Moderation = chat.moderation
+	aiMsg.Usage = chat.usage
+	aiMsg.Citations = chat.citations
+	aiMsg.Model = chat.model
+	if err := database.StoreChatMessages(context.Background(), userMsg, aiMsg); err != nil {
+		return nil, err
+	}
+	analyzeChatMessageInBackground(*userMsg)
+	logModeration(req, chat, aiMsg)
+	return aiMsg, nil
+}
+
+// flushStream pushes buffered events to the client when the response writer supports it.
+// The API Gateway adapter buffers the whole response and cannot flush, so events are
+// delivered together when the handler returns.
+func flushStream(c *gin.Context) {
+	if flusher, ok := c.Writer.(interface{ Unwrap() http.ResponseWriter }); ok {
+		if _, canFlush := flusher.Unwrap().(http.Flusher); !canFlush {
+			return
+		}
+	}
+	c.Writer.Flush()
+}
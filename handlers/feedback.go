@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"lambda-server/constants"
+	"lambda-server/database"
+	"lambda-server/feedback"
+	"lambda-server/llm"
+	"lambda-server/models"
+	"lambda-server/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setModel keeps the model that wrote the reply on the chat context, which differs from the
+// requested one when a fallback route answered
+func (chat *chatContext) setModel(resp *llm.Response, requested string) {
+	chat.model = requested
+	if resp != nil && resp.Model != "" {
+		chat.model = resp.Model
+	}
+}
+
+// SubmitMessageFeedback handles PUT /chat/sessions/:sessionId/messages/:messageId/feedback,
+// rating an AI reply up or down with an optional category and comment. Rating it again
+// replaces the earlier rating.
+func SubmitMessageFeedback(c *gin.Context) {
+	var req models.MessageFeedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request body",
+			Details: err.Error(),
+		})
+		return
+	}
+	req, err := feedback.Validate(req, constants.FeedbackCommentMaxLen)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid feedback",
+			Details: err.Error(),
+		})
+		return
+	}
+	sessionId, ok := chatSessionParam(c)
+	if !ok {
+		return
+	}
+
+	ctx := context.Background()
+	message, err := database.GetAIChatMessage(ctx, c.GetString("userId"), sessionId, c.Param(constants.QueryParamMessageId))
+	if !respondFeedbackError(c, err) {
+		return
+	}
+	rating := models.MessageFeedback{
+		Rating:    req.Rating,
+		Category:  req.Category,
+		Comment:   req.Comment,
+		UpdatedAt: time.Now().Unix(),
+	}
+	record := models.FeedbackRecord{
+		Date:           feedbackDate(*message),
+		Id:             feedbackId(*message),
+		UserId:         message.UserId,
+		SessionId:      message.SessionId,
+		Timestamp:      message.Timestamp,
+		MessageId:      message.ID(),
+		PersonaId:      message.PersonaId,
+		PersonaVersion: message.PersonaVersion,
+		Model:          message.Model,
+		Rating:         rating.Rating,
+		Category:       rating.Category,
+		HasComment:     rating.Comment != "",
+		UpdatedAt:      rating.UpdatedAt,
+	}
+	message, err = database.SetChatMessageFeedback(ctx, *message, rating, record)
+	if !respondFeedbackError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, message)
+}
+
+// DeleteMessageFeedback handles DELETE /chat/sessions/:sessionId/messages/:messageId/feedback,
+// withdrawing the rating of an AI reply. Withdrawing a rating that was never given succeeds.
+func DeleteMessageFeedback(c *gin.Context) {
+	sessionId, ok := chatSessionParam(c)
+	if !ok {
+		return
+	}
+	ctx := context.Background()
+	message, err := database.GetAIChatMessage(ctx, c.GetString("userId"), sessionId, c.Param(constants.QueryParamMessageId))
+	if !respondFeedbackError(c, err) {
+		return
+	}
+	message, err = database.DeleteChatMessageFeedback(ctx, *message, feedbackDate(*message), feedbackId(*message))
+	if !respondFeedbackError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, message)
+}
+
+// respondFeedbackError responds to a failed feedback update and reports whether it succeeded
+func respondFeedbackError(c *gin.Context, err error) bool {
+	if errors.Is(err, database.ErrAIMessageNotFound) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "AI message not found",
+		})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to update feedback",
+			Details: err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// feedbackDate is the day (UTC) feedback on a reply is kept under: the day it was sent
+func feedbackDate(message models.ChatMessage) string {
+	return time.Unix(message.Timestamp, 0).UTC().Format("20060102")
+}
+
+func feedbackId(message models.ChatMessage) string {
+	return fmt.Sprintf("%d#%s#%s#%s", message.Timestamp, message.UserId, message.SessionId, message.ID())
+}
+
+// ExportFeedback handles GET /admin/feedback, aggregating the ratings of replies sent between
+// from and to (YYYYMMDD, UTC, inclusive; default the last 30 days) by persona, persona version
+// and model, as JSON or, with format=csv, as a CSV file
+func ExportFeedback(c *gin.Context) {
+	format := c.DefaultQuery(constants.QueryParamFormat, constants.FeedbackFormatJSON)
+	if format != constants.FeedbackFormatJSON && format != constants.FeedbackFormatCSV {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid format, expected json or csv",
+		})
+		return
+	}
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	if value := c.Query(constants.QueryParamTo); value != "" {
+		day, err := utils.ParseDateParam(value, time.UTC)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid to date", Details: err.Error()})
+			return
+		}
+		to = day
+	}
+	from := to.AddDate(0, 0, 1-constants.FeedbackExportDefault)
+	if value := c.Query(constants.QueryParamFrom); value != "" {
+		day, err := utils.ParseDateParam(value, time.UTC)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid from date", Details: err.Error()})
+			return
+		}
+		from = day
+	}
+	if from.After(to) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "from date must not be after to date"})
+		return
+	}
+	if to.Sub(from) >= time.Duration(constants.FeedbackExportMaxDays)*24*time.Hour {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: fmt.Sprintf("Date range must be at most %d days", constants.FeedbackExportMaxDays),
+		})
+		return
+	}
+
+	records := []models.FeedbackRecord{}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		dayRecords, err := database.GetFeedbackRecords(c.Request.Context(), day.Format("20060102"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "Failed to get feedback",
+				Details: err.Error(),
+			})
+			return
+		}
+		records = append(records, dayRecords...)
+	}
+	groups := feedback.Aggregate(records)
+
+	if format == constants.FeedbackFormatCSV {
+		var out bytes.Buffer
+		if err := feedback.WriteCSV(&out, groups); err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "Failed to write feedback export",
+				Details: err.Error(),
+			})
+			return
+		}
+		fileName := fmt.Sprintf("mindmuse-feedback-%s-%s.csv", from.Format("20060102"), to.Format("20060102"))
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", out.Bytes())
+		return
+	}
+	c.JSON(http.StatusOK, models.FeedbackExportResponse{
+		From:   from.Format("20060102"),
+		To:     to.Format("20060102"),
+		Groups: groups,
+		Count:  len(records),
+	})
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"lambda-server/constants"
+	"lambda-server/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// idempotencyClaim is the Idempotency-Key a request holds while it runs. A nil claim, for a
+// request without the header, does nothing.
+type idempotencyClaim struct {
+	key      string
+	finished bool
+}
+
+// claimIdempotency claims the request's Idempotency-Key. When the key belongs to an earlier
+// request that completed, it returns that request's response to replay instead of running
+// this one. ok is false when it has already responded: the key is malformed, its earlier
+// request is still running, or it was used for a different request. If the key store cannot
+// be reached the request runs without a claim, so an outage there does not take chat down.
+func claimIdempotency(c *gin.Context, userId string, req any) (claim *idempotencyClaim, replay []byte, ok bool) {
+	header := c.GetHeader(constants.HeaderIdempotencyKey)
+	if header == "" {
+		return nil, nil, true
+	}
+	if len(header) > constants.IdempotencyKeyMaxLen {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid Idempotency-Key",
+			"details": fmt.Sprintf("the key must be at most %d characters", constants.IdempotencyKeyMaxLen),
+		})
+		return nil, nil, false
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read request", "details": err.Error()})
+		return nil, nil, false
+	}
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+	key := userId + "#" + c.FullPath() + "#" + header
+
+	record, err := database.ClaimIdempotencyKey(c.Request.Context(), key, hash, time.Now())
+	switch {
+	case err != nil:
+		log.Printf("Idempotency check for user %s failed, running the request: %v\n", userId, err)
+		return nil, nil, true
+	case record == nil:
+		return &idempotencyClaim{key: key}, nil, true
+	case record.RequestHash != hash:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Idempotency-Key reused",
+			"details": "This Idempotency-Key was used for a different request",
+		})
+		return nil, nil, false
+	case record.Status != constants.IdempotencyComplete:
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Request in progress",
+			"details": "A request with this Idempotency-Key is still being processed",
+		})
+		return nil, nil, false
+	}
+	c.Header(constants.HeaderIdempotentReplayed, "true")
+	return nil, []byte(record.Response), true
+}
+
+// complete stores the response for retries of the request
+func (claim *idempotencyClaim) complete(response any) {
+	if claim == nil || claim.finished {
+		return
+	}
+	claim.finished = true
+	body, err := json.Marshal(response)
+	if err == nil {
+		err = database.CompleteIdempotencyKey(context.Background(), claim.key, body)
+	}
+	if err != nil {
+		log.Printf("Failed to store the response for idempotency key %s: %v\n", claim.key, err)
+	}
+}
+
+// release frees the key of a request that ended without a response worth replaying, such as
+// an error or a canned reply, so a retry runs it again. It does nothing after complete.
+func (claim *idempotencyClaim) release() {
+	if claim == nil || claim.finished {
+		return
+	}
+	claim.finished = true
+	if err := database.ReleaseIdempotencyKey(context.Background(), claim.key); err != nil {
+		log.Printf("Failed to release idempotency key %s: %v\n", claim.key, err)
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"lambda-server/chatcontext"
+	"lambda-server/constants"
+	"lambda-server/database"
+	"lambda-server/helpers"
+	"lambda-server/llm"
+	"lambda-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errJournalDraftFailed is returned when the model could not draft a journal entry
+var errJournalDraftFailed = errors.New("the AI could not draft a journal entry right now, please try again")
+
+// CreateChatSessionJournalDraft handles POST /chat/sessions/:sessionId/journal-draft. It asks
+// the model to turn the conversation into a journal entry with a summary, key insights and
+// action items, and returns it as a draft; nothing is saved until the user posts the edited
+// draft to POST /journals with sourceSessionId set.
+func CreateChatSessionJournalDraft(c *gin.Context) {
+	userId := c.GetString("userId")
+	sessionId, ok := chatSessionParam(c)
+	if !ok {
+		return
+	}
+
+	provider, cfg, err := chatProvider()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Chat is not configured",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	session, err := database.GetChatSession(ctx, userId, sessionId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to get chat session",
+			Details: err.Error(),
+		})
+		return
+	}
+	history, err := helpers.GetChatHistorySince(userId, sessionId, "", constants.ChatDraftHistoryLimit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to get chat messages",
+			Details: err.Error(),
+		})
+		return
+	}
+	if len(history) == 0 {
+		status, message := http.StatusBadRequest, "Chat session has no messages to draft from"
+		if session == nil {
+			status, message = http.StatusNotFound, "Chat session not found"
+		}
+		c.JSON(status, models.ErrorResponse{Error: message})
+		return
+	}
+
+	// The running summary only adds something when older messages were left out above
+	var summary, title string
+	if session != nil {
+		if history[0].SessionIdTimestamp > session.SummarizedThroughKey() {
+			summary = session.Summary
+		}
+		title = session.Title
+	}
+	budget := cfg.ContextTokens
+	if budget <= 0 {
+		budget = llm.DefaultContextTokens
+	}
+	// Leaves a quarter of the window for the instructions and the draft
+	messages := chatcontext.Newest(chatHistoryMessages(history), budget*3/4)
+	if title == "" {
+		title = history[0].Message
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	completion, err := provider.Complete(ctx, llm.Request{
+		Model:    cfg.Model,
+		Messages: chatcontext.JournalDraftMessages(summary, messages),
+	})
+	if err != nil {
+		log.Printf("Journal draft for chat session %s failed: %v\n", sessionId, err)
+		c.JSON(http.StatusBadGateway, models.ErrorResponse{
+			Error:   "Failed to draft journal entry",
+			Details: errJournalDraftFailed.Error(),
+		})
+		return
+	}
+	draft, err := chatcontext.ParseJournalDraft(completion.Content, title, constants.ChatSessionTitleMaxLen)
+	if err != nil {
+		log.Printf("Journal draft for chat session %s failed: %v\n", sessionId, err)
+		c.JSON(http.StatusBadGateway, models.ErrorResponse{
+			Error:   "Failed to draft journal entry",
+			Details: errJournalDraftFailed.Error(),
+		})
+		return
+	}
+	draft.SourceSessionId = sessionId
+	c.JSON(http.StatusOK, draft)
+}
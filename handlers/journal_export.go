@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"fmt"
+	"lambda-server/constants"
+	"lambda-server/database"
+	"lambda-server/export"
+	"lambda-server/models"
+	"lambda-server/utils"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportJournalEntries handles GET /journals/export?format=md|pdf|json&from=YYYY-MM-DD&to=YYYY-MM-DD
+// Every matching entry of the signed-in user is streamed to the client, oldest first.
+func ExportJournalEntries(c *gin.Context) {
+	userId := c.GetString("userId")
+
+	format := c.DefaultQuery(constants.QueryParamFormat, export.FormatMarkdown)
+	loc := requestLocation(c)
+	meta := export.Meta{
+		Title:       "MindMuse Journal",
+		GeneratedAt: time.Now(),
+		Location:    loc,
+	}
+	if user, exists := c.Get("user"); exists {
+		meta.Name = user.(*models.User).Name
+	}
+
+	from := int64(0)
+	to := time.Now().Unix()
+	if value := c.Query(constants.QueryParamFrom); value != "" {
+		day, err := utils.ParseDateParam(value, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid from date", Details: err.Error()})
+			return
+		}
+		from = day.Unix()
+		meta.From = day.Format("2006-01-02")
+	}
+	if value := c.Query(constants.QueryParamTo); value != "" {
+		day, err := utils.ParseDateParam(value, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid to date", Details: err.Error()})
+			return
+		}
+		to = day.AddDate(0, 0, 1).Unix() - 1 // inclusive of the whole last day
+		meta.To = day.Format("2006-01-02")
+	}
+	if from > to {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "from date must not be after to date"})
+		return
+	}
+
+	writer, err := export.NewWriter(format, c.Writer)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid export format", Details: err.Error()})
+		return
+	}
+
+	fileName := fmt.Sprintf("mindmuse-journal-%s.%s", meta.GeneratedAt.In(loc).Format("20060102"), format)
+	c.Header("Content-Type", export.ContentType(format))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	c.Status(http.StatusOK)
+
+	// Headers are sent with the first write, so failures from here on can only be logged
+	ctx := c.Request.Context()
+	err = streamJournalExport(c, writer, meta, func(visit func(models.Journal) error) error {
+		return database.IterateUserJournals(ctx, userId, from, to, visit)
+	})
+	if err != nil {
+		log.Printf("Journal export for %s failed: %v\n", userId, err)
+	}
+}
+
+// streamJournalExport writes every entry visited by iterate, flushing after each one where the
+// response writer can flush. Behind the Lambda adapter it cannot, and the export is sent whole.
+func streamJournalExport(c *gin.Context, writer export.Writer, meta export.Meta, iterate func(visit func(models.Journal) error) error) error {
+	if err := writer.Begin(meta); err != nil {
+		return err
+	}
+	err := iterate(func(journal models.Journal) error {
+		if err := writer.Entry(journal); err != nil {
+			return err
+		}
+		flushStream(c)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return writer.End()
+}
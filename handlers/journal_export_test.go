@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"lambda-server/export"
+	"lambda-server/models"
+
+	"github.com/aws/aws-lambda-go/events"
+	ginadapter "github.com/awslabs/aws-lambda-go-api-proxy/gin"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func exportRouter(journals []models.Journal) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/export", func(c *gin.Context) {
+		writer, err := export.NewWriter(export.FormatJSON, c.Writer)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		c.Header("Content-Type", export.ContentType(export.FormatJSON))
+		c.Status(http.StatusOK)
+		err = streamJournalExport(c, writer, export.Meta{Title: "MindMuse Journal", GeneratedAt: time.Unix(1760900000, 0)}, func(visit func(models.Journal) error) error {
+			for _, journal := range journals {
+				if err := visit(journal); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+		}
+	})
+	return router
+}
+
+func exportJournals() []models.Journal {
+	return []models.Journal{
+		{JournalID: "j1", Date: "20261018", CreatedAt: 1760781600, Content: "First"},
+		{JournalID: "j2", Date: "20261018", CreatedAt: 1760810400, Content: "Second"},
+		{JournalID: "j3", Date: "20261019", CreatedAt: 1760868000, Content: "Third"},
+	}
+}
+
+func exportedCount(t *testing.T, body string) int {
+	var document map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal([]byte(body), &document), body)
+	var entries []json.RawMessage
+	require.NoError(t, json.Unmarshal(document["entries"], &entries))
+	return len(entries)
+}
+
+func TestStreamJournalExportThroughLambdaAdapter(t *testing.T) {
+	adapter := ginadapter.NewV2(exportRouter(exportJournals()))
+	req := events.APIGatewayV2HTTPRequest{
+		RawPath: "/export",
+		RequestContext: events.APIGatewayV2HTTPRequestContext{
+			HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{Method: http.MethodGet, Path: "/export"},
+		},
+	}
+
+	var resp events.APIGatewayV2HTTPResponse
+	var err error
+	assert.NotPanics(t, func() {
+		resp, err = adapter.ProxyWithContext(context.Background(), req)
+	}, "the adapter's writer cannot flush")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 3, exportedCount(t, resp.Body))
+}
+
+func TestStreamJournalExportFlushes(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	exportRouter(exportJournals()).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/export", nil))
+
+	assert.True(t, recorder.Flushed)
+	assert.Equal(t, 3, exportedCount(t, recorder.Body.String()))
+}
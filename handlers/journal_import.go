@@ -0,0 +1,388 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"lambda-server/constants"
+	"lambda-server/database"
+	"lambda-server/importer"
+	"lambda-server/models"
+	"lambda-server/sentiment"
+	"lambda-server/storage"
+	"lambda-server/utils"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImportJournalEntries handles POST /journals/import?source=dayone|journey|markdown&fileName=...
+// It creates an import job waiting for its zip archive and returns a signed URL the client
+// uploads the archive to with a PUT request. Archives go straight to the blob store, as they
+// may be larger than the Lambda request body limit; StartImportJob then queues the job.
+func ImportJournalEntries(c *gin.Context) {
+	userId := c.GetString("userId")
+	source := strings.ToLower(c.Query(constants.QueryParamImportSource))
+	if source != importer.SourceDayOne && source != importer.SourceJourney && source != importer.SourceMarkdown {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid source, expected dayone, journey or markdown",
+		})
+		return
+	}
+	fileName := c.Query(constants.QueryParamFileName)
+	if len(fileName) > constants.ImportFileNameMaxLen {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: fmt.Sprintf("fileName must be at most %d characters", constants.ImportFileNameMaxLen),
+		})
+		return
+	}
+	store, ok := blobStore(c)
+	if !ok {
+		return
+	}
+	ctx := context.Background()
+
+	now := time.Now()
+	jobId := utils.GenerateImportJobID()
+	job := models.ImportJob{
+		UserId:     userId,
+		JobId:      jobId,
+		Source:     source,
+		FileName:   fileName,
+		TimeZone:   requestLocation(c).String(),
+		ArchiveKey: importArchiveKey(userId, jobId),
+		Status:     constants.ImportStatusUploading,
+		Errors:     []models.ImportItemError{},
+		CreatedAt:  now.Unix(),
+		UpdatedAt:  now.Unix(),
+		ExpiresAt:  now.AddDate(0, 0, constants.ImportJobRetentionDays).Unix(),
+	}
+
+	expiry := time.Duration(constants.ImportURLExpiryMins) * time.Minute
+	uploadURL, err := store.SignedUploadURL(ctx, job.ArchiveKey, "application/zip", expiry)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to sign upload URL", Details: err.Error()})
+		return
+	}
+	if err := database.SaveImportJob(ctx, job); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to create import job", Details: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.ImportJobResponse{
+		Job:       job,
+		UploadURL: uploadURL,
+		ExpiresAt: now.Add(expiry).Unix(),
+		Message:   "Upload the zip archive with a PUT request to uploadUrl, then start the import",
+	})
+}
+
+// StartImportJob handles POST /journals/import/:jobId/start once the archive has been uploaded.
+// The import runs in the process-imports scheduled job, which outlives the request; the returned
+// job reports its progress.
+func StartImportJob(c *gin.Context) {
+	jobId := c.Param(constants.QueryParamJobId)
+	userId := c.GetString("userId")
+	if jobId == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Missing jobId in path",
+		})
+		return
+	}
+	store, ok := blobStore(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	job, err := database.GetImportJob(ctx, userId, jobId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Import job not found", Details: err.Error()})
+		return
+	}
+	if job.Status != constants.ImportStatusUploading {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Import has already been started"})
+		return
+	}
+
+	archive, err := store.Get(ctx, job.ArchiveKey)
+	if errors.Is(err, storage.ErrBlobNotFound) {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Upload the archive to uploadUrl before starting the import"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to read archive", Details: err.Error()})
+		return
+	}
+	archive.Close()
+
+	queued, err := database.QueueImportJob(ctx, userId, jobId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to queue import job", Details: err.Error()})
+		return
+	}
+	if !queued {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Import has already been started"})
+		return
+	}
+
+	// Not started here: Lambda freezes the instance once the response is sent
+	job.Status = constants.ImportStatusQueued
+	job.UpdatedAt = time.Now().Unix()
+	c.JSON(http.StatusAccepted, models.ImportJobResponse{
+		Job:     *job,
+		Message: "Import queued",
+	})
+}
+
+// GetImportJob handles GET /journals/import/:jobId
+func GetImportJob(c *gin.Context) {
+	jobId := c.Param(constants.QueryParamJobId)
+	userId := c.GetString("userId")
+	if jobId == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Missing jobId in path",
+		})
+		return
+	}
+	ctx := context.Background()
+
+	job, err := database.GetImportJob(ctx, userId, jobId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Import job not found", Details: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ImportJobResponse{Job: *job})
+}
+
+// ProcessImportJob claims a queued import job and writes the archive's entries in batches.
+// Entries matching an existing entry's creation time and content, or an entry imported earlier
+// from the same item, are counted as duplicates, so re-importing an archive (or resuming an
+// interrupted job) does not create copies. Imported entries are not checked for risk: they
+// were written in the past, and an SOS over them would alert contacts to a false alarm.
+func ProcessImportJob(ctx context.Context, job models.ImportJob) error {
+	store, err := storage.Default()
+	if err != nil {
+		return err
+	}
+	staleBefore := time.Now().Add(-time.Duration(constants.ImportStaleJobMins) * time.Minute)
+	claimed, err := database.ClaimImportJob(ctx, job.UserId, job.JobId, staleBefore)
+	if err != nil || !claimed {
+		return err
+	}
+
+	// Progress is recomputed from scratch when an interrupted job is resumed
+	job.Status = constants.ImportStatusRunning
+	job.Pending = constants.ImportPendingValue
+	job.Total, job.Processed, job.Imported, job.Duplicates, job.Failed = 0, 0, 0, 0, 0
+	job.Errors = []models.ImportItemError{}
+
+	if err := runImportJob(ctx, store, &job); err != nil {
+		job.Status = constants.ImportStatusFailed
+		job.Error = err.Error()
+	} else {
+		job.Status = constants.ImportStatusCompleted
+	}
+	job.UpdatedAt = time.Now().Unix()
+	job.CompletedAt = job.UpdatedAt
+	// Leaving the pending index; the record is kept for a while so the client can read the result
+	job.Pending = ""
+	job.ExpiresAt = time.Now().AddDate(0, 0, constants.ImportJobRetentionDays).Unix()
+	if err := database.SaveImportJob(ctx, job); err != nil {
+		return err
+	}
+
+	if err := store.Delete(ctx, job.ArchiveKey); err != nil {
+		log.Printf("Import job %s: failed to delete archive: %v\n", job.JobId, err)
+	}
+	if job.Status == constants.ImportStatusFailed {
+		return errors.New(job.Error)
+	}
+	return nil
+}
+
+// runImportJob parses the job's archive and writes the new entries, saving progress after each batch
+func runImportJob(ctx context.Context, store storage.BlobStore, job *models.ImportJob) error {
+	reader, err := store.Get(ctx, job.ArchiveKey)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	data, err := io.ReadAll(io.LimitReader(reader, constants.ImportMaxArchiveBytes+1))
+	reader.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+	if int64(len(data)) > constants.ImportMaxArchiveBytes {
+		return fmt.Errorf("archive is larger than %d bytes", constants.ImportMaxArchiveBytes)
+	}
+	if len(data) == 0 {
+		return errors.New("archive is empty")
+	}
+
+	items, itemErrors, err := importer.Parse(job.Source, data, utils.LoadLocation(job.TimeZone))
+	if err != nil {
+		return err
+	}
+	job.Total = len(items) + len(itemErrors)
+	for _, itemError := range itemErrors {
+		recordImportError(job, itemError)
+	}
+	job.Processed = len(itemErrors)
+
+	existing, err := database.GetUserJournalContents(ctx, job.UserId)
+	if err != nil {
+		return err
+	}
+	fingerprints := map[string]bool{}
+	takenCreatedAt := map[int64]bool{}
+	for _, journal := range existing {
+		fingerprints[importer.Fingerprint(journal.CreatedAt, journal.Content)] = true
+		if journal.ImportFingerprint != "" {
+			fingerprints[journal.ImportFingerprint] = true
+		}
+		takenCreatedAt[journal.CreatedAt] = true
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].CreatedAt.Before(items[j].CreatedAt) })
+
+	batch := []models.Journal{}
+	flush := func() error {
+		if len(batch) > 0 {
+			if err := database.BatchCreateJournalEntries(ctx, job.UserId, batch); err != nil {
+				return err
+			}
+			job.Imported += len(batch)
+			job.Processed += len(batch)
+			batch = batch[:0]
+		}
+		job.UpdatedAt = time.Now().Unix()
+		return database.SaveImportJob(ctx, *job)
+	}
+
+	now := time.Now().Unix()
+	for _, item := range items {
+		fingerprint := importer.Fingerprint(item.CreatedAt.Unix(), item.Content)
+		if fingerprints[fingerprint] {
+			job.Duplicates++
+			job.Processed++
+			continue
+		}
+		fingerprints[fingerprint] = true
+
+		// CreatedAt is the sort key, so entries written in the same second are moved apart
+		createdAt := item.CreatedAt.Unix()
+		for takenCreatedAt[createdAt] {
+			createdAt++
+		}
+		takenCreatedAt[createdAt] = true
+
+		// The import already runs in the background, so entries are analyzed as they are written
+		analysis := sentiment.Analyze(item.Title + "\n" + item.Content)
+		batch = append(batch, models.Journal{
+			UserId:            job.UserId,
+			CreatedAt:         createdAt,
+			JournalID:         utils.GenerateJournalID(),
+			Date:              item.CreatedAt.Format("20060102"),
+			Title:             item.Title,
+			Content:           item.Content,
+			UpdatedAt:         now,
+			Tags:              importTags(item.Tags),
+			Pinned:            item.Pinned,
+			ImportSource:      job.Source,
+			ImportFingerprint: fingerprint,
+			TimeZone:          item.CreatedAt.Location().String(),
+			Sentiment:         &analysis,
+		})
+		if len(batch) == constants.ImportBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
+
+// importTags normalizes tags from another app, dropping the ones this app does not accept
+// instead of failing the whole entry
+func importTags(tags []string) []string {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		t, err := utils.NormalizeTag(tag)
+		if err != nil || seen[t] {
+			continue
+		}
+		seen[t] = true
+		normalized = append(normalized, t)
+		if len(normalized) == constants.JournalMaxTags {
+			break
+		}
+	}
+	if len(normalized) == 0 {
+		return nil
+	}
+	return normalized
+}
+
+// recordImportError counts a failed item and keeps its error in the capped report
+func recordImportError(job *models.ImportJob, itemError models.ImportItemError) {
+	job.Failed++
+	if len(job.Errors) < constants.ImportMaxReportedErrors {
+		job.Errors = append(job.Errors, itemError)
+	}
+}
+
+// uploadImportArchive stores an archive sent to a signed upload URL of the local store
+func uploadImportArchive(c *gin.Context, store storage.BlobStore, key string) {
+	parts := strings.Split(strings.TrimPrefix(key, constants.ImportArchivePrefix), "/")
+	if len(parts) != 2 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid archive key"})
+		return
+	}
+	ctx := c.Request.Context()
+
+	job, err := database.GetImportJob(ctx, parts[0], strings.TrimSuffix(parts[1], ".zip"))
+	if err != nil || job.ArchiveKey != key {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Import job not found"})
+		return
+	}
+	if job.Status != constants.ImportStatusUploading {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Import has already been started"})
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, constants.ImportMaxArchiveBytes))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, models.ErrorResponse{
+				Error: fmt.Sprintf("Archive is larger than %d bytes", constants.ImportMaxArchiveBytes),
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Failed to read archive", Details: err.Error()})
+		return
+	}
+	if len(data) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Archive is empty"})
+		return
+	}
+	if err := store.Put(ctx, key, bytes.NewReader(data)); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to store archive", Details: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Archive uploaded"})
+}
+
+// importArchiveKey builds the blob key of an import archive: imports/userId/jobId.zip
+func importArchiveKey(userId, jobId string) string {
+	return constants.ImportArchivePrefix + userId + "/" + jobId + ".zip"
+}
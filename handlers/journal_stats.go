@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"lambda-server/constants"
+	"lambda-server/database"
+	"lambda-server/models"
+	"lambda-server/utils"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJournalStats handles GET /journals/stats?userId=...&year=YYYY
+// Streaks, the heatmap and the time-of-day distribution come from counters maintained as
+// entries are written, so the entries themselves are only read once to backfill old accounts.
+func GetJournalStats(c *gin.Context) {
+	userId := c.Query(constants.QueryParamUserId)
+	if userId == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Missing userId in query params",
+		})
+		return
+	}
+
+	loc := requestLocation(c)
+	now := time.Now().In(loc)
+	year := now.Year()
+	if value := c.Query(constants.QueryParamYear); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1970 || parsed > 9999 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid year"})
+			return
+		}
+		year = parsed
+	}
+	ctx := context.Background()
+
+	stats, err := database.GetJournalStats(ctx, userId)
+	if err == nil && !journalStatsBackfilled(stats) {
+		stats, err = database.RebuildJournalStats(ctx, userId)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to retrieve journal stats",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, buildJournalStats(stats, year, now))
+}
+
+// journalStatsBackfilled reports whether the counters include the entries written before they existed
+func journalStatsBackfilled(stats []models.JournalStat) bool {
+	for _, stat := range stats {
+		if stat.StatKey == constants.JournalStatTotalKey {
+			return stat.Backfilled
+		}
+	}
+	return false
+}
+
+// buildJournalStats turns the raw counters into the stats response for a year
+func buildJournalStats(stats []models.JournalStat, year int, now time.Time) models.JournalStatsResponse {
+	response := models.JournalStatsResponse{
+		Year:     year,
+		TimeZone: now.Location().String(),
+		Heatmap:  []models.JournalDayCount{},
+	}
+	yearPrefix := strconv.Itoa(year)
+	activeDays := []string{}
+
+	for _, stat := range stats {
+		switch {
+		case stat.StatKey == constants.JournalStatTotalKey:
+			response.TotalEntries = stat.Count
+			response.TotalWords = stat.Words
+
+		case strings.HasPrefix(stat.StatKey, constants.JournalStatHourPrefix):
+			hour, err := strconv.Atoi(strings.TrimPrefix(stat.StatKey, constants.JournalStatHourPrefix))
+			if err != nil || hour < 0 || hour > 23 || stat.Count <= 0 {
+				continue
+			}
+			response.TimeOfDay.Hours[hour] = stat.Count
+			switch {
+			case hour >= 5 && hour < 12:
+				response.TimeOfDay.Morning += stat.Count
+			case hour >= 12 && hour < 17:
+				response.TimeOfDay.Afternoon += stat.Count
+			case hour >= 17 && hour < 22:
+				response.TimeOfDay.Evening += stat.Count
+			default:
+				response.TimeOfDay.Night += stat.Count
+			}
+
+		case strings.HasPrefix(stat.StatKey, constants.JournalStatDayPrefix):
+			day := strings.TrimPrefix(stat.StatKey, constants.JournalStatDayPrefix)
+			if stat.Count <= 0 {
+				continue
+			}
+			activeDays = append(activeDays, day)
+			if !strings.HasPrefix(day, yearPrefix) {
+				continue
+			}
+			date, err := utils.ParseDateParam(day, time.UTC)
+			if err != nil {
+				continue
+			}
+			response.Heatmap = append(response.Heatmap, models.JournalDayCount{
+				Date:  date.Format("2006-01-02"),
+				Count: stat.Count,
+				Words: stat.Words,
+			})
+			response.YearEntries += stat.Count
+			response.YearWords += stat.Words
+		}
+	}
+
+	sort.Slice(response.Heatmap, func(i, j int) bool { return response.Heatmap[i].Date < response.Heatmap[j].Date })
+	response.ActiveDays = len(response.Heatmap)
+	if response.TotalEntries > 0 {
+		response.AverageWords = response.TotalWords / response.TotalEntries
+	}
+	response.CurrentStreak, response.LongestStreak = utils.ComputeStreaks(activeDays, now)
+	return response
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"lambda-server/constants"
+	"lambda-server/database"
+	"lambda-server/models"
+	"lambda-server/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJournalTags handles GET /journals/tags?userId=...
+func GetJournalTags(c *gin.Context) {
+	userId := c.Query(constants.QueryParamUserId)
+	if userId == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Missing userId in query params",
+		})
+		return
+	}
+	ctx := context.Background()
+
+	tags, err := database.GetUserTagCounts(ctx, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to fetch tags",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TagListResponse{
+		Tags:  tags,
+		Count: len(tags),
+	})
+}
+
+// RenameJournalTag handles PUT /journals/tags/:tag?userId=...
+func RenameJournalTag(c *gin.Context) {
+	userId := c.Query(constants.QueryParamUserId)
+	if userId == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Missing userId in query params",
+		})
+		return
+	}
+
+	var req models.TagRenameRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request body",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	source, err := utils.NormalizeTag(c.Param(constants.QueryParamTag))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid tag",
+			Details: err.Error(),
+		})
+		return
+	}
+	target, err := utils.NormalizeTag(req.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid tag name",
+			Details: err.Error(),
+		})
+		return
+	}
+	if source == target {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "New tag name must be different from the current one",
+		})
+		return
+	}
+	ctx := context.Background()
+
+	updated, err := database.RetagJournals(ctx, userId, []string{source}, target)
+	if err != nil {
+		c.JSON(journalErrorStatus(err), models.ErrorResponse{
+			Error:   "Failed to rename tag",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Tag renamed successfully",
+		"tag":            target,
+		"updatedEntries": updated,
+	})
+}
+
+// MergeJournalTags handles POST /journals/tags/merge?userId=...
+func MergeJournalTags(c *gin.Context) {
+	userId := c.Query(constants.QueryParamUserId)
+	if userId == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Missing userId in query params",
+		})
+		return
+	}
+
+	var req models.TagMergeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request body",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	target, err := utils.NormalizeTag(req.Target)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid target tag",
+			Details: err.Error(),
+		})
+		return
+	}
+	sources := []string{}
+	for _, tag := range req.Sources {
+		source, err := utils.NormalizeTag(tag)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid source tag",
+				Details: err.Error(),
+			})
+			return
+		}
+		if source != target {
+			sources = append(sources, source)
+		}
+	}
+	if len(sources) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "At least one source tag different from the target is required",
+		})
+		return
+	}
+	ctx := context.Background()
+
+	updated, err := database.RetagJournals(ctx, userId, sources, target)
+	if err != nil {
+		c.JSON(journalErrorStatus(err), models.ErrorResponse{
+			Error:   "Failed to merge tags",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Tags merged successfully",
+		"tag":            target,
+		"updatedEntries": updated,
+	})
+}
@@ -2,11 +2,13 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"lambda-server/constants"
 	"lambda-server/database"
 	"lambda-server/models"
 	"lambda-server/utils"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -31,20 +33,65 @@ func CreateJournalEntry(c *gin.Context) {
 		return
 	}
 
+	tags, emotions, err := validateJournalMetadata(req.Tags, req.Mood, req.Emotions)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid journal metadata",
+			Details: err.Error(),
+		})
+		return
+	}
+
 	ctx := context.Background()
 
-	currentTime := time.Now()
+	if req.PromptId != "" {
+		if _, err := findActivePrompt(ctx, req.PromptId); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid promptId",
+				Details: err.Error(),
+			})
+			return
+		}
+	}
+
+	if req.SourceSessionId != "" {
+		session, err := database.GetChatSession(ctx, userId, req.SourceSessionId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "Failed to get source chat session",
+				Details: err.Error(),
+			})
+			return
+		}
+		if session == nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: "Invalid sourceSessionId",
+			})
+			return
+		}
+	}
+
+	loc := requestLocation(c)
+	currentTime := time.Now().In(loc)
 	entry := models.Journal{
-		UserId:    userId,
-		CreatedAt: currentTime.Unix(),
-		JournalID: utils.GenerateJournalID(),
-		Title:     req.Title,
-		Content:   req.Content,
-		Date:      currentTime.Format("20060102"),
-		UpdatedAt: currentTime.Unix(),
+		UserId:          userId,
+		CreatedAt:       currentTime.Unix(),
+		JournalID:       utils.GenerateJournalID(),
+		Title:           req.Title,
+		Content:         req.Content,
+		Date:            currentTime.Format("20060102"),
+		UpdatedAt:       currentTime.Unix(),
+		Tags:            tags,
+		Mood:            req.Mood,
+		Emotions:        emotions,
+		Pinned:          req.Pinned,
+		PromptId:        req.PromptId,
+		TimeZone:        loc.String(),
+		ExcludeFromChat: req.ExcludeFromChat,
+		SourceSessionId: req.SourceSessionId,
 	}
 
-	err := database.CreateJournalEntry(ctx, entry)
+	err = database.CreateJournalEntry(ctx, entry)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "Failed to create journal entry",
@@ -52,10 +99,14 @@ func CreateJournalEntry(c *gin.Context) {
 		})
 		return
 	}
+	analyzeJournalInBackground(entry)
+	check := checkJournalRisk(c, entry)
 
 	c.JSON(http.StatusCreated, models.JournalResponse{
-		Journal: entry,
-		Message: "Journal entry created successfully",
+		Journal:         entry,
+		Message:         "Journal entry created successfully",
+		Risk:            check.responseRisk(),
+		CrisisResources: check.resources,
 	})
 }
 
@@ -82,11 +133,18 @@ func GetJournalEntry(c *gin.Context) {
 
 	foundEntry, err := database.GetJournalByID(ctx, userId, journalId)
 	if err != nil {
-		c.JSON(http.StatusNotFound, models.ErrorResponse{
+		c.JSON(journalErrorStatus(err), models.ErrorResponse{
 			Error: err.Error(),
 		})
 		return
 	}
+	// Trashed entries are only listed by GET /journals/trash
+	if foundEntry.DeletedAt != 0 {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: database.ErrJournalInTrash.Error(),
+		})
+		return
+	}
 
 	c.JSON(http.StatusOK, models.JournalResponse{Journal: *foundEntry})
 }
@@ -114,17 +172,120 @@ func DeleteJournalEntry(c *gin.Context) {
 
 	err := database.DeleteJournalEntry(ctx, userId, journalId)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		c.JSON(journalErrorStatus(err), models.ErrorResponse{
 			Error:   "Failed to delete journal entry",
 			Details: err.Error(),
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Journal entry deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Journal entry moved to trash"})
 }
 
-// GetAllJournalEntries handles GET /journals?userId=...
+// GetTrashedJournalEntries handles GET /journals/trash?userId=...
+func GetTrashedJournalEntries(c *gin.Context) {
+	userId := c.Query(constants.QueryParamUserId)
+	if userId == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Missing userId in query params",
+		})
+		return
+	}
+	ctx := context.Background()
+
+	entries, err := database.GetUserTrashedJournals(ctx, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to fetch trashed journal entries",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.JournalListResponse{
+		Journals: entries,
+		Count:    len(entries),
+	})
+}
+
+// RestoreJournalEntry handles POST /journals/:journalId/restore?userId=...
+func RestoreJournalEntry(c *gin.Context) {
+	journalId := c.Param(constants.QueryParamJournalId)
+	if journalId == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Missing journalId in path",
+		})
+		return
+	}
+	userId := c.Query(constants.QueryParamUserId)
+	if userId == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Missing userId in query params",
+		})
+		return
+	}
+	ctx := context.Background()
+
+	if err := database.RestoreJournalEntry(ctx, userId, journalId); err != nil {
+		c.JSON(journalErrorStatus(err), models.ErrorResponse{
+			Error:   "Failed to restore journal entry",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	restoredEntry, err := database.GetJournalByID(ctx, userId, journalId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to fetch restored journal entry",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.JournalResponse{
+		Journal: *restoredEntry,
+		Message: "Journal entry restored successfully",
+	})
+}
+
+// PermanentlyDeleteJournalEntry handles DELETE /journals/:journalId/permanent?userId=...
+func PermanentlyDeleteJournalEntry(c *gin.Context) {
+	journalId := c.Param(constants.QueryParamJournalId)
+	if journalId == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Missing journalId in path",
+		})
+		return
+	}
+	userId := c.Query(constants.QueryParamUserId)
+	if userId == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Missing userId in query params",
+		})
+		return
+	}
+	ctx := context.Background()
+
+	if err := database.PermanentlyDeleteJournalEntry(ctx, userId, journalId); err != nil {
+		c.JSON(journalErrorStatus(err), models.ErrorResponse{
+			Error:   "Failed to permanently delete journal entry",
+			Details: err.Error(),
+		})
+		return
+	}
+	if err := DeleteJournalAttachments(ctx, userId, journalId); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Journal entry deleted but its attachments could not be removed",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Journal entry deleted permanently"})
+}
+
+// GetAllJournalEntries handles GET /journals?userId=...&tag=...&mood=...
 func GetAllJournalEntries(c *gin.Context) {
 	userId := c.Query(constants.QueryParamUserId)
 	if userId == "" {
@@ -133,10 +294,35 @@ func GetAllJournalEntries(c *gin.Context) {
 		})
 		return
 	}
+
+	var filter models.JournalFilter
+	if tag := c.Query(constants.QueryParamTag); tag != "" {
+		normalized, err := utils.NormalizeTag(tag)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid tag filter",
+				Details: err.Error(),
+			})
+			return
+		}
+		filter.Tag = normalized
+	}
+	if mood := c.Query(constants.QueryParamMood); mood != "" {
+		parsed, err := strconv.Atoi(mood)
+		if err == nil {
+			err = utils.ValidateMood(parsed)
+		}
+		if err != nil || parsed == 0 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: "Invalid mood filter",
+			})
+			return
+		}
+		filter.Mood = parsed
+	}
 	ctx := context.Background()
 
-	// For now, scan all and filter in handler (for demo/testing)
-	entries, err := database.GetUserJournals(ctx, userId)
+	entries, err := database.GetUserJournals(ctx, userId, filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "Failed to fetch journal entries",
@@ -180,11 +366,25 @@ func UpdateJournalEntry(c *gin.Context) {
 		})
 		return
 	}
+	mood := 0
+	if updateData.Mood != nil {
+		mood = *updateData.Mood
+	}
+	tags, emotions, err := validateJournalMetadata(updateData.Tags, mood, updateData.Emotions)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid journal metadata",
+			Details: err.Error(),
+		})
+		return
+	}
+	updateData.Tags = tags
+	updateData.Emotions = emotions
 	ctx := context.Background()
 
-	err := database.UpdateJournalEntry(ctx, userId, journalId, updateData.Title, updateData.Content)
+	err = database.UpdateJournalEntry(ctx, userId, journalId, updateData)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		c.JSON(journalErrorStatus(err), models.ErrorResponse{
 			Error:   "Failed to update journal entry",
 			Details: err.Error(),
 		})
@@ -200,9 +400,53 @@ func UpdateJournalEntry(c *gin.Context) {
 		})
 		return
 	}
+	analyzeJournalInBackground(*updatedEntry)
+	check := checkJournalRisk(c, *updatedEntry)
 
 	c.JSON(http.StatusOK, models.JournalResponse{
-		Journal: *updatedEntry,
-		Message: "Journal entry updated successfully",
+		Journal:         *updatedEntry,
+		Message:         "Journal entry updated successfully",
+		Risk:            check.responseRisk(),
+		CrisisResources: check.resources,
 	})
 }
+
+// journalErrorStatus maps a journal database error to its HTTP status: 404 for a missing entry,
+// 409 when the entry is not in the trash state the operation needs, otherwise 500
+func journalErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrJournalNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrJournalInTrash), errors.Is(err, database.ErrJournalNotInTrash),
+		errors.Is(err, database.ErrJournalChanged):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// requestLocation returns the authenticated user's time zone, falling back to the
+// X-Timezone header sent by the client and then UTC
+func requestLocation(c *gin.Context) *time.Location {
+	var user *models.User
+	if value, exists := c.Get("user"); exists {
+		user, _ = value.(*models.User)
+	}
+	return utils.UserLocation(user, c.GetHeader(constants.HeaderTimezone))
+}
+
+// validateJournalMetadata normalizes tags and emotion labels and checks the mood rating
+func validateJournalMetadata(tags []string, mood int, emotions []string) ([]string, []string, error) {
+	normalizedTags, err := utils.NormalizeTags(tags)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := utils.ValidateMood(mood); err != nil {
+		return nil, nil, err
+	}
+	normalizedEmotions, err := utils.NormalizeEmotions(emotions)
+	if err != nil {
+		return nil, nil, err
+	}
+	return normalizedTags, normalizedEmotions, nil
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"lambda-server/constants"
+	"lambda-server/database"
+	"lambda-server/models"
+	"lambda-server/moderation"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// replyModeration checks every AI reply before it is stored and sent
+var replyModeration = moderation.Default()
+
+// moderate checks the AI reply of the turn and returns the text to store and send. The
+// decision is kept on the chat context and stored with the reply.
+func (chat *chatContext) moderate(req ChatRequest, reply string) string {
+	decision, sent, original := replyModeration.Moderate(moderation.Input{Reply: reply, UserMessage: req.Message})
+	chat.moderation = &decision
+	chat.moderatedFrom = original
+	return sent
+}
+
+// moderated returns the decision to show the client, which is only set when the reply was changed
+func (chat *chatContext) moderated() *models.ModerationDecision {
+	if chat.moderation == nil || chat.moderation.Action == moderation.ActionAllow {
+		return nil
+	}
+	return chat.moderation
+}
+
+// logModeration stores a changed reply for review. Failures are logged, not returned, so
+// they never cost the user their reply.
+func logModeration(req ChatRequest, chat *chatContext, aiMsg *models.ChatMessage) {
+	if chat.moderated() == nil {
+		return
+	}
+	entry := models.ModerationLog{
+		Date:      time.Unix(aiMsg.Timestamp, 0).UTC().Format("20060102"),
+		Id:        fmt.Sprintf("%d#%s#%s#%s", aiMsg.Timestamp, req.UserId, req.SessionId, aiMsg.ID()),
+		UserId:    req.UserId,
+		SessionId: req.SessionId,
+		Timestamp: aiMsg.Timestamp,
+		MessageId: aiMsg.MessageId,
+		Decision:  *chat.moderation,
+		Original:  chat.moderatedFrom,
+		Sent:      aiMsg.Message,
+	}
+	if err := database.SaveModerationLog(context.Background(), entry); err != nil {
+		log.Printf("Failed to log moderation of chat session %s: %v\n", req.SessionId, err)
+	}
+}
+
+// GetModerationLogs handles GET /admin/moderation, listing the replies moderation changed on
+// a day (YYYYMMDD, UTC; default today), optionally only those with the given action
+func GetModerationLogs(c *gin.Context) {
+	date := c.DefaultQuery(constants.QueryParamDate, time.Now().UTC().Format("20060102"))
+	if _, err := time.Parse("20060102", date); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid date, expected YYYYMMDD",
+			Details: err.Error(),
+		})
+		return
+	}
+	action := c.Query(constants.QueryParamAction)
+	if action != "" && !slices.Contains([]string{moderation.ActionDisclaimer, moderation.ActionRewrite, moderation.ActionBlock}, action) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid action, expected disclaimer, rewrite or block",
+		})
+		return
+	}
+
+	logs, err := database.GetModerationLogs(c.Request.Context(), date, action, constants.ModerationLogPageMax)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to get moderation logs",
+			Details: err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, models.ModerationLogListResponse{Logs: logs, Count: len(logs)})
+}
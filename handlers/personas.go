@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"lambda-server/constants"
+	"lambda-server/database"
+	"lambda-server/models"
+	"lambda-server/personas"
+	"lambda-server/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errPersonaUnavailable is returned when a chat request picks a missing or inactive persona
+var errPersonaUnavailable = errors.New("persona not found or inactive")
+
+// GetPersonas handles GET /personas, listing the personas users can pick for a chat session
+func GetPersonas(c *gin.Context) {
+	library, err := loadPersonaLibrary(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to retrieve personas", Details: err.Error()})
+		return
+	}
+
+	summaries := []models.PersonaSummary{}
+	for _, persona := range library {
+		if persona.Active {
+			summaries = append(summaries, models.PersonaSummary{
+				PersonaId:   persona.PersonaId,
+				Version:     persona.Version,
+				Name:        persona.Name,
+				Description: persona.Description,
+				Default:     persona.PersonaId == constants.DefaultPersonaId,
+			})
+		}
+	}
+
+	c.JSON(http.StatusOK, models.PersonaListResponse{
+		Personas: summaries,
+		Count:    len(summaries),
+	})
+}
+
+// GetAdminPersonas handles GET /admin/personas, returning the latest version of every persona
+func GetAdminPersonas(c *gin.Context) {
+	library, err := loadPersonaLibrary(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to retrieve personas", Details: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.AdminPersonaListResponse{
+		Personas: library,
+		Count:    len(library),
+	})
+}
+
+// GetAdminPersonaVersions handles GET /admin/personas/:personaId/versions, oldest first
+func GetAdminPersonaVersions(c *gin.Context) {
+	personaId := c.Param(constants.QueryParamPersonaId)
+	stored, err := database.GetStoredPersonas(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to retrieve personas", Details: err.Error()})
+		return
+	}
+	versions := personas.Versions(personaId, stored)
+	if len(versions) == 0 {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Persona not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.AdminPersonaListResponse{
+		Personas: versions,
+		Count:    len(versions),
+	})
+}
+
+// CreatePersona handles POST /admin/personas
+func CreatePersona(c *gin.Context) {
+	var req models.PersonaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request body", Details: err.Error()})
+		return
+	}
+	req, err := personas.Validate(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid persona", Details: err.Error()})
+		return
+	}
+
+	persona := personaVersion(c, req, utils.GeneratePersonaID(), 1)
+	persona.Active = req.Active == nil || *req.Active
+	if err := database.CreatePersonaVersion(c.Request.Context(), persona); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to create persona", Details: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.AdminPersonaResponse{
+		Persona: persona,
+		Message: "Persona created successfully",
+	})
+}
+
+// UpdatePersona handles PUT /admin/personas/:personaId
+// Every update stores a new version; earlier versions are kept for auditing.
+func UpdatePersona(c *gin.Context) {
+	personaId := c.Param(constants.QueryParamPersonaId)
+	var req models.PersonaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request body", Details: err.Error()})
+		return
+	}
+	req, err := personas.Validate(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid persona", Details: err.Error()})
+		return
+	}
+	if personaId == constants.DefaultPersonaId && req.Active != nil && !*req.Active {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "The default persona cannot be deactivated"})
+		return
+	}
+
+	library, err := loadPersonaLibrary(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to retrieve personas", Details: err.Error()})
+		return
+	}
+	existing := personas.Find(library, personaId)
+	if existing == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Persona not found"})
+		return
+	}
+
+	persona := personaVersion(c, req, personaId, existing.Version+1)
+	persona.Active = existing.Active
+	if req.Active != nil {
+		persona.Active = *req.Active
+	}
+	persona.BuiltIn = existing.BuiltIn
+	if !savePersonaVersion(c, persona) {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.AdminPersonaResponse{
+		Persona: persona,
+		Message: "Persona updated successfully",
+	})
+}
+
+// DeletePersona handles DELETE /admin/personas/:personaId
+// Personas are never removed, so messages stay traceable to their instructions; deleting
+// stores an inactive version instead. Sessions using it fall back to the default persona.
+func DeletePersona(c *gin.Context) {
+	personaId := c.Param(constants.QueryParamPersonaId)
+	if personaId == constants.DefaultPersonaId {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "The default persona cannot be deactivated"})
+		return
+	}
+
+	library, err := loadPersonaLibrary(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to retrieve personas", Details: err.Error()})
+		return
+	}
+	existing := personas.Find(library, personaId)
+	if existing == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Persona not found"})
+		return
+	}
+	if existing.Active {
+		persona := *existing
+		persona.Version++
+		persona.Active = false
+		persona.CreatedAt = time.Now().Unix()
+		persona.UpdatedBy = adminUserId(c)
+		if !savePersonaVersion(c, persona) {
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Persona deactivated successfully"})
+}
+
+// loadPersonaLibrary returns the latest version of every built-in and stored persona
+func loadPersonaLibrary(ctx context.Context) ([]models.Persona, error) {
+	stored, err := database.GetStoredPersonas(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return personas.Latest(stored), nil
+}
+
+// resolveChatPersona picks the persona for a chat message: the one requested, else the one
+// stored on the session, else the default. A requested persona must be active; a session's
+// persona that has since been deactivated falls back to the default.
+func resolveChatPersona(ctx context.Context, requested string, session *models.ChatSession) (*models.Persona, error) {
+	library, err := loadPersonaLibrary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if requested != "" {
+		persona := personas.Find(library, requested)
+		if persona == nil || !persona.Active {
+			return nil, errPersonaUnavailable
+		}
+		return persona, nil
+	}
+	if session != nil && session.PersonaId != "" {
+		if persona := personas.Find(library, session.PersonaId); persona != nil && persona.Active {
+			return persona, nil
+		}
+	}
+	return personas.Find(library, constants.DefaultPersonaId), nil
+}
+
+// personaVersion builds a persona version from a validated request
+func personaVersion(c *gin.Context, req models.PersonaRequest, personaId string, version int) models.Persona {
+	return models.Persona{
+		PersonaId:    personaId,
+		Version:      version,
+		Name:         req.Name,
+		Description:  req.Description,
+		SystemPrompt: req.SystemPrompt,
+		Guardrails:   req.Guardrails,
+		Style:        req.Style,
+		CreatedAt:    time.Now().Unix(),
+		UpdatedBy:    adminUserId(c),
+	}
+}
+
+// savePersonaVersion stores a persona version, writing the error response on failure
+func savePersonaVersion(c *gin.Context, persona models.Persona) bool {
+	err := database.CreatePersonaVersion(c.Request.Context(), persona)
+	if errors.Is(err, database.ErrPersonaVersionExists) {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Persona was changed by another request, reload and try again"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to save persona", Details: err.Error()})
+		return false
+	}
+	return true
+}
+
+// adminUserId returns the userId of the signed-in admin
+func adminUserId(c *gin.Context) string {
+	if user, exists := c.Get("user"); exists {
+		return user.(*models.User).UserId
+	}
+	return ""
+}
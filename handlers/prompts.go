@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"lambda-server/constants"
+	"lambda-server/database"
+	"lambda-server/models"
+	"lambda-server/prompts"
+	"lambda-server/utils"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPrompts handles GET /prompts?userId=...&category=...&locale=...
+// It returns the active prompt library in the requested locale (or the Accept-Language header).
+func GetPrompts(c *gin.Context) {
+	category := c.Query(constants.QueryParamCategory)
+	if category != "" && !slices.Contains(constants.PromptCategories, category) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid category, expected one of " + strings.Join(constants.PromptCategories, ", "),
+		})
+		return
+	}
+	ctx := context.Background()
+
+	library, err := loadPromptLibrary(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to retrieve prompts", Details: err.Error()})
+		return
+	}
+
+	locale := requestLocale(c)
+	localized := []models.LocalizedPrompt{}
+	for _, prompt := range library {
+		if prompt.Active && (category == "" || prompt.Category == category) {
+			localized = append(localized, prompts.Localize(prompt, locale))
+		}
+	}
+
+	c.JSON(http.StatusOK, models.PromptListResponse{
+		Prompts: localized,
+		Count:   len(localized),
+	})
+}
+
+// GetDailyPrompt handles GET /prompts/daily?userId=...&locale=...
+// The prompt is chosen from the user's recent journal moods and latest MindMuse score, skipping
+// prompts they saw or wrote about recently, and stays the same for the rest of the user's day.
+func GetDailyPrompt(c *gin.Context) {
+	userId := c.Query(constants.QueryParamUserId)
+	if userId == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Missing userId in query params",
+		})
+		return
+	}
+	ctx := context.Background()
+
+	library, err := loadPromptLibrary(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to retrieve prompts", Details: err.Error()})
+		return
+	}
+
+	now := time.Now().In(requestLocation(c))
+	today := now.Format("20060102")
+	windowStart := now.AddDate(0, 0, -constants.PromptRepeatWindowDays)
+
+	history, err := database.GetPromptHistory(ctx, userId, windowStart.Format("20060102"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to retrieve prompt history", Details: err.Error()})
+		return
+	}
+	recent := map[string]bool{}
+	for _, entry := range history {
+		if entry.Date == today {
+			if prompt := findPrompt(library, entry.PromptId); prompt != nil {
+				c.JSON(http.StatusOK, models.DailyPromptResponse{Prompt: prompts.Localize(*prompt, requestLocale(c)), Date: today})
+				return
+			}
+		}
+		recent[entry.PromptId] = true
+	}
+
+	signals, err := promptSignals(ctx, userId, now, recent)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to retrieve recent journals", Details: err.Error()})
+		return
+	}
+
+	selected := prompts.Select(library, signals, recent, userId+":"+today)
+	if selected == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "No prompts available"})
+		return
+	}
+
+	recorded, err := database.RecordDailyPrompt(ctx, models.PromptHistory{
+		UserId:    userId,
+		Date:      today,
+		PromptId:  selected.PromptId,
+		CreatedAt: now.Unix(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to record daily prompt", Details: err.Error()})
+		return
+	}
+	// A concurrent request may have recorded a different prompt first
+	if prompt := findPrompt(library, recorded.PromptId); prompt != nil {
+		selected = prompt
+	}
+
+	c.JSON(http.StatusOK, models.DailyPromptResponse{
+		Prompt: prompts.Localize(*selected, requestLocale(c)),
+		Date:   today,
+	})
+}
+
+// GetAdminPrompts handles GET /admin/prompts, returning every prompt with all its translations
+func GetAdminPrompts(c *gin.Context) {
+	ctx := context.Background()
+
+	library, err := loadPromptLibrary(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to retrieve prompts", Details: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.AdminPromptListResponse{
+		Prompts: library,
+		Count:   len(library),
+	})
+}
+
+// CreatePrompt handles POST /admin/prompts
+func CreatePrompt(c *gin.Context) {
+	var req models.PromptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request body", Details: err.Error()})
+		return
+	}
+	texts, err := validatePromptRequest(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid prompt", Details: err.Error()})
+		return
+	}
+	ctx := context.Background()
+
+	now := time.Now().Unix()
+	prompt := models.Prompt{
+		PromptId:  utils.GeneratePromptID(),
+		Category:  req.Category,
+		Texts:     texts,
+		Active:    req.Active == nil || *req.Active,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := database.SavePrompt(ctx, prompt); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to create prompt", Details: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.AdminPromptResponse{
+		Prompt:  prompt,
+		Message: "Prompt created successfully",
+	})
+}
+
+// UpdatePrompt handles PUT /admin/prompts/:promptId
+// Updating a built-in prompt stores an override with the same ID.
+func UpdatePrompt(c *gin.Context) {
+	promptId := c.Param(constants.QueryParamPromptId)
+	var req models.PromptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request body", Details: err.Error()})
+		return
+	}
+	texts, err := validatePromptRequest(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid prompt", Details: err.Error()})
+		return
+	}
+	ctx := context.Background()
+
+	library, err := loadPromptLibrary(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to retrieve prompts", Details: err.Error()})
+		return
+	}
+	existing := findPrompt(library, promptId)
+	if existing == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Prompt not found"})
+		return
+	}
+
+	prompt := *existing
+	prompt.Category = req.Category
+	prompt.Texts = texts
+	if req.Active != nil {
+		prompt.Active = *req.Active
+	}
+	prompt.UpdatedAt = time.Now().Unix()
+	if prompt.CreatedAt == 0 {
+		prompt.CreatedAt = prompt.UpdatedAt
+	}
+	if err := database.SavePrompt(ctx, prompt); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to update prompt", Details: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.AdminPromptResponse{
+		Prompt:  prompt,
+		Message: "Prompt updated successfully",
+	})
+}
+
+// DeletePrompt handles DELETE /admin/prompts/:promptId
+// Built-in prompts cannot be deleted; deleting an override restores the built-in version.
+func DeletePrompt(c *gin.Context) {
+	promptId := c.Param(constants.QueryParamPromptId)
+	ctx := context.Background()
+
+	stored, err := database.GetStoredPrompts(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to retrieve prompts", Details: err.Error()})
+		return
+	}
+	if findPrompt(stored, promptId) == nil {
+		if findPrompt(prompts.BuiltIn(), promptId) != nil {
+			c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Built-in prompts cannot be deleted, set active to false instead"})
+			return
+		}
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Prompt not found"})
+		return
+	}
+
+	if err := database.DeletePrompt(ctx, promptId); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to delete prompt", Details: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Prompt deleted successfully"})
+}
+
+// loadPromptLibrary returns the built-in prompts merged with the ones managed through the admin API
+func loadPromptLibrary(ctx context.Context) ([]models.Prompt, error) {
+	stored, err := database.GetStoredPrompts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return prompts.Merge(stored), nil
+}
+
+// findActivePrompt looks up an active prompt by ID, for journal entries started from a prompt
+func findActivePrompt(ctx context.Context, promptId string) (*models.Prompt, error) {
+	library, err := loadPromptLibrary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	prompt := findPrompt(library, promptId)
+	if prompt == nil || !prompt.Active {
+		return nil, fmt.Errorf("prompt %q not found", promptId)
+	}
+	return prompt, nil
+}
+
+func findPrompt(library []models.Prompt, promptId string) *models.Prompt {
+	for i := range library {
+		if library[i].PromptId == promptId {
+			return &library[i]
+		}
+	}
+	return nil
+}
+
+// promptSignals collects the user's average journal mood over the lookback window and latest score.
+// Prompts the user wrote entries from within the repeat window are added to recent.
+func promptSignals(ctx context.Context, userId string, now time.Time, recent map[string]bool) (prompts.Signals, error) {
+	signals := prompts.Signals{}
+	moodCutoff := now.AddDate(0, 0, -constants.PromptMoodLookbackDays).Unix()
+	from := now.AddDate(0, 0, -constants.PromptRepeatWindowDays).Unix()
+
+	moodTotal, moodCount := 0, 0
+	err := database.IterateUserJournals(ctx, userId, from, now.Unix(), func(journal models.Journal) error {
+		if journal.PromptId != "" {
+			recent[journal.PromptId] = true
+		}
+		if journal.Mood > 0 && journal.CreatedAt >= moodCutoff {
+			moodTotal += journal.Mood
+			moodCount++
+		}
+		return nil
+	})
+	if err != nil {
+		return signals, err
+	}
+	if moodCount > 0 {
+		signals.AverageMood = float64(moodTotal) / float64(moodCount)
+	}
+
+	// The score only refines the choice, so a failure to read it is not fatal
+	score, err := database.GetLatestMindMuseScore(ctx, userId)
+	if err != nil {
+		log.Printf("Failed to get latest MindMuse score for %s: %v\n", userId, err)
+	} else if score != nil {
+		signals.Score, signals.HasScore = score.Score, true
+	}
+	return signals, nil
+}
+
+// requestLocale returns the locale from the query params, falling back to the Accept-Language header
+func requestLocale(c *gin.Context) string {
+	if locale := c.Query(constants.QueryParamLocale); locale != "" {
+		return locale
+	}
+	return utils.PreferredLocale(c.GetHeader("Accept-Language"))
+}
+
+// validatePromptRequest checks the category and trims the translations of a prompt.
+// A text in the default locale is required so every user can be shown the prompt.
+func validatePromptRequest(req models.PromptRequest) (map[string]string, error) {
+	if !slices.Contains(constants.PromptCategories, req.Category) {
+		return nil, fmt.Errorf("category must be one of %s", strings.Join(constants.PromptCategories, ", "))
+	}
+	texts := map[string]string{}
+	for locale, text := range req.Texts {
+		locale = strings.TrimSpace(locale)
+		text = strings.TrimSpace(text)
+		if locale == "" || text == "" {
+			return nil, fmt.Errorf("locales and texts cannot be empty")
+		}
+		if len([]rune(text)) > constants.PromptMaxTextLength {
+			return nil, fmt.Errorf("text for %q is longer than %d characters", locale, constants.PromptMaxTextLength)
+		}
+		texts[locale] = text
+	}
+	if texts[constants.PromptDefaultLocale] == "" {
+		return nil, fmt.Errorf("a %q text is required", constants.PromptDefaultLocale)
+	}
+	return texts, nil
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"lambda-server/chatcontext"
+	"lambda-server/constants"
+	"lambda-server/database"
+	"lambda-server/helpers"
+	"lambda-server/llm"
+	"lambda-server/models"
+	"lambda-server/ratelimit"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errChatUserMismatch is returned when a chat request names a user other than the signed-in one
+var errChatUserMismatch = errors.New("userId does not match the signed-in user")
+
+// checkChatUser makes sure the userId in a chat request is the signed-in user, so nobody can
+// chat as, or spend the quota of, someone else
+func checkChatUser(c *gin.Context, req ChatRequest) error {
+	if userId := c.GetString("userId"); userId != req.UserId {
+		return errChatUserMismatch
+	}
+	return nil
+}
+
+// setUsage keeps the token usage of the reply on the chat context, estimating it from the
+// text when the provider did not report it
+func (chat *chatContext) setUsage(resp *llm.Response, reply string) {
+	usage := models.TokenUsage{}
+	if resp != nil {
+		usage.PromptTokens, usage.CompletionTokens = resp.Usage.PromptTokens, resp.Usage.CompletionTokens
+	}
+	if usage.PromptTokens == 0 && usage.CompletionTokens == 0 {
+		usage = models.TokenUsage{
+			PromptTokens:     chatcontext.EstimateMessagesTokens(chat.messages),
+			CompletionTokens: chatcontext.EstimateTokens(reply),
+			Estimated:        true,
+		}
+	}
+	chat.usage = &usage
+}
+
+// recordChatUsage counts the message and its tokens against the user's daily quota
+func recordChatUsage(req ChatRequest, chat *chatContext) {
+	if chat.usage == nil {
+		return
+	}
+	if err := database.RecordUsage(context.Background(), req.UserId, ratelimit.Day(time.Now()), *chat.usage); err != nil {
+		log.Printf("Failed to record chat usage of user %s: %v\n", req.UserId, err)
+	}
+}
+
+// GetUserQuota handles GET /admin/users/:userId/quota, showing a user's plan, limits and
+// usage today
+func GetUserQuota(c *gin.Context) {
+	user, err := helpers.GetUserByID(c.Param(constants.QueryParamUserId))
+	if err != nil || user == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "User not found",
+		})
+		return
+	}
+	respondUserQuota(c, user)
+}
+
+// UpdateUserQuota handles PUT /admin/users/:userId/quota, changing a user's plan and/or
+// overriding its limits
+func UpdateUserQuota(c *gin.Context) {
+	var req models.QuotaUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request body",
+			Details: err.Error(),
+		})
+		return
+	}
+	if req.Plan != nil && !slices.Contains(constants.Plans, *req.Plan) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid plan, expected free, plus or pro",
+		})
+		return
+	}
+	if req.Override != nil && req.ClearOverride {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Set either override or clearOverride, not both",
+		})
+		return
+	}
+	user, err := helpers.GetUserByID(c.Param(constants.QueryParamUserId))
+	if err != nil || user == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "User not found",
+		})
+		return
+	}
+
+	if req.Plan != nil {
+		user.Plan = *req.Plan
+	}
+	if req.Override != nil {
+		user.ChatLimitsOverride = req.Override
+	}
+	if req.ClearOverride {
+		user.ChatLimitsOverride = nil
+	}
+	if err := helpers.UpdateUser(user); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to update user",
+			Details: err.Error(),
+		})
+		return
+	}
+	log.Printf("Admin %s changed the chat limits of user %s\n", adminUserId(c), user.UserId)
+	respondUserQuota(c, user)
+}
+
+func respondUserQuota(c *gin.Context, user *models.User) {
+	now := time.Now()
+	usage, err := database.GetDailyUsage(c.Request.Context(), user.UserId, ratelimit.Day(now))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to get usage",
+			Details: err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, models.QuotaResponse{
+		UserId:   user.UserId,
+		Plan:     ratelimit.Plan(user),
+		Override: user.ChatLimitsOverride,
+		Limits:   ratelimit.Limits(user),
+		Usage:    usage,
+		ResetsAt: ratelimit.NextReset(now).Unix(),
+	})
+}
@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"lambda-server/constants"
+	"lambda-server/database"
+	"lambda-server/helpers"
+	"lambda-server/middlewares"
+	"lambda-server/models"
+	"lambda-server/realtime"
+	"lambda-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// The realtime connections open on this instance, and the local day each user was last
+// reminded to log their mood. Like the rate limit memory store, they are per instance.
+var (
+	realtimeHub           = realtime.NewHub()
+	realtimeRemindersOnce sync.Once
+	moodRemindersMu       sync.Mutex
+	moodReminded          = map[string]string{}
+)
+
+// realtimeUpgrader accepts WebSocket handshakes, selecting the protocol clients offer their
+// access token under. Connections are authorized by that token rather than cookies, so pages
+// on any origin may connect.
+var realtimeUpgrader = websocket.Upgrader{
+	Subprotocols: []string{realtime.AuthProtocol},
+	CheckOrigin:  func(*http.Request) bool { return true },
+}
+
+// HandleRealtime handles GET /chat/ws, upgrading the request to a WebSocket that carries chat
+// replies, typing indicators and notifications pushed by the server, as described in the
+// realtime package. It is served by the local server; the Lambda deployment uses an API
+// Gateway WebSocket API instead (see HandleRealtimeEvent).
+// The server pings every RealtimeHeartbeatSeconds and closes a connection that stays silent
+// for two intervals. Tokens are merged while the client is slow to read; a client too far
+// behind for that is disconnected with close code 1013.
+func HandleRealtime(c *gin.Context) {
+	if !utils.IsRunningLocally() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "WebSocket is not served here", "details": "Connect to the realtime WebSocket API"})
+		return
+	}
+	value, _ := c.Get("user")
+	user, ok := value.(*models.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	ws, err := realtimeUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("WebSocket handshake for user %s failed: %v\n", user.UserId, err)
+		return
+	}
+	newRealtimeConn(ws, user, requestLocale(c)).serve()
+}
+
+// realtimeConn is a WebSocket connection of the local server
+type realtimeConn struct {
+	ws      *websocket.Conn
+	user    *models.User
+	locale  string
+	queue   *realtime.Queue
+	ctx     context.Context // Ends when the connection closes
+	cancel  context.CancelFunc
+	mu      sync.Mutex
+	replies map[string]context.CancelFunc // Replies being generated, by chat message id
+}
+
+func newRealtimeConn(ws *websocket.Conn, user *models.User, locale string) *realtimeConn {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &realtimeConn{
+		ws:      ws,
+		user:    user,
+		locale:  locale,
+		queue:   realtime.NewQueue(constants.RealtimeQueueSize),
+		ctx:     ctx,
+		cancel:  cancel,
+		replies: map[string]context.CancelFunc{},
+	}
+}
+
+// serve runs the connection until the client leaves or falls behind
+func (conn *realtimeConn) serve() {
+	defer conn.close()
+	realtimeHub.Add(conn.user.UserId, conn.queue)
+	defer realtimeHub.Remove(conn.user.UserId, conn.queue)
+	startRealtimeReminders()
+
+	go conn.writeLoop()
+	go conn.pingLoop()
+	conn.send(realtime.Event{Type: realtime.EventReady, Data: realtime.Ready{HeartbeatSeconds: constants.RealtimeHeartbeatSeconds}})
+	go remindMood(conn.ctx, conn.user)
+	conn.readLoop()
+}
+
+// close stops the connection's replies and goroutines and closes the socket
+func (conn *realtimeConn) close() {
+	conn.cancel()
+	conn.queue.Close()
+	conn.ws.Close()
+}
+
+// readLoop handles client messages until the connection fails or goes silent
+func (conn *realtimeConn) readLoop() {
+	heartbeat := time.Duration(constants.RealtimeHeartbeatSeconds) * time.Second
+	extend := func() { conn.ws.SetReadDeadline(time.Now().Add(2 * heartbeat)) }
+	conn.ws.SetReadLimit(int64(constants.RealtimeMessageMaxBytes))
+	conn.ws.SetPongHandler(func(string) error {
+		extend()
+		return nil
+	})
+	extend()
+	for {
+		_, data, err := conn.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		extend()
+		msg, err := realtime.ParseClientMessage(data)
+		if err != nil {
+			conn.send(realtime.ErrorEvent(msg.Id, "Invalid message", err))
+			continue
+		}
+		conn.handle(msg)
+	}
+}
+
+// writeLoop writes queued events to the socket in order
+func (conn *realtimeConn) writeLoop() {
+	for {
+		event, err := conn.queue.Pop(conn.ctx)
+		if err != nil {
+			return
+		}
+		data, err := json.Marshal(event)
+		if err != nil {
+			log.Printf("Failed to encode realtime event %s: %v\n", event.Type, err)
+			continue
+		}
+		conn.ws.SetWriteDeadline(realtimeWriteDeadline())
+		if err := conn.ws.WriteMessage(websocket.TextMessage, data); err != nil {
+			conn.close()
+			return
+		}
+	}
+}
+
+// pingLoop sends a ping every heartbeat interval; the client's pongs keep the connection open
+func (conn *realtimeConn) pingLoop() {
+	ticker := time.NewTicker(time.Duration(constants.RealtimeHeartbeatSeconds) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-conn.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := conn.ws.WriteControl(websocket.PingMessage, nil, realtimeWriteDeadline()); err != nil {
+				conn.close()
+				return
+			}
+		}
+	}
+}
+
+// send queues an event for the client, disconnecting a client that has fallen too far behind
+func (conn *realtimeConn) send(event realtime.Event) {
+	err := conn.queue.Push(event)
+	if errors.Is(err, realtime.ErrQueueFull) {
+		log.Printf("Realtime client of user %s is too slow, disconnecting\n", conn.user.UserId)
+		conn.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "client too slow"), realtimeWriteDeadline())
+		conn.close()
+	}
+}
+
+// realtimeWriteDeadline is when a write to a socket started now must have finished
+func realtimeWriteDeadline() time.Time {
+	return time.Now().Add(time.Duration(constants.RealtimeWriteTimeoutSeconds) * time.Second)
+}
+
+// handle acts on a client message
+func (conn *realtimeConn) handle(msg realtime.ClientMessage) {
+	switch msg.Type {
+	case realtime.TypePing:
+		conn.send(realtime.Event{Type: realtime.EventPong})
+	case realtime.TypeTyping:
+		typing := realtime.Typing{SessionId: msg.SessionId, From: realtime.TypingUser, Typing: msg.Typing}
+		realtimeHub.Send(conn.user.UserId, realtime.Event{Type: realtime.EventTyping, Data: typing}, conn.queue)
+	case realtime.TypeCancel:
+		conn.mu.Lock()
+		cancel, ok := conn.replies[msg.Id]
+		conn.mu.Unlock()
+		if !ok {
+			conn.send(realtime.ErrorEvent(msg.Id, "No reply is being generated for this message", nil))
+			return
+		}
+		cancel()
+	case realtime.TypeChat:
+		conn.startReply(msg)
+	}
+}
+
+// startReply starts generating the reply to a chat message. A connection generates one reply
+// at a time.
+func (conn *realtimeConn) startReply(msg realtime.ClientMessage) {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if len(conn.replies) > 0 {
+		conn.send(realtime.ErrorEvent(msg.Id, "A reply is already being generated", nil))
+		return
+	}
+	if err := middlewares.CheckChatLimits(conn.ctx, conn.user, func(string, string) {}); err != nil {
+		conn.send(realtime.ErrorEvent(msg.Id, err.Message, errors.New(err.Details)))
+		return
+	}
+	ctx, cancel := context.WithCancel(conn.ctx)
+	conn.replies[msg.Id] = cancel
+	go func() {
+		defer func() {
+			conn.mu.Lock()
+			delete(conn.replies, msg.Id)
+			conn.mu.Unlock()
+			cancel()
+		}()
+		conn.reply(ctx, msg)
+	}()
+}
+
+// reply streams the reply to a chat message as events carrying its id. The user's connections
+// show the assistant typing meanwhile.
+func (conn *realtimeConn) reply(ctx context.Context, msg realtime.ClientMessage) {
+	req := realtimeChatRequest(conn.user, msg)
+	turn, turnErr := prepareChatTurn(ctx, req, conn.user)
+	if turnErr != nil {
+		conn.send(realtime.ErrorEvent(msg.Id, turnErr.message, turnErr.err))
+		return
+	}
+
+	typing := func(on bool) {
+		data := realtime.Typing{SessionId: msg.SessionId, From: realtime.TypingAssistant, Typing: on}
+		realtimeHub.Send(conn.user.UserId, realtime.Event{Type: realtime.EventTyping, Data: data}, nil)
+	}
+	typing(true)
+	defer typing(false)
+
+	done := turn.stream(ctx, conn.locale, func(event string, data any) {
+		conn.send(realtime.Event{Type: event, Id: msg.Id, Data: data})
+	})
+	if done == nil && ctx.Err() != nil && conn.ctx.Err() == nil {
+		conn.send(realtime.Event{Type: realtime.EventCancelled, Id: msg.Id})
+	}
+}
+
+// realtimeChatRequest is the chat request a realtime chat message stands for
+func realtimeChatRequest(user *models.User, msg realtime.ClientMessage) ChatRequest {
+	return ChatRequest{
+		UserId:    user.UserId,
+		SessionId: msg.SessionId,
+		Message:   msg.Message,
+		Model:     msg.Model,
+		PersonaId: msg.PersonaId,
+	}
+}
+
+// startRealtimeReminders checks the users connected to this instance for due reminders every
+// RealtimeReminderMinutes
+func startRealtimeReminders() {
+	realtimeRemindersOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(time.Duration(constants.RealtimeReminderMinutes) * time.Minute)
+			defer ticker.Stop()
+			for range ticker.C {
+				for _, userId := range realtimeHub.Users() {
+					user, err := helpers.GetUserByID(userId)
+					if err != nil {
+						log.Printf("Failed to load user %s for reminders: %v\n", userId, err)
+						continue
+					}
+					remindMood(context.Background(), user)
+				}
+			}
+		}()
+	})
+}
+
+// remindMood pushes a mood check-in reminder to the user's connections on this instance once a
+// day, after MoodReminderHour in their time zone, unless they have logged their mood that day
+func remindMood(ctx context.Context, user *models.User) {
+	moodRemindersMu.Lock()
+	defer moodRemindersMu.Unlock()
+	now := time.Now()
+	loc := utils.LoadLocation(user.TimeZone)
+	day, due := realtime.MoodReminderDay(now, loc, constants.MoodReminderHour, moodReminded[user.UserId])
+	if !due {
+		return
+	}
+	logged, err := database.HasMoodEntrySince(ctx, user.UserId, utils.StartOfDay(now, loc).Unix())
+	if err != nil {
+		log.Printf("Failed to check the mood entries of user %s: %v\n", user.UserId, err)
+		return
+	}
+	if logged || realtimeHub.Send(user.UserId, realtime.MoodCheckIn(now), nil) > 0 {
+		moodReminded[user.UserId] = day
+	}
+}
@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"lambda-server/constants"
+	"lambda-server/database"
+	"lambda-server/helpers"
+	"lambda-server/middlewares"
+	"lambda-server/models"
+	"lambda-server/realtime"
+	"lambda-server/utils"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Posters for the @connections endpoints of the WebSocket API, by endpoint
+var (
+	connectionPostersMu sync.Mutex
+	connectionPosters   = map[string]*realtime.ConnectionPoster{}
+)
+
+// HandleRealtimeEvent handles an event of the API Gateway WebSocket API, the Lambda counterpart
+// of GET /chat/ws speaking the same messages. $connect authenticates the access token passed
+// as ?token= or an Authorization header and records the connection; $disconnect forgets it;
+// every other route handles a client message, sending events back through the @connections
+// endpoint.
+// Differences from the local server: no "ready" event is sent, tokens are batched into a post
+// every RealtimeFlushMillis, and heartbeats are the client's ping messages, which also deliver
+// due mood check-in reminders.
+func HandleRealtimeEvent(ctx context.Context, event events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+	switch event.RequestContext.EventType {
+	case "CONNECT":
+		return realtimeConnect(ctx, event), nil
+	case "DISCONNECT":
+		if err := database.DeleteRealtimeConnection(ctx, event.RequestContext.ConnectionID); err != nil {
+			log.Printf("Failed to forget realtime connection %s: %v\n", event.RequestContext.ConnectionID, err)
+		}
+		return realtimeResponse(http.StatusOK), nil
+	default:
+		return realtimeMessage(ctx, event), nil
+	}
+}
+
+func realtimeResponse(status int) events.APIGatewayProxyResponse {
+	return events.APIGatewayProxyResponse{StatusCode: status}
+}
+
+// realtimeConnect authenticates a new connection and records it; a non-2xx response makes API
+// Gateway refuse the connection
+func realtimeConnect(ctx context.Context, event events.APIGatewayWebsocketProxyRequest) events.APIGatewayProxyResponse {
+	token := realtime.AccessToken(realtimeHeader(event, "Sec-WebSocket-Protocol"))
+	if token == "" {
+		if parts := strings.Fields(realtimeHeader(event, "Authorization")); len(parts) == 2 && strings.EqualFold(parts[0], "bearer") {
+			token = parts[1]
+		}
+	}
+	user, err := helpers.AuthenticateAccessToken(token)
+	if err != nil {
+		log.Printf("Refused realtime connection %s: %v\n", event.RequestContext.ConnectionID, err)
+		return realtimeResponse(http.StatusUnauthorized)
+	}
+
+	endpoint := os.Getenv(constants.RealtimeCallbackURLEnv)
+	if endpoint == constants.EMPTY_STRING {
+		endpoint = realtime.CallbackURL(event.RequestContext.DomainName, event.RequestContext.Stage)
+	}
+	now := time.Now()
+	conn := models.RealtimeConnection{
+		ConnectionId: event.RequestContext.ConnectionID,
+		UserId:       user.UserId,
+		Endpoint:     endpoint,
+		Locale:       utils.PreferredLocale(realtimeHeader(event, "Accept-Language")),
+		ConnectedAt:  now.Unix(),
+		ExpiresAt:    now.Add(time.Duration(constants.RealtimeConnectionTTLHours) * time.Hour).Unix(),
+	}
+	if err := database.SaveRealtimeConnection(ctx, conn); err != nil {
+		log.Printf("Failed to record realtime connection %s: %v\n", conn.ConnectionId, err)
+		return realtimeResponse(http.StatusInternalServerError)
+	}
+	response := realtimeResponse(http.StatusOK)
+	if realtime.AccessToken(realtimeHeader(event, "Sec-WebSocket-Protocol")) != "" {
+		// Browsers drop a connection whose handshake does not select one of the offered protocols
+		response.Headers = map[string]string{"Sec-WebSocket-Protocol": realtime.AuthProtocol}
+	}
+	return response
+}
+
+// realtimeHeader returns a header of the $connect request, whatever its case
+func realtimeHeader(event events.APIGatewayWebsocketProxyRequest, name string) string {
+	for key, value := range event.Headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// realtimeMessage handles a message from a connected client
+func realtimeMessage(ctx context.Context, event events.APIGatewayWebsocketProxyRequest) events.APIGatewayProxyResponse {
+	conn, err := database.GetRealtimeConnection(ctx, event.RequestContext.ConnectionID)
+	if err != nil {
+		log.Printf("Failed to get realtime connection %s: %v\n", event.RequestContext.ConnectionID, err)
+		return realtimeResponse(http.StatusInternalServerError)
+	}
+	if conn == nil {
+		return realtimeResponse(http.StatusGone)
+	}
+	msg, err := realtime.ParseClientMessage([]byte(event.Body))
+	if err != nil {
+		postRealtime(ctx, *conn, realtime.ErrorEvent(msg.Id, "Invalid message", err))
+		return realtimeResponse(http.StatusOK)
+	}
+
+	switch msg.Type {
+	case realtime.TypePing:
+		postRealtime(ctx, *conn, realtime.Event{Type: realtime.EventPong})
+		remindMoodConnection(ctx, *conn)
+	case realtime.TypeTyping:
+		typing := realtime.Typing{SessionId: msg.SessionId, From: realtime.TypingUser, Typing: msg.Typing}
+		postUserRealtime(ctx, conn.UserId, realtime.Event{Type: realtime.EventTyping, Data: typing}, conn.ConnectionId)
+	case realtime.TypeCancel:
+		if err := database.CancelRealtimeReply(ctx, conn.ConnectionId, msg.Id); err != nil {
+			postRealtime(ctx, *conn, realtime.ErrorEvent(msg.Id, "Failed to cancel the reply", err))
+		}
+	case realtime.TypeChat:
+		replyRealtime(ctx, *conn, msg)
+	}
+	return realtimeResponse(http.StatusOK)
+}
+
+// replyRealtime streams the reply to a chat message to the connection. The cancel message
+// arrives in another invocation, which records it on the connection; this one checks for it
+// each time it posts tokens.
+func replyRealtime(ctx context.Context, conn models.RealtimeConnection, msg realtime.ClientMessage) {
+	user, err := helpers.GetUserByID(conn.UserId)
+	if err != nil {
+		postRealtime(ctx, conn, realtime.ErrorEvent(msg.Id, "Failed to load user", err))
+		return
+	}
+	if err := middlewares.CheckChatLimits(ctx, user, func(string, string) {}); err != nil {
+		postRealtime(ctx, conn, realtime.ErrorEvent(msg.Id, err.Message, errors.New(err.Details)))
+		return
+	}
+	turn, turnErr := prepareChatTurn(ctx, realtimeChatRequest(user, msg), user)
+	if turnErr != nil {
+		postRealtime(ctx, conn, realtime.ErrorEvent(msg.Id, turnErr.message, turnErr.err))
+		return
+	}
+
+	replyCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	typing := func(on bool) {
+		data := realtime.Typing{SessionId: msg.SessionId, From: realtime.TypingAssistant, Typing: on}
+		postUserRealtime(ctx, conn.UserId, realtime.Event{Type: realtime.EventTyping, Data: data}, "")
+	}
+	typing(true)
+	defer typing(false)
+
+	sender := &realtimeSender{conn: conn, id: msg.Id, cancel: cancel, lastFlush: time.Now()}
+	done := turn.stream(replyCtx, conn.Locale, func(event string, data any) { sender.emit(ctx, event, data) })
+	sender.flush(ctx)
+	if done == nil && replyCtx.Err() != nil && !sender.gone {
+		postRealtime(ctx, conn, realtime.Event{Type: realtime.EventCancelled, Id: msg.Id})
+	}
+}
+
+// realtimeSender posts the events of a reply to a connection, batching tokens
+type realtimeSender struct {
+	conn      models.RealtimeConnection
+	id        string
+	cancel    context.CancelFunc
+	tokens    strings.Builder
+	lastFlush time.Time
+	gone      bool
+}
+
+func (s *realtimeSender) emit(ctx context.Context, event string, data any) {
+	if token, ok := data.(realtime.Token); ok && event == constants.ChatEventToken {
+		s.tokens.WriteString(token.Content)
+		if time.Since(s.lastFlush) >= time.Duration(constants.RealtimeFlushMillis)*time.Millisecond {
+			s.flush(ctx)
+		}
+		return
+	}
+	s.flush(ctx)
+	s.post(ctx, realtime.Event{Type: event, Id: s.id, Data: data})
+}
+
+// flush posts the tokens gathered since the last flush and checks whether the client
+// cancelled the reply
+func (s *realtimeSender) flush(ctx context.Context) {
+	s.lastFlush = time.Now()
+	if s.tokens.Len() > 0 {
+		s.post(ctx, realtime.Event{Type: constants.ChatEventToken, Id: s.id, Data: realtime.Token{Content: s.tokens.String()}})
+		s.tokens.Reset()
+	}
+	conn, err := database.GetRealtimeConnection(ctx, s.conn.ConnectionId)
+	if err != nil {
+		log.Printf("Failed to check realtime connection %s for cancellation: %v\n", s.conn.ConnectionId, err)
+		return
+	}
+	if conn == nil || conn.CancelledId == s.id {
+		s.cancel()
+	}
+}
+
+func (s *realtimeSender) post(ctx context.Context, event realtime.Event) {
+	if s.gone {
+		return
+	}
+	if errors.Is(postRealtime(ctx, s.conn, event), realtime.ErrGone) {
+		// Like a disconnected SSE client: generation stops and the partial reply is stored
+		s.gone = true
+		s.cancel()
+	}
+}
+
+// postUserRealtime sends an event to each of the user's connections other than skip
+func postUserRealtime(ctx context.Context, userId string, event realtime.Event, skip string) {
+	conns, err := database.GetUserRealtimeConnections(ctx, userId)
+	if err != nil {
+		log.Printf("Failed to get the realtime connections of user %s: %v\n", userId, err)
+		return
+	}
+	for _, conn := range conns {
+		if conn.ConnectionId != skip {
+			postRealtime(ctx, conn, event)
+		}
+	}
+}
+
+// postRealtime sends an event to a connection, forgetting the connection once it is gone
+func postRealtime(ctx context.Context, conn models.RealtimeConnection, event realtime.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to encode realtime event %s: %v\n", event.Type, err)
+		return err
+	}
+	poster, err := connectionPoster(ctx, conn.Endpoint)
+	if err == nil {
+		err = poster.Post(ctx, conn.ConnectionId, data)
+	}
+	if errors.Is(err, realtime.ErrGone) {
+		if err := database.DeleteRealtimeConnection(context.Background(), conn.ConnectionId); err != nil {
+			log.Printf("Failed to forget realtime connection %s: %v\n", conn.ConnectionId, err)
+		}
+	} else if err != nil {
+		log.Printf("Failed to post to realtime connection %s: %v\n", conn.ConnectionId, err)
+	}
+	return err
+}
+
+// connectionPoster returns the poster for an @connections endpoint, creating it on first use
+func connectionPoster(ctx context.Context, endpoint string) (*realtime.ConnectionPoster, error) {
+	connectionPostersMu.Lock()
+	defer connectionPostersMu.Unlock()
+	if poster, ok := connectionPosters[endpoint]; ok {
+		return poster, nil
+	}
+	poster, err := realtime.NewConnectionPoster(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	connectionPosters[endpoint] = poster
+	return poster, nil
+}
+
+// remindMoodConnection sends a due mood check-in reminder to a connection of the WebSocket API
+func remindMoodConnection(ctx context.Context, conn models.RealtimeConnection) {
+	user, err := helpers.GetUserByID(conn.UserId)
+	if err != nil {
+		log.Printf("Failed to load user %s for reminders: %v\n", conn.UserId, err)
+		return
+	}
+	now := time.Now()
+	loc := utils.LoadLocation(user.TimeZone)
+	day, due := realtime.MoodReminderDay(now, loc, constants.MoodReminderHour, conn.RemindedDay)
+	if !due {
+		return
+	}
+	logged, err := database.HasMoodEntrySince(ctx, user.UserId, utils.StartOfDay(now, loc).Unix())
+	if err != nil {
+		log.Printf("Failed to check the mood entries of user %s: %v\n", user.UserId, err)
+		return
+	}
+	if !logged && postRealtime(ctx, conn, realtime.MoodCheckIn(now)) != nil {
+		return
+	}
+	if err := database.SetRealtimeReminded(ctx, conn.ConnectionId, day); err != nil {
+		log.Printf("Failed to record the mood reminder of connection %s: %v\n", conn.ConnectionId, err)
+	}
+}
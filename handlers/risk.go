@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lambda-server/constants"
+	"lambda-server/database"
+	"lambda-server/helpers"
+	"lambda-server/llm"
+	"lambda-server/models"
+	"lambda-server/risk"
+	"lambda-server/sos"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// riskTimeout bounds escalating a high-risk text: saving the SOS record and alerting contacts
+const riskTimeout = 30 * time.Second
+
+// riskCheck is the outcome of checking a text for risk
+type riskCheck struct {
+	assessment models.RiskAssessment
+	resources  *models.CrisisResources // Set at medium risk and above
+}
+
+// responseRisk returns the assessment to show the client, which is only set alongside crisis resources
+func (check riskCheck) responseRisk() *models.RiskAssessment {
+	if check.resources == nil {
+		return nil
+	}
+	return &check.assessment
+}
+
+// checkRisk rates a text for risk of suicide or self-harm. When RISK_LLM_REVIEW is "true",
+// texts the lexicon flags get a second opinion from the chat LLM; if that fails, the
+// lexicon's rating stands.
+func checkRisk(ctx context.Context, user *models.User, locale, text string) riskCheck {
+	assessment := risk.Classify(text)
+	if assessment.Level != risk.LevelNone && os.Getenv(constants.RiskLLMReviewEnv) == "true" {
+		if review, err := reviewRisk(ctx, text); err != nil {
+			log.Printf("Risk review failed, keeping the lexicon rating: %v\n", err)
+		} else {
+			assessment = risk.Combine(assessment, review)
+		}
+	}
+
+	check := riskCheck{assessment: assessment}
+	if risk.AtLeast(assessment.Level, risk.LevelMedium) {
+		countryCode := ""
+		if user != nil {
+			countryCode = user.CountryCode
+			if user.Locale != "" {
+				locale = user.Locale
+			}
+		}
+		resources := risk.Resources(risk.Region(countryCode, locale))
+		check.resources = &resources
+	}
+	return check
+}
+
+// reviewRisk asks the chat LLM for its rating of a text
+func reviewRisk(ctx context.Context, text string) (models.RiskAssessment, error) {
+	provider, cfg, err := chatProvider()
+	if err != nil {
+		return models.RiskAssessment{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	completion, err := provider.Complete(ctx, llm.Request{Model: cfg.Model, Messages: risk.ReviewMessages(text)})
+	if err != nil {
+		return models.RiskAssessment{}, err
+	}
+	return risk.ParseReview(completion.Content)
+}
+
+// riskUser returns the author of a text, from the auth context when it matches, otherwise
+// from the database. Without the user, crisis resources fall back to the request locale and
+// no contacts are alerted.
+func riskUser(c *gin.Context, userId string) *models.User {
+	if value, exists := c.Get("user"); exists {
+		if user, ok := value.(*models.User); ok && user.UserId == userId {
+			return user
+		}
+	}
+	user, err := helpers.GetUserByID(userId)
+	if err != nil {
+		log.Printf("Failed to load user %s for risk check: %v\n", userId, err)
+		return nil
+	}
+	return user
+}
+
+// escalateRisk raises an SOS for a high-risk text and, with the user's consent, alerts their
+// emergency contacts straight away. While an SOS from the last SOSCooldownHours is active no
+// other is raised, so a crisis conversation does not alert the contacts on every message.
+// Without consent the SOS stays raised until the user resolves it.
+func escalateRisk(ctx context.Context, user *models.User, userId, source, sourceId string, check riskCheck) error {
+	if check.assessment.Level != risk.LevelHigh {
+		return nil
+	}
+	now := time.Now()
+	latest, err := database.GetLatestSOS(ctx, userId)
+	if err != nil {
+		return err
+	}
+	cooldown := now.Add(-time.Duration(constants.SOSCooldownHours) * time.Hour).Unix()
+	if latest != nil && sos.Active(*latest) && latest.Timestamp >= cooldown {
+		return nil
+	}
+
+	alert := models.SOS{
+		UserID:     userId,
+		Timestamp:  now.Unix(),
+		Status:     constants.SOSStatusRaised,
+		Source:     source,
+		SourceId:   sourceId,
+		Level:      check.assessment.Level,
+		Categories: check.assessment.Categories,
+		UpdatedAt:  now.Unix(),
+		History:    []models.SOSEvent{sos.Event(constants.SOSStatusRaised, constants.SOSActorSystem, now, "")},
+	}
+	if check.resources != nil {
+		alert.Region = check.resources.Region
+	}
+	// Record the SOS before alerting anyone, so it exists even if alerts fail
+	if err := database.CreateSOS(ctx, alert); err != nil {
+		if errors.Is(err, database.ErrSOSExists) {
+			// Another text of the same second raised it
+			return nil
+		}
+		return err
+	}
+	if user == nil || !user.NotifyContactsOnRisk {
+		return nil
+	}
+	_, err = notifySOS(ctx, user, alert.Timestamp)
+	return err
+}
+
+// escalateRiskAsync runs escalateRisk alongside the rest of the request. The returned channel
+// is closed when it is done; handlers wait on it before returning so Lambda does not freeze
+// the alerts halfway.
+func escalateRiskAsync(user *models.User, userId, source, sourceId string, check riskCheck) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ctx, cancel := context.WithTimeout(context.Background(), riskTimeout)
+		defer cancel()
+		if err := escalateRisk(ctx, user, userId, source, sourceId, check); err != nil {
+			log.Printf("Escalating %s %s for %s failed: %v\n", source, sourceId, userId, err)
+		}
+	}()
+	return done
+}
+
+// checkJournalRisk checks a journal entry and escalates it if needed
+func checkJournalRisk(c *gin.Context, journal models.Journal) riskCheck {
+	user := riskUser(c, journal.UserId)
+	check := checkRisk(c.Request.Context(), user, requestLocale(c), journal.Title+"\n"+journal.Content)
+	<-escalateRiskAsync(user, journal.UserId, constants.SOSSourceJournal, journal.JournalID, check)
+	return check
+}
+
+// safetyNote tells the model that the user's message shows risk and which services the app is showing them
+func safetyNote(check riskCheck) llm.Message {
+	var services []string
+	for _, resource := range check.resources.Resources {
+		contact := resource.Phone
+		if contact == "" {
+			contact = resource.URL
+		}
+		services = append(services, fmt.Sprintf("%s (%s)", resource.Name, contact))
+	}
+	content := "The user's latest message may indicate a risk of suicide or self-harm. Respond with warmth and " +
+		"without judgement, gently ask whether they are safe right now, and encourage them to reach out for " +
+		"support. The app is showing them these crisis services: " + strings.Join(services, ", ") + "."
+	if check.resources.EmergencyNumber != "" {
+		content += " The local emergency number is " + check.resources.EmergencyNumber + "."
+	}
+	return llm.Message{Role: llm.RoleSystem, Content: content}
+}
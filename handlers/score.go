@@ -5,6 +5,7 @@ import (
 	"lambda-server/database"
 	"lambda-server/models"
 	"lambda-server/constants"
+	"lambda-server/utils"
 	"net/http"
 	"time"
 
@@ -39,6 +40,7 @@ func SubmitMindMuseScore(c *gin.Context) {
 		UserId:    userId,
 		Score:     req.Score,
 		Timestamp: parsedTime.Unix(),
+		Date:      utils.DayKey(parsedTime, requestLocation(c)),
 	}
 
 	ctx := context.Background()
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"lambda-server/constants"
+	"lambda-server/database"
+	"lambda-server/models"
+	"lambda-server/sentiment"
+	"lambda-server/utils"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sentimentTimeout bounds a background analysis write
+const sentimentTimeout = 30 * time.Second
+
+// GetSentimentTrends handles GET /journals/sentiment?userId=...&from=YYYY-MM-DD&to=YYYY-MM-DD&interval=day|week
+// It averages the sentiment of the user's journal entries and chat messages per day or week.
+func GetSentimentTrends(c *gin.Context) {
+	userId := c.Query(constants.QueryParamUserId)
+	if userId == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Missing userId in query params",
+		})
+		return
+	}
+	interval := c.DefaultQuery(constants.QueryParamInterval, sentiment.IntervalDay)
+	if interval != sentiment.IntervalDay && interval != sentiment.IntervalWeek {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "interval must be day or week"})
+		return
+	}
+
+	loc := requestLocation(c)
+	to := utils.StartOfDay(time.Now(), loc)
+	if value := c.Query(constants.QueryParamTo); value != "" {
+		day, err := utils.ParseDateParam(value, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid to date", Details: err.Error()})
+			return
+		}
+		to = day
+	}
+	from := to.AddDate(0, 0, 1-constants.SentimentTrendDefaultDays)
+	if value := c.Query(constants.QueryParamFrom); value != "" {
+		day, err := utils.ParseDateParam(value, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid from date", Details: err.Error()})
+			return
+		}
+		from = day
+	}
+	if from.After(to) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "from date must not be after to date"})
+		return
+	}
+	if to.Sub(from) > time.Duration(constants.SentimentTrendMaxDays)*24*time.Hour {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Date range is too long"})
+		return
+	}
+	end := to.AddDate(0, 0, 1).Unix() - 1 // inclusive of the whole last day
+
+	ctx := c.Request.Context()
+	journalSamples := []sentiment.Sample{}
+	err := database.IterateUserJournals(ctx, userId, from.Unix(), end, func(journal models.Journal) error {
+		if journal.Sentiment != nil {
+			journalSamples = append(journalSamples, sentiment.Sample{Time: time.Unix(journal.CreatedAt, 0), Sentiment: *journal.Sentiment})
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to fetch journal entries",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Chat is read from per-day totals, bucketed in the zone each message was sent from
+	chatDays, err := database.GetChatSentimentDays(ctx, userId, utils.DayKey(from, loc), utils.DayKey(to, loc))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to fetch chat sentiment",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SentimentTrendResponse{
+		Interval: interval,
+		From:     from.Format("2006-01-02"),
+		To:       to.Format("2006-01-02"),
+		Journals: sentiment.Trend(journalSamples, interval, loc),
+		Chat:     sentiment.TrendFromDays(chatDays, interval),
+	})
+}
+
+// AnalyzeJournalSentiment scores a journal entry and stores the result on it
+func AnalyzeJournalSentiment(ctx context.Context, journal models.Journal) error {
+	result := sentiment.Analyze(journal.Title + "\n" + journal.Content)
+	return database.SetJournalSentiment(ctx, journal.UserId, journal.CreatedAt, journal.UpdatedAt, result)
+}
+
+// AnalyzeChatMessageSentiment scores a chat message, stores the result on it and counts it in
+// the user's chat sentiment for the day it was sent
+func AnalyzeChatMessageSentiment(ctx context.Context, msg models.ChatMessage) error {
+	day := msg.SentimentDay
+	if day == "" {
+		day = utils.DayKey(time.Unix(msg.Timestamp, 0), utils.LoadLocation(msg.TimeZone))
+	}
+	return database.SetChatMessageSentiment(ctx, msg, sentiment.Analyze(msg.Message), day)
+}
+
+// analyzeJournalInBackground analyzes a journal entry without holding up the response.
+// Entries missed here, for example when the Lambda is frozen, are picked up by the
+// analyze-sentiment scheduled job.
+func analyzeJournalInBackground(journal models.Journal) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), sentimentTimeout)
+		defer cancel()
+		if err := AnalyzeJournalSentiment(ctx, journal); err != nil {
+			log.Printf("Sentiment analysis of journal %s failed: %v\n", journal.JournalID, err)
+		}
+	}()
+}
+
+// analyzeChatMessageInBackground analyzes a chat message without holding up the response
+func analyzeChatMessageInBackground(msg models.ChatMessage) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), sentimentTimeout)
+		defer cancel()
+		if err := AnalyzeChatMessageSentiment(ctx, msg); err != nil {
+			log.Printf("Sentiment analysis of chat message %s failed: %v\n", msg.SessionIdTimestamp, err)
+		}
+	}()
+}
@@ -0,0 +1,456 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"lambda-server/constants"
+	"lambda-server/database"
+	"lambda-server/helpers"
+	"lambda-server/models"
+	"lambda-server/notify"
+	"lambda-server/realtime"
+	"lambda-server/risk"
+	"lambda-server/sos"
+	"lambda-server/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RaiseSOS handles POST /sos, raising an SOS for the signed-in user with an optional message
+// and location for their emergency contacts. The contacts are alerted once SOSCancelWindowSeconds
+// pass unless the user cancels it first: on the local server by a timer, on Lambda by the
+// notify-sos scheduled job, which runs every minute. While an SOS the user raised is still
+// active it is returned with 200 instead of raising another.
+func RaiseSOS(c *gin.Context) {
+	user, ok := sosUser(c)
+	if !ok {
+		return
+	}
+	var req models.SOSRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request body",
+			Details: err.Error(),
+		})
+		return
+	}
+	req, err := sos.Validate(req, constants.SOSMessageMaxLen)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid SOS",
+			Details: err.Error(),
+		})
+		return
+	}
+	locale := requestLocale(c)
+	if user.Locale != "" {
+		locale = user.Locale
+	}
+	resources := risk.Resources(risk.Region(user.CountryCode, locale))
+
+	ctx := c.Request.Context()
+	latest, err := database.GetLatestSOS(ctx, user.UserId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to check for an active SOS",
+			Details: err.Error(),
+		})
+		return
+	}
+	if latest != nil && latest.Source == constants.SOSSourceManual && sos.Active(*latest) {
+		c.JSON(http.StatusOK, models.SOSResponse{SOS: *latest, CrisisResources: &resources})
+		return
+	}
+
+	now := time.Now()
+	window := time.Duration(constants.SOSCancelWindowSeconds) * time.Second
+	alert := models.SOS{
+		UserID:    user.UserId,
+		Timestamp: now.Unix(),
+		Status:    constants.SOSStatusRaised,
+		Source:    constants.SOSSourceManual,
+		Region:    resources.Region,
+		Message:   req.Message,
+		Location:  req.Location,
+		NotifyAt:  now.Add(window).Unix(),
+		UpdatedAt: now.Unix(),
+		History:   []models.SOSEvent{sos.Event(constants.SOSStatusRaised, constants.SOSActorUser, now, "")},
+	}
+	if err := database.CreateSOS(ctx, alert); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, database.ErrSOSExists) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, models.ErrorResponse{
+			Error:   "Failed to raise SOS",
+			Details: err.Error(),
+		})
+		return
+	}
+	if utils.IsRunningLocally() {
+		time.AfterFunc(window, func() { notifySOSInBackground(user, alert.Timestamp) })
+	}
+	c.JSON(http.StatusCreated, models.SOSResponse{SOS: alert, CrisisResources: &resources})
+}
+
+// ListSOS handles GET /sos, returning a page of the signed-in user's SOS alerts, newest first
+func ListSOS(c *gin.Context) {
+	user, ok := sosUser(c)
+	if !ok {
+		return
+	}
+	limit, err := pageLimit(c, constants.SOSPageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid limit parameter",
+			Details: err.Error(),
+		})
+		return
+	}
+	alerts, cursor, err := database.ListSOS(c.Request.Context(), user.UserId, limit, c.Query(constants.QueryParamCursor))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, database.ErrInvalidCursor) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, models.ErrorResponse{
+			Error:   "Failed to list SOS alerts",
+			Details: err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, models.SOSListResponse{Alerts: alerts, Count: len(alerts), Cursor: cursor})
+}
+
+// GetSOS handles GET /sos/:timestamp
+func GetSOS(c *gin.Context) {
+	user, ok := sosUser(c)
+	if !ok {
+		return
+	}
+	alert, ok := loadSOS(c, user.UserId)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, models.SOSResponse{SOS: *alert})
+}
+
+// CancelSOS handles POST /sos/:timestamp/cancel, stopping a false alarm. Only an SOS whose
+// contacts have not been alerted yet can be cancelled; after that it can be resolved.
+func CancelSOS(c *gin.Context) {
+	changeSOS(c, constants.SOSStatusCancelled, "")
+}
+
+// ResolveSOS handles POST /sos/:timestamp/resolve, closing an SOS once the user is safe, with
+// an optional note for the audit trail
+func ResolveSOS(c *gin.Context) {
+	var req models.SOSResolveRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request body",
+			Details: err.Error(),
+		})
+		return
+	}
+	note := strings.TrimSpace(req.Note)
+	if len([]rune(note)) > constants.SOSNoteMaxLen {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid note",
+			Details: fmt.Sprintf("note must be at most %d characters", constants.SOSNoteMaxLen),
+		})
+		return
+	}
+	changeSOS(c, constants.SOSStatusResolved, note)
+}
+
+// changeSOS moves the signed-in user's SOS to status, answering 409 when it cannot get there
+// from its current status
+func changeSOS(c *gin.Context, status, note string) {
+	user, ok := sosUser(c)
+	if !ok {
+		return
+	}
+	alert, ok := loadSOS(c, user.UserId)
+	if !ok {
+		return
+	}
+	if err := sos.CheckTransition(*alert, status, constants.SOSActorUser); err != nil {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "SOS cannot be " + status,
+			Details: err.Error(),
+		})
+		return
+	}
+	updated, err := database.TransitionSOS(c.Request.Context(), user.UserId, alert.Timestamp, database.SOSTransition{
+		From:  sos.From(status, constants.SOSActorUser),
+		Event: sos.Event(status, constants.SOSActorUser, time.Now(), note),
+	})
+	if err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, database.ErrSOSConflict) {
+			// Most likely the contacts were alerted in the meantime
+			code = http.StatusConflict
+		}
+		c.JSON(code, models.ErrorResponse{
+			Error:   "SOS cannot be " + status,
+			Details: err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, models.SOSResponse{SOS: *updated})
+}
+
+// AcknowledgeSOS handles POST /sos/acknowledge, called from the link sent to an emergency
+// contact to tell the user they are responding. It needs no sign-in; the signed token in the
+// link identifies the SOS and the contact. The user's open WebSocket connections are notified.
+func AcknowledgeSOS(c *gin.Context) {
+	var req models.SOSAcknowledgeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request body",
+			Details: err.Error(),
+		})
+		return
+	}
+	secret, ok := sosSecret()
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+			Error: "SOS acknowledgement is not configured",
+		})
+		return
+	}
+	now := time.Now()
+	claims, err := sos.ParseAckToken(secret, req.Token, now)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "Invalid acknowledge link",
+			Details: err.Error(),
+		})
+		return
+	}
+	ctx := c.Request.Context()
+	user, err := helpers.GetUserByID(claims.UserId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "SOS not found",
+			Details: err.Error(),
+		})
+		return
+	}
+	alert, err := database.GetSOS(ctx, claims.UserId, claims.Timestamp)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, database.ErrSOSNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, models.ErrorResponse{
+			Error:   "Failed to get SOS",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	contact := "An emergency contact"
+	if claims.Contact >= 0 && claims.Contact < len(user.EmergencyContacts) && user.EmergencyContacts[claims.Contact].Name != "" {
+		contact = user.EmergencyContacts[claims.Contact].Name
+	}
+	response := models.SOSAcknowledgeResponse{UserName: user.Name, Status: alert.Status}
+	for _, event := range alert.History {
+		if event.Status == constants.SOSStatusAcknowledged && event.Contact == contact {
+			c.JSON(http.StatusOK, response)
+			return
+		}
+	}
+	if err := sos.CheckTransition(*alert, constants.SOSStatusAcknowledged, constants.SOSActorContact); err != nil {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "SOS is no longer active",
+			Details: err.Error(),
+		})
+		return
+	}
+	event := sos.Event(constants.SOSStatusAcknowledged, constants.SOSActorContact, now, "")
+	event.Contact = contact
+	updated, err := database.TransitionSOS(ctx, claims.UserId, claims.Timestamp, database.SOSTransition{
+		From:  sos.From(constants.SOSStatusAcknowledged, constants.SOSActorContact),
+		Event: event,
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, database.ErrSOSConflict) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, models.ErrorResponse{
+			Error:   "Failed to acknowledge SOS",
+			Details: err.Error(),
+		})
+		return
+	}
+	pushUserNotification(ctx, user.UserId, realtime.SOSAcknowledged(contact, now))
+	response.Status = updated.Status
+	c.JSON(http.StatusOK, response)
+}
+
+// NotifyDueSOS alerts the contacts of every SOS whose cancel window has passed, returning how
+// many were notified
+func NotifyDueSOS(ctx context.Context) (int, error) {
+	due, err := database.GetDueSOS(ctx, time.Now().Unix(), constants.SOSDueBatch)
+	if err != nil {
+		return 0, err
+	}
+	notified := 0
+	for _, alert := range due {
+		user, err := helpers.GetUserByID(alert.UserID)
+		if err != nil {
+			log.Printf("Failed to load user %s for SOS %d: %v\n", alert.UserID, alert.Timestamp, err)
+			continue
+		}
+		updated, err := notifySOS(ctx, user, alert.Timestamp)
+		if err != nil {
+			log.Printf("Notifying contacts of SOS %d for %s failed: %v\n", alert.Timestamp, alert.UserID, err)
+		}
+		if updated != nil {
+			notified++
+		}
+	}
+	return notified, nil
+}
+
+// notifySOSInBackground alerts the contacts of an SOS whose cancel window has passed
+func notifySOSInBackground(user *models.User, timestamp int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), riskTimeout)
+	defer cancel()
+	if _, err := notifySOS(ctx, user, timestamp); err != nil {
+		log.Printf("Notifying contacts of SOS %d for %s failed: %v\n", timestamp, user.UserId, err)
+	}
+}
+
+// notifySOS moves a raised SOS to notified and alerts the user's emergency contacts, each with
+// a link to acknowledge it. The status changes first so a cancel, or another instance getting
+// there first, wins; it returns nil without alerting anyone then.
+func notifySOS(ctx context.Context, user *models.User, timestamp int64) (*models.SOS, error) {
+	alert, err := database.TransitionSOS(ctx, user.UserId, timestamp, database.SOSTransition{
+		From:  sos.From(constants.SOSStatusNotified, constants.SOSActorSystem),
+		Event: sos.Event(constants.SOSStatusNotified, constants.SOSActorSystem, time.Now(), ""),
+	})
+	if errors.Is(err, database.ErrSOSConflict) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var failures []string
+	for i, contact := range user.EmergencyContacts {
+		if contact.Email == "" && contact.Phone == "" {
+			continue
+		}
+		link := sosAcknowledgeURL(user.UserId, timestamp, i)
+		message := notify.NewAlert(contact, user.Name)
+		message.AcknowledgeURL = link
+		if alert.Source == constants.SOSSourceManual {
+			message = notify.NewSOSAlert(contact, user.Name, *alert, link)
+		}
+		if err := notify.Default().Notify(ctx, message); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", contact.Name, err))
+			continue
+		}
+		alert.ContactsNotified++
+	}
+	if alert.ContactsNotified > 0 {
+		if err := database.SetSOSContactsNotified(ctx, user.UserId, timestamp, alert.ContactsNotified); err != nil {
+			return alert, err
+		}
+	}
+	if len(failures) > 0 {
+		return alert, fmt.Errorf("failed to alert emergency contacts: %s", strings.Join(failures, "; "))
+	}
+	return alert, nil
+}
+
+// sosAcknowledgeURL returns the link a contact opens to acknowledge an SOS, or "" when
+// SOS_ACKNOWLEDGE_URL is not set
+func sosAcknowledgeURL(userId string, timestamp int64, contact int) string {
+	base := os.Getenv(constants.SOSAcknowledgeURLEnv)
+	if base == constants.EMPTY_STRING {
+		return ""
+	}
+	secret, ok := sosSecret()
+	if !ok {
+		log.Printf("%s is set but JWT_SECRET is not; sending SOS alerts without acknowledge links\n", constants.SOSAcknowledgeURLEnv)
+		return ""
+	}
+	token := sos.AckToken(secret, sos.AckClaims{
+		UserId:    userId,
+		Timestamp: timestamp,
+		Contact:   contact,
+		ExpiresAt: time.Now().Add(time.Duration(constants.SOSAckTTLHours) * time.Hour).Unix(),
+	})
+	separator := "?"
+	if strings.Contains(base, "?") {
+		separator = "&"
+	}
+	return base + separator + "token=" + url.QueryEscape(token)
+}
+
+// sosSecret returns the key acknowledge tokens are signed with, or false when none is set; an
+// empty key would let anyone forge them
+func sosSecret() ([]byte, bool) {
+	secret := os.Getenv("JWT_SECRET")
+	return []byte(secret), secret != constants.EMPTY_STRING
+}
+
+// sosUser returns the signed-in user, answering 401 when there is none
+func sosUser(c *gin.Context) (*models.User, bool) {
+	value, _ := c.Get("user")
+	user, ok := value.(*models.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
+	}
+	return user, ok
+}
+
+// loadSOS returns the user's SOS named by the :timestamp path parameter, answering 400 or 404
+// when there is none
+func loadSOS(c *gin.Context, userId string) (*models.SOS, bool) {
+	timestamp, err := strconv.ParseInt(c.Param(constants.QueryParamSOSTimestamp), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid SOS timestamp",
+			Details: err.Error(),
+		})
+		return nil, false
+	}
+	alert, err := database.GetSOS(c.Request.Context(), userId, timestamp)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, database.ErrSOSNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, models.ErrorResponse{
+			Error:   "Failed to get SOS",
+			Details: err.Error(),
+		})
+		return nil, false
+	}
+	return alert, true
+}
+
+// pushUserNotification sends a notification to the user's open WebSocket connections: those
+// on this instance for the local server, otherwise those of the WebSocket API
+func pushUserNotification(ctx context.Context, userId string, event realtime.Event) {
+	if utils.IsRunningLocally() {
+		realtimeHub.Send(userId, event, nil)
+		return
+	}
+	postUserRealtime(ctx, userId, event, "")
+}
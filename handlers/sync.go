@@ -0,0 +1,448 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lambda-server/constants"
+	"lambda-server/database"
+	"lambda-server/models"
+	"lambda-server/utils"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Kinds of records in sync results
+const (
+	syncTypeJournal = "journal"
+	syncTypeMood    = "mood"
+)
+
+// maxSyncIdLength bounds client-generated record IDs
+const maxSyncIdLength = 100
+
+// HandleSync handles POST /sync?userId=...
+// It applies the client's offline changes, then returns the server changes since the client's token.
+// Edits are checked against the server copy with utils.ResolveSyncConflict; deleted records come
+// back as tombstones with deletedAt set. Resending a batch with the same batchId replays the
+// results of the first run instead of applying the changes again.
+func HandleSync(c *gin.Context) {
+	var req models.SyncRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request body",
+			Details: err.Error(),
+		})
+		return
+	}
+	userId := c.Query(constants.QueryParamUserId)
+	if userId == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Missing userId in query params",
+		})
+		return
+	}
+	if len(req.BatchId) > maxSyncIdLength {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "batchId is too long"})
+		return
+	}
+	if len(req.Journals)+len(req.Moods) > constants.SyncMaxChanges {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: fmt.Sprintf("A sync batch can hold at most %d changes", constants.SyncMaxChanges),
+		})
+		return
+	}
+	token, err := utils.DecodeSyncToken(req.Token)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid sync token", Details: err.Error()})
+		return
+	}
+
+	ctx := context.Background()
+	response := models.SyncResponse{}
+
+	batch, err := database.ClaimSyncBatch(ctx, userId, req.BatchId)
+	if errors.Is(err, database.ErrSyncBatchInProgress) {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to start sync",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	if batch != nil {
+		response.Results = batch.Results
+		response.Replayed = true
+	} else {
+		// Entries the batch writes are checked for risk before the response, as when they are
+		// created or edited online, so Lambda does not freeze the checks halfway
+		var written []models.Journal
+		defer func() {
+			for _, journal := range written {
+				checkJournalRisk(c, journal)
+			}
+		}()
+		results, err := applySyncChanges(ctx, userId, requestLocation(c), req, &written)
+		if err != nil {
+			// Let the client resend the batch; changes applied so far are idempotent on retry
+			if releaseErr := database.ReleaseSyncBatch(ctx, userId, req.BatchId); releaseErr != nil {
+				log.Printf("Failed to release sync batch %s for %s: %v\n", req.BatchId, userId, releaseErr)
+			}
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "Failed to apply changes",
+				Details: err.Error(),
+			})
+			return
+		}
+		if err := database.CompleteSyncBatch(ctx, userId, req.BatchId, results); err != nil {
+			// Without the stored results a resend would be refused as in progress; release the
+			// batch so it can be applied again instead
+			if releaseErr := database.ReleaseSyncBatch(ctx, userId, req.BatchId); releaseErr != nil {
+				log.Printf("Failed to release sync batch %s for %s: %v\n", req.BatchId, userId, releaseErr)
+			}
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "Failed to record sync batch",
+				Details: err.Error(),
+			})
+			return
+		}
+		response.Results = results
+	}
+
+	if err := collectSyncChanges(ctx, userId, token, &response); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to read server changes",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// applySyncChanges applies every change of a batch in order. Invalid changes are rejected
+// individually; an error is only returned when the database fails. Journal entries created or
+// edited are appended to written.
+func applySyncChanges(ctx context.Context, userId string, loc *time.Location, req models.SyncRequest, written *[]models.Journal) ([]models.SyncResult, error) {
+	results := []models.SyncResult{}
+	for _, change := range req.Journals {
+		result, err := applyJournalChange(ctx, userId, loc, change, written)
+		if err != nil {
+			return nil, fmt.Errorf("journal %s: %w", change.JournalId, err)
+		}
+		results = append(results, result)
+	}
+	for _, change := range req.Moods {
+		result, err := applyMoodChange(ctx, userId, change)
+		if err != nil {
+			return nil, fmt.Errorf("mood entry %s: %w", change.MoodEntryId, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// applyJournalChange applies one journal create, update or delete
+func applyJournalChange(ctx context.Context, userId string, loc *time.Location, change models.SyncJournalChange, written *[]models.Journal) (models.SyncResult, error) {
+	result := models.SyncResult{Type: syncTypeJournal, Id: change.JournalId, Op: change.Op}
+	if len(change.JournalId) > maxSyncIdLength {
+		return rejectSync(result, "journalId is too long"), nil
+	}
+
+	existing, err := database.GetJournalByID(ctx, userId, change.JournalId)
+	if err != nil && !errors.Is(err, database.ErrJournalNotFound) {
+		return result, err
+	}
+
+	// Device clocks can be wrong; an edit can never be newer than its arrival here
+	now := time.Now().Unix()
+	if change.ClientUpdatedAt > now {
+		change.ClientUpdatedAt = now
+	}
+
+	switch change.Op {
+	case constants.SyncOpCreate:
+		if existing != nil {
+			// A resent create that was already applied
+			result.Status = constants.SyncStatusApplied
+			result.Journal = existing
+			return result, nil
+		}
+		return createSyncedJournal(ctx, userId, loc, change, result, written)
+
+	case constants.SyncOpUpdate:
+		if existing == nil {
+			return rejectSync(result, database.ErrJournalNotFound.Error()), nil
+		}
+		conflict, clientWins := utils.ResolveSyncConflict(existing.UpdatedAt, change.BaseVersion, change.ClientUpdatedAt)
+		if conflict {
+			if !clientWins {
+				return keepServerJournal(result, existing), nil
+			}
+			result.Resolution = constants.SyncResolutionClientWins
+		}
+		return updateSyncedJournal(ctx, userId, existing, change, result, written)
+
+	case constants.SyncOpDelete:
+		if existing == nil || existing.DeletedAt != 0 {
+			// Deleting twice, or deleting an entry already purged, leaves the same state
+			result.Status = constants.SyncStatusApplied
+			result.Journal = existing
+			return result, nil
+		}
+		conflict, clientWins := utils.ResolveSyncConflict(existing.UpdatedAt, change.BaseVersion, change.ClientUpdatedAt)
+		if conflict {
+			if !clientWins {
+				return keepServerJournal(result, existing), nil
+			}
+			result.Resolution = constants.SyncResolutionClientWins
+		}
+		// Trashed by another request since it was read: the outcome is the same
+		if err := database.DeleteJournalEntry(ctx, userId, change.JournalId); err != nil && !errors.Is(err, database.ErrJournalInTrash) {
+			return result, err
+		}
+		return finishJournalChange(ctx, userId, change.JournalId, result)
+	}
+
+	return rejectSync(result, fmt.Sprintf("unknown op %q", change.Op)), nil
+}
+
+// createSyncedJournal stores a journal entry written offline. Its CreatedAt is moved forward
+// by a second at a time if another entry of the user already has it.
+func createSyncedJournal(ctx context.Context, userId string, loc *time.Location, change models.SyncJournalChange, result models.SyncResult, written *[]models.Journal) (models.SyncResult, error) {
+	if strings.TrimSpace(change.Title) == "" || strings.TrimSpace(change.Content) == "" {
+		return rejectSync(result, "title and content are required"), nil
+	}
+	tags, emotions, err := validateJournalMetadata(change.Tags, change.Mood, change.Emotions)
+	if err != nil {
+		return rejectSync(result, err.Error()), nil
+	}
+	if change.PromptId != "" {
+		if _, err := findActivePrompt(ctx, change.PromptId); err != nil {
+			return rejectSync(result, err.Error()), nil
+		}
+	}
+
+	now := time.Now()
+	createdAt := change.CreatedAt
+	if createdAt <= 0 || createdAt > now.Unix() {
+		createdAt = now.Unix()
+	}
+	entry := models.Journal{
+		UserId:          userId,
+		JournalID:       change.JournalId,
+		Title:           change.Title,
+		Content:         change.Content,
+		UpdatedAt:       now.Unix(),
+		Tags:            tags,
+		Mood:            change.Mood,
+		Emotions:        emotions,
+		Pinned:          change.Pinned,
+		PromptId:        change.PromptId,
+		TimeZone:        loc.String(),
+		ExcludeFromChat: change.ExcludeFromChat,
+	}
+	for attempt := 0; ; attempt++ {
+		entry.CreatedAt = createdAt + int64(attempt)
+		entry.Date = time.Unix(entry.CreatedAt, 0).In(loc).Format("20060102")
+		err = database.CreateJournalEntryIfAbsent(ctx, entry)
+		if !errors.Is(err, database.ErrJournalSlotTaken) || attempt >= 60 {
+			break
+		}
+	}
+	if err != nil {
+		return result, err
+	}
+	analyzeJournalInBackground(entry)
+	*written = append(*written, entry)
+
+	result.Status = constants.SyncStatusApplied
+	result.Journal = &entry
+	return result, nil
+}
+
+// updateSyncedJournal replaces the content and metadata of a journal entry with the client copy.
+// An entry in the trash is restored in the same write, since the edit won over the deletion.
+func updateSyncedJournal(ctx context.Context, userId string, existing *models.Journal, change models.SyncJournalChange, result models.SyncResult, written *[]models.Journal) (models.SyncResult, error) {
+	if strings.TrimSpace(change.Title) == "" || strings.TrimSpace(change.Content) == "" {
+		return rejectSync(result, "title and content are required"), nil
+	}
+	// Clients send the whole record, so missing lists mean cleared rather than unchanged
+	if change.Tags == nil {
+		change.Tags = []string{}
+	}
+	if change.Emotions == nil {
+		change.Emotions = []string{}
+	}
+	tags, emotions, err := validateJournalMetadata(change.Tags, change.Mood, change.Emotions)
+	if err != nil {
+		return rejectSync(result, err.Error()), nil
+	}
+
+	err = database.RestoreAndUpdateJournalEntry(ctx, userId, change.JournalId, models.JournalUpdateRequest{
+		Title:           change.Title,
+		Content:         change.Content,
+		Tags:            tags,
+		Mood:            &change.Mood,
+		Emotions:        emotions,
+		Pinned:          &change.Pinned,
+		ExcludeFromChat: &change.ExcludeFromChat,
+	})
+	if err != nil {
+		return result, err
+	}
+	result, err = finishJournalChange(ctx, userId, change.JournalId, result)
+	if err == nil {
+		analyzeJournalInBackground(*result.Journal)
+		*written = append(*written, *result.Journal)
+	}
+	return result, err
+}
+
+// finishJournalChange marks a change as applied and attaches the stored copy of the entry
+func finishJournalChange(ctx context.Context, userId string, journalId string, result models.SyncResult) (models.SyncResult, error) {
+	journal, err := database.GetJournalByID(ctx, userId, journalId)
+	if err != nil {
+		return result, err
+	}
+	result.Status = constants.SyncStatusApplied
+	result.Journal = journal
+	return result, nil
+}
+
+// keepServerJournal reports a change that lost its conflict, returning the server copy
+func keepServerJournal(result models.SyncResult, existing *models.Journal) models.SyncResult {
+	result.Status = constants.SyncStatusConflict
+	result.Resolution = constants.SyncResolutionServerWins
+	result.Journal = existing
+	return result
+}
+
+// applyMoodChange applies one mood entry create or delete. Mood entries are never edited,
+// so they cannot conflict.
+func applyMoodChange(ctx context.Context, userId string, change models.SyncMoodChange) (models.SyncResult, error) {
+	result := models.SyncResult{Type: syncTypeMood, Id: change.MoodEntryId, Op: change.Op}
+	if len(change.MoodEntryId) > maxSyncIdLength {
+		return rejectSync(result, "moodEntryId is too long"), nil
+	}
+
+	switch change.Op {
+	case constants.SyncOpCreate:
+		if change.MoodQuestionnaireID == "" || len(change.Answers) == 0 {
+			return rejectSync(result, "moodQuestionnaireId and answers are required"), nil
+		}
+		entry := models.MoodEntry{
+			UserID:              userId,
+			MoodEntryId:         change.MoodEntryId,
+			MoodQuestionnaireID: change.MoodQuestionnaireID,
+			Answers:             change.Answers,
+			UpdatedAt:           time.Now().Unix(),
+		}
+		for attempt := 0; attempt <= 60; attempt++ {
+			entry.Timestamp = change.Timestamp + int64(attempt)
+			created, err := database.CreateMoodEntry(ctx, entry)
+			if err != nil {
+				return result, err
+			}
+			if created {
+				result.Status = constants.SyncStatusApplied
+				result.Mood = &entry
+				return result, nil
+			}
+			taken, err := database.GetMoodEntry(ctx, userId, entry.Timestamp)
+			if err != nil {
+				return result, err
+			}
+			if taken != nil && taken.MoodEntryId == change.MoodEntryId {
+				// A resent create that was already applied
+				result.Status = constants.SyncStatusApplied
+				result.Mood = taken
+				return result, nil
+			}
+		}
+		return result, fmt.Errorf("no free timestamp near %d", change.Timestamp)
+
+	case constants.SyncOpDelete:
+		existing, err := database.GetMoodEntry(ctx, userId, change.Timestamp)
+		if err != nil {
+			return result, err
+		}
+		if existing == nil || existing.MoodEntryId != change.MoodEntryId {
+			return rejectSync(result, "mood entry not found"), nil
+		}
+		if existing.DeletedAt == 0 {
+			if err := database.DeleteMoodEntry(ctx, userId, change.Timestamp); err != nil {
+				return result, err
+			}
+			if existing, err = database.GetMoodEntry(ctx, userId, change.Timestamp); err != nil {
+				return result, err
+			}
+		}
+		result.Status = constants.SyncStatusApplied
+		result.Mood = existing
+		return result, nil
+	}
+
+	return rejectSync(result, fmt.Sprintf("unknown op %q", change.Op)), nil
+}
+
+// collectSyncChanges adds the next page of server changes after the client's token to the response.
+// A pass over the change feed reads every record with updatedAt >= Since and may span several
+// responses; the next pass starts a few seconds before this one did, so writes that committed
+// while it ran are not missed. Records can therefore arrive twice and clients upsert them.
+func collectSyncChanges(ctx context.Context, userId string, token utils.SyncToken, response *models.SyncResponse) error {
+	now := time.Now()
+	if token.Started == 0 {
+		token.Started = now.Unix()
+		// Purged trash leaves no tombstone, so a client that missed the whole retention window
+		// cannot be brought up to date incrementally
+		oldest := now.AddDate(0, 0, -utils.JournalTrashRetentionDays()).Unix()
+		if token.Since > 0 && token.Since < oldest {
+			token.Since = 0
+			response.Reset = true
+		}
+	}
+
+	response.Journals = []models.Journal{}
+	if !token.JournalsDone {
+		journals, cursor, err := database.GetJournalsUpdatedSince(ctx, userId, token.Since, token.JournalCursor)
+		if err != nil {
+			return err
+		}
+		response.Journals = journals
+		token.JournalCursor = cursor
+		token.JournalsDone = cursor == ""
+	}
+	response.Moods = []models.MoodEntry{}
+	if !token.MoodsDone {
+		moods, cursor, err := database.GetMoodEntriesUpdatedSince(ctx, userId, token.Since, token.MoodCursor)
+		if err != nil {
+			return err
+		}
+		response.Moods = moods
+		token.MoodCursor = cursor
+		token.MoodsDone = cursor == ""
+	}
+
+	response.HasMore = !token.JournalsDone || !token.MoodsDone
+	if !response.HasMore {
+		token = utils.SyncToken{Since: token.Started - constants.SyncClockSkewSeconds}
+	}
+	response.Token = utils.EncodeSyncToken(token)
+	return nil
+}
+
+// rejectSync reports a change that could not be applied
+func rejectSync(result models.SyncResult, reason string) models.SyncResult {
+	result.Status = constants.SyncStatusRejected
+	result.Error = reason
+	return result
+}
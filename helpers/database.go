@@ -197,38 +197,48 @@ func DeleteUser(userID string) error {
 *   Chat Related DB functions
  */
 
-// StoreChatMessage stores a chat message in DynamoDB
-func StoreChatMessage(msg *models.ChatMessage) error {
-	msg.SessionIdTimestamp = msg.SessionId + "#" + fmt.Sprintf("%d", msg.Timestamp)
-	av, err := attributevalue.MarshalMap(msg)
-	if err != nil {
-		return err
-	}
+// GetChatHistoryBySession retrieves the most recent chat messages of a session, oldest first
+func GetChatHistoryBySession(userId, sessionId string, limit int32) ([]models.ChatMessage, error) {
+	return GetChatHistorySince(userId, sessionId, "", limit)
+}
 
-	input := &dynamodb.PutItemInput{
-		Item:      av,
-		TableName: aws.String(constants.ChatTable),
-	}
-
-	_, err = dynamoClient.PutItem(context.TODO(), input)
-	if err != nil {
-		print("Put Item failed with error ", err)
+// GetChatHistorySince retrieves up to limit of the most recent messages of a session whose sort
+// key comes after the given one, oldest first. An empty key reads from the start of the session.
+func GetChatHistorySince(userId, sessionId, after string, limit int32) ([]models.ChatMessage, error) {
+	chatHistory, err := queryChatHistory(userId, sessionId, after, limit, false)
+	// Back to chronological order
+	for i, j := 0, len(chatHistory)-1; i < j; i, j = i+1, j-1 {
+		chatHistory[i], chatHistory[j] = chatHistory[j], chatHistory[i]
 	}
+	return chatHistory, err
+}
 
-	return err
+// GetOldestChatHistorySince retrieves up to limit of the oldest messages of a session whose sort
+// key comes after the given one, oldest first
+func GetOldestChatHistorySince(userId, sessionId, after string, limit int32) ([]models.ChatMessage, error) {
+	return queryChatHistory(userId, sessionId, after, limit, true)
 }
 
-// GetChatHistoryBySession retrieves all chat messages for a user and session, ordered by timestamp
-func GetChatHistoryBySession(userId, sessionId string, limit int32) ([]models.ChatMessage, error) {
+// queryChatHistory reads up to limit messages of a session after the given sort key, from
+// either end of the range
+func queryChatHistory(userId, sessionId, after string, limit int32, oldestFirst bool) ([]models.ChatMessage, error) {
+	// BETWEEN is inclusive, so the message at the cursor is read as well and skipped below
+	from := sessionId + "#"
+	queryLimit := limit
+	if after != "" {
+		from = after
+		queryLimit++
+	}
 	input := &dynamodb.QueryInput{
 		TableName: aws.String(constants.ChatTable),
-		KeyConditionExpression: aws.String("userId = :userId AND begins_with(sessionId_timestamp, :sessionIdPrefix)"),
+		KeyConditionExpression: aws.String("userId = :userId AND sessionId_timestamp BETWEEN :from AND :to"),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":userId":          &types.AttributeValueMemberS{Value: userId},
-			":sessionIdPrefix": &types.AttributeValueMemberS{Value: sessionId + "#"},
+			":userId": &types.AttributeValueMemberS{Value: userId},
+			":from":   &types.AttributeValueMemberS{Value: from},
+			":to":     &types.AttributeValueMemberS{Value: sessionId + "#~"}, // '~' sorts after every digit and ULID character
 		},
-		Limit:            &limit,
-		ScanIndexForward: aws.Bool(true), // chronological order
+		Limit:            &queryLimit,
+		ScanIndexForward: aws.Bool(oldestFirst), // Limit keeps the messages at the end read first
 	}
 
 	result, err := dynamoClient.Query(context.TODO(), input)
@@ -237,6 +247,17 @@ func GetChatHistoryBySession(userId, sessionId string, limit int32) ([]models.Ch
 	}
 
 	chatHistory := []models.ChatMessage{}
-	err = attributevalue.UnmarshalListOfMaps(result.Items, &chatHistory)
-	return chatHistory, err
+	if err := attributevalue.UnmarshalListOfMaps(result.Items, &chatHistory); err != nil {
+		return nil, err
+	}
+	messages := make([]models.ChatMessage, 0, len(chatHistory))
+	for _, msg := range chatHistory {
+		if msg.SessionIdTimestamp != after {
+			messages = append(messages, msg)
+		}
+	}
+	if int32(len(messages)) > limit {
+		messages = messages[:limit]
+	}
+	return messages, nil
 }
@@ -9,6 +9,7 @@ import (
 
 	"lambda-server/constants"
 	"lambda-server/models"
+	"lambda-server/utils"
 
 	"github.com/dgrijalva/jwt-go"
 )
@@ -134,8 +135,32 @@ func CheckInactivity(user *models.User) error {
 		user.TokenVersion++
 		user.RefreshToken = ""
 		UpdateUser(user)
-		return errors.New("session expired due to inactivity")
+		// Tell the user when they were last seen, in their own time zone and date format
+		lastActive := utils.FormatLocalDate(time.Unix(user.LastActiveAt, 0), utils.LoadLocation(user.TimeZone), user.Locale)
+		return fmt.Errorf("session expired due to inactivity since %s", lastActive)
 	}
 
 	return nil
 }
+
+// AuthenticateAccessToken returns the user an access token belongs to, checking it the way
+// AuthMiddleware does, for connections that cannot go through the middleware
+func AuthenticateAccessToken(tokenString string) (*models.User, error) {
+	claims, err := ValidateToken(tokenString, constants.TokenTypeAccess)
+	if err != nil {
+		return nil, fmt.Errorf("access token invalid: %w", err)
+	}
+	user, err := GetUserByID(claims.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user.TokenVersion != claims.TokenVersion {
+		return nil, errors.New("token expired or invalid")
+	}
+	if err := CheckInactivity(user); err != nil {
+		return nil, err
+	}
+	user.LastActiveAt = time.Now().Unix()
+	UpdateUser(user)
+	return user, nil
+}
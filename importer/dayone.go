@@ -0,0 +1,60 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"lambda-server/models"
+)
+
+// dayOneExport is the shape of the JSON file in a Day One export
+type dayOneExport struct {
+	Entries []struct {
+		UUID         string   `json:"uuid"`
+		CreationDate string   `json:"creationDate"` // RFC 3339, UTC
+		TimeZone     string   `json:"timeZone"`
+		Text         string   `json:"text"`
+		Tags         []string `json:"tags"`
+		Starred      bool     `json:"starred"`
+	} `json:"entries"`
+}
+
+// parseDayOneFile reads the journal JSON files of a Day One export; media folders are ignored
+func parseDayOneFile(name string, data []byte, loc *time.Location) ([]Item, []models.ImportItemError) {
+	if !strings.EqualFold(path.Ext(name), ".json") {
+		return nil, nil
+	}
+	var export dayOneExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, []models.ImportItemError{{Item: name, Error: "invalid Day One JSON: " + err.Error()}}
+	}
+
+	items := []Item{}
+	itemErrors := []models.ImportItemError{}
+	for i, entry := range export.Entries {
+		ref := fmt.Sprintf("%s#%d", name, i+1)
+		createdAt, err := time.Parse(time.RFC3339, entry.CreationDate)
+		if err != nil {
+			itemErrors = append(itemErrors, models.ImportItemError{Item: ref, Error: "invalid creationDate"})
+			continue
+		}
+		if strings.TrimSpace(entry.Text) == "" {
+			itemErrors = append(itemErrors, models.ImportItemError{Item: ref, Error: "entry has no text"})
+			continue
+		}
+		title, content := splitTitle(entry.Text)
+		items = append(items, Item{
+			Ref:        ref,
+			ExternalID: entry.UUID,
+			Title:      title,
+			Content:    content,
+			CreatedAt:  createdAt.In(entryLocation(entry.TimeZone, loc)),
+			Tags:       entry.Tags,
+			Pinned:     entry.Starred,
+		})
+	}
+	return items, itemErrors
+}
@@ -0,0 +1,131 @@
+// Package importer parses journal archives exported by other apps into journal items.
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"lambda-server/models"
+	"lambda-server/utils"
+)
+
+// Supported archive sources
+const (
+	SourceDayOne   = "dayone"
+	SourceJourney  = "journey"
+	SourceMarkdown = "markdown"
+)
+
+// maxTitleLength bounds titles derived from the first line of an entry
+const maxTitleLength = 120
+
+// Item is a journal entry read from an archive, before it is mapped to models.Journal
+type Item struct {
+	Ref        string // File (and index) the item came from, used in error reports
+	ExternalID string // Identifier in the source app, if any
+	Title      string
+	Content    string
+	CreatedAt  time.Time // Original creation time, in the entry's own time zone when known
+	Tags       []string
+	Pinned     bool
+}
+
+// Parse reads every entry of a zip archive exported by the given source.
+// Dates without a time zone are read in loc, the importing user's zone.
+// Items that cannot be read are reported in the error list instead of failing the whole archive.
+func Parse(source string, archive []byte, loc *time.Location) ([]Item, []models.ImportItemError, error) {
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("archive is not a valid zip file: %w", err)
+	}
+
+	var parseFile func(name string, data []byte, loc *time.Location) ([]Item, []models.ImportItemError)
+	switch source {
+	case SourceDayOne:
+		parseFile = parseDayOneFile
+	case SourceJourney:
+		parseFile = parseJourneyFile
+	case SourceMarkdown:
+		parseFile = parseMarkdownFile
+	default:
+		return nil, nil, fmt.Errorf("unsupported import source %q", source)
+	}
+
+	items := []Item{}
+	itemErrors := []models.ImportItemError{}
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() || isHiddenPath(file.Name) {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			itemErrors = append(itemErrors, models.ImportItemError{Item: file.Name, Error: err.Error()})
+			continue
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			itemErrors = append(itemErrors, models.ImportItemError{Item: file.Name, Error: err.Error()})
+			continue
+		}
+		fileItems, fileErrors := parseFile(file.Name, data, loc)
+		items = append(items, fileItems...)
+		itemErrors = append(itemErrors, fileErrors...)
+	}
+	return items, itemErrors, nil
+}
+
+// Fingerprint identifies an entry by its creation second and content, so the same entry
+// is recognised as a duplicate whichever archive (or the app itself) it came from
+func Fingerprint(createdAt int64, content string) string {
+	sum := sha256.Sum256([]byte(strconv.FormatInt(createdAt, 10) + "\x00" + strings.TrimSpace(content)))
+	return hex.EncodeToString(sum[:])
+}
+
+// splitTitle uses the first non-empty line of a text as its title and the rest as content.
+// Markdown heading markers are removed from the title.
+func splitTitle(text string) (string, string) {
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+	first, rest, _ := strings.Cut(text, "\n")
+	title := strings.TrimSpace(strings.TrimLeft(first, "#"))
+	if len([]rune(title)) > maxTitleLength {
+		title = string([]rune(title)[:maxTitleLength])
+	}
+	content := strings.TrimSpace(rest)
+	if content == "" {
+		content = text
+	}
+	return title, content
+}
+
+// isHiddenPath skips macOS metadata and dot files commonly found in zip archives
+func isHiddenPath(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") || part == "__MACOSX" {
+			return true
+		}
+	}
+	return false
+}
+
+// entryLocation resolves the time zone recorded on an entry, falling back to the importing user's zone
+func entryLocation(name string, fallback *time.Location) *time.Location {
+	if utils.ValidateTimeZone(name) != nil {
+		return fallback
+	}
+	return utils.LoadLocation(name)
+}
+
+// baseName returns a file name without its directory and extension
+func baseName(name string) string {
+	base := path.Base(name)
+	return strings.TrimSuffix(base, path.Ext(base))
+}
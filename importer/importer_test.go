@@ -0,0 +1,101 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func buildArchive(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		assert.Nil(t, err)
+		_, err = f.Write([]byte(content))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestParseDayOne(t *testing.T) {
+	archive := buildArchive(t, map[string]string{
+		"Journal.json": `{"entries": [
+			{"uuid": "A1", "creationDate": "2024-03-10T22:30:00Z", "timeZone": "Asia/Kolkata", "text": "# Late night\nCould not sleep.", "tags": ["Sleep"], "starred": true},
+			{"uuid": "A2", "creationDate": "not a date", "text": "Broken"}
+		]}`,
+		"photos/abc.jpeg":         "binary",
+		"__MACOSX/._Journal.json": "junk",
+	})
+
+	items, itemErrors, err := Parse(SourceDayOne, archive, time.UTC)
+	assert.Nil(t, err)
+	assert.Len(t, items, 1)
+	assert.Len(t, itemErrors, 1)
+	assert.Equal(t, "Journal.json#2", itemErrors[0].Item)
+
+	item := items[0]
+	assert.Equal(t, "Late night", item.Title)
+	assert.Equal(t, "Could not sleep.", item.Content)
+	assert.True(t, item.Pinned)
+	// The original date is kept in the entry's own time zone
+	assert.Equal(t, "20240311", item.CreatedAt.Format("20060102"))
+	assert.Equal(t, int64(1710109800), item.CreatedAt.Unix())
+}
+
+func TestParseJourneyStripsHTML(t *testing.T) {
+	archive := buildArchive(t, map[string]string{
+		"1700000000000-abc.json": `{"id": "abc", "date_journal": 1700000000000, "timezone": "UTC", "text": "<p>Walk</p><p>Saw &amp; heard birds</p>", "tags": ["nature"], "favourite": false}`,
+		"1700000000000-abc.jpg":  "binary",
+	})
+
+	items, itemErrors, err := Parse(SourceJourney, archive, time.UTC)
+	assert.Nil(t, err)
+	assert.Empty(t, itemErrors)
+	assert.Len(t, items, 1)
+	assert.Equal(t, "Walk", items[0].Title)
+	assert.Equal(t, "Saw & heard birds", items[0].Content)
+	assert.Equal(t, time.UnixMilli(1700000000000).Unix(), items[0].CreatedAt.Unix())
+}
+
+func TestParseMarkdown(t *testing.T) {
+	archive := buildArchive(t, map[string]string{
+		"notes/2023-05-01 Garden.md": "Planted tomatoes.",
+		"notes/trip.md":              "---\ntitle: \"Trip\"\ndate: 2023-06-02 08:15\ntags:\n  - travel\n  - family\n---\nPacked the car.",
+		"notes/undated.md":           "No date here.",
+		"notes/readme.pdf":           "ignored",
+	})
+
+	kolkata, _ := time.LoadLocation("Asia/Kolkata")
+	items, itemErrors, err := Parse(SourceMarkdown, archive, kolkata)
+	assert.Nil(t, err)
+	assert.Len(t, items, 2)
+	assert.Len(t, itemErrors, 1)
+	assert.Equal(t, "notes/undated.md", itemErrors[0].Item)
+
+	byTitle := map[string]Item{}
+	for _, item := range items {
+		byTitle[item.Title] = item
+	}
+	assert.Equal(t, "Planted tomatoes.", byTitle["Garden"].Content)
+	assert.Equal(t, "20230501", byTitle["Garden"].CreatedAt.Format("20060102"))
+	assert.Equal(t, []string{"travel", "family"}, byTitle["Trip"].Tags)
+	// Dates without an offset are in the importing user's time zone
+	assert.Equal(t, "2023-06-02 08:15 +0530", byTitle["Trip"].CreatedAt.Format("2006-01-02 15:04 -0700"))
+}
+
+func TestParseRejectsInvalidInput(t *testing.T) {
+	_, _, err := Parse(SourceMarkdown, []byte("not a zip"), time.UTC)
+	assert.NotNil(t, err)
+	_, _, err = Parse("evernote", buildArchive(t, map[string]string{"a.md": "x"}), time.UTC)
+	assert.NotNil(t, err)
+}
+
+func TestFingerprintIgnoresSurroundingWhitespace(t *testing.T) {
+	assert.Equal(t, Fingerprint(1700000000, "Hello\n"), Fingerprint(1700000000, "  Hello"))
+	assert.NotEqual(t, Fingerprint(1700000000, "Hello"), Fingerprint(1700000001, "Hello"))
+}
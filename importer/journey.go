@@ -0,0 +1,62 @@
+package importer
+
+import (
+	"encoding/json"
+	"html"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"lambda-server/models"
+)
+
+// journeyEntry is the shape of one entry file in a Journey export
+type journeyEntry struct {
+	ID          string   `json:"id"`
+	DateJournal int64    `json:"date_journal"` // unix milliseconds
+	Timezone    string   `json:"timezone"`
+	Text        string   `json:"text"`
+	Tags        []string `json:"tags"`
+	Favourite   bool     `json:"favourite"`
+}
+
+var (
+	htmlBreakPattern = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</div>|</h[1-6]>|</li>`)
+	htmlTagPattern   = regexp.MustCompile(`<[^>]+>`)
+)
+
+// parseJourneyFile reads one entry JSON file of a Journey export; photos are ignored
+func parseJourneyFile(name string, data []byte, loc *time.Location) ([]Item, []models.ImportItemError) {
+	if !strings.EqualFold(path.Ext(name), ".json") {
+		return nil, nil
+	}
+	var entry journeyEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, []models.ImportItemError{{Item: name, Error: "invalid Journey JSON: " + err.Error()}}
+	}
+	if entry.DateJournal <= 0 {
+		return nil, []models.ImportItemError{{Item: name, Error: "missing date_journal"}}
+	}
+
+	text := entry.Text
+	// Newer Journey versions export rich text as HTML
+	if strings.Contains(text, "<") && htmlTagPattern.MatchString(text) {
+		text = htmlBreakPattern.ReplaceAllString(text, "\n")
+		text = html.UnescapeString(htmlTagPattern.ReplaceAllString(text, ""))
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, []models.ImportItemError{{Item: name, Error: "entry has no text"}}
+	}
+
+	title, content := splitTitle(text)
+	return []Item{{
+		Ref:        name,
+		ExternalID: entry.ID,
+		Title:      title,
+		Content:    content,
+		CreatedAt:  time.UnixMilli(entry.DateJournal).In(entryLocation(entry.Timezone, loc)),
+		Tags:       entry.Tags,
+		Pinned:     entry.Favourite,
+	}}, nil
+}
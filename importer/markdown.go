@@ -0,0 +1,124 @@
+package importer
+
+import (
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"lambda-server/models"
+)
+
+// Dates are read from front matter first, then from a YYYY-MM-DD prefix of the file name
+var fileDatePattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})`)
+
+var frontMatterDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// parseMarkdownFile reads one .md file of a plain Markdown folder.
+// Optional YAML front matter may set title, date and tags.
+func parseMarkdownFile(name string, data []byte, loc *time.Location) ([]Item, []models.ImportItemError) {
+	ext := strings.ToLower(path.Ext(name))
+	if ext != ".md" && ext != ".markdown" && ext != ".txt" {
+		return nil, nil
+	}
+
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	meta, body := splitFrontMatter(text)
+
+	var createdAt time.Time
+	var err error
+	if value := meta["date"]; value != "" {
+		createdAt, err = parseFrontMatterDate(value, loc)
+	} else if match := fileDatePattern.FindString(path.Base(name)); match != "" {
+		createdAt, err = time.ParseInLocation("2006-01-02", match, loc)
+	} else {
+		return nil, []models.ImportItemError{{Item: name, Error: "no date in front matter or file name"}}
+	}
+	if err != nil {
+		return nil, []models.ImportItemError{{Item: name, Error: "invalid date"}}
+	}
+
+	if strings.TrimSpace(body) == "" {
+		return nil, []models.ImportItemError{{Item: name, Error: "entry has no text"}}
+	}
+
+	title := strings.Trim(meta["title"], `"'`)
+	content := strings.TrimSpace(body)
+	if title == "" {
+		if strings.HasPrefix(content, "#") {
+			title, content = splitTitle(content)
+		} else {
+			title = strings.TrimSpace(fileDatePattern.ReplaceAllString(baseName(name), ""))
+			title = strings.TrimLeft(title, " -_")
+		}
+	}
+
+	return []Item{{
+		Ref:       name,
+		Title:     title,
+		Content:   content,
+		CreatedAt: createdAt,
+		Tags:      parseFrontMatterList(meta["tags"]),
+	}}, nil
+}
+
+// splitFrontMatter separates a leading "---" block of simple key: value pairs from the body
+func splitFrontMatter(text string) (map[string]string, string) {
+	meta := map[string]string{}
+	if !strings.HasPrefix(text, "---\n") {
+		return meta, text
+	}
+	end := strings.Index(text[4:], "\n---")
+	if end < 0 {
+		return meta, text
+	}
+	block := text[4 : 4+end]
+	body := strings.TrimPrefix(text[4+end+4:], "\n")
+
+	lastKey := ""
+	for _, line := range strings.Split(block, "\n") {
+		// YAML list items continue the previous key, e.g. "tags:\n  - work"
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "- ") && lastKey != "" {
+			meta[lastKey] = strings.TrimSpace(meta[lastKey] + "," + strings.TrimPrefix(trimmed, "- "))
+			continue
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		lastKey = strings.ToLower(strings.TrimSpace(key))
+		meta[lastKey] = strings.TrimSpace(value)
+	}
+	return meta, body
+}
+
+// parseFrontMatterDate accepts the common date formats used in front matter; dates without an offset are read in loc
+func parseFrontMatterDate(value string, loc *time.Location) (time.Time, error) {
+	value = strings.Trim(value, `"'`)
+	var err error
+	for _, layout := range frontMatterDateLayouts {
+		var t time.Time
+		if t, err = time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// parseFrontMatterList reads "[a, b]", "a, b" or the comma-joined YAML list built by splitFrontMatter
+func parseFrontMatterList(value string) []string {
+	value = strings.Trim(strings.TrimSpace(value), "[]")
+	values := []string{}
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.Trim(strings.TrimSpace(part), `"'`); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
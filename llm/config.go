@@ -0,0 +1,221 @@
+package llm
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Provider names accepted in LLM_PROVIDER
+const (
+	ProviderHuggingFace = "huggingface"
+	ProviderOpenAI      = "openai"
+	ProviderOllama      = "ollama"
+	ProviderMock        = "mock"
+)
+
+// Environment variables read by ConfigFromEnv
+const (
+	EnvProvider        = "LLM_PROVIDER"
+	EnvBaseURL         = "LLM_BASE_URL"
+	EnvAPIKey          = "LLM_API_KEY"
+	EnvModel           = "LLM_MODEL"
+	EnvAllowedModels   = "LLM_ALLOWED_MODELS" // Comma-separated models clients may pick per request
+	EnvTimeoutSeconds  = "LLM_TIMEOUT_SECONDS"
+	EnvStreamTimeout   = "LLM_STREAM_TIMEOUT_SECONDS"
+	EnvContextTokens   = "LLM_CONTEXT_TOKENS" // Prompt token budget for chat history
+	EnvFallbacks       = "LLM_FALLBACKS"      // Comma-separated routes tried after the primary: "model" or "provider:model"
+	EnvMaxRetries      = "LLM_MAX_RETRIES"    // Retries per route of a retryable failure
+	EnvRetryBaseMs     = "LLM_RETRY_BASE_MS"  // Upper bound of the first backoff, doubling per retry
+	EnvBreakerFailures = "LLM_BREAKER_FAILURES"
+	EnvBreakerCooldown = "LLM_BREAKER_COOLDOWN_SECONDS"
+
+	// Provider-specific API key fallbacks
+	EnvHuggingFaceAPIKey = "HUGGINGFACE_API_KEY"
+	EnvOpenAIAPIKey      = "OPENAI_API_KEY"
+)
+
+// Defaults for unset or invalid settings
+const (
+	DefaultTimeout         = 30 * time.Second
+	DefaultStreamTimeout   = 2 * time.Minute
+	DefaultContextTokens   = 3000
+	DefaultMaxRetries      = 2
+	DefaultRetryBaseDelay  = 250 * time.Millisecond
+	DefaultRetryMaxDelay   = 4 * time.Second
+	DefaultBreakerFailures = 5
+	DefaultBreakerCooldown = 30 * time.Second
+)
+
+// Config selects and configures a provider
+type Config struct {
+	Provider      string
+	BaseURL       string // Empty uses the provider's default
+	APIKey        string
+	Model         string   // Default model; empty uses the provider's default
+	AllowedModels []string // Models a request may choose besides the default
+	Timeout       time.Duration
+	StreamTimeout time.Duration
+	ContextTokens int // Estimated tokens of prompt a chat request may send
+
+	Fallbacks       []Fallback // Routes tried in order when the primary fails
+	Retry           RetryPolicy
+	BreakerFailures int // Consecutive failures that open a route's circuit breaker; 0 disables it
+	BreakerCooldown time.Duration
+}
+
+// Fallback is a route of the fallback chain. An empty or matching Provider reuses the primary
+// provider with another model; any other provider uses its default endpoint and API key.
+type Fallback struct {
+	Provider string
+	Model    string
+}
+
+// ConfigFromEnv reads the provider configuration from the environment. The Hugging Face
+// router is used when LLM_PROVIDER is unset, keeping the previous behaviour.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Provider:      strings.ToLower(strings.TrimSpace(os.Getenv(EnvProvider))),
+		BaseURL:       os.Getenv(EnvBaseURL),
+		APIKey:        os.Getenv(EnvAPIKey),
+		Model:         os.Getenv(EnvModel),
+		Timeout:       envSeconds(EnvTimeoutSeconds, DefaultTimeout),
+		StreamTimeout: envSeconds(EnvStreamTimeout, DefaultStreamTimeout),
+		ContextTokens: DefaultContextTokens,
+		Retry: RetryPolicy{
+			MaxRetries: DefaultMaxRetries,
+			BaseDelay:  DefaultRetryBaseDelay,
+			MaxDelay:   DefaultRetryMaxDelay,
+		},
+		BreakerFailures: DefaultBreakerFailures,
+		BreakerCooldown: envSeconds(EnvBreakerCooldown, DefaultBreakerCooldown),
+	}
+	if cfg.Provider == "" {
+		cfg.Provider = ProviderHuggingFace
+	}
+	if cfg.APIKey == "" {
+		cfg.APIKey = providerAPIKey(cfg.Provider)
+	}
+	if tokens, err := strconv.Atoi(os.Getenv(EnvContextTokens)); err == nil && tokens > 0 {
+		cfg.ContextTokens = tokens
+	}
+	if retries, err := strconv.Atoi(os.Getenv(EnvMaxRetries)); err == nil && retries >= 0 {
+		cfg.Retry.MaxRetries = retries
+	}
+	if ms, err := strconv.Atoi(os.Getenv(EnvRetryBaseMs)); err == nil && ms > 0 {
+		cfg.Retry.BaseDelay = time.Duration(ms) * time.Millisecond
+	}
+	if failures, err := strconv.Atoi(os.Getenv(EnvBreakerFailures)); err == nil && failures >= 0 {
+		cfg.BreakerFailures = failures
+	}
+	cfg.AllowedModels = envList(EnvAllowedModels)
+	for _, entry := range envList(EnvFallbacks) {
+		cfg.Fallbacks = append(cfg.Fallbacks, parseFallback(entry))
+	}
+	return cfg
+}
+
+// parseFallback reads "provider:model" when the part before the first colon names a
+// provider, and a model of the primary provider otherwise, since model names may contain
+// colons too
+func parseFallback(entry string) Fallback {
+	if provider, model, found := strings.Cut(entry, ":"); found {
+		switch provider = strings.ToLower(provider); provider {
+		case ProviderHuggingFace, ProviderOpenAI, ProviderOllama, ProviderMock:
+			return Fallback{Provider: provider, Model: model}
+		}
+	}
+	return Fallback{Model: entry}
+}
+
+// providerAPIKey returns the provider-specific API key from the environment
+func providerAPIKey(provider string) string {
+	switch provider {
+	case ProviderHuggingFace:
+		return os.Getenv(EnvHuggingFaceAPIKey)
+	case ProviderOpenAI:
+		return os.Getenv(EnvOpenAIAPIKey)
+	}
+	return ""
+}
+
+// New creates the provider described by cfg
+func New(cfg Config) (LLMProvider, error) {
+	switch cfg.Provider {
+	case ProviderHuggingFace:
+		return NewHuggingFaceProvider(cfg.BaseURL, cfg.APIKey, cfg.Model), nil
+	case ProviderOpenAI:
+		baseURL := cfg.BaseURL
+		if baseURL == "" {
+			baseURL = OpenAIBaseURL
+		}
+		if cfg.Model == "" {
+			return nil, fmt.Errorf("%s must be set for the openai provider", EnvModel)
+		}
+		return NewOpenAIProvider(baseURL, cfg.APIKey, cfg.Model), nil
+	case ProviderOllama:
+		return NewOllamaProvider(cfg.BaseURL, cfg.Model), nil
+	case ProviderMock:
+		return NewMockProvider(), nil
+	}
+	return nil, fmt.Errorf("unknown LLM provider %q", cfg.Provider)
+}
+
+// NewResilient creates the primary provider wrapped with retries, the configured fallback
+// routes and a circuit breaker per route
+func NewResilient(cfg Config) (LLMProvider, error) {
+	primary, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	routes := []Route{{Provider: primary, Breaker: NewCircuitBreaker(cfg.BreakerFailures, cfg.BreakerCooldown)}}
+	for _, fallback := range cfg.Fallbacks {
+		provider := primary
+		if fallback.Provider != "" && fallback.Provider != cfg.Provider {
+			provider, err = New(Config{Provider: fallback.Provider, APIKey: providerAPIKey(fallback.Provider), Model: fallback.Model})
+			if err != nil {
+				return nil, fmt.Errorf("fallback %s: %w", fallback.Provider, err)
+			}
+		}
+		routes = append(routes, Route{
+			Provider: provider,
+			Model:    fallback.Model,
+			Breaker:  NewCircuitBreaker(cfg.BreakerFailures, cfg.BreakerCooldown),
+		})
+	}
+	return NewResilientProvider(cfg.Retry, routes...), nil
+}
+
+// ResolveModel checks a model requested by a client. An empty request uses the default model;
+// anything else must be the default or one of the allowed models.
+func (cfg Config) ResolveModel(requested string) (string, error) {
+	if requested == "" || requested == cfg.Model {
+		return cfg.Model, nil
+	}
+	for _, model := range cfg.AllowedModels {
+		if model == requested {
+			return requested, nil
+		}
+	}
+	return "", fmt.Errorf("model %q is not available", requested)
+}
+
+func envList(name string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func envSeconds(name string, fallback time.Duration) time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || seconds <= 0 {
+		return fallback
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,65 @@
+// Package llm talks to chat completion backends through a common LLMProvider interface.
+// Implementations cover the Hugging Face router, OpenAI-compatible endpoints, Ollama-style
+// local servers and a deterministic mock for tests.
+package llm
+
+import (
+	"context"
+	"fmt"
+)
+
+// Message roles
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Message is one turn of a conversation
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Request is a chat completion request
+type Request struct {
+	Model       string    // Empty uses the provider's default model
+	Messages    []Message // Oldest first
+	MaxTokens   int       // Zero leaves the limit to the backend
+	Temperature *float64  // Nil leaves the temperature to the backend
+}
+
+// Usage counts the tokens of a completion, when the backend reports them
+type Usage struct {
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+}
+
+// Response is a finished chat completion
+type Response struct {
+	Content string
+	Model   string
+	Usage   Usage
+}
+
+// LLMProvider generates chat completions. Implementations must honour ctx cancellation.
+type LLMProvider interface {
+	// Name identifies the backend, e.g. "huggingface" or "ollama"
+	Name() string
+	// Complete returns the whole completion at once
+	Complete(ctx context.Context, req Request) (*Response, error)
+	// Stream calls onToken with each piece of the completion as it arrives and returns the
+	// assembled completion. On error the pieces already delivered are still in the response.
+	Stream(ctx context.Context, req Request, onToken func(string)) (*Response, error)
+}
+
+// APIError is returned when a backend answers with a non-success status
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s returned status %d: %s", e.Provider, e.StatusCode, e.Body)
+}
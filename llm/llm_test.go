@@ -0,0 +1,378 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testMessages = []Message{
+	{Role: RoleSystem, Content: "Be kind."},
+	{Role: RoleUser, Content: "Hello there"},
+}
+
+func TestOpenAIProviderComplete(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		var body openAIRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "custom-model", body.Model)
+		assert.Equal(t, testMessages, body.Messages)
+		assert.False(t, body.Stream)
+		fmt.Fprint(w, `{"model":"custom-model","choices":[{"message":{"role":"assistant","content":"Hi!"}}],"usage":{"prompt_tokens":7,"completion_tokens":2}}`)
+	}))
+	defer server.Close()
+
+	provider := NewOpenAIProvider(server.URL+"/v1/", "secret", "default-model")
+	resp, err := provider.Complete(context.Background(), Request{Model: "custom-model", Messages: testMessages})
+	require.NoError(t, err)
+	assert.Equal(t, "Hi!", resp.Content)
+	assert.Equal(t, Usage{PromptTokens: 7, CompletionTokens: 2}, resp.Usage)
+}
+
+func TestOpenAIProviderStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body openAIRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.True(t, body.Stream)
+		assert.Equal(t, "default-model", body.Model)
+		fmt.Fprint(w, ": keep-alive\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"role\":\"assistant\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"lo\"}}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	tokens := []string{}
+	provider := NewHuggingFaceProvider(server.URL, "", "default-model")
+	resp, err := provider.Stream(context.Background(), Request{Messages: testMessages}, func(token string) {
+		tokens = append(tokens, token)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Hel", "lo"}, tokens)
+	assert.Equal(t, "Hello", resp.Content)
+	assert.Equal(t, ProviderHuggingFace, provider.Name())
+}
+
+func TestOpenAIProviderStreamCutOff(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Part\"}}]}\n\n")
+	}))
+	defer server.Close()
+
+	resp, err := NewOpenAIProvider(server.URL, "", "m").Stream(context.Background(), Request{Messages: testMessages}, func(string) {})
+	require.Error(t, err)
+	assert.Equal(t, "Part", resp.Content)
+}
+
+func TestProviderAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"error":"slow down"}`)
+	}))
+	defer server.Close()
+
+	_, err := NewOpenAIProvider(server.URL, "", "m").Complete(context.Background(), Request{Messages: testMessages})
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+	assert.Equal(t, ProviderOpenAI, apiErr.Provider)
+}
+
+func TestProviderContextCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := NewOllamaProvider(server.URL, "").Complete(ctx, Request{Messages: testMessages})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestOllamaProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/chat", r.URL.Path)
+		var body ollamaRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, OllamaModel, body.Model)
+		assert.Equal(t, float64(100), body.Options["num_predict"])
+		if !body.Stream {
+			fmt.Fprint(w, `{"model":"llama3.1","message":{"role":"assistant","content":"Hi!"},"done":true,"prompt_eval_count":5,"eval_count":2}`)
+			return
+		}
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"Hi"},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":" you"},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":5,"eval_count":2}`)
+	}))
+	defer server.Close()
+
+	provider := NewOllamaProvider(server.URL, "")
+	req := Request{Messages: testMessages, MaxTokens: 100}
+
+	resp, err := provider.Complete(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, "Hi!", resp.Content)
+	assert.Equal(t, Usage{PromptTokens: 5, CompletionTokens: 2}, resp.Usage)
+
+	tokens := []string{}
+	resp, err = provider.Stream(context.Background(), req, func(token string) { tokens = append(tokens, token) })
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Hi", " you"}, tokens)
+	assert.Equal(t, "Hi you", resp.Content)
+}
+
+func TestMockProvider(t *testing.T) {
+	echo := NewMockProvider()
+	resp, err := echo.Complete(context.Background(), Request{Messages: testMessages})
+	require.NoError(t, err)
+	assert.Equal(t, "Echo: Hello there", resp.Content)
+
+	scripted := NewMockProvider("first answer", "second answer")
+	tokens := []string{}
+	resp, err = scripted.Stream(context.Background(), Request{Messages: testMessages}, func(token string) { tokens = append(tokens, token) })
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first", " answer"}, tokens)
+	assert.Equal(t, "first answer", resp.Content)
+	resp, _ = scripted.Complete(context.Background(), Request{Messages: testMessages})
+	assert.Equal(t, "second answer", resp.Content)
+	resp, _ = scripted.Complete(context.Background(), Request{Messages: testMessages})
+	assert.Equal(t, "second answer", resp.Content)
+	assert.Len(t, scripted.Requests(), 3)
+
+	failing := &MockProvider{Err: errors.New("down")}
+	_, err = failing.Complete(context.Background(), Request{})
+	assert.EqualError(t, err, "down")
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv(EnvProvider, "")
+	t.Setenv(EnvAPIKey, "")
+	t.Setenv(EnvHuggingFaceAPIKey, "hf-key")
+	t.Setenv(EnvAllowedModels, " a , b,,")
+	t.Setenv(EnvTimeoutSeconds, "5")
+	t.Setenv(EnvStreamTimeout, "nope")
+	t.Setenv(EnvContextTokens, "1200")
+	t.Setenv(EnvFallbacks, "small-model, ollama:llama3.1")
+	t.Setenv(EnvMaxRetries, "0")
+
+	cfg := ConfigFromEnv()
+	assert.Equal(t, ProviderHuggingFace, cfg.Provider)
+	assert.Equal(t, "hf-key", cfg.APIKey)
+	assert.Equal(t, []string{"a", "b"}, cfg.AllowedModels)
+	assert.Equal(t, 5*time.Second, cfg.Timeout)
+	assert.Equal(t, DefaultStreamTimeout, cfg.StreamTimeout)
+	assert.Equal(t, 1200, cfg.ContextTokens)
+	assert.Equal(t, []Fallback{{Model: "small-model"}, {Provider: ProviderOllama, Model: "llama3.1"}}, cfg.Fallbacks)
+	assert.Equal(t, 0, cfg.Retry.MaxRetries)
+	assert.Equal(t, DefaultBreakerFailures, cfg.BreakerFailures)
+}
+
+func TestNew(t *testing.T) {
+	for _, name := range []string{ProviderHuggingFace, ProviderOllama, ProviderMock} {
+		provider, err := New(Config{Provider: name})
+		require.NoError(t, err)
+		assert.Equal(t, name, provider.Name())
+	}
+	_, err := New(Config{Provider: ProviderOpenAI})
+	assert.Error(t, err, "openai needs a model")
+	_, err = New(Config{Provider: "unknown"})
+	assert.Error(t, err)
+}
+
+func TestResolveModel(t *testing.T) {
+	cfg := Config{Model: "default", AllowedModels: []string{"small"}}
+	model, err := cfg.ResolveModel("")
+	require.NoError(t, err)
+	assert.Equal(t, "default", model)
+	model, err = cfg.ResolveModel("small")
+	require.NoError(t, err)
+	assert.Equal(t, "small", model)
+	_, err = cfg.ResolveModel("huge")
+	assert.Error(t, err)
+}
+
+// flakyServer answers the OpenAI chat API, failing the first calls with the given responses
+func flakyServer(t *testing.T, failures ...func(w http.ResponseWriter)) (*httptest.Server, *int) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body openAIRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		calls++
+		if calls <= len(failures) {
+			failures[calls-1](w)
+			return
+		}
+		if body.Stream {
+			fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"ok from "+body.Model+"\"}}]}\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		fmt.Fprint(w, `{"model":"`+body.Model+`","choices":[{"message":{"role":"assistant","content":"ok from `+body.Model+`"}}]}`)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func status(code int) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) { w.WriteHeader(code) }
+}
+
+func errorBody(w http.ResponseWriter) {
+	fmt.Fprint(w, `{"error":{"message":"model overloaded"}}`)
+}
+
+var fastRetry = RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+func TestResilientProviderRetries(t *testing.T) {
+	server, calls := flakyServer(t, status(http.StatusServiceUnavailable), errorBody)
+	provider := NewResilientProvider(fastRetry, Route{Provider: NewOpenAIProvider(server.URL, "", "primary")})
+
+	resp, err := provider.Complete(context.Background(), Request{Messages: testMessages})
+	require.NoError(t, err)
+	assert.Equal(t, "ok from primary", resp.Content)
+	assert.Equal(t, 3, *calls)
+}
+
+func TestResilientProviderDoesNotRetryClientErrors(t *testing.T) {
+	server, calls := flakyServer(t, status(http.StatusBadRequest))
+	provider := NewResilientProvider(fastRetry, Route{Provider: NewOpenAIProvider(server.URL, "", "primary")})
+
+	_, err := provider.Complete(context.Background(), Request{Messages: testMessages})
+	assert.ErrorIs(t, err, ErrUnavailable)
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Equal(t, 1, *calls)
+}
+
+func TestResilientProviderFallsBack(t *testing.T) {
+	down := func(w http.ResponseWriter) { w.WriteHeader(http.StatusBadGateway) }
+	primary, primaryCalls := flakyServer(t, down, down, down, down, down, down)
+	backup, backupCalls := flakyServer(t)
+	shared := NewOpenAIProvider(primary.URL, "", "big")
+	provider := NewResilientProvider(fastRetry,
+		Route{Provider: shared},
+		Route{Provider: shared, Model: "small"},
+		Route{Provider: NewOpenAIProvider(backup.URL, "", "backup")},
+	)
+
+	resp, err := provider.Complete(context.Background(), Request{Messages: testMessages})
+	require.NoError(t, err)
+	assert.Equal(t, "ok from backup", resp.Content)
+	assert.Equal(t, 6, *primaryCalls, "both primary routes retried")
+	assert.Equal(t, 1, *backupCalls)
+
+	tokens := []string{}
+	resp, err = provider.Stream(context.Background(), Request{Messages: testMessages}, func(token string) { tokens = append(tokens, token) })
+	require.NoError(t, err)
+	assert.Equal(t, "ok from big", resp.Content, "the primary has recovered")
+	assert.Equal(t, []string{"ok from big"}, tokens)
+}
+
+func TestResilientProviderStreamCutOffIsFinal(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Part\"}}]}\n\n")
+	}))
+	defer server.Close()
+	backup, backupCalls := flakyServer(t)
+	provider := NewResilientProvider(fastRetry,
+		Route{Provider: NewOpenAIProvider(server.URL, "", "m")},
+		Route{Provider: NewOpenAIProvider(backup.URL, "", "backup")},
+	)
+
+	tokens := []string{}
+	resp, err := provider.Stream(context.Background(), Request{Messages: testMessages}, func(token string) { tokens = append(tokens, token) })
+	require.Error(t, err)
+	assert.Equal(t, "Part", resp.Content)
+	assert.Equal(t, []string{"Part"}, tokens)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 0, *backupCalls)
+}
+
+func TestResilientProviderCircuitBreaker(t *testing.T) {
+	down := status(http.StatusInternalServerError)
+	primary, primaryCalls := flakyServer(t, down, down, down)
+	backup, backupCalls := flakyServer(t)
+	clock := time.Unix(1000, 0)
+	breaker := NewCircuitBreaker(2, time.Minute)
+	breaker.now = func() time.Time { return clock }
+	provider := NewResilientProvider(RetryPolicy{MaxRetries: 1, BaseDelay: time.Millisecond},
+		Route{Provider: NewOpenAIProvider(primary.URL, "", "primary"), Breaker: breaker},
+		Route{Provider: NewOpenAIProvider(backup.URL, "", "backup")},
+	)
+
+	resp, err := provider.Complete(context.Background(), Request{Messages: testMessages})
+	require.NoError(t, err)
+	assert.Equal(t, "ok from backup", resp.Content)
+	assert.Equal(t, 2, *primaryCalls)
+	assert.Equal(t, CircuitOpen, breaker.State())
+
+	// While open, the primary is skipped without a call
+	_, err = provider.Complete(context.Background(), Request{Messages: testMessages})
+	require.NoError(t, err)
+	assert.Equal(t, 2, *primaryCalls)
+	assert.Equal(t, 2, *backupCalls)
+
+	// After the cooldown one probe goes through; it fails and reopens the breaker
+	clock = clock.Add(time.Minute)
+	assert.Equal(t, CircuitHalfOpen, breaker.State())
+	_, err = provider.Complete(context.Background(), Request{Messages: testMessages})
+	require.NoError(t, err)
+	assert.Equal(t, 3, *primaryCalls)
+	assert.Equal(t, CircuitOpen, breaker.State())
+
+	// The next probe succeeds and closes it
+	clock = clock.Add(time.Minute)
+	resp, err = provider.Complete(context.Background(), Request{Messages: testMessages})
+	require.NoError(t, err)
+	assert.Equal(t, "ok from primary", resp.Content)
+	assert.Equal(t, CircuitClosed, breaker.State())
+}
+
+func TestResilientProviderAllDown(t *testing.T) {
+	server, _ := flakyServer(t, status(503), status(503), status(503), status(503))
+	provider := NewResilientProvider(RetryPolicy{MaxRetries: 1, BaseDelay: time.Millisecond},
+		Route{Provider: NewOpenAIProvider(server.URL, "", "a")},
+		Route{Provider: &MockProvider{Err: errors.New("down")}},
+	)
+	_, err := provider.Complete(context.Background(), Request{Messages: testMessages})
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.EqualError(t, err, "no LLM provider is available: down")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = provider.Complete(ctx, Request{Messages: testMessages})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRetryable(t *testing.T) {
+	assert.True(t, Retryable(&APIError{StatusCode: http.StatusTooManyRequests}))
+	assert.True(t, Retryable(&APIError{StatusCode: http.StatusGatewayTimeout}))
+	assert.True(t, Retryable(errors.New("openai: invalid response")))
+	assert.False(t, Retryable(&APIError{StatusCode: http.StatusUnauthorized}))
+	assert.False(t, Retryable(context.DeadlineExceeded))
+}
+
+func TestParseFallback(t *testing.T) {
+	assert.Equal(t, Fallback{Provider: ProviderOllama, Model: "llama3.1:8b"}, parseFallback("ollama:llama3.1:8b"))
+	assert.Equal(t, Fallback{Model: "moonshotai/Kimi-K2-Instruct:novita"}, parseFallback("moonshotai/Kimi-K2-Instruct:novita"))
+}
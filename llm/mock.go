@@ -0,0 +1,122 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// MockProvider returns deterministic replies without any network access. By default it echoes
+// the last user message; set Replies to script the answers, or Err to make every call fail.
+// It records the requests it receives so tests can inspect them.
+type MockProvider struct {
+	Replies []string // Returned in order; the last one repeats
+	Err     error
+
+	mu       sync.Mutex
+	requests []Request
+}
+
+// NewMockProvider creates a mock that answers with replies, or echoes when none are given
+func NewMockProvider(replies ...string) *MockProvider {
+	return &MockProvider{Replies: replies}
+}
+
+// Name returns the provider name
+func (p *MockProvider) Name() string {
+	return ProviderMock
+}
+
+// Complete returns the next scripted reply
+func (p *MockProvider) Complete(ctx context.Context, req Request) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	reply, err := p.next(req)
+	if err != nil {
+		return nil, err
+	}
+	return p.response(req, reply), nil
+}
+
+// Stream delivers the next scripted reply word by word
+func (p *MockProvider) Stream(ctx context.Context, req Request, onToken func(string)) (*Response, error) {
+	reply, err := p.next(req)
+	if err != nil {
+		return nil, err
+	}
+	var sent strings.Builder
+	for _, token := range splitTokens(reply) {
+		if err := ctx.Err(); err != nil {
+			return p.response(req, sent.String()), err
+		}
+		sent.WriteString(token)
+		onToken(token)
+	}
+	return p.response(req, reply), nil
+}
+
+// Requests returns the requests received so far
+func (p *MockProvider) Requests() []Request {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Request(nil), p.requests...)
+}
+
+func (p *MockProvider) next(req Request) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	calls := len(p.requests)
+	p.requests = append(p.requests, req)
+	if p.Err != nil {
+		return "", p.Err
+	}
+	if len(p.Replies) == 0 {
+		return "Echo: " + lastUserMessage(req.Messages), nil
+	}
+	if calls >= len(p.Replies) {
+		calls = len(p.Replies) - 1
+	}
+	return p.Replies[calls], nil
+}
+
+func (p *MockProvider) response(req Request, content string) *Response {
+	model := req.Model
+	if model == "" {
+		model = ProviderMock
+	}
+	prompt := 0
+	for _, msg := range req.Messages {
+		prompt += len(strings.Fields(msg.Content))
+	}
+	return &Response{
+		Content: content,
+		Model:   model,
+		Usage:   Usage{PromptTokens: prompt, CompletionTokens: len(strings.Fields(content))},
+	}
+}
+
+// splitTokens splits text into words, each keeping its leading space, so they join back exactly
+func splitTokens(text string) []string {
+	tokens := []string{}
+	start := 0
+	for i := 1; i < len(text); i++ {
+		if text[i] == ' ' && text[i-1] != ' ' {
+			tokens = append(tokens, text[start:i])
+			start = i
+		}
+	}
+	if start < len(text) {
+		tokens = append(tokens, text[start:])
+	}
+	return tokens
+}
+
+func lastUserMessage(messages []Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == RoleUser {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
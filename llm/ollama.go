@@ -0,0 +1,159 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Ollama defaults
+const (
+	OllamaBaseURL = "http://localhost:11434"
+	OllamaModel   = "llama3.1"
+)
+
+// OllamaProvider talks to an Ollama-style local server through its /api/chat endpoint
+type OllamaProvider struct {
+	baseURL string
+	model   string
+	client  *http.Client
+}
+
+// NewOllamaProvider creates a provider for the server at baseURL
+func NewOllamaProvider(baseURL, model string) *OllamaProvider {
+	if baseURL == "" {
+		baseURL = OllamaBaseURL
+	}
+	if model == "" {
+		model = OllamaModel
+	}
+	return &OllamaProvider{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		model:   model,
+		client:  &http.Client{},
+	}
+}
+
+// Name returns the provider name
+func (p *OllamaProvider) Name() string {
+	return ProviderOllama
+}
+
+type ollamaRequest struct {
+	Model    string         `json:"model"`
+	Messages []Message      `json:"messages"`
+	Stream   bool           `json:"stream"`
+	Options  map[string]any `json:"options,omitempty"`
+}
+
+// ollamaChunk is a whole response, or one line of a streamed response
+type ollamaChunk struct {
+	Model           string  `json:"model"`
+	Message         Message `json:"message"`
+	Done            bool    `json:"done"`
+	PromptEvalCount int     `json:"prompt_eval_count"`
+	EvalCount       int     `json:"eval_count"`
+	Error           string  `json:"error"`
+}
+
+// Complete requests a completion and waits for all of it
+func (p *OllamaProvider) Complete(ctx context.Context, req Request) (*Response, error) {
+	resp, err := p.post(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var chunk ollamaChunk
+	if err := json.NewDecoder(resp.Body).Decode(&chunk); err != nil {
+		return nil, fmt.Errorf("%s: invalid response: %w", ProviderOllama, err)
+	}
+	if chunk.Error != "" {
+		return nil, fmt.Errorf("%s: %s", ProviderOllama, chunk.Error)
+	}
+	return &Response{
+		Content: chunk.Message.Content,
+		Model:   chunk.Model,
+		Usage:   Usage{PromptTokens: chunk.PromptEvalCount, CompletionTokens: chunk.EvalCount},
+	}, nil
+}
+
+// Stream requests a completion as newline-delimited JSON chunks
+func (p *OllamaProvider) Stream(ctx context.Context, req Request, onToken func(string)) (*Response, error) {
+	resp, err := p.post(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &Response{Model: p.modelFor(req)}
+	var content strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var chunk ollamaChunk
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			result.Content = content.String()
+			return result, fmt.Errorf("%s: invalid stream chunk: %w", ProviderOllama, err)
+		}
+		if chunk.Error != "" {
+			result.Content = content.String()
+			return result, fmt.Errorf("%s: %s", ProviderOllama, chunk.Error)
+		}
+		if chunk.Message.Content != "" {
+			content.WriteString(chunk.Message.Content)
+			onToken(chunk.Message.Content)
+		}
+		if chunk.Done {
+			result.Content = content.String()
+			result.Usage = Usage{PromptTokens: chunk.PromptEvalCount, CompletionTokens: chunk.EvalCount}
+			return result, nil
+		}
+	}
+	result.Content = content.String()
+	if err := scanner.Err(); err != nil {
+		return result, err
+	}
+	return result, fmt.Errorf("%s: %w", ProviderOllama, io.ErrUnexpectedEOF)
+}
+
+func (p *OllamaProvider) post(ctx context.Context, req Request, stream bool) (*http.Response, error) {
+	options := map[string]any{}
+	if req.MaxTokens > 0 {
+		options["num_predict"] = req.MaxTokens
+	}
+	if req.Temperature != nil {
+		options["temperature"] = *req.Temperature
+	}
+	body, err := json.Marshal(ollamaRequest{
+		Model:    p.modelFor(req),
+		Messages: req.Messages,
+		Stream:   stream,
+		Options:  options,
+	})
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	return send(p.client, ProviderOllama, httpReq)
+}
+
+func (p *OllamaProvider) modelFor(req Request) string {
+	if req.Model != "" {
+		return req.Model
+	}
+	return p.model
+}
@@ -0,0 +1,218 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Default endpoints
+const (
+	HuggingFaceBaseURL = "https://router.huggingface.co/v1"
+	HuggingFaceModel   = "moonshotai/Kimi-K2-Instruct:novita"
+	OpenAIBaseURL      = "https://api.openai.com/v1"
+)
+
+// OpenAIProvider talks to any endpoint implementing the OpenAI chat completions API,
+// including the Hugging Face router
+type OpenAIProvider struct {
+	name    string
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+}
+
+// NewOpenAIProvider creates a provider for an OpenAI-compatible API rooted at baseURL (e.g. ".../v1")
+func NewOpenAIProvider(baseURL, apiKey, model string) *OpenAIProvider {
+	return &OpenAIProvider{
+		name:    ProviderOpenAI,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+		client:  &http.Client{},
+	}
+}
+
+// NewHuggingFaceProvider creates a provider for the Hugging Face Inference Providers router
+func NewHuggingFaceProvider(baseURL, apiKey, model string) *OpenAIProvider {
+	if baseURL == "" {
+		baseURL = HuggingFaceBaseURL
+	}
+	if model == "" {
+		model = HuggingFaceModel
+	}
+	provider := NewOpenAIProvider(baseURL, apiKey, model)
+	provider.name = ProviderHuggingFace
+	return provider
+}
+
+// Name returns the provider name
+func (p *OpenAIProvider) Name() string {
+	return p.name
+}
+
+type openAIRequest struct {
+	Model       string    `json:"model"`
+	Messages    []Message `json:"messages"`
+	MaxTokens   int       `json:"max_tokens,omitempty"`
+	Temperature *float64  `json:"temperature,omitempty"`
+	Stream      bool      `json:"stream,omitempty"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+type openAIError struct {
+	Message string `json:"message"`
+}
+
+// Complete requests a completion and waits for all of it
+func (p *OpenAIProvider) Complete(ctx context.Context, req Request) (*Response, error) {
+	resp, err := p.post(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Model   string `json:"model"`
+		Choices []struct {
+			Message Message `json:"message"`
+		} `json:"choices"`
+		Usage *openAIUsage `json:"usage"`
+		Error *openAIError `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("%s: invalid response: %w", p.name, err)
+	}
+	if body.Error != nil {
+		return nil, fmt.Errorf("%s: %s", p.name, body.Error.Message)
+	}
+	if len(body.Choices) == 0 {
+		return nil, fmt.Errorf("%s: response has no choices", p.name)
+	}
+	result := &Response{Content: body.Choices[0].Message.Content, Model: body.Model}
+	if body.Usage != nil {
+		result.Usage = Usage{PromptTokens: body.Usage.PromptTokens, CompletionTokens: body.Usage.CompletionTokens}
+	}
+	return result, nil
+}
+
+// Stream requests a completion as server-sent events
+func (p *OpenAIProvider) Stream(ctx context.Context, req Request, onToken func(string)) (*Response, error) {
+	resp, err := p.post(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &Response{Model: p.modelFor(req)}
+	var content strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue // blank separators, comments and other fields
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			result.Content = content.String()
+			return result, nil
+		}
+		var chunk struct {
+			Model   string `json:"model"`
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+			Usage *openAIUsage `json:"usage"`
+			Error *openAIError `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			result.Content = content.String()
+			return result, fmt.Errorf("%s: invalid stream chunk: %w", p.name, err)
+		}
+		if chunk.Error != nil {
+			result.Content = content.String()
+			return result, fmt.Errorf("%s: %s", p.name, chunk.Error.Message)
+		}
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			result.Usage = Usage{PromptTokens: chunk.Usage.PromptTokens, CompletionTokens: chunk.Usage.CompletionTokens}
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content != "" {
+				content.WriteString(choice.Delta.Content)
+				onToken(choice.Delta.Content)
+			}
+		}
+	}
+	result.Content = content.String()
+	if err := scanner.Err(); err != nil {
+		return result, err
+	}
+	return result, fmt.Errorf("%s: %w", p.name, io.ErrUnexpectedEOF)
+}
+
+// post sends a chat completions request and checks the status
+func (p *OpenAIProvider) post(ctx context.Context, req Request, stream bool) (*http.Response, error) {
+	body, err := json.Marshal(openAIRequest{
+		Model:       p.modelFor(req),
+		Messages:    req.Messages,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		Stream:      stream,
+	})
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	if stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+	return send(p.client, p.name, httpReq)
+}
+
+func (p *OpenAIProvider) modelFor(req Request) string {
+	if req.Model != "" {
+		return req.Model
+	}
+	return p.model
+}
+
+// send performs a request and turns non-success statuses into an APIError
+func send(client *http.Client, provider string, req *http.Request) (*http.Response, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctxErr := req.Context().Err(); ctxErr != nil && errors.Is(err, ctxErr) {
+			return nil, ctxErr
+		}
+		return nil, fmt.Errorf("%s: %w", provider, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, &APIError{Provider: provider, StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(detail))}
+	}
+	return resp, nil
+}
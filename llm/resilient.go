@@ -0,0 +1,255 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrUnavailable is wrapped by the error of a ResilientProvider when every route failed
+var ErrUnavailable = errors.New("no LLM provider is available")
+
+// ErrCircuitOpen is returned for a route whose circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// RetryPolicy controls how often a failing call is retried on the same route
+type RetryPolicy struct {
+	MaxRetries int           // Retries after the first attempt
+	BaseDelay  time.Duration // Upper bound of the first backoff; doubles on each retry
+	MaxDelay   time.Duration // Upper bound of any backoff
+}
+
+// backoff returns a random delay up to the exponential bound for the given retry (0-based),
+// so clients retrying together do not hit the backend in lockstep
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	bound := p.BaseDelay << retry
+	if bound <= 0 || (p.MaxDelay > 0 && bound > p.MaxDelay) {
+		bound = p.MaxDelay
+	}
+	if bound <= 0 {
+		return 0
+	}
+	return rand.N(bound) + 1
+}
+
+// Retryable reports whether a failed call may succeed if repeated: timeouts, rate limits,
+// server errors, network errors and malformed or error bodies. Other client errors and
+// cancellation of the caller's context are final.
+func Retryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooEarly, http.StatusTooManyRequests:
+			return true
+		}
+		return apiErr.StatusCode >= 500
+	}
+	return true
+}
+
+// Circuit breaker states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// CircuitBreaker stops calls to a route after consecutive failures, so an outage fails fast
+// instead of waiting out timeouts. Once the cooldown has passed, a single probe call is let
+// through; it closes the circuit on success and reopens it on failure.
+type CircuitBreaker struct {
+	failures int
+	cooldown time.Duration
+	now      func() time.Time
+
+	mu          sync.Mutex
+	consecutive int
+	openedAt    time.Time
+	probing     bool
+}
+
+// NewCircuitBreaker creates a breaker that opens after failures consecutive failures and
+// stays open for cooldown. A threshold of zero or less disables it.
+func NewCircuitBreaker(failures int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{failures: failures, cooldown: cooldown, now: time.Now}
+}
+
+// State returns the breaker's state
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.openedAt.IsZero():
+		return CircuitClosed
+	case b.probing || b.now().Sub(b.openedAt) >= b.cooldown:
+		return CircuitHalfOpen
+	}
+	return CircuitOpen
+}
+
+// Allow reports whether a call may be made, taking the probe slot when the breaker is half-open
+func (b *CircuitBreaker) Allow() bool {
+	if b == nil || b.failures <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openedAt.IsZero() {
+		return true
+	}
+	if b.probing || b.now().Sub(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// Success records a successful call and closes the breaker
+func (b *CircuitBreaker) Success() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.consecutive, b.openedAt, b.probing = 0, time.Time{}, false
+}
+
+// Failure records a failed call, opening the breaker at the threshold or after a failed probe
+func (b *CircuitBreaker) Failure() {
+	if b == nil || b.failures <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.consecutive++
+	if b.probing || b.consecutive >= b.failures {
+		b.openedAt, b.probing = b.now(), false
+	}
+}
+
+// Release gives back a probe slot taken by Allow when the call ended without telling whether
+// the backend is healthy, such as when the caller cancelled it
+func (b *CircuitBreaker) Release() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// Route is one entry of a fallback chain: a provider, optionally with a fixed model
+type Route struct {
+	Provider LLMProvider
+	Model    string // Replaces the request's model when set
+	Breaker  *CircuitBreaker
+}
+
+// ResilientProvider retries failed calls with jittered backoff and falls through a chain of
+// routes, skipping those whose circuit breaker is open. Streams are only retried or passed on
+// to the next route while no token has been delivered.
+type ResilientProvider struct {
+	routes []Route
+	retry  RetryPolicy
+}
+
+// NewResilientProvider creates a provider that tries routes in order
+func NewResilientProvider(retry RetryPolicy, routes ...Route) *ResilientProvider {
+	return &ResilientProvider{routes: routes, retry: retry}
+}
+
+// Name returns the name of the first route's provider
+func (p *ResilientProvider) Name() string {
+	if len(p.routes) == 0 {
+		return ""
+	}
+	return p.routes[0].Provider.Name()
+}
+
+// Complete returns the completion of the first route that succeeds
+func (p *ResilientProvider) Complete(ctx context.Context, req Request) (*Response, error) {
+	return p.call(ctx, req, func(ctx context.Context, route Route, req Request) (*Response, bool, error) {
+		resp, err := route.Provider.Complete(ctx, req)
+		return resp, false, err
+	})
+}
+
+// Stream streams the completion of the first route that succeeds
+func (p *ResilientProvider) Stream(ctx context.Context, req Request, onToken func(string)) (*Response, error) {
+	return p.call(ctx, req, func(ctx context.Context, route Route, req Request) (*Response, bool, error) {
+		delivered := false
+		resp, err := route.Provider.Stream(ctx, req, func(token string) {
+			delivered = true
+			onToken(token)
+		})
+		return resp, delivered, err
+	})
+}
+
+// call runs attempt on each route in turn. attempt reports whether output already reached
+// the caller, in which case its error is final.
+func (p *ResilientProvider) call(ctx context.Context, req Request, attempt func(context.Context, Route, Request) (*Response, bool, error)) (*Response, error) {
+	var lastErr error
+	for _, route := range p.routes {
+		routeReq := req
+		if route.Model != "" {
+			routeReq.Model = route.Model
+		}
+		for retry := 0; ; retry++ {
+			if !route.Breaker.Allow() {
+				lastErr = fmt.Errorf("%s: %w", route.Provider.Name(), ErrCircuitOpen)
+				break
+			}
+			resp, delivered, err := attempt(ctx, route, routeReq)
+			if err == nil {
+				route.Breaker.Success()
+				return resp, nil
+			}
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				route.Breaker.Release()
+				return resp, err
+			}
+			lastErr = err
+			if !Retryable(err) {
+				route.Breaker.Release()
+				break // The request itself was refused; another route may still accept it
+			}
+			route.Breaker.Failure()
+			if delivered {
+				return resp, err
+			}
+			if retry >= p.retry.MaxRetries {
+				break
+			}
+			if err := sleep(ctx, p.retry.backoff(retry)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no routes configured")
+	}
+	return nil, fmt.Errorf("%w: %w", ErrUnavailable, lastErr)
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -7,7 +7,9 @@ import (
 	// "lambda-server/database"
 	"fmt"
 	"lambda-server/constants"
+	"lambda-server/handlers"
 	"lambda-server/routes"
+	"lambda-server/storage"
 	"lambda-server/utils"
 	"log"
 	"net/http"
@@ -30,6 +32,11 @@ func init() {
 	// Set Gin mode
 	setGinMode()
 
+	// Without a blob store only attachments and imports are unavailable; their routes answer 503
+	if err := storage.Init(); err != nil {
+		log.Printf("Blob store configuration failed, attachments and imports are unavailable: %v", err)
+	}
+
 	// Setup router using the routes package
 	router = routes.SetupRouter()
 
@@ -52,9 +59,27 @@ func setGinMode() {
 func Handler(ctx context.Context, event interface{}) (interface{}, error) {
 	eventBytes, _ := json.Marshal(event)
 
+	// Try to unmarshal as an EventBridge scheduled event (used for periodic maintenance jobs)
+	var scheduledEvent events.CloudWatchEvent
+	if err := json.Unmarshal(eventBytes, &scheduledEvent); err == nil && isScheduledEvent(scheduledEvent) {
+		return handleScheduledEvent(ctx, scheduledEvent)
+	}
+	if job, ok := parseScheduledJobEvent(eventBytes); ok {
+		return runScheduledJob(ctx, job)
+	}
+
+	// Try to unmarshal as an API Gateway WebSocket event (realtime chat)
+	var websocketEvent events.APIGatewayWebsocketProxyRequest
+	if err := json.Unmarshal(eventBytes, &websocketEvent); err == nil && websocketEvent.RequestContext.ConnectionID != "" {
+		return handlers.HandleRealtimeEvent(ctx, websocketEvent)
+	}
+
 	// Try to unmarshal as Lambda Function URL event
 	var functionURLEvent events.LambdaFunctionURLRequest
 	if err := json.Unmarshal(eventBytes, &functionURLEvent); err == nil && functionURLEvent.RequestContext.HTTP.Method != "" {
+		if responseStreamingEnabled() {
+			return streamFunctionURLRequest(ctx, functionURLEvent)
+		}
 		res, err := ginLambda.ProxyWithContext(ctx, convertFunctionURLToAPIGatewayV2(functionURLEvent))
 		responseBytes, _ := json.Marshal(res)
     log.Printf("Lambda Response: %s\n", string(responseBytes))
@@ -131,6 +156,8 @@ func runLocalServer() {
 		port = "8080"
 	}
 
+	go runLocalImportWorker()
+
 	log.Printf("🚀 Server running on http://localhost:%s", port)
 	for _, route := range router.Routes() { 
 		fmt.Printf("%s http://localhost:8080%s\n", route.Method, route.Path) 
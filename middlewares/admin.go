@@ -0,0 +1,27 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"lambda-server/constants"
+	"lambda-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminMiddleware only lets users with the admin role through. It must run after AuthMiddleware.
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get("user")
+		user, ok := value.(*models.User)
+		if !exists || !ok || user.Role != constants.UserRoleAdmin {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"message": "Admin access required",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"lambda-server/constants"
 	"lambda-server/helpers"
 	"lambda-server/models"
+	"lambda-server/realtime"
+	"lambda-server/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -72,6 +74,7 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		// Update last active time
 		user.LastActiveAt = time.Now().Unix()
+		captureClientPreferences(c, user)
 		helpers.UpdateUser(user)
 
 		// Set user in context
@@ -81,6 +84,19 @@ func AuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// WebSocketTokenAuth lets a WebSocket handshake, to which browsers cannot add headers, offer
+// its access token as a subprotocol after realtime.AuthProtocol. Unlike a query parameter, the
+// token stays out of URLs and request logs. It must run before AuthMiddleware.
+func WebSocketTokenAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		protocols := strings.Join(c.Request.Header.Values("Sec-WebSocket-Protocol"), ",")
+		if token := realtime.AccessToken(protocols); token != "" && c.GetHeader("Authorization") == "" {
+			c.Request.Header.Set("Authorization", "Bearer "+token)
+		}
+		c.Next()
+	}
+}
+
 func useAccessTokenToGetClaims(c *gin.Context) (*models.JWTClaims, error) {
 	tokenString, err := GetTokenFromAuthorizationHeader(c)
 	if err != nil {
@@ -122,3 +138,18 @@ func GetTokenFromAuthorizationHeader(c *gin.Context) (string, error) {
 
 	return parts[1], nil
 }
+
+// captureClientPreferences fills in a user's time zone and locale from the client's headers
+// when they have not been set yet, e.g. for accounts created before they were recorded
+func captureClientPreferences(c *gin.Context, user *models.User) {
+	if user.TimeZone == "" {
+		if timeZone := c.GetHeader(constants.HeaderTimezone); utils.ValidateTimeZone(timeZone) == nil {
+			user.TimeZone = timeZone
+		}
+	}
+	if user.Locale == "" {
+		if locale, err := utils.NormalizeLocale(utils.PreferredLocale(c.GetHeader("Accept-Language"))); err == nil {
+			user.Locale = locale
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"lambda-server/constants"
+	"lambda-server/database"
+	"lambda-server/models"
+	"lambda-server/ratelimit"
+	"lambda-server/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rateLimitContentionRetrySeconds is the Retry-After of a request refused because its bucket was contended
+const rateLimitContentionRetrySeconds = 1
+
+// The rate limit store, chosen from RATE_LIMIT_STORE on first use
+var (
+	rateLimitStoreOnce sync.Once
+	rateLimitStore     ratelimit.Store
+)
+
+func chatRateLimitStore() ratelimit.Store {
+	rateLimitStoreOnce.Do(func() {
+		kind := os.Getenv(constants.RateLimitStoreEnv)
+		if kind == constants.RateLimitStoreMem || (kind == constants.EMPTY_STRING && utils.IsRunningLocally()) {
+			rateLimitStore = ratelimit.NewMemoryStore()
+		} else {
+			rateLimitStore = database.RateLimitStore{}
+		}
+	})
+	return rateLimitStore
+}
+
+// ChatRateLimit enforces the user's request rate and daily chat quotas, and reports them in
+// X-RateLimit-* and X-Quota-* headers. It must run after AuthMiddleware. If the limit stores
+// cannot be reached the request is let through, so an outage there does not take chat down.
+func ChatRateLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get("user")
+		user, ok := value.(*models.User)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": "Authorization header required or invalid",
+			})
+			c.Abort()
+			return
+		}
+		if err := CheckChatLimits(context.Background(), user, c.Header); err != nil {
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":   err.Message,
+				"details": err.Details,
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// ChatLimitError tells a user they must wait before chatting again
+type ChatLimitError struct {
+	Message    string
+	Details    string
+	RetryAfter int // Seconds
+}
+
+func (e *ChatLimitError) Error() string {
+	return e.Message
+}
+
+// CheckChatLimits counts a chat message against the user's request rate and checks their daily
+// quotas, reporting each limit through header. It returns an error when the message must be
+// refused, including when concurrent requests keep the rate limit bucket contended; only when
+// the limit stores cannot be reached is the message allowed.
+func CheckChatLimits(ctx context.Context, user *models.User, header func(key, value string)) *ChatLimitError {
+	limits := ratelimit.Limits(user)
+	now := time.Now()
+
+	if limits.RequestsPerMinute >= 0 {
+		header(constants.HeaderRateLimitLimit, strconv.Itoa(limits.RequestsPerMinute))
+		bucket := ratelimit.PerMinute(limits.RequestsPerMinute, limits.Burst)
+		result, err := chatRateLimitStore().Take(ctx, "chat#"+user.UserId, bucket, 1)
+		if errors.Is(err, ratelimit.ErrContention) {
+			// Other requests of the same user keep taking from the bucket
+			header(constants.HeaderRetryAfter, strconv.Itoa(rateLimitContentionRetrySeconds))
+			return &ChatLimitError{
+				Message:    "Too many requests",
+				Details:    "Please wait a moment before sending another message",
+				RetryAfter: rateLimitContentionRetrySeconds,
+			}
+		}
+		if err != nil {
+			log.Printf("Rate limit check for user %s failed, allowing the request: %v\n", user.UserId, err)
+		} else {
+			header(constants.HeaderRateLimitRemaining, strconv.Itoa(result.Remaining))
+			header(constants.HeaderRateLimitReset, strconv.Itoa(seconds(result.Reset)))
+			if !result.Allowed {
+				retryAfter := seconds(result.RetryAfter)
+				header(constants.HeaderRetryAfter, strconv.Itoa(retryAfter))
+				return &ChatLimitError{
+					Message:    "Too many requests",
+					Details:    "Please wait a moment before sending another message",
+					RetryAfter: retryAfter,
+				}
+			}
+		}
+	}
+
+	usage, err := database.GetDailyUsage(ctx, user.UserId, ratelimit.Day(now))
+	if err != nil {
+		log.Printf("Quota check for user %s failed, allowing the request: %v\n", user.UserId, err)
+		return nil
+	}
+	quota := ratelimit.CheckQuota(limits, usage, now)
+	header(constants.HeaderQuotaReset, strconv.FormatInt(quota.ResetsAt.Unix(), 10))
+	if limits.DailyMessages >= 0 {
+		header(constants.HeaderQuotaMessages, strconv.Itoa(limits.DailyMessages))
+		// Counts the message being sent
+		header(constants.HeaderQuotaMessagesLeft, strconv.Itoa(max(quota.MessagesLeft-1, 0)))
+	}
+	if limits.DailyTokens >= 0 {
+		header(constants.HeaderQuotaTokens, strconv.Itoa(limits.DailyTokens))
+		header(constants.HeaderQuotaTokensLeft, strconv.Itoa(quota.TokensLeft))
+	}
+	if quota.Exceeded {
+		retryAfter := seconds(quota.ResetsAt.Sub(now))
+		header(constants.HeaderRetryAfter, strconv.Itoa(retryAfter))
+		return &ChatLimitError{
+			Message:    "Daily chat limit reached",
+			Details:    "Your daily chat allowance resets at midnight UTC",
+			RetryAfter: retryAfter,
+		}
+	}
+	return nil
+}
+
+// seconds rounds a duration up to whole seconds for a header
+func seconds(d time.Duration) int {
+	return int(math.Ceil(min(d, 24*time.Hour).Seconds()))
+}
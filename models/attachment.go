@@ -0,0 +1,40 @@
+package models
+
+// Attachment represents a photo or voice note attached to a journal entry
+// Partition Key: UserId, Sort Key: AttachmentId
+// The bytes live in the blob store under StorageKey; this item only holds the metadata.
+type Attachment struct {
+	UserId       string `json:"userId" dynamodbav:"UserId"`             // Partition Key
+	AttachmentId string `json:"attachmentId" dynamodbav:"AttachmentId"` // Sort Key
+	JournalId    string `json:"journalId" dynamodbav:"JournalId"`
+	Kind         string `json:"kind" dynamodbav:"kind"` // "photo" or "voice"
+	FileName     string `json:"fileName,omitempty" dynamodbav:"fileName,omitempty"`
+	ContentType  string `json:"contentType" dynamodbav:"contentType"` // Detected from the uploaded bytes once ready
+	Size         int64  `json:"size" dynamodbav:"size"`
+	StorageKey   string `json:"-" dynamodbav:"storageKey"`
+	Status       string `json:"status" dynamodbav:"status"` // "pending" or "ready"
+	CreatedAt    int64  `json:"createdAt" dynamodbav:"createdAt"`
+	UpdatedAt    int64  `json:"updatedAt" dynamodbav:"updatedAt"`
+}
+
+// AttachmentCreateRequest represents the request body for requesting an attachment upload URL
+type AttachmentCreateRequest struct {
+	FileName    string `json:"fileName"`
+	ContentType string `json:"contentType" binding:"required"`
+	Size        int64  `json:"size" binding:"required"`
+}
+
+// AttachmentResponse represents an attachment with a signed URL to upload or download it
+type AttachmentResponse struct {
+	Attachment  Attachment `json:"attachment"`
+	UploadURL   string     `json:"uploadUrl,omitempty"`
+	DownloadURL string     `json:"downloadUrl,omitempty"`
+	ExpiresAt   int64      `json:"expiresAt,omitempty"`
+	Message     string     `json:"message,omitempty"`
+}
+
+// AttachmentListResponse represents the response body for the attachments of a journal entry
+type AttachmentListResponse struct {
+	Attachments []AttachmentResponse `json:"attachments"`
+	Count       int                  `json:"count"`
+}
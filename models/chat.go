@@ -1,13 +1,113 @@
 package models
 
+import "strconv"
+
 // ChatMessage represents a single message in a chat session
-// Partition Key: userId, Sort Key: sessionId#timestamp
+// Partition Key: userId, Sort Key: sessionId#timestamp#messageId (sessionId#timestamp for
+// messages stored before IDs were assigned)
 // This allows efficient queries for all messages by user and session, ordered by time.
 type ChatMessage struct {
 	UserId             string `json:"userId" dynamodbav:"userId"`           // Partition Key
 	SessionId          string `json:"sessionId" dynamodbav:"sessionId"`     // Session identifier
 	Timestamp          int64  `json:"timestamp" dynamodbav:"timestamp"`     // Timestamp
+	MessageId          string `json:"messageId,omitempty" dynamodbav:"messageId,omitempty"` // ULID, unique within the session; unset on messages stored before IDs were assigned
 	SessionIdTimestamp string `json:"sessionId_timestamp" dynamodbav:"sessionId_timestamp"` // Composite sort key
 	Sender             string `json:"sender" dynamodbav:"sender"`           // "user" or "ai"
 	Message            string `json:"message" dynamodbav:"message"`         // Message content
-} 
\ No newline at end of file
+	Sentiment          *Sentiment `json:"sentiment,omitempty" dynamodbav:"sentiment,omitempty"` // Analysis of user messages, filled in asynchronously
+	Partial            bool       `json:"partial,omitempty" dynamodbav:"partial,omitempty"`     // AI reply cut off because the client disconnected mid-stream
+	PersonaId          string     `json:"personaId,omitempty" dynamodbav:"personaId,omitempty"`           // Persona the session was using
+	PersonaVersion     int        `json:"personaVersion,omitempty" dynamodbav:"personaVersion,omitempty"` // Version of that persona, for auditing what the model was told
+	Moderation         *ModerationDecision `json:"moderation,omitempty" dynamodbav:"moderation,omitempty"` // Set on AI replies; how moderation treated the reply
+	Usage              *TokenUsage         `json:"usage,omitempty" dynamodbav:"usage,omitempty"`           // Set on AI replies; tokens the reply cost
+	Citations          []Citation          `json:"citations,omitempty" dynamodbav:"citations,omitempty"`   // Set on AI replies; the user's passages the prompt included
+	Model              string              `json:"model,omitempty" dynamodbav:"model,omitempty"`           // Set on AI replies; the model that wrote the reply
+	Feedback           *MessageFeedback    `json:"feedback,omitempty" dynamodbav:"feedback,omitempty"`     // Set on AI replies the user rated
+	SentimentPending   string              `json:"-" dynamodbav:"sentimentPending,omitempty"`            // Set on user messages waiting for analysis; puts them in the sparse pending index
+	SentimentDay       string              `json:"-" dynamodbav:"sentimentDay,omitempty"`                // Day (YYYYMMDD) whose chat sentiment totals include this message
+	TimeZone           string              `json:"-" dynamodbav:"timeZone,omitempty"`                    // Zone of the user when sent, used to pick SentimentDay
+} 
+
+// ID identifies a message within its session: its MessageId, or the timestamp of a message
+// stored before IDs were assigned
+func (m ChatMessage) ID() string {
+	if m.MessageId != "" {
+		return m.MessageId
+	}
+	return strconv.FormatInt(m.Timestamp, 10)
+}
+
+// ChatSession is a conversation, stored in the chat table next to its messages
+// Partition Key: userId, Sort Key: sessionId_timestamp = "#SESSION#<sessionId>"
+type ChatSession struct {
+	UserId             string `json:"userId" dynamodbav:"userId"`
+	SortKey            string `json:"-" dynamodbav:"sessionId_timestamp"`
+	SessionId          string `json:"sessionId" dynamodbav:"sessionId"`
+	Title              string `json:"title" dynamodbav:"title,omitempty"` // Set by the user or generated after the first exchange
+	Archived           bool   `json:"archived" dynamodbav:"archived,omitempty"`
+	PersonaId          string `json:"personaId,omitempty" dynamodbav:"personaId,omitempty"` // Persona picked for the session; empty uses the default
+	LastMessageAt      int64  `json:"lastMessageAt" dynamodbav:"lastMessageAt"`             // Sort key of the userId-lastMessageAt-index GSI
+	LastMessagePreview string `json:"lastMessagePreview,omitempty" dynamodbav:"lastMessagePreview,omitempty"`
+	MessageCount       int    `json:"messageCount" dynamodbav:"messageCount"`
+	Summary            string `json:"-" dynamodbav:"summary,omitempty"`           // Running summary of turns that no longer fit the context window
+	SummarizedThrough  int64  `json:"-" dynamodbav:"summarizedThrough,omitempty"` // Timestamp of the newest message folded into Summary, on summaries stored before SummaryCursor
+	SummaryCursor      string `json:"-" dynamodbav:"summaryCursor,omitempty"`     // Sort key of the newest message folded into Summary
+	CreatedAt          int64  `json:"createdAt" dynamodbav:"createdAt"`
+	UpdatedAt          int64  `json:"updatedAt" dynamodbav:"updatedAt"`
+}
+
+// SummarizedThroughKey returns the sort key of the newest message folded into the summary, or ""
+// when nothing was summarized. For summaries stored with only a timestamp it is the key of a
+// message stored before IDs were assigned, so later messages in the same second are read again.
+func (s ChatSession) SummarizedThroughKey() string {
+	if s.SummaryCursor != "" {
+		return s.SummaryCursor
+	}
+	if s.SummarizedThrough > 0 {
+		return s.SessionId + "#" + strconv.FormatInt(s.SummarizedThrough, 10)
+	}
+	return ""
+}
+
+// ChatSessionCreateRequest represents the request body for starting a chat session
+type ChatSessionCreateRequest struct {
+	Title     string `json:"title,omitempty"`     // Generated after the first exchange when empty
+	PersonaId string `json:"personaId,omitempty"` // Empty uses the default persona
+}
+
+// ChatSessionUpdateRequest represents the request body for renaming or archiving a chat session
+type ChatSessionUpdateRequest struct {
+	Title    *string `json:"title,omitempty"`
+	Archived *bool   `json:"archived,omitempty"`
+}
+
+// ChatSessionResponse represents the response body for a single chat session
+type ChatSessionResponse struct {
+	Session ChatSession `json:"session"`
+	Message string      `json:"message,omitempty"`
+}
+
+// ChatSessionListResponse represents the response body for a page of chat sessions, most recent first
+type ChatSessionListResponse struct {
+	Sessions []ChatSession `json:"sessions"`
+	Count    int           `json:"count"`
+	Cursor   string        `json:"cursor,omitempty"` // Pass back to get the next page; empty on the last page
+}
+
+// ChatMessageListResponse represents the response body for a page of a session's messages, oldest first
+type ChatMessageListResponse struct {
+	Messages []ChatMessage `json:"messages"`
+	Count    int           `json:"count"`
+	Cursor   string        `json:"cursor,omitempty"` // Pass back to get the page of older messages; empty when there are none
+}
+
+// JournalDraft is a journal entry drafted from a chat session, for the user to edit and save
+// with POST /journals, passing SourceSessionId along
+type JournalDraft struct {
+	Title           string   `json:"title"`
+	Content         string   `json:"content"` // Summary, insights and action items laid out as Markdown
+	Summary         string   `json:"summary"`
+	Insights        []string `json:"insights"`
+	ActionItems     []string `json:"actionItems"`
+	SourceSessionId string   `json:"sourceSessionId"`
+}
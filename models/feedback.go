@@ -0,0 +1,55 @@
+package models
+
+// MessageFeedback is a user's rating of an AI reply
+type MessageFeedback struct {
+	Rating    string `json:"rating" dynamodbav:"rating"`                         // "up" or "down"
+	Category  string `json:"category,omitempty" dynamodbav:"category,omitempty"` // "unhelpful", "unsafe" or "inaccurate"
+	Comment   string `json:"comment,omitempty" dynamodbav:"comment,omitempty"`
+	UpdatedAt int64  `json:"updatedAt" dynamodbav:"updatedAt"`
+}
+
+// MessageFeedbackRequest represents the request body for rating an AI reply
+type MessageFeedbackRequest struct {
+	Rating   string `json:"rating" binding:"required"`
+	Category string `json:"category,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// FeedbackRecord is a copy of the feedback on an AI reply with what produced the reply, kept
+// by day for evaluation. Rating the reply again replaces it.
+type FeedbackRecord struct {
+	Date           string `json:"date" dynamodbav:"Date"` // YYYYMMDD (UTC) the reply was sent
+	Id             string `json:"id" dynamodbav:"Id"`     // "<timestamp>#<userId>#<sessionId>#<messageId>"
+	UserId         string `json:"userId" dynamodbav:"userId"`
+	SessionId      string `json:"sessionId" dynamodbav:"sessionId"`
+	Timestamp      int64  `json:"timestamp" dynamodbav:"timestamp"` // Timestamp of the AI message
+	MessageId      string `json:"messageId" dynamodbav:"messageId"`
+	PersonaId      string `json:"personaId,omitempty" dynamodbav:"personaId,omitempty"`
+	PersonaVersion int    `json:"personaVersion,omitempty" dynamodbav:"personaVersion,omitempty"`
+	Model          string `json:"model,omitempty" dynamodbav:"model,omitempty"`
+	Rating         string `json:"rating" dynamodbav:"rating"`
+	Category       string `json:"category,omitempty" dynamodbav:"category,omitempty"`
+	HasComment     bool   `json:"hasComment,omitempty" dynamodbav:"hasComment,omitempty"` // Comments stay on the message; exports only count them
+	UpdatedAt      int64  `json:"updatedAt" dynamodbav:"updatedAt"`
+}
+
+// FeedbackGroup is the feedback on replies from one persona version and model
+type FeedbackGroup struct {
+	PersonaId      string         `json:"personaId"`
+	PersonaVersion int            `json:"personaVersion"`
+	Model          string         `json:"model"`
+	Total          int            `json:"total"`
+	Up             int            `json:"up"`
+	Down           int            `json:"down"`
+	HelpfulRate    float64        `json:"helpfulRate"` // Up / Total
+	Categories     map[string]int `json:"categories,omitempty"`
+	Comments       int            `json:"comments"`
+}
+
+// FeedbackExportResponse represents the response body for the feedback export
+type FeedbackExportResponse struct {
+	From   string          `json:"from"` // YYYYMMDD (UTC)
+	To     string          `json:"to"`
+	Groups []FeedbackGroup `json:"groups"`
+	Count  int             `json:"count"` // Rated replies in the range
+}
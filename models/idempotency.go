@@ -0,0 +1,12 @@
+package models
+
+// IdempotencyRecord remembers a request sent with an Idempotency-Key, so a retry gets the
+// original response instead of running the request again
+type IdempotencyRecord struct {
+	Key         string `json:"key" dynamodbav:"Key"`                               // "<userId>#<route>#<Idempotency-Key>"
+	RequestHash string `json:"requestHash" dynamodbav:"requestHash"`               // SHA-256 of the request body; a retry must match it
+	Status      string `json:"status" dynamodbav:"status"`                         // "pending" while the request runs, then "complete"
+	Response    string `json:"response,omitempty" dynamodbav:"response,omitempty"` // JSON of the response, once complete
+	CreatedAt   int64  `json:"createdAt" dynamodbav:"createdAt"`
+	ExpiresAt   int64  `json:"expiresAt" dynamodbav:"expiresAt"` // DynamoDB TTL, unix seconds
+}
@@ -0,0 +1,39 @@
+package models
+
+// ImportJob tracks an asynchronous journal import from another app
+// Partition Key: UserId, Sort Key: JobId
+type ImportJob struct {
+	UserId      string            `json:"userId" dynamodbav:"UserId"` // Partition Key
+	JobId       string            `json:"jobId" dynamodbav:"JobId"`   // Sort Key
+	Source      string            `json:"source" dynamodbav:"source"` // "dayone", "journey" or "markdown"
+	FileName    string            `json:"fileName,omitempty" dynamodbav:"fileName,omitempty"`
+	TimeZone    string            `json:"timeZone" dynamodbav:"timeZone"` // Zone for archive dates that carry none
+	ArchiveKey  string            `json:"-" dynamodbav:"archiveKey"`      // Blob store key of the uploaded archive
+	Status      string            `json:"status" dynamodbav:"status"`     // "uploading", "queued", "running", "completed" or "failed"
+	Total       int               `json:"total" dynamodbav:"total"`       // Entries found in the archive
+	Processed   int               `json:"processed" dynamodbav:"processed"`
+	Imported    int               `json:"imported" dynamodbav:"imported"`
+	Duplicates  int               `json:"duplicates" dynamodbav:"duplicates"` // Skipped because they already exist
+	Failed      int               `json:"failed" dynamodbav:"failed"`
+	Errors      []ImportItemError `json:"errors" dynamodbav:"errors"`                   // Per-item error report (capped)
+	Error       string            `json:"error,omitempty" dynamodbav:"error,omitempty"` // Set when the whole job failed
+	CreatedAt   int64             `json:"createdAt" dynamodbav:"createdAt"`
+	UpdatedAt   int64             `json:"updatedAt" dynamodbav:"updatedAt"`
+	CompletedAt int64             `json:"completedAt,omitempty" dynamodbav:"completedAt,omitempty"`
+	Pending     string            `json:"-" dynamodbav:"pending,omitempty"`   // "pending" while queued or running, which puts the job in the pending index
+	ExpiresAt   int64             `json:"-" dynamodbav:"expiresAt,omitempty"` // DynamoDB TTL, set until the archive is uploaded and once the job finishes
+}
+
+// ImportItemError describes an archive item that could not be imported
+type ImportItemError struct {
+	Item  string `json:"item" dynamodbav:"item"` // File name, with the entry index for multi-entry files
+	Error string `json:"error" dynamodbav:"error"`
+}
+
+// ImportJobResponse represents the response body for an import job
+type ImportJobResponse struct {
+	Job       ImportJob `json:"job"`
+	UploadURL string    `json:"uploadUrl,omitempty"` // Where to PUT the archive, while the job is uploading
+	ExpiresAt int64     `json:"expiresAt,omitempty"` // When uploadUrl expires
+	Message   string    `json:"message,omitempty"`
+}
@@ -0,0 +1,46 @@
+package models
+
+// JournalStat is an activity counter maintained as journal entries are written, trashed and restored
+// Partition Key: UserId, Sort Key: StatKey ("DAY#YYYYMMDD", "HOUR#hh" or "TOTAL")
+type JournalStat struct {
+	UserId  string `json:"-" dynamodbav:"UserId"`
+	StatKey string `json:"-" dynamodbav:"StatKey"`
+	Count   int    `json:"count" dynamodbav:"count"`
+	Words   int    `json:"words,omitempty" dynamodbav:"words,omitempty"` // Not tracked for hour counters
+	// Set on the TOTAL item once counters were rebuilt from the user's existing entries
+	Backfilled bool `json:"-" dynamodbav:"backfilled,omitempty"`
+	// Set on the TOTAL item while a rebuild holds the claim, to when it was claimed
+	RebuildingAt int64 `json:"-" dynamodbav:"rebuildingAt,omitempty"`
+}
+
+// JournalDayCount is one cell of the calendar heatmap
+type JournalDayCount struct {
+	Date  string `json:"date"` // YYYY-MM-DD
+	Count int    `json:"count"`
+	Words int    `json:"words"`
+}
+
+// JournalTimeOfDay is the distribution of entries over the hours of the day
+type JournalTimeOfDay struct {
+	Hours     [24]int `json:"hours"`     // Entries per hour, 0 = midnight to 1am
+	Morning   int     `json:"morning"`   // 05:00-11:59
+	Afternoon int     `json:"afternoon"` // 12:00-16:59
+	Evening   int     `json:"evening"`   // 17:00-21:59
+	Night     int     `json:"night"`     // 22:00-04:59
+}
+
+// JournalStatsResponse represents the response body for a user's journaling activity
+type JournalStatsResponse struct {
+	Year          int               `json:"year"`
+	TimeZone      string            `json:"timeZone"`
+	CurrentStreak int               `json:"currentStreak"` // Consecutive days with an entry, ending today or yesterday
+	LongestStreak int               `json:"longestStreak"`
+	TotalEntries  int               `json:"totalEntries"`
+	TotalWords    int               `json:"totalWords"`
+	YearEntries   int               `json:"yearEntries"`
+	YearWords     int               `json:"yearWords"`
+	ActiveDays    int               `json:"activeDays"` // Days with at least one entry in the year
+	AverageWords  int               `json:"averageWords"`
+	Heatmap       []JournalDayCount `json:"heatmap"` // Days of the year with at least one entry, in order
+	TimeOfDay     JournalTimeOfDay  `json:"timeOfDay"`
+}
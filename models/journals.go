@@ -7,29 +7,105 @@ type Journal struct {
 	UserId    string `json:"userId" dynamodbav:"UserId"`       // Partition Key
 	CreatedAt int64  `json:"createdAt" dynamodbav:"CreatedAt"` // Sort Key (reverse chronological)
 	JournalID string `json:"journalId" dynamodbav:"JournalId"` // Unique per journal
-	Date      string `json:"date" dynamodbav:"date"`
+	Date      string `json:"date" dynamodbav:"date"`           // YYYYMMDD in the writer's time zone
 	Title     string `json:"title" dynamodbav:"title"`
 	Content   string `json:"content" dynamodbav:"content"`
 	UpdatedAt int64  `json:"updatedAt" dynamodbav:"updatedAt"`
+	DeletedAt int64  `json:"deletedAt,omitempty" dynamodbav:"deletedAt,omitempty"` // Set when the entry is moved to trash
+	// Structured metadata
+	Tags     []string `json:"tags,omitempty" dynamodbav:"tags,omitempty"`         // Normalized user-defined tags
+	Mood     int      `json:"mood,omitempty" dynamodbav:"mood,omitempty"`         // Optional mood rating from 1 to 5
+	Emotions []string `json:"emotions,omitempty" dynamodbav:"emotions,omitempty"` // Emotion labels from constants.JournalEmotionLabels
+	Pinned   bool     `json:"pinned" dynamodbav:"pinned"`                         // Pinned/favorite flag
+	// IDs of the photos and voice notes attached to the entry
+	AttachmentIds []string `json:"attachmentIds,omitempty" dynamodbav:"attachmentIds,omitempty"`
+	// App the entry was imported from ("dayone", "journey" or "markdown"), empty for entries written here
+	ImportSource string `json:"importSource,omitempty" dynamodbav:"importSource,omitempty"`
+	// Fingerprint of the imported item as it was in the archive. The stored CreatedAt can be moved
+	// to keep entries apart, so re-imports are matched on this instead.
+	ImportFingerprint string `json:"-" dynamodbav:"importFingerprint,omitempty"`
+	// IANA time zone the entry was written in, used to bucket it by day and hour (UTC when empty)
+	TimeZone string `json:"timeZone,omitempty" dynamodbav:"timeZone,omitempty"`
+	// Journaling prompt the entry was started from
+	PromptId string `json:"promptId,omitempty" dynamodbav:"promptId,omitempty"`
+	// Sentiment and emotion analysis of the content, filled in asynchronously after each write
+	Sentiment *Sentiment `json:"sentiment,omitempty" dynamodbav:"sentiment,omitempty"`
+	// Set while the entry waits for (re)analysis, which puts it in the sparse pending index
+	SentimentPending string `json:"-" dynamodbav:"sentimentPending,omitempty"`
+	// Keeps the entry out of the passages chat retrieves from the user's journals
+	ExcludeFromChat bool `json:"excludeFromChat" dynamodbav:"excludeFromChat,omitempty"`
+	// Chat session the entry was drafted from
+	SourceSessionId string `json:"sourceSessionId,omitempty" dynamodbav:"sourceSessionId,omitempty"`
+	// Incremented by every edit, retag and attachment change; writes are conditional on it
+	Version int64 `json:"version,omitempty" dynamodbav:"version,omitempty"`
 }
 
 // JournalCreateRequest represents the request body for creating a journal entry
 type JournalCreateRequest struct {
-	Title   string `json:"title" binding:"required"`
-	Content string `json:"content" binding:"required"`
+	Title    string   `json:"title" binding:"required"`
+	Content  string   `json:"content" binding:"required"`
+	Tags     []string `json:"tags,omitempty"`
+	Mood     int      `json:"mood,omitempty"`
+	Emotions []string `json:"emotions,omitempty"`
+	Pinned   bool     `json:"pinned,omitempty"`
+	PromptId string   `json:"promptId,omitempty"` // Set when the entry is started from a journaling prompt
+	// Keeps the entry out of the passages chat retrieves from the user's journals
+	ExcludeFromChat bool `json:"excludeFromChat,omitempty"`
+	// Set when saving a draft from POST /chat/sessions/:sessionId/journal-draft
+	SourceSessionId string `json:"sourceSessionId,omitempty"`
 }
 
 // JournalUpdateRequest represents the request body for updating a journal entry
+// Metadata fields are optional; nil fields keep their current values
 type JournalUpdateRequest struct {
-	Title   string `json:"title" binding:"required"`
-	Content string `json:"content" binding:"required"`
+	Title    string   `json:"title" binding:"required"`
+	Content  string   `json:"content" binding:"required"`
+	Tags     []string `json:"tags,omitempty"`
+	Mood     *int     `json:"mood,omitempty"`
+	Emotions []string `json:"emotions,omitempty"`
+	Pinned   *bool    `json:"pinned,omitempty"`
+	// Keeps the entry out of the passages chat retrieves from the user's journals
+	ExcludeFromChat *bool `json:"excludeFromChat,omitempty"`
+}
+
+// JournalFilter narrows the journal list by tag and/or mood (zero values mean no filter)
+type JournalFilter struct {
+	Tag  string
+	Mood int
+}
+
+// TagCount represents the number of live journal entries using a tag
+// Partition Key: UserId, Sort Key: Tag
+type TagCount struct {
+	UserId string `json:"-" dynamodbav:"UserId"`
+	Tag    string `json:"tag" dynamodbav:"Tag"`
+	Count  int    `json:"count" dynamodbav:"count"`
+}
+
+// TagListResponse represents the response body for the user's tags
+type TagListResponse struct {
+	Tags  []TagCount `json:"tags"`
+	Count int        `json:"count"`
+}
+
+// TagRenameRequest represents the request body for renaming a tag
+type TagRenameRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// TagMergeRequest represents the request body for merging several tags into one
+type TagMergeRequest struct {
+	Sources []string `json:"sources" binding:"required"`
+	Target  string   `json:"target" binding:"required"`
 }
 
 // JournalResponse represents the response body for a single journal entry
 // (can be extended for additional metadata if needed)
 type JournalResponse struct {
-	Journal Journal `json:"journal"`
-	Message string  `json:"message,omitempty"`
+	Journal         Journal          `json:"journal"`
+	Message         string           `json:"message,omitempty"`
+	Risk            *RiskAssessment  `json:"risk,omitempty"`            // Set when the entry suggests a risk of suicide or self-harm
+	CrisisResources *CrisisResources `json:"crisisResources,omitempty"` // Services to show alongside Risk
 }
 
 // JournalListResponse represents the response body for multiple journal entries
@@ -0,0 +1,35 @@
+package models
+
+// ModerationFinding is one problem a moderation check found in an AI reply
+type ModerationFinding struct {
+	Check    string `json:"check" dynamodbav:"check"`       // Name of the check that found it
+	Category string `json:"category" dynamodbav:"category"` // "medical_claim", "medication_dosage", "harmful_instructions" or "pii"
+	Action   string `json:"action" dynamodbav:"action"`     // What the finding asked for: "disclaimer", "rewrite" or "block"
+}
+
+// ModerationDecision is the outcome of moderating an AI reply before it is stored and sent
+type ModerationDecision struct {
+	Action   string              `json:"action" dynamodbav:"action"` // Strongest action taken: "allow", "disclaimer", "rewrite" or "block"
+	Findings []ModerationFinding `json:"findings,omitempty" dynamodbav:"findings,omitempty"`
+	Version  int                 `json:"version" dynamodbav:"version"` // Moderation rules version
+}
+
+// ModerationLog records a moderated AI reply for review. Replies that passed every check
+// are not logged.
+type ModerationLog struct {
+	Date      string             `json:"date" dynamodbav:"Date"` // YYYYMMDD (UTC) the reply was moderated
+	Id        string             `json:"id" dynamodbav:"Id"`     // "<timestamp>#<userId>#<sessionId>#<messageId>"
+	UserId    string             `json:"userId" dynamodbav:"userId"`
+	SessionId string             `json:"sessionId" dynamodbav:"sessionId"`
+	Timestamp int64              `json:"timestamp" dynamodbav:"timestamp"` // Timestamp of the stored AI message
+	MessageId string             `json:"messageId" dynamodbav:"messageId"`
+	Decision  ModerationDecision `json:"decision" dynamodbav:"decision"`
+	Original  string             `json:"original" dynamodbav:"original"` // The model's reply with echoed personal data removed
+	Sent      string             `json:"sent" dynamodbav:"sent"`         // The reply that was stored and sent
+}
+
+// ModerationLogListResponse represents the response body for the moderation review list
+type ModerationLogListResponse struct {
+	Logs  []ModerationLog `json:"logs"`
+	Count int             `json:"count"`
+}
@@ -1,10 +1,15 @@
 package models
 
+// MoodEntry is a user's answers to a mood questionnaire
+// Partition Key: UserID, Sort Key: Timestamp
 type MoodEntry struct {
-	UserID              string            `dynamodbav:"UserID"`              // User ID from user table
-	Timestamp           int64             `dynamodbav:"Timestamp"`           // Unix epoch time
-	MoodQuestionnaireID string            `dynamodbav:"MoodQuestionnaireID"` // Questionnaire ID
-	Answers             map[string]string `dynamodbav:"Answers"`             // question number -> user answer
+	UserID              string            `json:"userId" dynamodbav:"UserID"`                           // User ID from user table
+	Timestamp           int64             `json:"timestamp" dynamodbav:"Timestamp"`                     // Unix epoch time
+	MoodEntryId         string            `json:"moodEntryId" dynamodbav:"moodEntryId"`                 // Client-generated ID, stable across retries
+	MoodQuestionnaireID string            `json:"moodQuestionnaireId" dynamodbav:"MoodQuestionnaireID"` // Questionnaire ID
+	Answers             map[string]string `json:"answers" dynamodbav:"Answers"`                         // question number -> user answer
+	UpdatedAt           int64             `json:"updatedAt" dynamodbav:"updatedAt"`
+	DeletedAt           int64             `json:"deletedAt,omitempty" dynamodbav:"deletedAt,omitempty"` // Tombstone for sync
 }
 
 type MoodQuestionnaire struct {
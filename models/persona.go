@@ -0,0 +1,62 @@
+package models
+
+// Persona is one version of a companion chat persona
+// Partition Key: PersonaId, Sort Key: Version
+// Every edit stores a new version, so the exact instructions behind any chat message can be looked up later.
+type Persona struct {
+	PersonaId    string       `json:"personaId" dynamodbav:"PersonaId"` // Partition Key
+	Version      int          `json:"version" dynamodbav:"Version"`     // Sort Key
+	Name         string       `json:"name" dynamodbav:"name"`
+	Description  string       `json:"description,omitempty" dynamodbav:"description,omitempty"`
+	SystemPrompt string       `json:"systemPrompt" dynamodbav:"systemPrompt"`
+	Guardrails   []string     `json:"guardrails,omitempty" dynamodbav:"guardrails,omitempty"` // Rules the model must always follow, on top of the built-in safety rules
+	Style        PersonaStyle `json:"style" dynamodbav:"style"`
+	Active       bool         `json:"active" dynamodbav:"active"` // Inactive personas cannot be picked for new messages
+	BuiltIn      bool         `json:"builtIn" dynamodbav:"-"`     // Shipped with the app rather than stored in DynamoDB
+	CreatedAt    int64        `json:"createdAt" dynamodbav:"createdAt"`
+	UpdatedBy    string       `json:"updatedBy,omitempty" dynamodbav:"updatedBy,omitempty"` // Admin userId that saved this version
+}
+
+// PersonaStyle shapes the replies of a persona
+type PersonaStyle struct {
+	Length      string   `json:"length,omitempty" dynamodbav:"length,omitempty"`           // "brief", "balanced" or "detailed"
+	Temperature *float64 `json:"temperature,omitempty" dynamodbav:"temperature,omitempty"` // 0-2; nil uses the provider default
+	MaxTokens   int      `json:"maxTokens,omitempty" dynamodbav:"maxTokens,omitempty"`     // 0 uses the provider default
+}
+
+// PersonaRequest represents the request body for creating or updating a persona
+type PersonaRequest struct {
+	Name         string       `json:"name" binding:"required"`
+	Description  string       `json:"description,omitempty"`
+	SystemPrompt string       `json:"systemPrompt" binding:"required"`
+	Guardrails   []string     `json:"guardrails,omitempty"`
+	Style        PersonaStyle `json:"style"`
+	Active       *bool        `json:"active,omitempty"` // Defaults to true on create; nil keeps the current value on update
+}
+
+// PersonaSummary describes a persona users can pick for a chat session
+type PersonaSummary struct {
+	PersonaId   string `json:"personaId"`
+	Version     int    `json:"version"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Default     bool   `json:"default"` // Used when a session has no persona
+}
+
+// PersonaListResponse represents the response body for the personas users can pick
+type PersonaListResponse struct {
+	Personas []PersonaSummary `json:"personas"`
+	Count    int              `json:"count"`
+}
+
+// AdminPersonaResponse represents the response body for a single persona version in the admin API
+type AdminPersonaResponse struct {
+	Persona Persona `json:"persona"`
+	Message string  `json:"message,omitempty"`
+}
+
+// AdminPersonaListResponse represents the response body for a list of personas or persona versions in the admin API
+type AdminPersonaListResponse struct {
+	Personas []Persona `json:"personas"`
+	Count    int       `json:"count"`
+}
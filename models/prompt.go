@@ -0,0 +1,62 @@
+package models
+
+// Prompt is a guided journaling prompt from the prompt library
+// Partition Key: PromptId
+// Texts holds the prompt in each supported locale, keyed by language tag (e.g. "en", "es", "pt-BR").
+type Prompt struct {
+	PromptId  string            `json:"promptId" dynamodbav:"PromptId"` // Partition Key
+	Category  string            `json:"category" dynamodbav:"category"` // "cbt", "gratitude" or "reflection"
+	Texts     map[string]string `json:"texts" dynamodbav:"texts"`
+	Active    bool              `json:"active" dynamodbav:"active"` // Inactive prompts are never shown
+	BuiltIn   bool              `json:"builtIn" dynamodbav:"-"`     // Shipped with the app rather than stored in DynamoDB
+	CreatedAt int64             `json:"createdAt" dynamodbav:"createdAt"`
+	UpdatedAt int64             `json:"updatedAt" dynamodbav:"updatedAt"`
+}
+
+// PromptRequest represents the request body for creating or updating a prompt
+type PromptRequest struct {
+	Category string            `json:"category" binding:"required"`
+	Texts    map[string]string `json:"texts" binding:"required"`
+	Active   *bool             `json:"active,omitempty"` // Defaults to true on create; nil keeps the current value on update
+}
+
+// LocalizedPrompt is a prompt resolved to a single locale for display
+type LocalizedPrompt struct {
+	PromptId string `json:"promptId"`
+	Category string `json:"category"`
+	Text     string `json:"text"`
+	Locale   string `json:"locale"` // Locale the text is actually in, after fallback
+}
+
+// PromptHistory records the daily prompt shown to a user on a given day
+// Partition Key: UserId, Sort Key: Date (YYYYMMDD)
+type PromptHistory struct {
+	UserId    string `json:"userId" dynamodbav:"UserId"`
+	Date      string `json:"date" dynamodbav:"Date"`
+	PromptId  string `json:"promptId" dynamodbav:"promptId"`
+	CreatedAt int64  `json:"createdAt" dynamodbav:"createdAt"`
+}
+
+// PromptListResponse represents the response body for the localized prompt library
+type PromptListResponse struct {
+	Prompts []LocalizedPrompt `json:"prompts"`
+	Count   int               `json:"count"`
+}
+
+// DailyPromptResponse represents the response body for a user's prompt of the day
+type DailyPromptResponse struct {
+	Prompt LocalizedPrompt `json:"prompt"`
+	Date   string          `json:"date"`
+}
+
+// AdminPromptResponse represents the response body for a single prompt in the admin API
+type AdminPromptResponse struct {
+	Prompt  Prompt `json:"prompt"`
+	Message string `json:"message,omitempty"`
+}
+
+// AdminPromptListResponse represents the response body for the full prompt library in the admin API
+type AdminPromptListResponse struct {
+	Prompts []Prompt `json:"prompts"`
+	Count   int      `json:"count"`
+}
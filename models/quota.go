@@ -0,0 +1,48 @@
+package models
+
+// ChatLimits are the chat limits of a plan. In an admin override, zero fields keep the
+// plan's value and negative ones lift the limit.
+type ChatLimits struct {
+	RequestsPerMinute int `json:"requestsPerMinute" dynamodbav:"requestsPerMinute"`
+	Burst             int `json:"burst" dynamodbav:"burst"` // Requests that may be made back to back
+	DailyMessages     int `json:"dailyMessages" dynamodbav:"dailyMessages"`
+	DailyTokens       int `json:"dailyTokens" dynamodbav:"dailyTokens"` // Prompt and completion tokens together
+}
+
+// TokenUsage counts the tokens of an AI reply
+type TokenUsage struct {
+	PromptTokens     int  `json:"promptTokens" dynamodbav:"promptTokens"`
+	CompletionTokens int  `json:"completionTokens" dynamodbav:"completionTokens"`
+	Estimated        bool `json:"estimated,omitempty" dynamodbav:"estimated,omitempty"` // The provider did not report usage, so it was estimated from the text
+}
+
+// DailyUsage is a user's chat usage on one day (UTC)
+type DailyUsage struct {
+	UserId           string `json:"userId" dynamodbav:"userId"`
+	Date             string `json:"date" dynamodbav:"Date"` // YYYYMMDD
+	Messages         int    `json:"messages" dynamodbav:"messages"`
+	PromptTokens     int    `json:"promptTokens" dynamodbav:"promptTokens"`
+	CompletionTokens int    `json:"completionTokens" dynamodbav:"completionTokens"`
+}
+
+// Tokens returns the prompt and completion tokens together
+func (u DailyUsage) Tokens() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// QuotaResponse represents the response body describing a user's chat limits and usage
+type QuotaResponse struct {
+	UserId   string      `json:"userId"`
+	Plan     string      `json:"plan"`
+	Override *ChatLimits `json:"override,omitempty"`
+	Limits   ChatLimits  `json:"limits"` // Effective limits; negative means unlimited
+	Usage    DailyUsage  `json:"usage"`  // Usage today
+	ResetsAt int64       `json:"resetsAt"`
+}
+
+// QuotaUpdateRequest represents the request body for changing a user's plan or limits
+type QuotaUpdateRequest struct {
+	Plan          *string     `json:"plan,omitempty"`
+	Override      *ChatLimits `json:"override,omitempty"`
+	ClearOverride bool        `json:"clearOverride,omitempty"`
+}
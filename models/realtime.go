@@ -0,0 +1,14 @@
+package models
+
+// RealtimeConnection is a client connected to the API Gateway WebSocket API. The local server
+// keeps its connections in memory instead.
+type RealtimeConnection struct {
+	ConnectionId string `json:"connectionId" dynamodbav:"ConnectionId"`
+	UserId       string `json:"userId" dynamodbav:"userId"`
+	Endpoint     string `json:"endpoint" dynamodbav:"endpoint"` // @connections endpoint to post to
+	Locale       string `json:"locale,omitempty" dynamodbav:"locale,omitempty"`
+	ConnectedAt  int64  `json:"connectedAt" dynamodbav:"connectedAt"`
+	CancelledId  string `json:"cancelledId,omitempty" dynamodbav:"cancelledId,omitempty"` // Chat message whose reply the client cancelled
+	RemindedDay  string `json:"remindedDay,omitempty" dynamodbav:"remindedDay,omitempty"` // Local day (YYYYMMDD) of the last mood reminder
+	ExpiresAt    int64  `json:"expiresAt" dynamodbav:"expiresAt"`                         // DynamoDB TTL, unix seconds
+}
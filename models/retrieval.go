@@ -0,0 +1,13 @@
+package models
+
+// Citation is a passage from the user's own writing that chat put in the prompt, numbered
+// as the model was asked to cite it
+type Citation struct {
+	Ref       int    `json:"ref" dynamodbav:"ref"`       // Cited as [Ref] in the reply
+	Source    string `json:"source" dynamodbav:"source"` // "journal" or "chat"
+	JournalId string `json:"journalId,omitempty" dynamodbav:"journalId,omitempty"`
+	SessionId string `json:"sessionId,omitempty" dynamodbav:"sessionId,omitempty"`
+	Title     string `json:"title,omitempty" dynamodbav:"title,omitempty"`
+	Date      string `json:"date,omitempty" dynamodbav:"date,omitempty"` // YYYYMMDD
+	Snippet   string `json:"snippet" dynamodbav:"snippet"`
+}
@@ -0,0 +1,24 @@
+package models
+
+// RiskAssessment rates how strongly a text suggests a risk of suicide or self-harm
+type RiskAssessment struct {
+	Level      string   `json:"level"`                // "none", "low", "medium" or "high"
+	Categories []string `json:"categories,omitempty"` // "suicide", "self_harm", "hopelessness"
+	Source     string   `json:"source"`               // "lexicon", or "lexicon+llm" when the LLM gave a second opinion
+}
+
+// CrisisResource is a helpline or service a user at risk can contact
+type CrisisResource struct {
+	Name  string `json:"name"`
+	Phone string `json:"phone,omitempty"`
+	SMS   string `json:"sms,omitempty"`
+	URL   string `json:"url,omitempty"`
+}
+
+// CrisisResources are the crisis services for the user's region
+type CrisisResources struct {
+	Region          string           `json:"region,omitempty"` // ISO 3166-1 alpha-2; empty for the international fallback
+	EmergencyNumber string           `json:"emergencyNumber,omitempty"`
+	Message         string           `json:"message"`
+	Resources       []CrisisResource `json:"resources"`
+}
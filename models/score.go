@@ -4,4 +4,5 @@ type MindMuseScore struct {
 	UserId    string  `json:"userId" dynamodbav:"userId"`
 	Score     float64 `json:"score" dynamodbav:"score"`
 	Timestamp int64   `json:"timestamp" dynamodbav:"timestamp"`
+	Date      string  `json:"date,omitempty" dynamodbav:"date,omitempty"` // YYYYMMDD in the user's time zone
 } 
\ No newline at end of file
@@ -0,0 +1,58 @@
+package models
+
+// Sentiment is the lexicon-based sentiment and emotion analysis of a piece of text
+type Sentiment struct {
+	Compound   float64       `json:"compound" dynamodbav:"compound"` // Overall polarity from -1 (most negative) to 1 (most positive)
+	Positive   float64       `json:"positive" dynamodbav:"positive"` // Share of the text that is positive, 0 to 1
+	Negative   float64       `json:"negative" dynamodbav:"negative"` // Share of the text that is negative, 0 to 1
+	Neutral    float64       `json:"neutral" dynamodbav:"neutral"`   // Share of the text that is neutral, 0 to 1
+	Label      string        `json:"label" dynamodbav:"label"`       // "positive", "negative" or "neutral"
+	Emotions   EmotionScores `json:"emotions" dynamodbav:"emotions"`
+	AnalyzedAt int64         `json:"analyzedAt" dynamodbav:"analyzedAt"`
+	Version    int           `json:"version" dynamodbav:"version"` // Analyzer version, bumped when the lexicon changes
+}
+
+// EmotionScores holds the intensity of each basic emotion, from 0 to 1
+type EmotionScores struct {
+	Sadness float64 `json:"sadness" dynamodbav:"sadness"`
+	Anxiety float64 `json:"anxiety" dynamodbav:"anxiety"`
+	Anger   float64 `json:"anger" dynamodbav:"anger"`
+	Joy     float64 `json:"joy" dynamodbav:"joy"`
+}
+
+// SentimentTrendPoint summarizes the analyzed texts of one day or week
+type SentimentTrendPoint struct {
+	Period   string        `json:"period"` // First day of the period, YYYY-MM-DD
+	Count    int           `json:"count"`
+	Compound float64       `json:"compound"` // Average compound score
+	Emotions EmotionScores `json:"emotions"` // Average emotion scores
+	Positive int           `json:"positive"` // Number of positive texts
+	Negative int           `json:"negative"`
+	Neutral  int           `json:"neutral"`
+}
+
+// SentimentTrendResponse represents the response body for sentiment trends
+type SentimentTrendResponse struct {
+	Interval string                `json:"interval"` // "day" or "week"
+	From     string                `json:"from"`
+	To       string                `json:"to"`
+	Journals []SentimentTrendPoint `json:"journals"`
+	Chat     []SentimentTrendPoint `json:"chat"` // The user's own chat messages
+}
+
+// SentimentDay holds the totals of the analyzed chat messages a user sent on one day, so trends
+// are read from one item per day instead of from every message
+// Partition Key: UserId, Sort Key: Day (YYYYMMDD in the zone the messages were sent from)
+type SentimentDay struct {
+	UserId   string  `json:"-" dynamodbav:"UserId"`
+	Day      string  `json:"day" dynamodbav:"Day"`
+	Count    int     `json:"count" dynamodbav:"count"`
+	Compound float64 `json:"compound" dynamodbav:"compound"` // Sum of the compound scores
+	Sadness  float64 `json:"sadness" dynamodbav:"sadness"`   // Sums of the emotion scores
+	Anxiety  float64 `json:"anxiety" dynamodbav:"anxiety"`
+	Anger    float64 `json:"anger" dynamodbav:"anger"`
+	Joy      float64 `json:"joy" dynamodbav:"joy"`
+	Positive int     `json:"positive" dynamodbav:"positive"` // Number of texts with each label
+	Negative int     `json:"negative" dynamodbav:"negative"`
+	Neutral  int     `json:"neutral" dynamodbav:"neutral"`
+}
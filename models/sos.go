@@ -1,7 +1,71 @@
 package models
 
+// SOS is a crisis alert, raised by the user or when they write about suicide or self-harm
+// Partition Key: UserID, Sort Key: Timestamp
 type SOS struct {
-	UserID    string `dynamodbav:"UserID"`    // User ID from user table
-	Timestamp int64  `dynamodbav:"Timestamp"` // Unix epoch time
-	Status    string `dynamodbav:"Status"`
+	UserID           string       `json:"userId" dynamodbav:"UserID"`                         // User ID from user table
+	Timestamp        int64        `json:"timestamp" dynamodbav:"Timestamp"`                   // Unix epoch time
+	Status           string       `json:"status" dynamodbav:"Status"`                         // raised, notified, acknowledged, resolved or cancelled; "open" on older alerts
+	Source           string       `json:"source" dynamodbav:"source"`                         // "chat", "journal" or "manual"
+	SourceId         string       `json:"sourceId,omitempty" dynamodbav:"sourceId,omitempty"` // Chat sessionId or journalId; the text itself is not copied
+	Level            string       `json:"level,omitempty" dynamodbav:"level,omitempty"`
+	Categories       []string     `json:"categories,omitempty" dynamodbav:"categories,omitempty"`
+	Region           string       `json:"region,omitempty" dynamodbav:"region,omitempty"`
+	Message          string       `json:"message,omitempty" dynamodbav:"message,omitempty"` // Written by the user for their contacts when raising it themselves
+	Location         *SOSLocation `json:"location,omitempty" dynamodbav:"location,omitempty"`
+	NotifyAt         int64        `json:"notifyAt,omitempty" dynamodbav:"notifyAt,omitempty"` // When contacts are alerted unless it is cancelled first; 0 when no alert is pending
+	ContactsNotified int          `json:"contactsNotified" dynamodbav:"contactsNotified"`     // Emergency contacts alerted
+	UpdatedAt        int64        `json:"updatedAt,omitempty" dynamodbav:"updatedAt,omitempty"`
+	History          []SOSEvent   `json:"history" dynamodbav:"history"` // Audit trail of status changes, oldest first
+}
+
+// SOSLocation is where the user was when raising an SOS
+type SOSLocation struct {
+	Latitude  float64 `json:"latitude" dynamodbav:"latitude"`
+	Longitude float64 `json:"longitude" dynamodbav:"longitude"`
+	Accuracy  float64 `json:"accuracy,omitempty" dynamodbav:"accuracy,omitempty"` // Meters
+}
+
+// SOSEvent records a status change of an SOS
+type SOSEvent struct {
+	Status  string `json:"status" dynamodbav:"status"`
+	At      int64  `json:"at" dynamodbav:"at"`
+	Actor   string `json:"actor" dynamodbav:"actor"`                         // "user", "contact" or "system"
+	Contact string `json:"contact,omitempty" dynamodbav:"contact,omitempty"` // Name of the contact who acknowledged
+	Note    string `json:"note,omitempty" dynamodbav:"note,omitempty"`
+}
+
+// SOSRequest is the body of POST /sos; both fields are optional
+type SOSRequest struct {
+	Message  string       `json:"message"`
+	Location *SOSLocation `json:"location"`
+}
+
+// SOSResolveRequest is the body of POST /sos/:timestamp/resolve
+type SOSResolveRequest struct {
+	Note string `json:"note"`
+}
+
+// SOSAcknowledgeRequest is the body of POST /sos/acknowledge
+type SOSAcknowledgeRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// SOSResponse is an SOS and the crisis services to show alongside it
+type SOSResponse struct {
+	SOS             SOS              `json:"sos"`
+	CrisisResources *CrisisResources `json:"crisisResources,omitempty"`
+}
+
+// SOSAcknowledgeResponse tells a contact whose SOS they acknowledged
+type SOSAcknowledgeResponse struct {
+	UserName string `json:"userName"`
+	Status   string `json:"status"`
+}
+
+// SOSListResponse is a page of a user's SOS alerts, newest first
+type SOSListResponse struct {
+	Alerts []SOS  `json:"alerts"`
+	Count  int    `json:"count"`
+	Cursor string `json:"cursor,omitempty"` // Pass back to get the next page; empty on the last page
 }
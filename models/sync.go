@@ -0,0 +1,69 @@
+package models
+
+// SyncRequest is a batch of changes made on a device while offline, plus the token of its last sync
+type SyncRequest struct {
+	BatchId  string              `json:"batchId" binding:"required"` // Client-generated; resending the same batch returns the same results
+	Token    string              `json:"token"`                      // Empty on the first sync
+	Journals []SyncJournalChange `json:"journals"`
+	Moods    []SyncMoodChange    `json:"moods"`
+}
+
+// SyncJournalChange is a journal create, update or delete made on the device
+type SyncJournalChange struct {
+	Op              string   `json:"op" binding:"required"`        // "create", "update" or "delete"
+	JournalId       string   `json:"journalId" binding:"required"` // Generated by the client for creates
+	BaseVersion     int64    `json:"baseVersion"`                  // updatedAt of the server copy the change was made on
+	ClientUpdatedAt int64    `json:"clientUpdatedAt"`              // When the change was made on the device (unix seconds)
+	CreatedAt       int64    `json:"createdAt"`                    // For creates: when the entry was written on the device
+	Title           string   `json:"title"`
+	Content         string   `json:"content"`
+	Tags            []string `json:"tags,omitempty"`
+	Mood            int      `json:"mood,omitempty"`
+	Emotions        []string `json:"emotions,omitempty"`
+	Pinned          bool     `json:"pinned,omitempty"`
+	PromptId        string   `json:"promptId,omitempty"`
+	ExcludeFromChat bool     `json:"excludeFromChat,omitempty"`
+}
+
+// SyncMoodChange is a mood entry recorded or deleted on the device
+type SyncMoodChange struct {
+	Op                  string            `json:"op" binding:"required"` // "create" or "delete"
+	MoodEntryId         string            `json:"moodEntryId" binding:"required"`
+	Timestamp           int64             `json:"timestamp" binding:"required"`
+	MoodQuestionnaireID string            `json:"moodQuestionnaireId"`
+	Answers             map[string]string `json:"answers"`
+}
+
+// SyncResult is the outcome of one client change
+type SyncResult struct {
+	Type       string     `json:"type" dynamodbav:"type"` // "journal" or "mood"
+	Id         string     `json:"id" dynamodbav:"id"`
+	Op         string     `json:"op" dynamodbav:"op"`
+	Status     string     `json:"status" dynamodbav:"status"`                             // "applied", "conflict" or "rejected"
+	Resolution string     `json:"resolution,omitempty" dynamodbav:"resolution,omitempty"` // Set when the change conflicted with a server edit
+	Error      string     `json:"error,omitempty" dynamodbav:"error,omitempty"`
+	Journal    *Journal   `json:"journal,omitempty" dynamodbav:"-"` // Server copy after the change
+	Mood       *MoodEntry `json:"mood,omitempty" dynamodbav:"-"`
+}
+
+// SyncResponse carries the results of the client's changes and the server changes since its token
+type SyncResponse struct {
+	Token    string       `json:"token"`   // Send with the next sync
+	HasMore  bool         `json:"hasMore"` // More server changes are waiting; sync again right away
+	Reset    bool         `json:"reset"`   // The token was too old; replace local data with the returned records
+	Results  []SyncResult `json:"results"`
+	Journals []Journal    `json:"journals"` // Changed entries; entries with deletedAt set are tombstones
+	Moods    []MoodEntry  `json:"moods"`    // Changed mood entries; entries with deletedAt set are tombstones
+	Replayed bool         `json:"replayed"` // The batch had already been applied; results are from the first run
+}
+
+// SyncBatch records a processed batch so a resent batch is not applied twice
+// Partition Key: UserId, Sort Key: BatchId
+type SyncBatch struct {
+	UserId    string       `dynamodbav:"UserId"`
+	BatchId   string       `dynamodbav:"BatchId"`
+	Status    string       `dynamodbav:"status"` // "processing" or "done"
+	Results   []SyncResult `dynamodbav:"results"`
+	CreatedAt int64        `dynamodbav:"createdAt"`
+	ExpiresAt int64        `dynamodbav:"expiresAt"` // DynamoDB TTL attribute
+}
@@ -21,6 +21,16 @@ type User struct {
 	ProfilePicture    string       `json:"profilePicture,omitempty" dynamodbav:"profilePicture,omitempty"`
 	Dob               string       `json:"dob,omitempty" dynamodbav:"dob,omitempty"` // date of birth
 	EmergencyContacts [3]Emergency `json:"emergencyContacts,omitempty" dynamodbav:"emergencyContacts,omitempty"`
+	Role              string       `json:"role,omitempty" dynamodbav:"role,omitempty"`         // "admin" for staff, empty for regular users
+	TimeZone          string       `json:"timeZone,omitempty" dynamodbav:"timeZone,omitempty"` // IANA zone, e.g. "Asia/Kolkata"; days are bucketed in it
+	Locale            string       `json:"locale,omitempty" dynamodbav:"locale,omitempty"`     // BCP 47 tag, e.g. "en-IN"
+	// Consent to alert the emergency contacts when the user writes about suicide or self-harm
+	NotifyContactsOnRisk bool `json:"notifyContactsOnRisk" dynamodbav:"notifyContactsOnRisk,omitempty"`
+	// Consent to let chat draw on the user's journal entries and earlier conversations
+	ChatUsesJournals bool `json:"chatUsesJournals" dynamodbav:"chatUsesJournals,omitempty"`
+	// Chat plan ("free", "plus" or "pro"; empty is free) and an admin's override of its limits
+	Plan               string      `json:"plan,omitempty" dynamodbav:"plan,omitempty"`
+	ChatLimitsOverride *ChatLimits `json:"chatLimitsOverride,omitempty" dynamodbav:"chatLimitsOverride,omitempty"`
 	// Password reset fields
 	PasswordResetToken     string `json:"passwordResetToken,omitempty" dynamodbav:"passwordResetToken,omitempty"`
 	PasswordResetExpiresAt int64  `json:"passwordResetExpiresAt,omitempty" dynamodbav:"passwordResetExpiresAt,omitempty"`
@@ -55,4 +65,10 @@ type UserUpdateRequest struct {
 	Password       *string `json:"password,omitempty"`
 	ProfilePicture *string `json:"profilePicture,omitempty"`
 	Dob            *string `json:"dob,omitempty"`
+	TimeZone       *string `json:"timeZone,omitempty"`
+	Locale         *string `json:"locale,omitempty"`
+	// Consent to alert emergency contacts on high-risk messages or journal entries
+	NotifyContactsOnRisk *bool `json:"notifyContactsOnRisk,omitempty"`
+	// Consent to let chat draw on journal entries and earlier conversations
+	ChatUsesJournals *bool `json:"chatUsesJournals,omitempty"`
 }
@@ -0,0 +1,162 @@
+package moderation
+
+import (
+	"regexp"
+	"strings"
+
+	"lambda-server/models"
+)
+
+// MedicalClaims asks for a disclaimer when a reply diagnoses the user or promises a cure
+type MedicalClaims struct{}
+
+var medicalClaimPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\byou (?:have|are suffering from|(?:likely|probably|clearly|definitely|might|may|must) have)\s+(?:an?\s+)?(?:clinical\s+|major\s+|severe\s+)?(?:depression|depressive disorder|anxiety disorder|bipolar|ptsd|ocd|adhd|schizophrenia|borderline personality|personality disorder|eating disorder|mental illness|psychosis)\b`),
+	regexp.MustCompile(`(?i)\byou are (?:bipolar|schizophrenic|psychotic|clinically depressed)\b`),
+	regexp.MustCompile(`(?i)\b(?:i|i'd|i would|i can) diagnose\b`),
+	regexp.MustCompile(`(?i)\byour diagnosis is\b`),
+	regexp.MustCompile(`(?i)\b(?:this|that|it) (?:is|sounds like|looks like) (?:clinical depression|bipolar disorder|ptsd|ocd|adhd|schizophrenia|a panic disorder)\b`),
+	regexp.MustCompile(`(?i)\bwill (?:cure|fix) your (?:depression|anxiety|ptsd|ocd|adhd|bipolar)\b`),
+}
+
+func (MedicalClaims) Name() string { return "medical_claims" }
+
+func (c MedicalClaims) Review(in Input) []models.ModerationFinding {
+	return matchAny(c.Name(), CategoryMedicalClaim, ActionDisclaimer, medicalClaimPatterns, in.Reply)
+}
+
+// MedicationDosage blocks replies that give medication amounts, or tell the user to start,
+// stop or change a dose
+type MedicationDosage struct{}
+
+var (
+	doseAmount     = regexp.MustCompile(`(?i)\b\d+(?:[.,]\d+)?\s?(?:mg|milligrams?|mcg|micrograms?|ml|millilitres?|milliliters?|pills?|tablets?|capsules?)\b`)
+	doseContext    = regexp.MustCompile(`(?i)\b(?:take|taking|dose|doses|dosage|increase|decrease|double|halve|reduce|up to|per day|a day|daily|twice|every \d+ hours)\b`)
+	medicationName = regexp.MustCompile(`(?i)\b(?:sertraline|zoloft|fluoxetine|prozac|citalopram|escitalopram|lexapro|paroxetine|venlafaxine|duloxetine|bupropion|mirtazapine|trazodone|amitriptyline|lithium|lamotrigine|quetiapine|seroquel|olanzapine|aripiprazole|alprazolam|xanax|lorazepam|diazepam|valium|clonazepam|zolpidem|melatonin|methylphenidate|ritalin|adderall|ibuprofen|paracetamol|acetaminophen|tylenol|aspirin|codeine|tramadol|oxycodone)\b`)
+	doseChange     = regexp.MustCompile(`(?i)\b(?:you should|try|i recommend|i'd recommend|i suggest|it's fine to|it is fine to|go ahead and)\s+(?:stop|stopping|quit|quitting|double|doubling|increase|increasing|skip|skipping)\s+(?:taking\s+)?(?:your|the)\s+(?:medication|meds|antidepressants?|pills|prescription|dose)\b`)
+)
+
+func (MedicationDosage) Name() string { return "medication_dosage" }
+
+func (c MedicationDosage) Review(in Input) []models.ModerationFinding {
+	for _, sentence := range sentences(in.Reply) {
+		if doseChange.MatchString(sentence) ||
+			doseAmount.MatchString(sentence) && (doseContext.MatchString(sentence) || medicationName.MatchString(sentence)) {
+			return []models.ModerationFinding{{Check: c.Name(), Category: CategoryMedicationDosage, Action: ActionBlock}}
+		}
+	}
+	return nil
+}
+
+// HarmfulInstructions blocks replies that describe ways to hurt oneself or hide self-harm
+type HarmfulInstructions struct{}
+
+var harmfulPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\b(?:lethal|fatal|deadly|toxic) (?:dose|amount|quantity|dosage)\b`),
+	regexp.MustCompile(`(?i)\bhow (?:to|you can|you could) (?:kill|hurt|harm|cut|poison|hang|strangle|suffocate) (?:yourself|myself|oneself)\b`),
+	regexp.MustCompile(`(?i)\b(?:tie|make|making|tying) a noose\b`),
+	regexp.MustCompile(`(?i)\b(?:ways|methods|method) (?:to|of) (?:die|dying|kill yourself|end your life|commit suicide|self[- ]harm)\b`),
+	regexp.MustCompile(`(?i)\bhow many (?:pills|tablets|capsules) (?:would|will|does it|it takes|to)\b`),
+	regexp.MustCompile(`(?i)\bcut (?:deeper|your wrists?|along the vein)\b`),
+	regexp.MustCompile(`(?i)\bhide (?:the |your )?(?:cuts|scars|burns|self[- ]harm|wounds)\b`),
+}
+
+func (HarmfulInstructions) Name() string { return "harmful_instructions" }
+
+func (c HarmfulInstructions) Review(in Input) []models.ModerationFinding {
+	return matchAny(c.Name(), CategoryHarmfulInstructions, ActionBlock, harmfulPatterns, in.Reply)
+}
+
+// PIIEcho removes email addresses, phone numbers and card or ID numbers the user wrote that
+// the reply repeats back. Numbers the user did not write, such as helplines, are kept.
+type PIIEcho struct{}
+
+var (
+	emailPattern  = regexp.MustCompile(`(?i)\b[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}\b`)
+	numberPattern = regexp.MustCompile(`\+?\d[\d\s().-]{5,}\d`)
+	datePattern   = regexp.MustCompile(`^(?:\d{4}[-/.]\d{1,2}[-/.]\d{1,2}|\d{1,2}[-/.]\d{1,2}[-/.]\d{2,4})$`)
+)
+
+// RedactedText replaces personal data removed from a reply
+const RedactedText = "[redacted]"
+
+// minPIIDigits is the fewest digits a number needs to count as personal data
+const minPIIDigits = 7
+
+func (PIIEcho) Name() string { return "pii_echo" }
+
+func (c PIIEcho) Review(in Input) []models.ModerationFinding {
+	if len(echoedPII(in)) == 0 {
+		return nil
+	}
+	return []models.ModerationFinding{{Check: c.Name(), Category: CategoryPII, Action: ActionRewrite}}
+}
+
+func (PIIEcho) Rewrite(in Input) string {
+	echoed := echoedPII(in)
+	redact := func(match string) string {
+		if echoed[piiKey(match)] {
+			return RedactedText
+		}
+		return match
+	}
+	reply := emailPattern.ReplaceAllStringFunc(in.Reply, redact)
+	return numberPattern.ReplaceAllStringFunc(reply, redact)
+}
+
+// echoedPII returns the keys of the personal data in the user's message that the reply repeats
+func echoedPII(in Input) map[string]bool {
+	written := map[string]bool{}
+	for _, match := range piiMatches(in.UserMessage) {
+		written[piiKey(match)] = true
+	}
+	echoed := map[string]bool{}
+	for _, match := range piiMatches(in.Reply) {
+		if key := piiKey(match); written[key] {
+			echoed[key] = true
+		}
+	}
+	return echoed
+}
+
+// piiMatches finds email addresses and numbers long enough to identify someone
+func piiMatches(text string) []string {
+	matches := emailPattern.FindAllString(text, -1)
+	for _, match := range numberPattern.FindAllString(text, -1) {
+		if len(piiKey(match)) >= minPIIDigits && !datePattern.MatchString(strings.TrimSpace(match)) {
+			matches = append(matches, match)
+		}
+	}
+	return matches
+}
+
+// piiKey normalizes an email address to lower case and a number to its digits, so the same
+// value written differently still matches
+func piiKey(match string) string {
+	if strings.Contains(match, "@") {
+		return strings.ToLower(match)
+	}
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, match)
+}
+
+// matchAny returns a single finding if any pattern matches the text
+func matchAny(check, category, action string, patterns []*regexp.Regexp, text string) []models.ModerationFinding {
+	for _, pattern := range patterns {
+		if pattern.MatchString(text) {
+			return []models.ModerationFinding{{Check: check, Category: category, Action: action}}
+		}
+	}
+	return nil
+}
+
+var sentenceEnd = regexp.MustCompile(`[.!?]+(?:\s+|$)|\n+`)
+
+// sentences splits text roughly into sentences, so a dose and its context are matched together
+func sentences(text string) []string {
+	return sentenceEnd.Split(text, -1)
+}
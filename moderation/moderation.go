@@ -0,0 +1,143 @@
+// Package moderation checks AI replies before they are stored and sent, and blocks, rewrites
+// or adds a disclaimer to replies that make medical claims, give medication doses, describe
+// ways to cause harm or repeat the user's personal data
+package moderation
+
+import (
+	"slices"
+	"strings"
+
+	"lambda-server/models"
+)
+
+// Version is bumped whenever the built-in checks change, so logged decisions can be compared
+const Version = 1
+
+// Actions, weakest first
+const (
+	ActionAllow      = "allow"
+	ActionDisclaimer = "disclaimer" // Append a note to the reply
+	ActionRewrite    = "rewrite"    // Replace the offending parts of the reply
+	ActionBlock      = "block"      // Replace the whole reply with a safe message
+)
+
+// Finding categories
+const (
+	CategoryMedicalClaim        = "medical_claim"
+	CategoryMedicationDosage    = "medication_dosage"
+	CategoryHarmfulInstructions = "harmful_instructions"
+	CategoryPII                 = "pii"
+)
+
+var actions = []string{ActionAllow, ActionDisclaimer, ActionRewrite, ActionBlock}
+
+// Rank orders actions from 0 (allow) to 3 (block); unknown actions rank as allow
+func Rank(action string) int {
+	return max(slices.Index(actions, action), 0)
+}
+
+// Input is a reply to moderate and what the user wrote to get it
+type Input struct {
+	Reply       string
+	UserMessage string
+}
+
+// Check is one moderation rule. Review returns what it found in the reply; a check whose
+// findings ask for a rewrite must also implement Rewriter.
+type Check interface {
+	Name() string
+	Review(in Input) []models.ModerationFinding
+}
+
+// Rewriter is implemented by checks that can fix a reply instead of blocking it
+type Rewriter interface {
+	Rewrite(in Input) string
+}
+
+// Pipeline runs checks in order. Rewrites apply before later checks run, so they see the
+// rewritten reply.
+type Pipeline struct {
+	checks []Check
+}
+
+// New returns a pipeline running the given checks in order
+func New(checks ...Check) *Pipeline {
+	return &Pipeline{checks: checks}
+}
+
+// Default returns a pipeline with the built-in checks
+func Default() *Pipeline {
+	return New(PIIEcho{}, HarmfulInstructions{}, MedicationDosage{}, MedicalClaims{})
+}
+
+// Moderate reviews a reply and returns the decision and the text to store and send.
+// Original is the reply after rewrites only, for the review log.
+func (p *Pipeline) Moderate(in Input) (decision models.ModerationDecision, reply string, original string) {
+	decision = models.ModerationDecision{Action: ActionAllow, Version: Version}
+	for _, check := range p.checks {
+		findings := check.Review(in)
+		if len(findings) == 0 {
+			continue
+		}
+		for _, finding := range findings {
+			if finding.Action == ActionRewrite {
+				if rewriter, ok := check.(Rewriter); ok {
+					in.Reply = rewriter.Rewrite(in)
+					break
+				}
+			}
+		}
+		decision.Findings = append(decision.Findings, findings...)
+		for _, finding := range findings {
+			if Rank(finding.Action) > Rank(decision.Action) {
+				decision.Action = finding.Action
+			}
+		}
+	}
+
+	switch decision.Action {
+	case ActionBlock:
+		return decision, blockMessage(decision.Findings), in.Reply
+	case ActionDisclaimer:
+		return decision, strings.TrimSpace(in.Reply) + "\n\n" + disclaimers(decision.Findings), in.Reply
+	}
+	return decision, in.Reply, in.Reply
+}
+
+// blockMessage is the reply sent in place of a blocked one, for the first blocking finding
+func blockMessage(findings []models.ModerationFinding) string {
+	for _, finding := range findings {
+		if finding.Action != ActionBlock {
+			continue
+		}
+		if message, ok := blockMessages[finding.Category]; ok {
+			return message
+		}
+	}
+	return blockMessages[""]
+}
+
+// disclaimers joins the notes for each category that asked for one
+func disclaimers(findings []models.ModerationFinding) string {
+	notes := []string{}
+	for _, finding := range findings {
+		note, ok := disclaimerNotes[finding.Category]
+		if finding.Action == ActionDisclaimer && ok && !slices.Contains(notes, note) {
+			notes = append(notes, note)
+		}
+	}
+	return strings.Join(notes, " ")
+}
+
+var blockMessages = map[string]string{
+	CategoryMedicationDosage: "I'm not able to give advice about medication or doses. Please talk to your doctor " +
+		"or pharmacist before starting, stopping or changing any medication. I'm happy to keep talking about how you're feeling.",
+	CategoryHarmfulInstructions: "I can't help with that. If you're thinking about hurting yourself, please reach out " +
+		"to a crisis line or your local emergency number right now. You don't have to go through this alone, and I'm here to keep talking.",
+	"": "I'm sorry, I can't help with that. Is there something else on your mind you'd like to talk about?",
+}
+
+var disclaimerNotes = map[string]string{
+	CategoryMedicalClaim: "Please keep in mind that I can't diagnose conditions. A doctor or licensed mental health " +
+		"professional can give you a proper assessment.",
+}
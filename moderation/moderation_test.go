@@ -0,0 +1,97 @@
+package moderation
+
+import (
+	"strings"
+	"testing"
+
+	"lambda-server/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModerateAllow(t *testing.T) {
+	decision, reply, original := Default().Moderate(Input{
+		Reply:       "That sounds really hard. Would you like to talk about what happened today?",
+		UserMessage: "I had an awful day",
+	})
+	assert.Equal(t, ActionAllow, decision.Action)
+	assert.Empty(t, decision.Findings)
+	assert.Equal(t, Version, decision.Version)
+	assert.Equal(t, original, reply)
+}
+
+func TestModerateMedicalClaim(t *testing.T) {
+	decision, reply, original := Default().Moderate(Input{Reply: "From what you describe, you probably have clinical depression."})
+	assert.Equal(t, ActionDisclaimer, decision.Action)
+	assert.Equal(t, []models.ModerationFinding{{Check: "medical_claims", Category: CategoryMedicalClaim, Action: ActionDisclaimer}}, decision.Findings)
+	assert.True(t, strings.HasPrefix(reply, original+"\n\n"))
+	assert.Contains(t, reply, "can't diagnose")
+}
+
+func TestModerateMedicationDosage(t *testing.T) {
+	for _, text := range []string{
+		"You could take 50 mg of sertraline in the morning.",
+		"Try doubling your dose tonight. I suggest doubling your medication.",
+		"Melatonin 3mg can help.",
+		"I recommend stopping your antidepressants for a week.",
+	} {
+		decision, reply, _ := Default().Moderate(Input{Reply: text})
+		assert.Equal(t, ActionBlock, decision.Action, text)
+		assert.Contains(t, reply, "doctor or pharmacist", text)
+	}
+
+	decision, _, _ := Default().Moderate(Input{Reply: "Please don't change your medication without your doctor. Try 5 minutes of slow breathing."})
+	assert.Equal(t, ActionAllow, decision.Action)
+}
+
+func TestModerateHarmfulInstructions(t *testing.T) {
+	decision, reply, original := Default().Moderate(Input{Reply: "Here is how to hurt yourself without anyone noticing."})
+	assert.Equal(t, ActionBlock, decision.Action)
+	assert.Contains(t, reply, "crisis line")
+	assert.Equal(t, "Here is how to hurt yourself without anyone noticing.", original)
+
+	decision, _, _ = Default().Moderate(Input{Reply: "If you feel like hurting yourself, please call 988 right away."})
+	assert.Equal(t, ActionAllow, decision.Action)
+}
+
+func TestModeratePIIEcho(t *testing.T) {
+	decision, reply, original := Default().Moderate(Input{
+		UserMessage: "My number is (555) 123-4567 and my email is Jo.Doe@example.com, born 1990-04-02",
+		Reply:       "Thanks! I'll remember 555-123-4567 and jo.doe@example.com. You can also call 1-800-273-8255. Since 1990-04-02...",
+	})
+	assert.Equal(t, ActionRewrite, decision.Action)
+	assert.Equal(t, "Thanks! I'll remember [redacted] and [redacted]. You can also call 1-800-273-8255. Since 1990-04-02...", reply)
+	assert.Equal(t, reply, original)
+}
+
+func TestModerateStrongestActionWins(t *testing.T) {
+	decision, reply, original := Default().Moderate(Input{
+		UserMessage: "call me on 07700 900123",
+		Reply:       "You have an anxiety disorder, so take 2 tablets of lorazepam. I'll text 07700900123.",
+	})
+	assert.Equal(t, ActionBlock, decision.Action)
+	assert.Len(t, decision.Findings, 3)
+	assert.Contains(t, reply, "doctor or pharmacist")
+	assert.NotContains(t, original, "07700900123")
+}
+
+type shoutCheck struct{}
+
+func (shoutCheck) Name() string { return "shout" }
+
+func (c shoutCheck) Review(in Input) []models.ModerationFinding {
+	if strings.ToUpper(in.Reply) == in.Reply {
+		return nil
+	}
+	return []models.ModerationFinding{{Check: c.Name(), Category: "style", Action: ActionRewrite}}
+}
+
+func (shoutCheck) Rewrite(in Input) string { return strings.ToUpper(in.Reply) }
+
+func TestCustomCheck(t *testing.T) {
+	decision, reply, _ := New(shoutCheck{}).Moderate(Input{Reply: "hello"})
+	assert.Equal(t, ActionRewrite, decision.Action)
+	assert.Equal(t, "HELLO", reply)
+	assert.Equal(t, 3, Rank(ActionBlock))
+	assert.Equal(t, 0, Rank("unknown"))
+}
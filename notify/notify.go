@@ -0,0 +1,137 @@
+// Package notify delivers crisis alerts to a user's emergency contacts
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"lambda-server/constants"
+	"lambda-server/models"
+)
+
+// Alert asks an emergency contact to check in on a user. It never includes what the user wrote
+// in their journal or chats; only an SOS the user raises themselves carries their note and
+// location.
+type Alert struct {
+	Contact        models.Emergency    `json:"contact"`
+	UserName       string              `json:"userName"`
+	Message        string              `json:"message"`
+	SentAt         int64               `json:"sentAt"`
+	Note           string              `json:"note,omitempty"`           // Written by the user for their contacts
+	Location       *models.SOSLocation `json:"location,omitempty"`       // Shared by the user
+	AcknowledgeURL string              `json:"acknowledgeUrl,omitempty"` // Lets the contact tell the user they are on it
+}
+
+// Notifier delivers alerts to emergency contacts
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+var (
+	defaultNotifier     Notifier
+	defaultNotifierOnce sync.Once
+)
+
+// Default returns the notifier configured from the environment. Alerts are posted as JSON to
+// RISK_NOTIFY_WEBHOOK_URL, for example a function that sends them by email or SMS; when it is
+// not set they are only logged.
+func Default() Notifier {
+	defaultNotifierOnce.Do(func() {
+		url := os.Getenv(constants.RiskNotifyWebhookURLEnv)
+		if url == constants.EMPTY_STRING {
+			defaultNotifier = LogNotifier{}
+			return
+		}
+		defaultNotifier = NewWebhookNotifier(url, os.Getenv(constants.RiskNotifyWebhookTokenEnv))
+	})
+	return defaultNotifier
+}
+
+// NewAlert builds the alert sent to contact about a user who may be at risk
+func NewAlert(contact models.Emergency, userName string) Alert {
+	if userName == "" {
+		userName = "Someone who listed you as an emergency contact"
+	}
+	return Alert{
+		Contact:  contact,
+		UserName: userName,
+		Message: fmt.Sprintf("Hi %s, %s may be going through a very hard time right now and could use your support. "+
+			"Please check in with them as soon as you can. If you believe they are in immediate danger, "+
+			"contact local emergency services.", contact.Name, userName),
+		SentAt: time.Now().Unix(),
+	}
+}
+
+// NewSOSAlert builds the alert sent to contact when a user raises an SOS themselves
+func NewSOSAlert(contact models.Emergency, userName string, sos models.SOS, acknowledgeURL string) Alert {
+	if userName == "" {
+		userName = "Someone who listed you as an emergency contact"
+	}
+	message := fmt.Sprintf("Hi %s, %s has raised an SOS and asked for your help. Please contact them right away. "+
+		"If you believe they are in immediate danger, contact local emergency services.", contact.Name, userName)
+	if acknowledgeURL != "" {
+		message += " Let them know you are on it: " + acknowledgeURL
+	}
+	return Alert{
+		Contact:        contact,
+		UserName:       userName,
+		Message:        message,
+		SentAt:         time.Now().Unix(),
+		Note:           sos.Message,
+		Location:       sos.Location,
+		AcknowledgeURL: acknowledgeURL,
+	}
+}
+
+// LogNotifier writes alerts to the log instead of delivering them
+type LogNotifier struct{}
+
+// Notify logs the alert
+func (LogNotifier) Notify(ctx context.Context, alert Alert) error {
+	log.Printf("Crisis alert for %s (no notifier configured): %s\n", alert.Contact.Name, alert.Message)
+	return nil
+}
+
+// WebhookNotifier posts alerts as JSON to a URL
+type WebhookNotifier struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewWebhookNotifier creates a notifier posting to url. A non-empty token is sent as a bearer token.
+func NewWebhookNotifier(url, token string) *WebhookNotifier {
+	return &WebhookNotifier{url: url, token: token, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Notify posts the alert; any non-2xx response is an error
+func (n *WebhookNotifier) Notify(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create alert request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send alert: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("alert webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"lambda-server/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAlertLeavesOutContent(t *testing.T) {
+	alert := NewAlert(models.Emergency{Name: "Sam", Email: "sam@example.com"}, "Alex")
+	assert.Contains(t, alert.Message, "Hi Sam, Alex may be going through")
+	assert.Equal(t, "sam@example.com", alert.Contact.Email)
+
+	anonymous := NewAlert(models.Emergency{Name: "Sam"}, "")
+	assert.Contains(t, anonymous.Message, "Someone who listed you")
+}
+
+func TestNewSOSAlert(t *testing.T) {
+	sos := models.SOS{Message: "Please call me", Location: &models.SOSLocation{Latitude: 12.97, Longitude: 77.59}}
+	alert := NewSOSAlert(models.Emergency{Name: "Sam"}, "Alex", sos, "https://example.com/ack?token=abc")
+	assert.Contains(t, alert.Message, "Hi Sam, Alex has raised an SOS")
+	assert.Contains(t, alert.Message, "https://example.com/ack?token=abc")
+	assert.Equal(t, "Please call me", alert.Note)
+	assert.Equal(t, sos.Location, alert.Location)
+
+	risk := NewAlert(models.Emergency{Name: "Sam"}, "Alex")
+	assert.Empty(t, risk.Note)
+	assert.Nil(t, risk.Location)
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var received Alert
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	alert := NewAlert(models.Emergency{Name: "Sam", Phone: "5550100"}, "Alex")
+	require.NoError(t, NewWebhookNotifier(server.URL, "secret").Notify(context.Background(), alert))
+	assert.Equal(t, "Bearer secret", auth)
+	assert.Equal(t, alert, received)
+}
+
+func TestWebhookNotifierFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	err := NewWebhookNotifier(server.URL, "").Notify(context.Background(), NewAlert(models.Emergency{Name: "Sam"}, "Alex"))
+	assert.ErrorContains(t, err, "502")
+}
@@ -0,0 +1,116 @@
+package personas
+
+import (
+	"slices"
+
+	"lambda-server/constants"
+	"lambda-server/models"
+)
+
+// builtIn is the persona library shipped with the app. Admins can edit or deactivate these
+// personas, which stores a newer version with the same ID. Bump the version whenever a
+// built-in persona's text changes, so chat messages stay traceable to what the model was told.
+var builtIn = []models.Persona{
+	{
+		PersonaId:   constants.DefaultPersonaId,
+		Version:     1,
+		Name:        "Supportive listener",
+		Description: "A warm companion who listens, reflects your feelings back and gently offers ideas.",
+		SystemPrompt: "You are MindMuse, a warm and supportive mental wellbeing companion. Listen carefully, " +
+			"reflect the user's feelings back to them, and offer gentle, practical suggestions such as " +
+			"grounding or journaling exercises when they help. Let the user lead the conversation.",
+		Style: models.PersonaStyle{Length: constants.PersonaLengthBalanced},
+	},
+	{
+		PersonaId:   "cbt-coach",
+		Version:     1,
+		Name:        "CBT coach",
+		Description: "Helps you notice unhelpful thoughts, weigh the evidence and plan small steps.",
+		SystemPrompt: "You are MindMuse, a companion using techniques from cognitive behavioural therapy. Help the " +
+			"user notice automatic thoughts, name common thinking traps such as catastrophising or " +
+			"all-or-nothing thinking, weigh the evidence for and against a thought, and find a more " +
+			"balanced view. Suggest one small, concrete step they could take next.",
+		Guardrails: []string{
+			"Ask one question at a time.",
+			"Explain any technique you use in plain words.",
+		},
+		Style: models.PersonaStyle{Length: constants.PersonaLengthBalanced},
+	},
+	{
+		PersonaId:   "mindfulness-guide",
+		Version:     1,
+		Name:        "Mindfulness guide",
+		Description: "Guides short breathing, body scan and grounding exercises.",
+		SystemPrompt: "You are MindMuse, a calm mindfulness guide. Invite the user to notice the present moment " +
+			"without judgement, and offer short breathing, body scan or five-senses grounding exercises " +
+			"step by step. Use a slow, gentle pace.",
+		Guardrails: []string{
+			"Keep each exercise under two minutes unless the user asks for more.",
+		},
+		Style: models.PersonaStyle{Length: constants.PersonaLengthBrief},
+	},
+}
+
+// BuiltIn returns a fresh copy of the built-in persona library
+func BuiltIn() []models.Persona {
+	library := make([]models.Persona, 0, len(builtIn))
+	for _, persona := range builtIn {
+		persona.Guardrails = append([]string(nil), persona.Guardrails...)
+		persona.Active = true
+		persona.BuiltIn = true
+		library = append(library, persona)
+	}
+	return library
+}
+
+// Latest combines the built-in library with the stored persona versions, keeping the newest
+// version of each persona. Stored versions always follow the built-in version they replace.
+func Latest(stored []models.Persona) []models.Persona {
+	byId := map[string]int{}
+	library := BuiltIn()
+	for i, persona := range library {
+		byId[persona.PersonaId] = i
+	}
+	for _, persona := range stored {
+		i, ok := byId[persona.PersonaId]
+		if !ok {
+			byId[persona.PersonaId] = len(library)
+			library = append(library, persona)
+			continue
+		}
+		if persona.Version > library[i].Version {
+			persona.BuiltIn = library[i].BuiltIn
+			library[i] = persona
+		}
+	}
+	return library
+}
+
+// Versions returns every version of a persona, oldest first, including the built-in version
+func Versions(personaId string, stored []models.Persona) []models.Persona {
+	versions := []models.Persona{}
+	for _, persona := range BuiltIn() {
+		if persona.PersonaId == personaId {
+			versions = append(versions, persona)
+		}
+	}
+	builtInVersion := len(versions) > 0
+	for _, persona := range stored {
+		if persona.PersonaId == personaId {
+			persona.BuiltIn = builtInVersion
+			versions = append(versions, persona)
+		}
+	}
+	slices.SortFunc(versions, func(a, b models.Persona) int { return a.Version - b.Version })
+	return versions
+}
+
+// Find looks up a persona by ID
+func Find(library []models.Persona, personaId string) *models.Persona {
+	for i := range library {
+		if library[i].PersonaId == personaId {
+			return &library[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+// Package personas holds the companion chat personas and turns them into system prompts
+package personas
+
+import (
+	"fmt"
+	"strings"
+
+	"lambda-server/constants"
+	"lambda-server/models"
+)
+
+// safetyRules are part of every persona's instructions and cannot be removed by admins
+var safetyRules = []string{
+	"You are not a therapist or a doctor: do not diagnose conditions or recommend medication.",
+	"If the user may be in danger or thinking about harming themselves or others, respond with care, " +
+		"encourage them to contact local emergency services or a crisis line now, and suggest reaching out to someone they trust.",
+	"Never claim to be human.",
+}
+
+// lengthInstructions tell the model how long replies of each length should be
+var lengthInstructions = map[string]string{
+	constants.PersonaLengthBrief:    "Keep replies short: two to four sentences.",
+	constants.PersonaLengthBalanced: "Keep replies concise: one or two short paragraphs.",
+	constants.PersonaLengthDetailed: "Give thorough replies when it helps, using short paragraphs or lists.",
+}
+
+// SystemPrompt builds the full system message for a persona: its prompt, the reply style,
+// and the safety rules followed by the persona's own guardrails
+func SystemPrompt(persona models.Persona) string {
+	var prompt strings.Builder
+	prompt.WriteString(strings.TrimSpace(persona.SystemPrompt))
+	if instruction := lengthInstructions[persona.Style.Length]; instruction != "" {
+		prompt.WriteString("\n\n" + instruction)
+	}
+	prompt.WriteString("\n\nAlways follow these rules:")
+	for _, rule := range append(append([]string(nil), safetyRules...), persona.Guardrails...) {
+		prompt.WriteString("\n- " + rule)
+	}
+	return prompt.String()
+}
+
+// Validate checks a persona request and returns it trimmed
+func Validate(req models.PersonaRequest) (models.PersonaRequest, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Description = strings.TrimSpace(req.Description)
+	req.SystemPrompt = strings.TrimSpace(req.SystemPrompt)
+	if req.Name == "" || req.SystemPrompt == "" {
+		return req, fmt.Errorf("name and systemPrompt cannot be empty")
+	}
+	if len([]rune(req.SystemPrompt)) > constants.PersonaMaxPromptLength {
+		return req, fmt.Errorf("systemPrompt is longer than %d characters", constants.PersonaMaxPromptLength)
+	}
+	if len(req.Guardrails) > constants.PersonaMaxGuardrails {
+		return req, fmt.Errorf("at most %d guardrails are allowed", constants.PersonaMaxGuardrails)
+	}
+	guardrails := []string{}
+	for _, rule := range req.Guardrails {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			return req, fmt.Errorf("guardrails cannot be empty")
+		}
+		if len([]rune(rule)) > constants.PersonaMaxRuleLength {
+			return req, fmt.Errorf("guardrails must be at most %d characters", constants.PersonaMaxRuleLength)
+		}
+		guardrails = append(guardrails, rule)
+	}
+	req.Guardrails = guardrails
+
+	style := req.Style
+	if style.Length == "" {
+		style.Length = constants.PersonaLengthBalanced
+	}
+	if _, ok := lengthInstructions[style.Length]; !ok {
+		return req, fmt.Errorf("style.length must be one of %s", strings.Join(constants.PersonaLengths, ", "))
+	}
+	if style.Temperature != nil && (*style.Temperature < 0 || *style.Temperature > 2) {
+		return req, fmt.Errorf("style.temperature must be between 0 and 2")
+	}
+	if style.MaxTokens < 0 || style.MaxTokens > constants.PersonaMaxTokens {
+		return req, fmt.Errorf("style.maxTokens must be between 0 and %d", constants.PersonaMaxTokens)
+	}
+	req.Style = style
+	return req, nil
+}
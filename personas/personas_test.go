@@ -0,0 +1,79 @@
+package personas
+
+import (
+	"strings"
+	"testing"
+
+	"lambda-server/constants"
+	"lambda-server/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatestKeepsNewestVersion(t *testing.T) {
+	library := Latest([]models.Persona{
+		{PersonaId: "cbt-coach", Version: 3, Name: "CBT coach v3", Active: true},
+		{PersonaId: "cbt-coach", Version: 2, Name: "CBT coach v2", Active: true},
+		{PersonaId: "custom", Version: 1, Name: "Custom", Active: true},
+		{PersonaId: "custom", Version: 2, Name: "Custom v2", Active: false},
+	})
+	assert.Len(t, library, len(BuiltIn())+1)
+
+	coach := Find(library, "cbt-coach")
+	require.NotNil(t, coach)
+	assert.Equal(t, 3, coach.Version)
+	assert.True(t, coach.BuiltIn)
+
+	custom := Find(library, "custom")
+	require.NotNil(t, custom)
+	assert.Equal(t, "Custom v2", custom.Name)
+	assert.False(t, custom.Active)
+	assert.False(t, custom.BuiltIn)
+}
+
+func TestVersions(t *testing.T) {
+	versions := Versions("cbt-coach", []models.Persona{
+		{PersonaId: "cbt-coach", Version: 3},
+		{PersonaId: "other", Version: 5},
+		{PersonaId: "cbt-coach", Version: 2},
+	})
+	require.Len(t, versions, 3)
+	for i, persona := range versions {
+		assert.Equal(t, i+1, persona.Version)
+		assert.True(t, persona.BuiltIn)
+	}
+	assert.Empty(t, Versions("missing", nil))
+}
+
+func TestSystemPromptIncludesSafetyRulesAndGuardrails(t *testing.T) {
+	prompt := SystemPrompt(models.Persona{
+		SystemPrompt: "  Be kind.  ",
+		Guardrails:   []string{"Ask one question at a time."},
+		Style:        models.PersonaStyle{Length: constants.PersonaLengthBrief},
+	})
+	assert.True(t, strings.HasPrefix(prompt, "Be kind.\n\nKeep replies short"))
+	assert.Contains(t, prompt, "- You are not a therapist")
+	assert.True(t, strings.HasSuffix(prompt, "\n- Ask one question at a time."))
+}
+
+func TestValidate(t *testing.T) {
+	req, err := Validate(models.PersonaRequest{Name: " Coach ", SystemPrompt: "Help.", Guardrails: []string{" Be brief "}})
+	require.NoError(t, err)
+	assert.Equal(t, "Coach", req.Name)
+	assert.Equal(t, []string{"Be brief"}, req.Guardrails)
+	assert.Equal(t, constants.PersonaLengthBalanced, req.Style.Length)
+
+	hot := 2.5
+	for _, bad := range []models.PersonaRequest{
+		{Name: "x", SystemPrompt: "  "},
+		{Name: "x", SystemPrompt: "y", Guardrails: []string{""}},
+		{Name: "x", SystemPrompt: "y", Style: models.PersonaStyle{Length: "epic"}},
+		{Name: "x", SystemPrompt: "y", Style: models.PersonaStyle{Temperature: &hot}},
+		{Name: "x", SystemPrompt: "y", Style: models.PersonaStyle{MaxTokens: -1}},
+		{Name: "x", SystemPrompt: strings.Repeat("y", constants.PersonaMaxPromptLength+1)},
+	} {
+		_, err := Validate(bad)
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,105 @@
+package prompts
+
+import (
+	"lambda-server/constants"
+	"lambda-server/models"
+)
+
+// builtIn is the prompt library shipped with the app. Admins can override or deactivate
+// these prompts by saving a prompt with the same ID.
+var builtIn = []struct {
+	id       string
+	category string
+	texts    map[string]string
+}{
+	{"cbt-evidence", constants.PromptCategoryCBT, map[string]string{
+		"en": "Write down a thought that has been weighing on you. What evidence supports it, and what evidence doesn't?",
+		"es": "Escribe un pensamiento que te haya pesado. ¿Qué pruebas lo apoyan y cuáles no?",
+	}},
+	{"cbt-friend", constants.PromptCategoryCBT, map[string]string{
+		"en": "If a close friend were in your situation today, what would you tell them?",
+		"es": "Si un buen amigo estuviera hoy en tu situación, ¿qué le dirías?",
+	}},
+	{"cbt-reframe", constants.PromptCategoryCBT, map[string]string{
+		"en": "Describe something that went wrong recently. Is there another way to look at it?",
+		"es": "Describe algo que salió mal hace poco. ¿Hay otra forma de verlo?",
+	}},
+	{"cbt-small-step", constants.PromptCategoryCBT, map[string]string{
+		"en": "What is one small, doable step that would make tomorrow a little easier?",
+		"es": "¿Qué pequeño paso, fácil de dar, haría que mañana fuera un poco más fácil?",
+	}},
+	{"cbt-worry-time", constants.PromptCategoryCBT, map[string]string{
+		"en": "List what you are worried about right now. Which of these can you influence, and which can you let go of?",
+		"es": "Haz una lista de lo que te preocupa ahora. ¿Sobre qué puedes influir y qué puedes soltar?",
+	}},
+	{"gratitude-three-things", constants.PromptCategoryGratitude, map[string]string{
+		"en": "Name three things that went well today and why they mattered to you.",
+		"es": "Nombra tres cosas que salieron bien hoy y por qué te importaron.",
+	}},
+	{"gratitude-person", constants.PromptCategoryGratitude, map[string]string{
+		"en": "Who made a difference in your life recently? What would you like to thank them for?",
+		"es": "¿Quién marcó una diferencia en tu vida últimamente? ¿Qué te gustaría agradecerle?",
+	}},
+	{"gratitude-senses", constants.PromptCategoryGratitude, map[string]string{
+		"en": "What is something you saw, heard or tasted today that you enjoyed?",
+		"es": "¿Qué viste, escuchaste o probaste hoy que disfrutaste?",
+	}},
+	{"gratitude-self", constants.PromptCategoryGratitude, map[string]string{
+		"en": "What is something about yourself you are grateful for this week?",
+		"es": "¿Qué aprecias de ti mismo esta semana?",
+	}},
+	{"reflection-energy", constants.PromptCategoryReflection, map[string]string{
+		"en": "What gave you energy today, and what drained it?",
+		"es": "¿Qué te dio energía hoy y qué te la quitó?",
+	}},
+	{"reflection-learned", constants.PromptCategoryReflection, map[string]string{
+		"en": "What is something you learned about yourself this week?",
+		"es": "¿Qué aprendiste sobre ti esta semana?",
+	}},
+	{"reflection-future-self", constants.PromptCategoryReflection, map[string]string{
+		"en": "Write a short note to yourself one year from now.",
+		"es": "Escribe una nota breve para tu yo de dentro de un año.",
+	}},
+	{"reflection-values", constants.PromptCategoryReflection, map[string]string{
+		"en": "When did you feel most like yourself recently? What were you doing?",
+		"es": "¿Cuándo te sentiste más tú mismo últimamente? ¿Qué estabas haciendo?",
+	}},
+}
+
+// BuiltIn returns a fresh copy of the built-in prompt library
+func BuiltIn() []models.Prompt {
+	library := make([]models.Prompt, 0, len(builtIn))
+	for _, p := range builtIn {
+		texts := make(map[string]string, len(p.texts))
+		for locale, text := range p.texts {
+			texts[locale] = text
+		}
+		library = append(library, models.Prompt{
+			PromptId: p.id,
+			Category: p.category,
+			Texts:    texts,
+			Active:   true,
+			BuiltIn:  true,
+		})
+	}
+	return library
+}
+
+// Merge combines the built-in library with the stored prompts; a stored prompt replaces
+// the built-in prompt with the same ID
+func Merge(stored []models.Prompt) []models.Prompt {
+	byId := map[string]int{}
+	library := BuiltIn()
+	for i, prompt := range library {
+		byId[prompt.PromptId] = i
+	}
+	for _, prompt := range stored {
+		if i, ok := byId[prompt.PromptId]; ok {
+			prompt.BuiltIn = true
+			library[i] = prompt
+			continue
+		}
+		library = append(library, prompt)
+	}
+	return library
+}
@@ -0,0 +1,110 @@
+// Package prompts holds the built-in journaling prompt library and picks a user's prompt of the day.
+package prompts
+
+import (
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"lambda-server/constants"
+	"lambda-server/models"
+)
+
+// Signals summarises how a user has been doing recently
+type Signals struct {
+	AverageMood float64 // Average journal mood (1-5) over the lookback window, 0 if none was recorded
+	Score       float64 // Latest MindMuse score
+	HasScore    bool
+}
+
+// PreferredCategory returns the prompt category that best fits the user's recent mood and score.
+// Low moods or a low score lead to supportive CBT prompts, good moods to gratitude, anything else to reflection.
+func PreferredCategory(signals Signals) string {
+	lowMood := signals.AverageMood > 0 && signals.AverageMood <= constants.PromptLowMood
+	lowScore := signals.HasScore && signals.Score < constants.PromptLowScore
+	if lowMood || lowScore {
+		return constants.PromptCategoryCBT
+	}
+	if signals.AverageMood >= constants.PromptHighMood {
+		return constants.PromptCategoryGratitude
+	}
+	return constants.PromptCategoryReflection
+}
+
+// Select picks the prompt of the day from the active prompts.
+// Prompts in recent are skipped; the preferred category is tried first, then the others.
+// When every prompt has been used recently the whole library is eligible again.
+// The pick is deterministic for a given seed (e.g. user and date), so repeated calls agree.
+func Select(library []models.Prompt, signals Signals, recent map[string]bool, seed string) *models.Prompt {
+	active := []models.Prompt{}
+	for _, prompt := range library {
+		if prompt.Active {
+			active = append(active, prompt)
+		}
+	}
+	if len(active) == 0 {
+		return nil
+	}
+	// Sort so the pick does not depend on storage order
+	sort.Slice(active, func(i, j int) bool { return active[i].PromptId < active[j].PromptId })
+
+	preferred := PreferredCategory(signals)
+	candidates := filter(active, func(p models.Prompt) bool { return p.Category == preferred && !recent[p.PromptId] })
+	if len(candidates) == 0 {
+		candidates = filter(active, func(p models.Prompt) bool { return !recent[p.PromptId] })
+	}
+	if len(candidates) == 0 {
+		candidates = filter(active, func(p models.Prompt) bool { return p.Category == preferred })
+	}
+	if len(candidates) == 0 {
+		candidates = active
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(seed))
+	return &candidates[h.Sum32()%uint32(len(candidates))]
+}
+
+// Localize resolves a prompt's text for a locale, falling back from "pt-BR" to "pt",
+// then to the default locale, then to any available text
+func Localize(prompt models.Prompt, locale string) models.LocalizedPrompt {
+	localized := models.LocalizedPrompt{PromptId: prompt.PromptId, Category: prompt.Category}
+	for _, candidate := range localeFallbacks(locale) {
+		if text, ok := prompt.Texts[candidate]; ok && text != "" {
+			localized.Text, localized.Locale = text, candidate
+			return localized
+		}
+	}
+	// Any text is better than none; pick the smallest key so the result is stable
+	keys := make([]string, 0, len(prompt.Texts))
+	for key := range prompt.Texts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 {
+		localized.Text, localized.Locale = prompt.Texts[keys[0]], keys[0]
+	}
+	return localized
+}
+
+// localeFallbacks lists the locales to try for a requested locale, most specific first
+func localeFallbacks(locale string) []string {
+	fallbacks := []string{}
+	if locale != "" {
+		fallbacks = append(fallbacks, locale)
+		if base, _, found := strings.Cut(locale, "-"); found {
+			fallbacks = append(fallbacks, base)
+		}
+	}
+	return append(fallbacks, constants.PromptDefaultLocale)
+}
+
+func filter(prompts []models.Prompt, keep func(models.Prompt) bool) []models.Prompt {
+	kept := []models.Prompt{}
+	for _, prompt := range prompts {
+		if keep(prompt) {
+			kept = append(kept, prompt)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,74 @@
+package prompts
+
+import (
+	"fmt"
+	"testing"
+
+	"lambda-server/constants"
+	"lambda-server/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreferredCategory(t *testing.T) {
+	assert.Equal(t, constants.PromptCategoryReflection, PreferredCategory(Signals{}))
+	assert.Equal(t, constants.PromptCategoryCBT, PreferredCategory(Signals{AverageMood: 2}))
+	assert.Equal(t, constants.PromptCategoryGratitude, PreferredCategory(Signals{AverageMood: 4.5}))
+	// A low score outweighs a good mood
+	assert.Equal(t, constants.PromptCategoryCBT, PreferredCategory(Signals{AverageMood: 4.5, Score: 20, HasScore: true}))
+	assert.Equal(t, constants.PromptCategoryReflection, PreferredCategory(Signals{AverageMood: 3, Score: 80, HasScore: true}))
+}
+
+func TestSelectSkipsRecentPrompts(t *testing.T) {
+	library := BuiltIn()
+	signals := Signals{AverageMood: 1.5}
+
+	recent := map[string]bool{}
+	for i := 0; i < len(library); i++ {
+		prompt := Select(library, signals, recent, fmt.Sprintf("user-1:day-%d", i))
+		assert.NotNil(t, prompt)
+		assert.False(t, recent[prompt.PromptId], "prompt %s repeated", prompt.PromptId)
+		recent[prompt.PromptId] = true
+	}
+	// Once everything was shown, the library is eligible again
+	assert.NotNil(t, Select(library, signals, recent, "user-1:20261101"))
+}
+
+func TestSelectPrefersCategoryAndIsDeterministic(t *testing.T) {
+	library := BuiltIn()
+	signals := Signals{AverageMood: 4.2}
+
+	first := Select(library, signals, nil, "user-1:20261019")
+	assert.Equal(t, constants.PromptCategoryGratitude, first.Category)
+	assert.Equal(t, first.PromptId, Select(library, signals, nil, "user-1:20261019").PromptId)
+}
+
+func TestSelectIgnoresInactivePrompts(t *testing.T) {
+	library := []models.Prompt{
+		{PromptId: "a", Category: constants.PromptCategoryCBT, Active: false},
+		{PromptId: "b", Category: constants.PromptCategoryReflection, Active: true},
+	}
+	assert.Equal(t, "b", Select(library, Signals{AverageMood: 1}, nil, "seed").PromptId)
+	assert.Nil(t, Select(library[:1], Signals{}, nil, "seed"))
+}
+
+func TestLocalizeFallsBack(t *testing.T) {
+	prompt := models.Prompt{PromptId: "p", Texts: map[string]string{"en": "Hello", "pt": "Olá"}}
+	assert.Equal(t, "pt", Localize(prompt, "pt-BR").Locale)
+	assert.Equal(t, "Hello", Localize(prompt, "fr").Text)
+	assert.Equal(t, "Olá", Localize(models.Prompt{Texts: map[string]string{"pt": "Olá"}}, "fr").Text)
+}
+
+func TestMergeOverridesBuiltIn(t *testing.T) {
+	merged := Merge([]models.Prompt{
+		{PromptId: "cbt-friend", Category: constants.PromptCategoryCBT, Active: false},
+		{PromptId: "custom", Category: constants.PromptCategoryReflection, Active: true},
+	})
+	assert.Len(t, merged, len(BuiltIn())+1)
+	for _, prompt := range merged {
+		if prompt.PromptId == "cbt-friend" {
+			assert.False(t, prompt.Active)
+			assert.True(t, prompt.BuiltIn)
+		}
+	}
+}
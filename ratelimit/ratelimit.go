@@ -0,0 +1,210 @@
+// Package ratelimit limits how fast and how much each user may chat: a token bucket per user
+// smooths bursts of requests, and daily quotas cap messages and LLM tokens per plan
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"lambda-server/constants"
+	"lambda-server/models"
+)
+
+// Bucket is a token bucket: it holds up to Capacity tokens and refills at RefillPerSecond
+type Bucket struct {
+	Capacity        float64
+	RefillPerSecond float64
+}
+
+// State is the stored state of one bucket
+type State struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// Result is the outcome of taking from a bucket
+type Result struct {
+	Allowed    bool
+	Remaining  int           // Whole tokens left after this request
+	RetryAfter time.Duration // How long until the request would be allowed; zero when allowed
+	Reset      time.Duration // How long until the bucket is full again
+}
+
+// ErrContention is returned by a Store that could not update a bucket because other requests
+// kept updating it first. Only a burst of requests on the same key causes it, so the request
+// is refused like one over the limit.
+var ErrContention = errors.New("rate limit is under contention")
+
+// memorySweepInterval is how often MemoryStore drops buckets that have refilled
+const memorySweepInterval = time.Minute
+
+// Store keeps buckets and takes tokens from them atomically
+type Store interface {
+	Take(ctx context.Context, key string, bucket Bucket, cost float64) (Result, error)
+}
+
+// PerMinute returns a bucket allowing requestsPerMinute on average and burst at once
+func PerMinute(requestsPerMinute, burst int) Bucket {
+	if burst <= 0 {
+		burst = max(requestsPerMinute, 1)
+	}
+	return Bucket{Capacity: float64(burst), RefillPerSecond: float64(requestsPerMinute) / 60}
+}
+
+// Take refills a bucket's state up to now and takes cost tokens from it if it holds enough.
+// A nil state is a new, full bucket. The returned state is unchanged apart from the refill
+// when the request is not allowed.
+func (b Bucket) Take(state *State, now time.Time, cost float64) (State, Result) {
+	tokens := b.Capacity
+	if state != nil {
+		elapsed := max(now.Sub(state.UpdatedAt).Seconds(), 0)
+		tokens = math.Min(b.Capacity, state.Tokens+elapsed*b.RefillPerSecond)
+	}
+	next := State{Tokens: tokens, UpdatedAt: now}
+	result := Result{Allowed: tokens >= cost}
+	if result.Allowed {
+		next.Tokens -= cost
+	} else {
+		result.RetryAfter = b.wait(cost - tokens)
+	}
+	result.Remaining = int(math.Floor(next.Tokens))
+	result.Reset = b.wait(b.Capacity - next.Tokens)
+	return next, result
+}
+
+// wait returns how long the bucket takes to refill the given number of tokens
+func (b Bucket) wait(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	if b.RefillPerSecond <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(math.Ceil(tokens / b.RefillPerSecond * float64(time.Second)))
+}
+
+// MemoryStore keeps buckets in process memory, for local runs and tests. Limits are per
+// process, so it must not be used where requests are spread over several instances.
+// Buckets that have refilled are dropped, as a full bucket is the same as a missing one.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]memoryBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// memoryBucket is a bucket's state and when it is full again
+type memoryBucket struct {
+	state  State
+	fullAt time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]memoryBucket{}, now: time.Now}
+}
+
+// Take takes cost tokens from the bucket under key
+func (s *MemoryStore) Take(ctx context.Context, key string, bucket Bucket, cost float64) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.sweep(now)
+	var state *State
+	if stored, ok := s.buckets[key]; ok {
+		state = &stored.state
+	}
+	next, result := bucket.Take(state, now, cost)
+	s.buckets[key] = memoryBucket{state: next, fullAt: now.Add(result.Reset)}
+	return result, nil
+}
+
+// sweep drops the buckets that are full again, at most once per memorySweepInterval
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memorySweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, stored := range s.buckets {
+		if !now.Before(stored.fullAt) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// planLimits are the built-in limits of each plan
+var planLimits = map[string]models.ChatLimits{
+	constants.PlanFree: {RequestsPerMinute: 6, Burst: 5, DailyMessages: 50, DailyTokens: 100_000},
+	constants.PlanPlus: {RequestsPerMinute: 20, Burst: 10, DailyMessages: 500, DailyTokens: 1_000_000},
+	constants.PlanPro:  {RequestsPerMinute: 60, Burst: 20, DailyMessages: 2_000, DailyTokens: 5_000_000},
+}
+
+// Plan returns the plan a user is on; users without a known plan are on the free plan
+func Plan(user *models.User) string {
+	if user != nil {
+		if _, ok := planLimits[user.Plan]; ok {
+			return user.Plan
+		}
+	}
+	return constants.PlanFree
+}
+
+// Limits returns the effective chat limits of a user: their plan's, with any admin override
+// applied. Negative limits mean unlimited.
+func Limits(user *models.User) models.ChatLimits {
+	limits := planLimits[Plan(user)]
+	if user == nil || user.ChatLimitsOverride == nil {
+		return limits
+	}
+	override := *user.ChatLimitsOverride
+	limits.RequestsPerMinute = overrideLimit(limits.RequestsPerMinute, override.RequestsPerMinute)
+	limits.Burst = overrideLimit(limits.Burst, override.Burst)
+	limits.DailyMessages = overrideLimit(limits.DailyMessages, override.DailyMessages)
+	limits.DailyTokens = overrideLimit(limits.DailyTokens, override.DailyTokens)
+	return limits
+}
+
+func overrideLimit(plan, override int) int {
+	if override == 0 {
+		return plan
+	}
+	return override
+}
+
+// Quota is the state of a user's daily quotas
+type Quota struct {
+	MessagesLeft int // Negative when unlimited
+	TokensLeft   int // Negative when unlimited
+	Exceeded     bool
+	ResetsAt     time.Time // Next midnight UTC
+}
+
+// CheckQuota compares a user's usage today with their daily limits. The current message
+// counts against the message quota; the token quota only needs tokens left, since the
+// cost of the reply is not known in advance.
+func CheckQuota(limits models.ChatLimits, usage models.DailyUsage, now time.Time) Quota {
+	quota := Quota{MessagesLeft: -1, TokensLeft: -1, ResetsAt: NextReset(now)}
+	if limits.DailyMessages >= 0 {
+		quota.MessagesLeft = max(limits.DailyMessages-usage.Messages, 0)
+		quota.Exceeded = quota.MessagesLeft == 0
+	}
+	if limits.DailyTokens >= 0 {
+		quota.TokensLeft = max(limits.DailyTokens-usage.Tokens(), 0)
+		quota.Exceeded = quota.Exceeded || quota.TokensLeft == 0
+	}
+	return quota
+}
+
+// Day returns the UTC date quotas are counted under, as YYYYMMDD
+func Day(now time.Time) string {
+	return now.UTC().Format("20060102")
+}
+
+// NextReset returns the next midnight UTC, when daily quotas reset
+func NextReset(now time.Time) time.Time {
+	year, month, day := now.UTC().Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"lambda-server/constants"
+	"lambda-server/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketTake(t *testing.T) {
+	bucket := PerMinute(6, 2) // One token every 10 seconds, two at once
+	now := time.Unix(1000, 0)
+
+	state, result := bucket.Take(nil, now, 1)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 1, result.Remaining)
+	assert.Equal(t, 10*time.Second, result.Reset)
+
+	state, result = bucket.Take(&state, now, 1)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+
+	state, result = bucket.Take(&state, now.Add(4*time.Second), 1)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 6*time.Second, result.RetryAfter)
+	assert.InDelta(t, 0.4, state.Tokens, 1e-9, "refill is kept, nothing is taken")
+
+	_, result = bucket.Take(&state, now.Add(10*time.Second), 1)
+	assert.True(t, result.Allowed)
+
+	state = State{Tokens: 0, UpdatedAt: now}
+	state, _ = bucket.Take(&state, now.Add(time.Hour), 0)
+	assert.Equal(t, 2.0, state.Tokens, "refill stops at capacity")
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Unix(1000, 0)
+	store.now = func() time.Time { return now }
+	bucket := PerMinute(60, 1)
+	ctx := context.Background()
+
+	result, err := store.Take(ctx, "a", bucket, 1)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	result, _ = store.Take(ctx, "a", bucket, 1)
+	assert.False(t, result.Allowed)
+	result, _ = store.Take(ctx, "b", bucket, 1)
+	assert.True(t, result.Allowed, "buckets are per key")
+
+	now = now.Add(time.Second)
+	result, _ = store.Take(ctx, "a", bucket, 1)
+	assert.True(t, result.Allowed)
+}
+
+func TestMemoryStoreDropsRefilledBuckets(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Unix(1000, 0)
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	_, err := store.Take(ctx, "idle", PerMinute(60, 1), 1)
+	require.NoError(t, err)
+	_, err = store.Take(ctx, "slow", PerMinute(1, 5), 5)
+	require.NoError(t, err)
+	assert.Len(t, store.buckets, 2)
+
+	now = now.Add(2 * time.Minute)
+	result, err := store.Take(ctx, "busy", PerMinute(60, 1), 1)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Contains(t, store.buckets, "slow", "still refilling")
+	assert.NotContains(t, store.buckets, "idle", "full again, so dropped")
+
+	result, _ = store.Take(ctx, "slow", PerMinute(1, 5), 5)
+	assert.False(t, result.Allowed, "a kept bucket keeps its state")
+}
+
+func TestLimits(t *testing.T) {
+	assert.Equal(t, planLimits[constants.PlanFree], Limits(nil))
+	assert.Equal(t, constants.PlanFree, Plan(&models.User{Plan: "gold"}))
+
+	user := &models.User{
+		Plan:               constants.PlanPlus,
+		ChatLimitsOverride: &models.ChatLimits{DailyMessages: -1, DailyTokens: 42},
+	}
+	limits := Limits(user)
+	assert.Equal(t, planLimits[constants.PlanPlus].RequestsPerMinute, limits.RequestsPerMinute)
+	assert.Equal(t, -1, limits.DailyMessages)
+	assert.Equal(t, 42, limits.DailyTokens)
+}
+
+func TestCheckQuota(t *testing.T) {
+	now := time.Date(2026, 3, 4, 15, 0, 0, 0, time.UTC)
+	limits := models.ChatLimits{DailyMessages: 10, DailyTokens: 1000}
+
+	quota := CheckQuota(limits, models.DailyUsage{Messages: 9, PromptTokens: 600, CompletionTokens: 100}, now)
+	assert.False(t, quota.Exceeded)
+	assert.Equal(t, 1, quota.MessagesLeft)
+	assert.Equal(t, 300, quota.TokensLeft)
+	assert.Equal(t, time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC), quota.ResetsAt)
+
+	assert.True(t, CheckQuota(limits, models.DailyUsage{Messages: 10}, now).Exceeded)
+	assert.True(t, CheckQuota(limits, models.DailyUsage{PromptTokens: 1200}, now).Exceeded)
+
+	unlimited := CheckQuota(models.ChatLimits{DailyMessages: -1, DailyTokens: -1}, models.DailyUsage{Messages: 1e6}, now)
+	assert.False(t, unlimited.Exceeded)
+	assert.Equal(t, -1, unlimited.MessagesLeft)
+	assert.Equal(t, "20260304", Day(now))
+}
@@ -0,0 +1,104 @@
+package realtime
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// ErrGone is returned when posting to a connection the client has closed
+var ErrGone = errors.New("realtime: connection is gone")
+
+// ConnectionPoster sends messages to the clients of an API Gateway WebSocket API through the
+// API's @connections endpoint, signing each request with the function's credentials
+type ConnectionPoster struct {
+	Endpoint    string // e.g. https://{api-id}.execute-api.{region}.amazonaws.com/{stage}
+	Region      string
+	Credentials aws.CredentialsProvider
+	Client      *http.Client
+}
+
+// signer signs requests to @connections endpoints; it is safe for concurrent use
+var signer = v4.NewSigner()
+
+// NewConnectionPoster returns a poster for the endpoint using the default AWS configuration
+func NewConnectionPoster(ctx context.Context, endpoint string) (*ConnectionPoster, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	region := cfg.Region
+	if region == "" {
+		region = "ap-south-1" // The region the rest of the backend runs in
+	}
+	return &ConnectionPoster{
+		Endpoint:    endpoint,
+		Region:      region,
+		Credentials: cfg.Credentials,
+		Client:      &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// CallbackURL returns the @connections endpoint of the API a WebSocket event came through
+func CallbackURL(domainName, stage string) string {
+	return "https://" + domainName + "/" + stage
+}
+
+// Post sends data to a connection. It returns ErrGone when the client has disconnected.
+func (p *ConnectionPoster) Post(ctx context.Context, connectionId string, data []byte) error {
+	target := strings.TrimSuffix(p.Endpoint, "/") + "/@connections/" + url.PathEscape(connectionId)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to build connection request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := p.sign(ctx, req, data); err != nil {
+		return err
+	}
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to connection: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusGone {
+		return ErrGone
+	}
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("posting to connection failed with status %d: %s", resp.StatusCode, body)
+	}
+	return nil
+}
+
+// sign adds a Signature Version 4 authorization for the execute-api service
+func (p *ConnectionPoster) sign(ctx context.Context, req *http.Request, body []byte) error {
+	if p.Credentials == nil {
+		return errors.New("no AWS credentials to sign the connection request")
+	}
+	creds, err := p.Credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get AWS credentials: %w", err)
+	}
+	hash := sha256.Sum256(body)
+	if err := signer.SignHTTP(ctx, creds, req, hex.EncodeToString(hash[:]), "execute-api", p.Region, time.Now()); err != nil {
+		return fmt.Errorf("failed to sign connection request: %w", err)
+	}
+	return nil
+}
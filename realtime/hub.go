@@ -0,0 +1,66 @@
+package realtime
+
+import "sync"
+
+// Hub tracks the connections open on this instance, by user, through their queues
+type Hub struct {
+	mu    sync.Mutex
+	users map[string]map[*Queue]bool
+}
+
+// NewHub returns an empty hub
+func NewHub() *Hub {
+	return &Hub{users: map[string]map[*Queue]bool{}}
+}
+
+// Add registers a connection of the user
+func (h *Hub) Add(userId string, queue *Queue) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.users[userId] == nil {
+		h.users[userId] = map[*Queue]bool{}
+	}
+	h.users[userId][queue] = true
+}
+
+// Remove forgets a connection of the user
+func (h *Hub) Remove(userId string, queue *Queue) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.users[userId], queue)
+	if len(h.users[userId]) == 0 {
+		delete(h.users, userId)
+	}
+}
+
+// Users returns the users with an open connection
+func (h *Hub) Users() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	users := make([]string, 0, len(h.users))
+	for userId := range h.users {
+		users = append(users, userId)
+	}
+	return users
+}
+
+// Send offers an event to each of the user's connections other than skip, which may be nil,
+// and returns how many queued it. Connections too far behind miss the event.
+func (h *Hub) Send(userId string, event Event, skip *Queue) int {
+	h.mu.Lock()
+	queues := make([]*Queue, 0, len(h.users[userId]))
+	for queue := range h.users[userId] {
+		if queue != skip {
+			queues = append(queues, queue)
+		}
+	}
+	h.mu.Unlock()
+
+	sent := 0
+	for _, queue := range queues {
+		if queue.Offer(event) {
+			sent++
+		}
+	}
+	return sent
+}
@@ -0,0 +1,178 @@
+// Package realtime defines the messages of the realtime chat connection and the pieces its two
+// transports share: a WebSocket served by the local server, and an API Gateway WebSocket API in
+// front of the Lambda deployment. Every message is a JSON object with a "type".
+package realtime
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"lambda-server/constants"
+)
+
+// Messages sent by the client
+const (
+	TypeChat   = "chat"   // Send a chat message and stream the reply
+	TypeCancel = "cancel" // Stop generating the reply to an earlier chat message
+	TypeTyping = "typing" // The user started or stopped typing
+	TypePing   = "ping"   // Application heartbeat, answered with pong
+)
+
+// Events sent by the server. A chat message's reply streams as the events of POST /chat/stream
+// (crisis, token, moderated, done and error), each carrying the id of the chat message.
+const (
+	EventReady        = "ready"        // The connection is open; data is Ready
+	EventPong         = "pong"         // Answers ping
+	EventTyping       = "typing"       // Someone is typing; data is Typing
+	EventCancelled    = "cancelled"    // Generation stopped before any of the reply was stored
+	EventNotification = "notification" // Pushed by the server; data is Notification
+	EventError        = constants.ChatEventError
+)
+
+// Sources of typing events
+const (
+	TypingUser      = "user"      // The user, on another of their devices
+	TypingAssistant = "assistant" // The AI is writing a reply
+)
+
+// AuthProtocol is the WebSocket subprotocol a client offers ahead of its access token, as in
+// new WebSocket(url, ["bearer", accessToken]), since browsers cannot add an Authorization
+// header to the handshake. The server selects it, so the token is never echoed back.
+const AuthProtocol = "bearer"
+
+// Notification kinds
+const (
+	NotificationMoodCheckIn     = "mood_checkin"
+	NotificationSOSAcknowledged = "sos_acknowledged"
+)
+
+// ClientMessage is a message from the client
+type ClientMessage struct {
+	Type      string `json:"type"`
+	Id        string `json:"id,omitempty"` // Chosen by the client; events about a chat message carry it
+	SessionId string `json:"sessionId,omitempty"`
+	Message   string `json:"message,omitempty"`
+	Model     string `json:"model,omitempty"`
+	PersonaId string `json:"personaId,omitempty"`
+	Typing    bool   `json:"typing,omitempty"`
+}
+
+// Event is a message from the server
+type Event struct {
+	Type string `json:"type"`
+	Id   string `json:"id,omitempty"` // The chat message the event is about
+	Data any    `json:"data,omitempty"`
+}
+
+// Ready describes a newly opened connection
+type Ready struct {
+	ConnectionId     string `json:"connectionId,omitempty"`
+	HeartbeatSeconds int    `json:"heartbeatSeconds"` // Clients should ping at least this often
+}
+
+// Typing tells the client someone started or stopped typing in a session
+type Typing struct {
+	SessionId string `json:"sessionId"`
+	From      string `json:"from"` // TypingUser or TypingAssistant
+	Typing    bool   `json:"typing"`
+}
+
+// Token is a piece of a streamed reply
+type Token struct {
+	Content string `json:"content"`
+}
+
+// Notification is pushed by the server outside of any reply
+type Notification struct {
+	Kind  string `json:"kind"`
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	At    int64  `json:"at"`
+}
+
+// ErrorData describes a failed client message
+type ErrorData struct {
+	Error   string `json:"error"`
+	Details string `json:"details,omitempty"`
+}
+
+// ParseClientMessage decodes and validates a message from the client
+func ParseClientMessage(data []byte) (ClientMessage, error) {
+	var msg ClientMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return msg, fmt.Errorf("invalid message: %w", err)
+	}
+	msg.Id = strings.TrimSpace(msg.Id)
+	switch msg.Type {
+	case TypeChat:
+		if msg.Id == "" || msg.SessionId == "" || strings.TrimSpace(msg.Message) == "" {
+			return msg, errors.New("chat needs an id, a sessionId and a message")
+		}
+	case TypeCancel:
+		if msg.Id == "" {
+			return msg, errors.New("cancel needs the id of the chat message")
+		}
+	case TypeTyping:
+		if msg.SessionId == "" {
+			return msg, errors.New("typing needs a sessionId")
+		}
+	case TypePing:
+	default:
+		return msg, fmt.Errorf("unknown message type %q", msg.Type)
+	}
+	return msg, nil
+}
+
+// ErrorEvent reports a failed client message
+func ErrorEvent(id, message string, err error) Event {
+	data := ErrorData{Error: message}
+	if err != nil {
+		data.Details = err.Error()
+	}
+	return Event{Type: EventError, Id: id, Data: data}
+}
+
+// MoodCheckIn is the notification reminding a user to log how they feel today
+func MoodCheckIn(now time.Time) Event {
+	return Event{Type: EventNotification, Data: Notification{
+		Kind:  NotificationMoodCheckIn,
+		Title: "How are you feeling?",
+		Body:  "Take a moment to check in with your mood today.",
+		At:    now.Unix(),
+	}}
+}
+
+// SOSAcknowledged is the notification telling a user that an emergency contact is responding
+// to their SOS
+func SOSAcknowledged(contact string, now time.Time) Event {
+	return Event{Type: EventNotification, Data: Notification{
+		Kind:  NotificationSOSAcknowledged,
+		Title: "Help is on the way",
+		Body:  contact + " saw your SOS and is reaching out.",
+		At:    now.Unix(),
+	}}
+}
+
+// MoodReminderDay returns the user's local day (YYYYMMDD) when a mood check-in reminder may be
+// due at now: it is past hour o'clock for them and they were not reminded earlier that day.
+// The caller still checks whether they logged their mood since local midnight.
+func MoodReminderDay(now time.Time, loc *time.Location, hour int, remindedDay string) (string, bool) {
+	local := now.In(loc)
+	day := local.Format("20060102")
+	return day, local.Hour() >= hour && day != remindedDay
+}
+
+// AccessToken returns the access token offered after AuthProtocol in a Sec-WebSocket-Protocol
+// header, or "" when there is none
+func AccessToken(protocols string) string {
+	offered := strings.Split(protocols, ",")
+	for i := 0; i+1 < len(offered); i++ {
+		if strings.TrimSpace(offered[i]) == AuthProtocol {
+			return strings.TrimSpace(offered[i+1])
+		}
+	}
+	return ""
+}
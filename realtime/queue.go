@@ -0,0 +1,121 @@
+package realtime
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"lambda-server/constants"
+)
+
+var (
+	// ErrQueueFull is returned when a client reads too slowly to keep its queue below capacity
+	ErrQueueFull = errors.New("realtime: event queue full")
+	// ErrQueueClosed is returned once the connection owning the queue is gone
+	ErrQueueClosed = errors.New("realtime: event queue closed")
+)
+
+// Queue holds the events waiting to be written to a connection. Tokens of a reply are merged
+// into the token already waiting, so a model writing faster than the client reads never fills
+// it; the client just receives fewer, longer tokens. Other events count against its capacity.
+type Queue struct {
+	mu     sync.Mutex
+	events []Event
+	limit  int
+	ready  chan struct{} // Signalled when events are added
+	closed bool
+}
+
+// NewQueue returns a queue holding up to limit events
+func NewQueue(limit int) *Queue {
+	return &Queue{limit: limit, ready: make(chan struct{}, 1)}
+}
+
+// Push adds an event that must be delivered. It fails with ErrQueueFull when the client has
+// fallen too far behind, and the connection should then be closed.
+func (q *Queue) Push(event Event) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	if token, ok := event.Data.(Token); ok && event.Type == constants.ChatEventToken {
+		if q.mergeToken(event.Id, token) {
+			return nil
+		}
+	}
+	if len(q.events) >= q.limit {
+		return ErrQueueFull
+	}
+	q.add(event)
+	return nil
+}
+
+// Offer adds an event that may be dropped, such as a typing indicator or notification, and
+// reports whether it was queued
+func (q *Queue) Offer(event Event) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed || len(q.events) >= q.limit {
+		return false
+	}
+	q.add(event)
+	return true
+}
+
+// mergeToken appends a token to the last queued event when that is a token of the same reply
+func (q *Queue) mergeToken(id string, token Token) bool {
+	if len(q.events) == 0 {
+		return false
+	}
+	last := &q.events[len(q.events)-1]
+	queued, ok := last.Data.(Token)
+	if !ok || last.Type != constants.ChatEventToken || last.Id != id {
+		return false
+	}
+	last.Data = Token{Content: queued.Content + token.Content}
+	return true
+}
+
+func (q *Queue) add(event Event) {
+	q.events = append(q.events, event)
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Pop removes and returns the oldest event, waiting for one until ctx is done or the queue is
+// closed
+func (q *Queue) Pop(ctx context.Context) (Event, error) {
+	for {
+		q.mu.Lock()
+		if len(q.events) > 0 {
+			event := q.events[0]
+			q.events = q.events[1:]
+			q.mu.Unlock()
+			return event, nil
+		}
+		closed := q.closed
+		q.mu.Unlock()
+		if closed {
+			return Event{}, ErrQueueClosed
+		}
+		select {
+		case <-q.ready:
+		case <-ctx.Done():
+			return Event{}, ctx.Err()
+		}
+	}
+}
+
+// Close stops the queue accepting events. Events already queued can still be popped.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
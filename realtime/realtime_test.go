@@ -0,0 +1,164 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"lambda-server/constants"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func token(id, content string) Event {
+	return Event{Type: constants.ChatEventToken, Id: id, Data: Token{Content: content}}
+}
+
+func TestParseClientMessage(t *testing.T) {
+	msg, err := ParseClientMessage([]byte(`{"type":"chat","id":" m1 ","sessionId":"s1","message":"hi"}`))
+	require.NoError(t, err)
+	assert.Equal(t, ClientMessage{Type: TypeChat, Id: "m1", SessionId: "s1", Message: "hi"}, msg)
+
+	_, err = ParseClientMessage([]byte(`{"type":"chat","id":"m1","sessionId":"s1","message":"  "}`))
+	assert.Error(t, err)
+	_, err = ParseClientMessage([]byte(`{"type":"cancel"}`))
+	assert.Error(t, err)
+	_, err = ParseClientMessage([]byte(`{"type":"typing","typing":true}`))
+	assert.Error(t, err)
+	_, err = ParseClientMessage([]byte(`{"type":"shout"}`))
+	assert.Error(t, err)
+	_, err = ParseClientMessage([]byte(`not json`))
+	assert.Error(t, err)
+
+	msg, err = ParseClientMessage([]byte(`{"type":"ping"}`))
+	require.NoError(t, err)
+	assert.Equal(t, TypePing, msg.Type)
+}
+
+func TestAccessToken(t *testing.T) {
+	assert.Equal(t, "abc.def.ghi", AccessToken("bearer, abc.def.ghi"))
+	assert.Equal(t, "abc.def.ghi", AccessToken("other,bearer,abc.def.ghi"))
+	assert.Empty(t, AccessToken("bearer"))
+	assert.Empty(t, AccessToken("abc.def.ghi"))
+	assert.Empty(t, AccessToken(""))
+}
+
+func TestEventJSON(t *testing.T) {
+	data, err := json.Marshal(token("m1", "Hel"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"token","id":"m1","data":{"content":"Hel"}}`, string(data))
+}
+
+func TestQueueMergesTokensOfAReply(t *testing.T) {
+	queue := NewQueue(2)
+	require.NoError(t, queue.Push(token("m1", "Hel")))
+	require.NoError(t, queue.Push(token("m1", "lo")))
+	require.NoError(t, queue.Push(token("m2", "Hi")))
+	// Full, but tokens still merge into the last one
+	require.NoError(t, queue.Push(token("m2", " there")))
+	assert.ErrorIs(t, queue.Push(Event{Type: constants.ChatEventDone, Id: "m2"}), ErrQueueFull)
+	assert.False(t, queue.Offer(Event{Type: EventTyping}))
+
+	ctx := context.Background()
+	event, err := queue.Pop(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, token("m1", "Hello"), event)
+	event, err = queue.Pop(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, token("m2", "Hi there"), event)
+}
+
+func TestQueuePopWaitsForEvents(t *testing.T) {
+	queue := NewQueue(4)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		queue.Push(Event{Type: EventPong})
+	}()
+	event, err := queue.Pop(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, EventPong, event.Type)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = queue.Pop(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestQueueClose(t *testing.T) {
+	queue := NewQueue(4)
+	require.NoError(t, queue.Push(Event{Type: EventPong}))
+	queue.Close()
+	assert.ErrorIs(t, queue.Push(Event{Type: EventPong}), ErrQueueClosed)
+
+	// Queued events drain before Pop reports the close
+	_, err := queue.Pop(context.Background())
+	require.NoError(t, err)
+	_, err = queue.Pop(context.Background())
+	assert.ErrorIs(t, err, ErrQueueClosed)
+}
+
+func TestHubSendSkipsTheSender(t *testing.T) {
+	hub := NewHub()
+	phone, laptop, other := NewQueue(4), NewQueue(4), NewQueue(4)
+	hub.Add("u1", phone)
+	hub.Add("u1", laptop)
+	hub.Add("u2", other)
+
+	assert.Equal(t, 1, hub.Send("u1", Event{Type: EventTyping}, phone))
+	assert.Equal(t, 2, hub.Send("u1", Event{Type: EventNotification}, nil))
+	assert.ElementsMatch(t, []string{"u1", "u2"}, hub.Users())
+
+	hub.Remove("u1", phone)
+	hub.Remove("u1", laptop)
+	assert.Equal(t, []string{"u2"}, hub.Users())
+	assert.Zero(t, hub.Send("u1", Event{Type: EventTyping}, nil))
+}
+
+func TestMoodReminderDay(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Kolkata")
+	require.NoError(t, err)
+	evening := time.Date(2026, 3, 5, 19, 0, 0, 0, loc)
+
+	day, due := MoodReminderDay(evening, loc, 18, "")
+	assert.Equal(t, "20260305", day)
+	assert.True(t, due)
+	_, due = MoodReminderDay(evening, loc, 18, "20260305")
+	assert.False(t, due, "already reminded today")
+	_, due = MoodReminderDay(evening.Add(-2*time.Hour), loc, 18, "")
+	assert.False(t, due, "too early")
+}
+
+func TestConnectionPosterSignsRequests(t *testing.T) {
+	var gotPath, gotAuth, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotAuth = r.URL.EscapedPath(), r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		if strings.HasSuffix(r.URL.Path, "gone") {
+			w.WriteHeader(http.StatusGone)
+		}
+	}))
+	defer server.Close()
+
+	poster := &ConnectionPoster{
+		Endpoint: server.URL + "/prod",
+		Region:   "ap-south-1",
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, nil
+		}),
+	}
+	require.NoError(t, poster.Post(context.Background(), "abc=", []byte(`{"type":"pong"}`)))
+	assert.Equal(t, "/prod/@connections/abc=", gotPath)
+	assert.Contains(t, gotAuth, "AWS4-HMAC-SHA256 Credential=AKID/")
+	assert.Contains(t, gotAuth, "/ap-south-1/execute-api/aws4_request")
+	assert.Equal(t, `{"type":"pong"}`, gotBody)
+
+	assert.ErrorIs(t, poster.Post(context.Background(), "gone", nil), ErrGone)
+}
@@ -0,0 +1,58 @@
+package retrieval
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache keeps each user's index for a while, so a conversation does not reload their journals
+// on every message. It is per process; an entry changed meanwhile is caught by the caller
+// checking the passages it retrieves.
+type Cache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	index   *Index
+	expires time.Time
+}
+
+// NewCache creates a cache whose indexes expire after ttl
+func NewCache(ttl time.Duration) *Cache {
+	return &Cache{ttl: ttl, now: time.Now, entries: map[string]cacheEntry{}}
+}
+
+// Get returns the cached index of a user, building and caching it when there is none
+func (c *Cache) Get(userId string, build func() (*Index, error)) (*Index, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[userId]
+	c.mu.Unlock()
+	if ok && c.now().Before(entry.expires) {
+		return entry.index, nil
+	}
+	index, err := build()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for key, stale := range c.entries {
+		if !now.Before(stale.expires) {
+			delete(c.entries, key)
+		}
+	}
+	c.entries[userId] = cacheEntry{index: index, expires: now.Add(c.ttl)}
+	return index, nil
+}
+
+// Forget drops a user's index, so the next Get rebuilds it
+func (c *Cache) Forget(userId string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, userId)
+}
@@ -0,0 +1,137 @@
+package retrieval
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"lambda-server/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func journalPassage(id, text string) Passage {
+	return Passage{Source: SourceJournal, JournalId: id, Text: text}
+}
+
+func TestTerms(t *testing.T) {
+	assert.Equal(t, []string{"worr", "mom", "exam"}, Terms("I'm so worried about Mom's exams!"))
+	assert.Equal(t, Terms("worries"), Terms("worried"))
+	assert.Empty(t, Terms("I really don't feel like it"))
+}
+
+func TestSearchRanksRelevantPassagesFirst(t *testing.T) {
+	index := NewIndex([]Passage{
+		journalPassage("a", "Went for a long run by the river, legs are sore."),
+		journalPassage("b", "Anxious about the chemistry exam on Friday. Studied all evening."),
+		journalPassage("c", "Dinner with Sam. We talked about the exam and laughed a lot."),
+	})
+
+	results := index.Search("I keep worrying about my chemistry exam", 2)
+	require.Len(t, results, 2)
+	assert.Equal(t, "b", results[0].Passage.JournalId)
+	assert.Equal(t, "c", results[1].Passage.JournalId)
+	assert.Greater(t, results[0].Score, results[1].Score)
+}
+
+func TestSearchSkipsUnrelatedPassages(t *testing.T) {
+	index := NewIndex([]Passage{journalPassage("a", "Went for a run by the river.")})
+
+	assert.Empty(t, index.Search("chemistry exam", 4))
+	assert.Empty(t, index.Search("the and of", 4))
+	assert.Empty(t, NewIndex(nil).Search("exam", 4))
+}
+
+func TestSearchMatchesTitles(t *testing.T) {
+	index := NewIndex([]Passage{
+		{Source: SourceJournal, JournalId: "a", Title: "Grandma's garden", Text: "We picked tomatoes."},
+		journalPassage("b", "Quiet day at work."),
+	})
+
+	results := index.Search("garden", 4)
+	require.Len(t, results, 1)
+	assert.Equal(t, "a", results[0].Passage.JournalId)
+}
+
+func TestJournalPassagesSplitsAtSentences(t *testing.T) {
+	journal := models.Journal{
+		JournalID: "j1",
+		Title:     "Long day",
+		Date:      "20240305",
+		Content:   "One two three four. Five six seven.\nEight nine ten eleven twelve thirteen.",
+	}
+
+	passages := JournalPassages(journal, 8)
+	require.Len(t, passages, 2)
+	assert.Equal(t, "One two three four. Five six seven.", passages[0].Text)
+	assert.Equal(t, "Eight nine ten eleven twelve thirteen.", passages[1].Text)
+	for _, passage := range passages {
+		assert.Equal(t, SourceJournal, passage.Source)
+		assert.Equal(t, "j1", passage.JournalId)
+		assert.Equal(t, "20240305", passage.Date)
+	}
+}
+
+func TestJournalPassagesSplitsLongSentences(t *testing.T) {
+	passages := JournalPassages(models.Journal{Content: strings.Repeat("word ", 25)}, 10)
+
+	require.Len(t, passages, 3)
+	assert.Len(t, strings.Fields(passages[2].Text), 5)
+	assert.Empty(t, JournalPassages(models.Journal{Content: "  "}, 10))
+}
+
+func TestSessionPassage(t *testing.T) {
+	passage := SessionPassage(models.ChatSession{
+		SessionId:     "s1",
+		Title:         "Exam nerves",
+		Summary:       "User talked through exam anxiety.",
+		LastMessageAt: time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC).Unix(),
+	})
+
+	assert.Equal(t, Passage{Source: SourceChat, SessionId: "s1", Title: "Exam nerves", Date: "20240305", Text: "User talked through exam anxiety."}, passage)
+}
+
+func TestPromptAndCitations(t *testing.T) {
+	results := []Result{
+		{Passage: Passage{Source: SourceJournal, JournalId: "j1", Title: "Exam", Date: "20240305", Text: "Anxious about the exam."}},
+		{Passage: Passage{Source: SourceChat, SessionId: "s1", Text: strings.Repeat("a", 30)}},
+	}
+
+	prompt := Prompt(results)
+	assert.Contains(t, prompt, "not instructions")
+	assert.Contains(t, prompt, `[1] Journal entry "Exam" (2024-03-05): Anxious about the exam.`)
+	assert.Contains(t, prompt, "[2] Summary of an earlier conversation: ")
+	assert.Empty(t, Prompt(nil))
+
+	citations := Citations(results, 10)
+	require.Len(t, citations, 2)
+	assert.Equal(t, models.Citation{Ref: 1, Source: SourceJournal, JournalId: "j1", Title: "Exam", Date: "20240305", Snippet: "Anxious a…"}, citations[0])
+	assert.Equal(t, 2, citations[1].Ref)
+	assert.Equal(t, "s1", citations[1].SessionId)
+}
+
+func TestCacheReusesIndexUntilExpiry(t *testing.T) {
+	now := time.Unix(0, 0)
+	cache := NewCache(time.Minute)
+	cache.now = func() time.Time { return now }
+	builds := 0
+	build := func() (*Index, error) {
+		builds++
+		return NewIndex(nil), nil
+	}
+
+	first, err := cache.Get("u1", build)
+	require.NoError(t, err)
+	second, _ := cache.Get("u1", build)
+	assert.Same(t, first, second)
+	assert.Equal(t, 1, builds)
+
+	now = now.Add(time.Minute)
+	_, _ = cache.Get("u1", build)
+	assert.Equal(t, 2, builds)
+
+	cache.Forget("u1")
+	_, _ = cache.Get("u1", build)
+	assert.Equal(t, 3, builds)
+}
@@ -0,0 +1,138 @@
+package risk
+
+// pattern is a phrase that signals risk. Phrases are written in normalized form: lowercase,
+// without accents or apostrophes, words separated by single spaces.
+type pattern struct {
+	phrase   string
+	level    string
+	category string
+}
+
+// patterns lists the phrases the classifier looks for, grouped by language
+var patterns = []pattern{
+	// English
+	{"kill myself", LevelHigh, CategorySuicide},
+	{"killing myself", LevelHigh, CategorySuicide},
+	{"end my life", LevelHigh, CategorySuicide},
+	{"ending my life", LevelHigh, CategorySuicide},
+	{"take my own life", LevelHigh, CategorySuicide},
+	{"taking my own life", LevelHigh, CategorySuicide},
+	{"commit suicide", LevelHigh, CategorySuicide},
+	{"suicidal", LevelHigh, CategorySuicide},
+	{"want to die", LevelHigh, CategorySuicide},
+	{"wanna die", LevelHigh, CategorySuicide},
+	{"end it all", LevelHigh, CategorySuicide},
+	{"dont want to live", LevelHigh, CategorySuicide},
+	{"do not want to live", LevelHigh, CategorySuicide},
+	{"dont want to be alive", LevelHigh, CategorySuicide},
+	{"suicide", LevelMedium, CategorySuicide},
+	{"wish i was dead", LevelMedium, CategorySuicide},
+	{"wish i were dead", LevelMedium, CategorySuicide},
+	{"better off dead", LevelMedium, CategorySuicide},
+	{"better off without me", LevelMedium, CategorySuicide},
+	{"no reason to live", LevelMedium, CategorySuicide},
+	{"nothing to live for", LevelMedium, CategorySuicide},
+	{"not worth living", LevelMedium, CategorySuicide},
+	{"never wake up", LevelMedium, CategorySuicide},
+	{"cut myself", LevelMedium, CategorySelfHarm},
+	{"cutting myself", LevelMedium, CategorySelfHarm},
+	{"hurt myself", LevelMedium, CategorySelfHarm},
+	{"hurting myself", LevelMedium, CategorySelfHarm},
+	{"harm myself", LevelMedium, CategorySelfHarm},
+	{"self harm", LevelMedium, CategorySelfHarm},
+	{"burn myself", LevelMedium, CategorySelfHarm},
+	{"hopeless", LevelLow, CategoryHopelessness},
+	{"cant go on", LevelLow, CategoryHopelessness},
+	{"cannot go on", LevelLow, CategoryHopelessness},
+	{"no way out", LevelLow, CategoryHopelessness},
+	{"im a burden", LevelLow, CategoryHopelessness},
+	{"i am a burden", LevelLow, CategoryHopelessness},
+
+	// Spanish
+	{"suicidarme", LevelHigh, CategorySuicide},
+	{"quiero morir", LevelHigh, CategorySuicide},
+	{"quiero morirme", LevelHigh, CategorySuicide},
+	{"me quiero morir", LevelHigh, CategorySuicide},
+	{"quitarme la vida", LevelHigh, CategorySuicide},
+	{"matarme", LevelHigh, CategorySuicide},
+	{"acabar con mi vida", LevelHigh, CategorySuicide},
+	{"no quiero vivir", LevelHigh, CategorySuicide},
+	{"suicidio", LevelMedium, CategorySuicide},
+	{"sin razon para vivir", LevelMedium, CategorySuicide},
+	{"autolesion", LevelMedium, CategorySelfHarm},
+	{"autolesionarme", LevelMedium, CategorySelfHarm},
+	{"hacerme dano", LevelMedium, CategorySelfHarm},
+	{"cortarme", LevelMedium, CategorySelfHarm},
+	{"sin esperanza", LevelLow, CategoryHopelessness},
+	{"no puedo mas", LevelLow, CategoryHopelessness},
+
+	// Portuguese
+	{"me matar", LevelHigh, CategorySuicide},
+	{"quero morrer", LevelHigh, CategorySuicide},
+	{"me suicidar", LevelHigh, CategorySuicide},
+	{"tirar minha vida", LevelHigh, CategorySuicide},
+	{"tirar a minha vida", LevelHigh, CategorySuicide},
+	{"acabar com minha vida", LevelHigh, CategorySuicide},
+	{"nao quero viver", LevelHigh, CategorySuicide},
+	{"suicidio", LevelMedium, CategorySuicide},
+	{"me cortar", LevelMedium, CategorySelfHarm},
+	{"me machucar", LevelMedium, CategorySelfHarm},
+	{"automutilacao", LevelMedium, CategorySelfHarm},
+	{"sem esperanca", LevelLow, CategoryHopelessness},
+	{"nao aguento mais", LevelLow, CategoryHopelessness},
+
+	// French
+	{"me suicider", LevelHigh, CategorySuicide},
+	{"je veux mourir", LevelHigh, CategorySuicide},
+	{"me tuer", LevelHigh, CategorySuicide},
+	{"mettre fin a mes jours", LevelHigh, CategorySuicide},
+	{"mettre fin a ma vie", LevelHigh, CategorySuicide},
+	{"suicide", LevelMedium, CategorySuicide},
+	{"me faire du mal", LevelMedium, CategorySelfHarm},
+	{"me scarifier", LevelMedium, CategorySelfHarm},
+	{"automutilation", LevelMedium, CategorySelfHarm},
+	{"sans espoir", LevelLow, CategoryHopelessness},
+	{"je nen peux plus", LevelLow, CategoryHopelessness},
+
+	// German
+	{"mich umbringen", LevelHigh, CategorySuicide},
+	{"mich toten", LevelHigh, CategorySuicide},
+	{"will sterben", LevelHigh, CategorySuicide},
+	{"mir das leben nehmen", LevelHigh, CategorySuicide},
+	{"nicht mehr leben", LevelHigh, CategorySuicide},
+	{"selbstmord", LevelMedium, CategorySuicide},
+	{"suizid", LevelMedium, CategorySuicide},
+	{"mich ritzen", LevelMedium, CategorySelfHarm},
+	{"selbstverletzung", LevelMedium, CategorySelfHarm},
+	{"hoffnungslos", LevelLow, CategoryHopelessness},
+
+	// Hindi, in Devanagari and romanized
+	{"आत्महत्या", LevelHigh, CategorySuicide},
+	{"मरना चाहता", LevelHigh, CategorySuicide},
+	{"मरना चाहती", LevelHigh, CategorySuicide},
+	{"खुदकुशी", LevelHigh, CategorySuicide},
+	{"aatmahatya", LevelHigh, CategorySuicide},
+	{"atmahatya", LevelHigh, CategorySuicide},
+	{"khudkushi", LevelHigh, CategorySuicide},
+	{"marna chahta", LevelHigh, CategorySuicide},
+	{"marna chahti", LevelHigh, CategorySuicide},
+}
+
+// negations lower the level of a phrase that follows closely, as in "I would never kill myself"
+var negations = map[string]bool{
+	"not": true, "never": true, "dont": true, "wont": true, "wouldnt": true, "no": true,
+	"nunca": true, "jamas": true, "jamais": true, "nie": true, "niemals": true,
+}
+
+// negationWindow is how many words before a phrase are checked for a negation
+const negationWindow = 3
+
+// accents maps accented Latin letters to their plain form
+var accents = map[rune]string{
+	'á': "a", 'à': "a", 'â': "a", 'ä': "a", 'ã': "a", 'å': "a",
+	'é': "e", 'è': "e", 'ê': "e", 'ë': "e",
+	'í': "i", 'ì': "i", 'î': "i", 'ï': "i",
+	'ó': "o", 'ò': "o", 'ô': "o", 'ö': "o", 'õ': "o",
+	'ú': "u", 'ù': "u", 'û': "u", 'ü': "u",
+	'ñ': "n", 'ç': "c", 'ß': "ss",
+}
@@ -0,0 +1,113 @@
+package risk
+
+import (
+	"slices"
+	"strings"
+
+	"lambda-server/models"
+)
+
+// region holds the crisis services of one country
+type region struct {
+	emergency string
+	resources []models.CrisisResource
+}
+
+// regions maps ISO 3166-1 alpha-2 codes to their crisis services
+var regions = map[string]region{
+	"US": {"911", []models.CrisisResource{
+		{Name: "988 Suicide & Crisis Lifeline", Phone: "988", SMS: "988", URL: "https://988lifeline.org"},
+		{Name: "Crisis Text Line", SMS: "741741", URL: "https://www.crisistextline.org"},
+	}},
+	"CA": {"911", []models.CrisisResource{
+		{Name: "9-8-8 Suicide Crisis Helpline", Phone: "988", SMS: "988", URL: "https://988.ca"},
+	}},
+	"GB": {"999", []models.CrisisResource{
+		{Name: "Samaritans", Phone: "116 123", URL: "https://www.samaritans.org"},
+		{Name: "Shout", SMS: "85258", URL: "https://giveusashout.org"},
+	}},
+	"IE": {"112", []models.CrisisResource{
+		{Name: "Samaritans", Phone: "116 123", URL: "https://www.samaritans.org"},
+		{Name: "Text About It", SMS: "50808", URL: "https://text50808.ie"},
+	}},
+	"AU": {"000", []models.CrisisResource{
+		{Name: "Lifeline", Phone: "13 11 14", SMS: "0477 13 11 14", URL: "https://www.lifeline.org.au"},
+	}},
+	"NZ": {"111", []models.CrisisResource{
+		{Name: "Need to talk? 1737", Phone: "1737", SMS: "1737", URL: "https://1737.org.nz"},
+	}},
+	"IN": {"112", []models.CrisisResource{
+		{Name: "Tele-MANAS", Phone: "14416", URL: "https://telemanas.mohfw.gov.in"},
+	}},
+	"ES": {"112", []models.CrisisResource{
+		{Name: "Línea 024", Phone: "024", URL: "https://www.sanidad.gob.es/linea024/"},
+	}},
+	"MX": {"911", []models.CrisisResource{
+		{Name: "Línea de la Vida", Phone: "800 911 2000"},
+	}},
+	"FR": {"112", []models.CrisisResource{
+		{Name: "3114 Numéro national de prévention du suicide", Phone: "3114", URL: "https://3114.fr"},
+	}},
+	"DE": {"112", []models.CrisisResource{
+		{Name: "TelefonSeelsorge", Phone: "0800 111 0 111", URL: "https://www.telefonseelsorge.de"},
+	}},
+	"BR": {"192", []models.CrisisResource{
+		{Name: "CVV - Centro de Valorização da Vida", Phone: "188", URL: "https://cvv.org.br"},
+	}},
+}
+
+// international is shown when the user's region is unknown or not listed
+var international = []models.CrisisResource{
+	{Name: "Find A Helpline", URL: "https://findahelpline.com"},
+}
+
+// dialCodes maps phone country calling codes to the regions above
+var dialCodes = map[string]string{
+	"1": "US", "44": "GB", "353": "IE", "61": "AU", "64": "NZ", "91": "IN",
+	"34": "ES", "52": "MX", "33": "FR", "49": "DE", "55": "BR",
+}
+
+// Region works out the user's country from their phone country code ("+44" or "GB"),
+// falling back to the region of their locale ("en-GB"). It returns "" when neither helps.
+func Region(countryCode, locale string) string {
+	code := strings.TrimSpace(countryCode)
+	if len(code) == 2 && isLetters(code) {
+		return strings.ToUpper(code)
+	}
+	if region := dialCodes[strings.TrimPrefix(code, "+")]; region != "" {
+		return region
+	}
+	parts := strings.FieldsFunc(locale, func(r rune) bool { return r == '-' || r == '_' })
+	for _, part := range parts[min(1, len(parts)):] {
+		if len(part) == 2 && isLetters(part) {
+			return strings.ToUpper(part)
+		}
+	}
+	return ""
+}
+
+// Resources returns the crisis services for a region, or international ones if it is not listed
+func Resources(regionCode string) models.CrisisResources {
+	r, ok := regions[regionCode]
+	if !ok {
+		return models.CrisisResources{
+			Message:   "You don't have to go through this alone. If you are in immediate danger, call your local emergency number now. You can find a free, confidential helpline near you here.",
+			Resources: slices.Clone(international),
+		}
+	}
+	return models.CrisisResources{
+		Region:          regionCode,
+		EmergencyNumber: r.emergency,
+		Message:         "You don't have to go through this alone. If you are in immediate danger, call " + r.emergency + " now. These services are free, confidential and ready to listen.",
+		Resources:       slices.Clone(r.resources),
+	}
+}
+
+func isLetters(s string) bool {
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package risk
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"lambda-server/llm"
+	"lambda-server/models"
+)
+
+// reviewPrompt asks the LLM for a second opinion on a text the lexicon flagged
+const reviewPrompt = "You are a clinical safety reviewer for a mental wellbeing app. Rate the risk that the " +
+	"author of the following text may harm themselves. Use \"high\" for a stated wish, intent or plan to die " +
+	"by suicide, \"medium\" for self-harm or passive thoughts of death, \"low\" for hopelessness without " +
+	"either, and \"none\" otherwise, including when suicide is only discussed in general or about someone " +
+	"else. Reply with JSON only, in the form " +
+	`{"level": "none|low|medium|high", "categories": ["suicide", "self_harm", "hopelessness"]}.`
+
+// ReviewMessages builds the LLM request for a second opinion on text
+func ReviewMessages(text string) []llm.Message {
+	return []llm.Message{
+		{Role: llm.RoleSystem, Content: reviewPrompt},
+		{Role: llm.RoleUser, Content: text},
+	}
+}
+
+// ParseReview reads the LLM's reply to ReviewMessages
+func ParseReview(reply string) (models.RiskAssessment, error) {
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return models.RiskAssessment{}, fmt.Errorf("no JSON object in review reply")
+	}
+	var review struct {
+		Level      string   `json:"level"`
+		Categories []string `json:"categories"`
+	}
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &review); err != nil {
+		return models.RiskAssessment{}, fmt.Errorf("invalid review reply: %w", err)
+	}
+	level := strings.ToLower(strings.TrimSpace(review.Level))
+	if !slices.Contains(levels, level) {
+		return models.RiskAssessment{}, fmt.Errorf("unknown risk level %q in review reply", review.Level)
+	}
+	return models.RiskAssessment{Level: level, Categories: review.Categories, Source: SourceLLM}, nil
+}
+
+// Combine merges the lexicon's assessment with the LLM's. The LLM can raise the level freely
+// but lower it by one step at most, so a model misreading an explicit statement cannot
+// silence it.
+func Combine(local, review models.RiskAssessment) models.RiskAssessment {
+	level := review.Level
+	if floor := Rank(local.Level) - 1; Rank(level) < floor {
+		level = levels[floor]
+	}
+	categories := slices.Clone(local.Categories)
+	for _, category := range review.Categories {
+		if !slices.Contains(categories, category) {
+			categories = append(categories, category)
+		}
+	}
+	if level == LevelNone {
+		categories = nil
+	}
+	return models.RiskAssessment{Level: level, Categories: categories, Source: SourceLLM}
+}
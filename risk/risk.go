@@ -0,0 +1,98 @@
+// Package risk flags messages and journal entries that mention suicide or self-harm, so the
+// app can show crisis resources and alert a user's emergency contacts
+package risk
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"lambda-server/models"
+)
+
+// Risk levels, lowest first
+const (
+	LevelNone   = "none"
+	LevelLow    = "low"    // Hopelessness without mention of self-harm
+	LevelMedium = "medium" // Self-harm or passive thoughts of death
+	LevelHigh   = "high"   // Stated wish or intent to die by suicide
+)
+
+// Risk categories
+const (
+	CategorySuicide      = "suicide"
+	CategorySelfHarm     = "self_harm"
+	CategoryHopelessness = "hopelessness"
+)
+
+// Assessment sources
+const (
+	SourceLexicon = "lexicon"
+	SourceLLM     = "lexicon+llm"
+)
+
+var levels = []string{LevelNone, LevelLow, LevelMedium, LevelHigh}
+
+// Rank orders levels from 0 (none) to 3 (high); unknown levels rank as none
+func Rank(level string) int {
+	return max(slices.Index(levels, level), 0)
+}
+
+// AtLeast reports whether level is at or above min
+func AtLeast(level, min string) bool {
+	return Rank(level) >= Rank(min)
+}
+
+// Classify rates text with the built-in multilingual phrase list. A phrase shortly after a
+// negation ("I would never hurt myself") counts one level lower.
+func Classify(text string) models.RiskAssessment {
+	words := strings.Fields(normalize(text))
+	assessment := models.RiskAssessment{Level: LevelNone, Source: SourceLexicon}
+	for _, p := range patterns {
+		phrase := strings.Fields(p.phrase)
+		for start := 0; start+len(phrase) <= len(words); start++ {
+			if !slices.Equal(words[start:start+len(phrase)], phrase) {
+				continue
+			}
+			level := p.level
+			if negated(words, start) {
+				level = levels[Rank(level)-1]
+			}
+			if Rank(level) > Rank(assessment.Level) {
+				assessment.Level = level
+			}
+			if level != LevelNone && !slices.Contains(assessment.Categories, p.category) {
+				assessment.Categories = append(assessment.Categories, p.category)
+			}
+		}
+	}
+	return assessment
+}
+
+// negated reports whether a negation appears shortly before words[start]
+func negated(words []string, start int) bool {
+	for i := max(start-negationWindow, 0); i < start; i++ {
+		if negations[words[i]] {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize lowercases text, strips accents and apostrophes, and turns everything that is not
+// part of a word into spaces
+func normalize(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case r == '\'' || r == '’':
+		case accents[r] != "":
+			b.WriteString(accents[r])
+		case unicode.IsLetter(r) || unicode.IsMark(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	return b.String()
+}
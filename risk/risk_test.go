@@ -0,0 +1,95 @@
+package risk
+
+import (
+	"testing"
+
+	"lambda-server/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		text     string
+		level    string
+		category string
+	}{
+		{"Had a lovely walk in the park today.", LevelNone, ""},
+		{"I'm killing it at work this week", LevelNone, ""},
+		{"I just want to DIE, honestly.", LevelHigh, CategorySuicide},
+		{"Sometimes I think about killing myself", LevelHigh, CategorySuicide},
+		{"I don't want to live anymore", LevelHigh, CategorySuicide},
+		{"I cut myself again last night", LevelMedium, CategorySelfHarm},
+		{"Everything feels hopeless", LevelLow, CategoryHopelessness},
+		{"I would never kill myself", LevelMedium, CategorySuicide},
+		{"Me quiero morir", LevelHigh, CategorySuicide},
+		{"Ya no puedo más", LevelLow, CategoryHopelessness},
+		{"Je veux mourir", LevelHigh, CategorySuicide},
+		{"Ich will mich umbringen", LevelHigh, CategorySuicide},
+		{"Eu quero morrer", LevelHigh, CategorySuicide},
+		{"मैं आत्महत्या के बारे में सोच रहा हूँ", LevelHigh, CategorySuicide},
+	}
+	for _, tc := range cases {
+		assessment := Classify(tc.text)
+		assert.Equal(t, tc.level, assessment.Level, tc.text)
+		assert.Equal(t, SourceLexicon, assessment.Source)
+		if tc.category != "" {
+			assert.Contains(t, assessment.Categories, tc.category, tc.text)
+		} else {
+			assert.Empty(t, assessment.Categories, tc.text)
+		}
+	}
+}
+
+func TestClassifyKeepsHighestLevel(t *testing.T) {
+	assessment := Classify("I feel hopeless and I have been hurting myself. I want to end my life.")
+	assert.Equal(t, LevelHigh, assessment.Level)
+	assert.ElementsMatch(t, []string{CategorySuicide, CategorySelfHarm, CategoryHopelessness}, assessment.Categories)
+}
+
+func TestParseReview(t *testing.T) {
+	review, err := ParseReview("Sure.\n```json\n{\"level\": \"High\", \"categories\": [\"suicide\"]}\n```")
+	require.NoError(t, err)
+	assert.Equal(t, LevelHigh, review.Level)
+	assert.Equal(t, []string{CategorySuicide}, review.Categories)
+
+	_, err = ParseReview("no idea")
+	assert.Error(t, err)
+	_, err = ParseReview(`{"level": "severe"}`)
+	assert.Error(t, err)
+}
+
+func TestCombine(t *testing.T) {
+	local := models.RiskAssessment{Level: LevelHigh, Categories: []string{CategorySuicide}, Source: SourceLexicon}
+
+	// The LLM may lower the level by one step only
+	combined := Combine(local, models.RiskAssessment{Level: LevelNone})
+	assert.Equal(t, LevelMedium, combined.Level)
+	assert.Equal(t, SourceLLM, combined.Source)
+
+	combined = Combine(models.RiskAssessment{Level: LevelLow, Categories: []string{CategoryHopelessness}},
+		models.RiskAssessment{Level: LevelHigh, Categories: []string{CategorySuicide}})
+	assert.Equal(t, LevelHigh, combined.Level)
+	assert.Equal(t, []string{CategoryHopelessness, CategorySuicide}, combined.Categories)
+
+	combined = Combine(models.RiskAssessment{Level: LevelLow, Categories: []string{CategoryHopelessness}},
+		models.RiskAssessment{Level: LevelNone})
+	assert.Equal(t, LevelNone, combined.Level)
+	assert.Empty(t, combined.Categories)
+}
+
+func TestRegionAndResources(t *testing.T) {
+	assert.Equal(t, "GB", Region("+44", "en-US"))
+	assert.Equal(t, "IN", Region("in", ""))
+	assert.Equal(t, "AU", Region("", "en-AU"))
+	assert.Equal(t, "", Region("", "en"))
+
+	resources := Resources("US")
+	assert.Equal(t, "911", resources.EmergencyNumber)
+	assert.Equal(t, "988", resources.Resources[0].Phone)
+
+	fallback := Resources("")
+	assert.Empty(t, fallback.Region)
+	assert.NotEmpty(t, fallback.Resources)
+}
@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"lambda-server/handlers"
+	"lambda-server/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupAdminRoutes configures the routes reserved for admin users
+func SetupAdminRoutes(api *gin.RouterGroup) {
+	admin := api.Group("/admin", middlewares.AuthMiddleware(), middlewares.AdminMiddleware())
+	{
+		admin.GET("/prompts", handlers.GetAdminPrompts)
+		admin.POST("/prompts", handlers.CreatePrompt)
+		admin.PUT("/prompts/:promptId", handlers.UpdatePrompt)
+		admin.DELETE("/prompts/:promptId", handlers.DeletePrompt)
+		admin.GET("/personas", handlers.GetAdminPersonas)
+		admin.POST("/personas", handlers.CreatePersona)
+		admin.GET("/personas/:personaId/versions", handlers.GetAdminPersonaVersions)
+		admin.PUT("/personas/:personaId", handlers.UpdatePersona)
+		admin.DELETE("/personas/:personaId", handlers.DeletePersona)
+		admin.GET("/moderation", handlers.GetModerationLogs)
+		admin.GET("/feedback", handlers.ExportFeedback)
+		admin.GET("/users/:userId/quota", handlers.GetUserQuota)
+		admin.PUT("/users/:userId/quota", handlers.UpdateUserQuota)
+	}
+}
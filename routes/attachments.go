@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"lambda-server/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupAttachmentRoutes configures the signed blob URLs used to upload and download attachments
+// kept in the local store; the S3 store hands out presigned URLs to the bucket instead.
+// These routes are authorized by the URL signature instead of the Authorization header.
+func SetupAttachmentRoutes(api *gin.RouterGroup) {
+	attachments := api.Group("/attachments")
+	{
+		attachments.PUT("/blob", handlers.UploadAttachmentBlob)
+		attachments.GET("/blob", handlers.DownloadAttachmentBlob)
+	}
+}
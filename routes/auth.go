@@ -15,9 +15,9 @@ func SetupAuthRoutes(api *gin.RouterGroup) {
 		user.POST("/register", handlers.HandleRegister)
 		user.POST("/refresh", handlers.HandleRefresh)
 		user.POST("/logout", middlewares.AuthMiddleware(), handlers.HandleLogout)
-		user.GET("/me", handlers.HandleGetProfile)
-		user.PATCH("/me", handlers.UpdateCurrentUser)
-		user.DELETE("/me", handlers.DeleteCurrentUser)
+		user.GET("/me", middlewares.AuthMiddleware(), handlers.HandleGetProfile)
+		user.PATCH("/me", middlewares.AuthMiddleware(), handlers.UpdateCurrentUser)
+		user.DELETE("/me", middlewares.AuthMiddleware(), handlers.DeleteCurrentUser)
 		user.POST("/forgot-password", handlers.HandleForgotPassword)
 		user.POST("/reset-password", handlers.HandleResetPassword)
 	}
@@ -13,3 +13,16 @@ func SetupEmergencyRoutes(api *gin.RouterGroup) {
 	emergency.POST("/create", middlewares.AuthMiddleware(), handlers.CreateEmergencyContacts)
 	emergency.GET("/contacts", middlewares.AuthMiddleware(), handlers.GetEmergencyContacts)
 }
+
+// SetupSOSRoutes configures the routes to raise and follow SOS alerts. Acknowledging is done by
+// emergency contacts, who sign in with the token from their alert instead.
+func SetupSOSRoutes(api *gin.RouterGroup) {
+	api.POST("/sos/acknowledge", handlers.AcknowledgeSOS)
+
+	sos := api.Group("/sos", middlewares.AuthMiddleware())
+	sos.POST("", handlers.RaiseSOS)
+	sos.GET("", handlers.ListSOS)
+	sos.GET("/:timestamp", handlers.GetSOS)
+	sos.POST("/:timestamp/cancel", handlers.CancelSOS)
+	sos.POST("/:timestamp/resolve", handlers.ResolveSOS)
+}
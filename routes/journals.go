@@ -13,14 +13,26 @@ func SetupJournalRoutes(api *gin.RouterGroup) {
 	{
 		journal.GET("", middlewares.AuthMiddleware(), handlers.GetAllJournalEntries)
 		journal.POST("", middlewares.AuthMiddleware(), handlers.CreateJournalEntry)
+		journal.GET("/trash", middlewares.AuthMiddleware(), handlers.GetTrashedJournalEntries)
+		journal.GET("/stats", middlewares.AuthMiddleware(), handlers.GetJournalStats)
+		journal.GET("/sentiment", middlewares.AuthMiddleware(), handlers.GetSentimentTrends)
+		journal.GET("/export", middlewares.AuthMiddleware(), handlers.ExportJournalEntries)
+		journal.POST("/import", middlewares.AuthMiddleware(), handlers.ImportJournalEntries)
+		journal.GET("/import/:jobId", middlewares.AuthMiddleware(), handlers.GetImportJob)
+		journal.POST("/import/:jobId/start", middlewares.AuthMiddleware(), handlers.StartImportJob)
+		journal.GET("/tags", middlewares.AuthMiddleware(), handlers.GetJournalTags)
+		journal.PUT("/tags/:tag", middlewares.AuthMiddleware(), handlers.RenameJournalTag)
+		journal.POST("/tags/merge", middlewares.AuthMiddleware(), handlers.MergeJournalTags)
 		journal.GET("/:journalId", middlewares.AuthMiddleware(), handlers.GetJournalEntry)
 		journal.DELETE("/:journalId", middlewares.AuthMiddleware(), handlers.DeleteJournalEntry)
 		journal.PUT("/:journalId", middlewares.AuthMiddleware(), handlers.UpdateJournalEntry)
+		journal.POST("/:journalId/restore", middlewares.AuthMiddleware(), handlers.RestoreJournalEntry)
+		journal.DELETE("/:journalId/permanent", middlewares.AuthMiddleware(), handlers.PermanentlyDeleteJournalEntry)
 
-		// Debug route to test query param extraction
-		journal.GET("/test", func(c *gin.Context) {
-			userId := c.Query("userId")
-			c.JSON(200, gin.H{"userId": userId})
-		})
+		// Attachments
+		journal.GET("/:journalId/attachments", middlewares.AuthMiddleware(), handlers.GetJournalAttachments)
+		journal.POST("/:journalId/attachments", middlewares.AuthMiddleware(), handlers.CreateJournalAttachment)
+		journal.POST("/:journalId/attachments/:attachmentId/complete", middlewares.AuthMiddleware(), handlers.CompleteJournalAttachment)
+		journal.DELETE("/:journalId/attachments/:attachmentId", middlewares.AuthMiddleware(), handlers.DeleteJournalAttachment)
 	}
 }
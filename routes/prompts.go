@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"lambda-server/handlers"
+	"lambda-server/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupPromptRoutes configures the journaling prompt routes
+func SetupPromptRoutes(api *gin.RouterGroup) {
+	prompt := api.Group("/prompts")
+	{
+		prompt.GET("", middlewares.AuthMiddleware(), handlers.GetPrompts)
+		prompt.GET("/daily", middlewares.AuthMiddleware(), handlers.GetDailyPrompt)
+	}
+}
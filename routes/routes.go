@@ -2,6 +2,7 @@ package routes
 
 import (
 	"lambda-server/handlers"
+	"lambda-server/middlewares"
 	"lambda-server/utils"
 	"net/http"
 	"time"
@@ -32,14 +33,29 @@ func SetupRouter() *gin.Engine {
 		// Setup journal routes
 		SetupJournalRoutes(api)
 
+		// Setup offline sync routes
+		SetupSyncRoutes(api)
+
+		// Setup journaling prompt routes
+		SetupPromptRoutes(api)
+
+		// Setup attachment blob routes
+		SetupAttachmentRoutes(api)
+
 		// Setup emergency routes
 		SetupEmergencyRoutes(api)
 
+		// Setup SOS routes
+		SetupSOSRoutes(api)
+
 		// Setup survey routes
 		SetupSurveyRoutes(api)
 
 		// Setup chat routes
 		SetupChatRoutes(api)
+
+		// Setup admin routes
+		SetupAdminRoutes(api)
 	}
 
 	return r
@@ -48,8 +64,6 @@ func SetupRouter() *gin.Engine {
 // corsMiddleware handles CORS headers
 func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		origin := c.Request.Header.Get("Origin")
-		print("Origin received: ", origin)
 		var allowedOrigin string
 		if utils.IsRunningLocally() {
 			allowedOrigin = "http://localhost:3000"
@@ -59,8 +73,8 @@ func corsMiddleware() gin.HandlerFunc {
 		c.Header("Access-Control-Allow-Origin", allowedOrigin)
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, PATCH, HEAD, OPTIONS")
 		c.Header("Access-Control-Allow-Credentials", "true")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Length, Content-Type, Authorization, X-Requested-With, Accept, Accept-Encoding, Accept-Language, Cache-Control, X-CSRF-Token, X-Client-Type")
-		c.Header("Access-Control-Expose-Headers", "Content-Length, Content-Type, X-New-Access-Token, X-New-Refresh-Token")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Length, Content-Type, Authorization, X-Requested-With, Accept, Accept-Encoding, Accept-Language, Cache-Control, X-CSRF-Token, X-Client-Type, X-Timezone, Idempotency-Key")
+		c.Header("Access-Control-Expose-Headers", "Content-Length, Content-Type, X-New-Access-Token, X-New-Refresh-Token, Retry-After, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, X-Quota-Messages-Limit, X-Quota-Messages-Remaining, X-Quota-Tokens-Limit, X-Quota-Tokens-Remaining, X-Quota-Reset, Idempotent-Replayed")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusOK)
@@ -85,5 +99,22 @@ func healthCheck(c *gin.Context) {
 
 // SetupChatRoutes registers chat-related endpoints
 func SetupChatRoutes(rg *gin.RouterGroup) {
-	rg.POST("/chat", handlers.HandleChat)
+	rg.POST("/chat", middlewares.AuthMiddleware(), middlewares.ChatRateLimit(), handlers.HandleChat)
+	rg.POST("/chat/stream", middlewares.AuthMiddleware(), middlewares.ChatRateLimit(), handlers.HandleChatStream)
+	// Each chat message on the socket is rate limited as it arrives
+	rg.GET("/chat/ws", middlewares.WebSocketTokenAuth(), middlewares.AuthMiddleware(), handlers.HandleRealtime)
+
+	sessions := rg.Group("/chat/sessions", middlewares.AuthMiddleware())
+	{
+		sessions.POST("", handlers.CreateChatSession)
+		sessions.GET("", handlers.ListChatSessions)
+		sessions.GET("/:sessionId", handlers.GetChatSession)
+		sessions.PATCH("/:sessionId", handlers.UpdateChatSession)
+		sessions.DELETE("/:sessionId", handlers.DeleteChatSession)
+		sessions.GET("/:sessionId/messages", handlers.GetChatSessionMessages)
+		sessions.PUT("/:sessionId/messages/:messageId/feedback", handlers.SubmitMessageFeedback)
+		sessions.DELETE("/:sessionId/messages/:messageId/feedback", handlers.DeleteMessageFeedback)
+		sessions.POST("/:sessionId/journal-draft", middlewares.ChatRateLimit(), handlers.CreateChatSessionJournalDraft)
+	}
+	rg.GET("/personas", middlewares.AuthMiddleware(), handlers.GetPersonas)
 }
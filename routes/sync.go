@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"lambda-server/handlers"
+	"lambda-server/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupSyncRoutes configures the offline sync route used by the mobile clients
+func SetupSyncRoutes(api *gin.RouterGroup) {
+	api.POST("/sync", middlewares.AuthMiddleware(), handlers.HandleSync)
+}
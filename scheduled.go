@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"lambda-server/constants"
+	"lambda-server/database"
+	"lambda-server/handlers"
+	"lambda-server/sentiment"
+	"lambda-server/utils"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Names of the maintenance jobs. An EventBridge rule with the constant input {"job": "<name>"}
+// runs a single job; a rule with the default input runs all of them.
+const (
+	jobPurgeJournalTrash = "purge-journal-trash"
+	jobProcessImports    = "process-imports"
+	jobAnalyzeSentiment  = "analyze-sentiment"
+	jobNotifySOS         = "notify-sos"
+	// Run by hand, not by the default rule: they scan whole tables
+	jobMarkOutdatedSentiment = "mark-outdated-sentiment"
+	jobMigrateChatSessions   = "migrate-chat-sessions"
+)
+
+// scheduledJobs maps each maintenance job to its implementation
+var scheduledJobs = map[string]func(ctx context.Context) (interface{}, error){
+	jobPurgeJournalTrash: purgeJournalTrash,
+	jobProcessImports:    processPendingImports,
+	jobAnalyzeSentiment:  analyzePendingSentiment,
+	jobNotifySOS:         notifyDueSOS,
+
+	jobMarkOutdatedSentiment: markOutdatedSentiment,
+	jobMigrateChatSessions:   migrateChatSessions,
+}
+
+// scheduledJobEvent is the constant input of a rule that runs a single maintenance job
+type scheduledJobEvent struct {
+	Job string `json:"job"`
+}
+
+// isScheduledEvent reports whether the event was emitted by an EventBridge schedule rule
+func isScheduledEvent(event events.CloudWatchEvent) bool {
+	return event.Source == "aws.events" && event.DetailType == "Scheduled Event"
+}
+
+// parseScheduledJobEvent reports whether the event is the constant input of a single-job rule
+func parseScheduledJobEvent(eventBytes []byte) (string, bool) {
+	var event scheduledJobEvent
+	if err := json.Unmarshal(eventBytes, &event); err != nil || event.Job == "" {
+		return "", false
+	}
+	_, ok := scheduledJobs[event.Job]
+	return event.Job, ok
+}
+
+// handleScheduledEvent runs all periodic maintenance jobs, continuing past failures
+func handleScheduledEvent(ctx context.Context, event events.CloudWatchEvent) (interface{}, error) {
+	log.Printf("Scheduled event %s: running maintenance jobs\n", event.ID)
+	results := map[string]interface{}{}
+	var firstErr error
+	for _, name := range []string{jobPurgeJournalTrash, jobProcessImports, jobAnalyzeSentiment, jobNotifySOS} {
+		result, err := runScheduledJob(ctx, name)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		results[name] = result
+	}
+	return results, firstErr
+}
+
+// runScheduledJob runs a single maintenance job by name
+func runScheduledJob(ctx context.Context, name string) (interface{}, error) {
+	result, err := scheduledJobs[name](ctx)
+	if err != nil {
+		log.Printf("Scheduled job %s failed: %v\n", name, err)
+		return nil, err
+	}
+	return result, nil
+}
+
+// purgeJournalTrash permanently deletes journals that have been in trash longer than the retention period
+func purgeJournalTrash(ctx context.Context) (interface{}, error) {
+	cutoff := time.Now().AddDate(0, 0, -utils.JournalTrashRetentionDays())
+	purged, err := database.PurgeTrashedJournals(ctx, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	for _, journal := range purged {
+		if len(journal.AttachmentIds) == 0 {
+			continue
+		}
+		if err := handlers.DeleteJournalAttachments(ctx, journal.UserId, journal.JournalID); err != nil {
+			log.Printf("Failed to clean up attachments of %s: %v\n", journal.JournalID, err)
+		}
+	}
+	log.Printf("Purged %d trashed journal entries\n", len(purged))
+
+	return map[string]interface{}{
+		"purgedJournals": len(purged),
+	}, nil
+}
+
+// processPendingImports runs import jobs that were queued or interrupted before they finished
+func processPendingImports(ctx context.Context) (interface{}, error) {
+	staleBefore := time.Now().Add(-time.Duration(constants.ImportStaleJobMins) * time.Minute)
+	jobs, err := database.GetPendingImportJobs(ctx, staleBefore)
+	if err != nil {
+		return nil, err
+	}
+	failed := 0
+	for _, job := range jobs {
+		if err := handlers.ProcessImportJob(ctx, job); err != nil {
+			log.Printf("Import job %s failed: %v\n", job.JobId, err)
+			failed++
+		}
+	}
+	log.Printf("Processed %d pending import jobs\n", len(jobs))
+
+	return map[string]interface{}{
+		"importJobs":       len(jobs),
+		"failedImportJobs": failed,
+	}, nil
+}
+
+// runLocalImportWorker runs the process-imports job every minute in the local server,
+// which has no EventBridge schedule
+func runLocalImportWorker() {
+	for range time.Tick(time.Minute) {
+		runScheduledJob(context.Background(), jobProcessImports)
+	}
+}
+
+// analyzePendingSentiment analyzes the journal entries and chat messages waiting in the
+// pending index: those the background analysis missed, and those marked by markOutdatedSentiment
+func analyzePendingSentiment(ctx context.Context) (interface{}, error) {
+	journals, err := database.GetJournalsNeedingSentiment(ctx, constants.SentimentBackfillBatch)
+	if err != nil {
+		return nil, err
+	}
+	failed := 0
+	for _, journal := range journals {
+		if err := handlers.AnalyzeJournalSentiment(ctx, journal); err != nil {
+			log.Printf("Sentiment analysis of journal %s failed: %v\n", journal.JournalID, err)
+			failed++
+		}
+	}
+
+	messages, err := database.GetChatMessagesNeedingSentiment(ctx, constants.SentimentBackfillBatch)
+	if err != nil {
+		return nil, err
+	}
+	for _, msg := range messages {
+		if err := handlers.AnalyzeChatMessageSentiment(ctx, msg); err != nil {
+			log.Printf("Sentiment analysis of chat message %s failed: %v\n", msg.SessionIdTimestamp, err)
+			failed++
+		}
+	}
+	log.Printf("Analyzed %d journal entries and %d chat messages\n", len(journals), len(messages))
+
+	return map[string]interface{}{
+		"analyzedJournals": len(journals),
+		"analyzedMessages": len(messages),
+		"failedAnalyses":   failed,
+	}, nil
+}
+
+// markOutdatedSentiment queues for analysis the texts analyzed by an older analyzer version, and
+// chat messages not counted in the per-day totals yet. Invoke it once with {"job":
+// "mark-outdated-sentiment"} after bumping sentiment.Version or deploying the day totals.
+func markOutdatedSentiment(ctx context.Context) (interface{}, error) {
+	marked, err := database.MarkOutdatedSentiment(ctx, sentiment.Version)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Marked %d texts for sentiment analysis\n", marked)
+
+	return map[string]interface{}{
+		"markedTexts": marked,
+	}, nil
+}
+
+// migrateChatSessions copies the session summaries of the old mindmuse_chat_sessions table onto
+// the session records in the chat table. It is run by hand once, after which the old table can
+// be deleted.
+func migrateChatSessions(ctx context.Context) (interface{}, error) {
+	migrated, err := database.MigrateLegacyChatSummaries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Migrated %d chat session summaries\n", migrated)
+
+	return map[string]interface{}{
+		"migratedSummaries": migrated,
+	}, nil
+}
+
+// notifyDueSOS alerts the emergency contacts of SOS alerts whose cancel window has passed. It
+// needs a rule of its own running every minute so contacts hear within about a minute.
+func notifyDueSOS(ctx context.Context) (interface{}, error) {
+	notified, err := handlers.NotifyDueSOS(ctx)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Notified contacts of %d SOS alerts\n", notified)
+
+	return map[string]interface{}{
+		"notifiedSOS": notified,
+	}, nil
+}
@@ -0,0 +1,117 @@
+package sentiment
+
+// valence holds the polarity of common words on VADER's scale, from -4 (most negative) to
+// 4 (most positive). The list favours words people use when writing about their day and
+// their mental health.
+var valence = map[string]float64{
+	// Positive
+	"accomplished": 2.1, "adore": 2.9, "alive": 1.6, "amazing": 2.8, "appreciate": 2.1,
+	"appreciated": 2.0, "awesome": 3.1, "beautiful": 2.9, "best": 3.2, "better": 1.9,
+	"blessed": 2.9, "brave": 2.4, "bright": 1.9, "calm": 1.3, "calmer": 1.4,
+	"capable": 1.6, "care": 2.2, "cared": 1.8, "celebrate": 2.7, "cheerful": 2.5,
+	"comfort": 1.5, "comfortable": 1.7, "confident": 2.2, "content": 1.6, "cool": 1.3,
+	"cozy": 1.8, "delighted": 2.9, "easy": 1.9, "energized": 2.1, "enjoy": 2.2,
+	"enjoyed": 2.3, "excellent": 2.7, "excited": 1.4, "exciting": 2.2, "fantastic": 2.6,
+	"fine": 0.8, "free": 2.3, "fresh": 1.3, "friend": 2.2, "friends": 2.1,
+	"fun": 2.3, "glad": 2.0, "good": 1.9, "grateful": 2.0, "great": 3.1,
+	"happier": 2.4, "happiest": 3.2, "happy": 2.7, "healthy": 1.7, "helped": 1.7,
+	"helpful": 1.8, "hope": 1.9, "hopeful": 2.3, "hug": 2.1, "improve": 1.9,
+	"improved": 2.1, "inspired": 2.2, "joy": 2.8, "joyful": 2.9, "kind": 2.4,
+	"laugh": 2.6, "laughed": 2.0, "like": 1.5, "liked": 1.8, "love": 3.2,
+	"loved": 2.9, "lovely": 2.8, "lucky": 1.8, "meaningful": 2.0, "nice": 1.8,
+	"optimistic": 2.3, "peace": 2.5, "peaceful": 2.2, "perfect": 2.7, "pleasant": 2.3,
+	"pleased": 1.9, "positive": 2.3, "productive": 1.9, "proud": 2.1, "refreshed": 1.9,
+	"relaxed": 2.2, "relief": 2.1, "relieved": 1.6, "rested": 1.6, "safe": 1.9,
+	"satisfied": 1.8, "smile": 1.5, "smiled": 2.5, "strong": 2.3, "success": 2.7,
+	"successful": 2.8, "support": 1.7, "supported": 1.9, "supportive": 2.1, "sweet": 2.0,
+	"thank": 1.5, "thankful": 2.7, "thanks": 1.9, "thrilled": 2.5, "understood": 1.5,
+	"valued": 1.9, "warm": 0.9, "welcome": 2.0, "well": 1.1, "wonderful": 2.7,
+	"worth": 0.9, "yay": 2.4,
+
+	// Negative
+	"abandoned": -2.2, "afraid": -2.2, "agitated": -2.0, "alone": -1.0, "angry": -2.3,
+	"annoyed": -1.6, "anxious": -1.0, "anxiety": -1.8, "argue": -1.4, "argument": -1.5,
+	"ashamed": -2.1, "awful": -2.0, "bad": -2.5, "betrayed": -2.8, "bitter": -1.8,
+	"blame": -1.4, "bored": -1.1, "broke": -1.4, "broken": -2.1, "burden": -1.9,
+	"burnout": -2.3, "cried": -1.6, "cry": -2.1, "crying": -2.1, "dead": -3.3,
+	"depressed": -2.3, "depression": -2.7, "despair": -3.0, "disappointed": -1.9, "disappointing": -2.2,
+	"disgusted": -2.4, "drained": -1.5, "dread": -2.0, "empty": -0.8, "exhausted": -1.5,
+	"fail": -2.5, "failed": -2.3, "failure": -2.3, "fear": -2.2, "fearful": -2.2,
+	"fight": -1.6, "frustrated": -2.4, "frustrating": -1.9, "furious": -2.7, "grief": -2.2,
+	"guilt": -1.9, "guilty": -1.8, "hate": -2.7, "hated": -3.2, "hopeless": -2.0,
+	"horrible": -2.5, "hurt": -2.4, "hurts": -2.1, "ignored": -1.6, "insecure": -1.8,
+	"irritated": -2.0, "isolated": -1.3, "jealous": -2.0, "lonely": -1.5, "lost": -1.3,
+	"mad": -2.2, "miserable": -2.2, "miss": -0.6, "nervous": -1.1, "numb": -1.4,
+	"overwhelmed": -1.5, "pain": -2.3, "painful": -2.4, "panic": -2.3, "pointless": -1.7,
+	"rage": -2.6, "regret": -1.8, "rejected": -1.7, "restless": -1.1, "sad": -2.1,
+	"sadness": -1.9, "scared": -1.9, "shame": -2.1, "sick": -2.3, "sorry": -0.3,
+	"stress": -1.8, "stressed": -1.4, "stressful": -2.0, "struggle": -1.6, "struggling": -1.8,
+	"stuck": -1.4, "suffer": -2.5, "suffering": -2.1, "suicidal": -3.5, "tense": -1.4,
+	"terrible": -2.1, "terrified": -3.0, "tired": -1.9, "trapped": -2.4, "ugly": -2.3,
+	"unhappy": -1.8, "upset": -1.6, "useless": -1.8, "weak": -1.9, "worried": -1.2,
+	"worry": -1.9, "worse": -2.1, "worst": -3.1, "worthless": -1.9, "wrong": -2.1,
+}
+
+// emotionWords maps words to the basic emotions they express, in the spirit of the NRC
+// emotion lexicon. Anxiety covers NRC's fear category.
+var emotionWords = map[string][]string{
+	// Sadness
+	"alone": {EmotionSadness}, "cried": {EmotionSadness}, "cry": {EmotionSadness},
+	"crying": {EmotionSadness}, "depressed": {EmotionSadness}, "depression": {EmotionSadness},
+	"despair": {EmotionSadness, EmotionAnxiety}, "disappointed": {EmotionSadness}, "empty": {EmotionSadness},
+	"grief": {EmotionSadness}, "heartbroken": {EmotionSadness}, "hopeless": {EmotionSadness, EmotionAnxiety},
+	"hurt": {EmotionSadness, EmotionAnger}, "isolated": {EmotionSadness}, "lonely": {EmotionSadness},
+	"lost": {EmotionSadness}, "miserable": {EmotionSadness}, "miss": {EmotionSadness},
+	"mourning": {EmotionSadness}, "numb": {EmotionSadness}, "regret": {EmotionSadness},
+	"sad": {EmotionSadness}, "sadness": {EmotionSadness}, "sorrow": {EmotionSadness},
+	"tears": {EmotionSadness}, "unhappy": {EmotionSadness}, "worthless": {EmotionSadness},
+
+	// Anxiety
+	"afraid": {EmotionAnxiety}, "anxious": {EmotionAnxiety}, "anxiety": {EmotionAnxiety},
+	"dread": {EmotionAnxiety}, "fear": {EmotionAnxiety}, "fearful": {EmotionAnxiety},
+	"insecure": {EmotionAnxiety}, "nervous": {EmotionAnxiety}, "overwhelmed": {EmotionAnxiety},
+	"panic": {EmotionAnxiety}, "restless": {EmotionAnxiety}, "scared": {EmotionAnxiety},
+	"stress": {EmotionAnxiety}, "stressed": {EmotionAnxiety}, "tense": {EmotionAnxiety},
+	"terrified": {EmotionAnxiety}, "uncertain": {EmotionAnxiety}, "uneasy": {EmotionAnxiety},
+	"worried": {EmotionAnxiety}, "worry": {EmotionAnxiety}, "worrying": {EmotionAnxiety},
+
+	// Anger
+	"agitated": {EmotionAnger}, "angry": {EmotionAnger}, "annoyed": {EmotionAnger},
+	"argue": {EmotionAnger}, "argument": {EmotionAnger}, "betrayed": {EmotionAnger, EmotionSadness},
+	"bitter": {EmotionAnger}, "blame": {EmotionAnger}, "disgusted": {EmotionAnger},
+	"fight": {EmotionAnger}, "frustrated": {EmotionAnger}, "furious": {EmotionAnger},
+	"hate": {EmotionAnger}, "hated": {EmotionAnger}, "irritated": {EmotionAnger},
+	"jealous": {EmotionAnger}, "mad": {EmotionAnger}, "rage": {EmotionAnger},
+	"resent": {EmotionAnger}, "unfair": {EmotionAnger},
+
+	// Joy
+	"celebrate": {EmotionJoy}, "cheerful": {EmotionJoy}, "delighted": {EmotionJoy},
+	"enjoy": {EmotionJoy}, "enjoyed": {EmotionJoy}, "excited": {EmotionJoy},
+	"fun": {EmotionJoy}, "glad": {EmotionJoy}, "grateful": {EmotionJoy},
+	"happier": {EmotionJoy}, "happiest": {EmotionJoy}, "happy": {EmotionJoy},
+	"joy": {EmotionJoy}, "joyful": {EmotionJoy}, "laugh": {EmotionJoy},
+	"laughed": {EmotionJoy}, "love": {EmotionJoy}, "loved": {EmotionJoy},
+	"lovely": {EmotionJoy}, "peaceful": {EmotionJoy}, "proud": {EmotionJoy},
+	"smile": {EmotionJoy}, "smiled": {EmotionJoy}, "thankful": {EmotionJoy},
+	"thrilled": {EmotionJoy}, "wonderful": {EmotionJoy}, "yay": {EmotionJoy},
+}
+
+// negations flip and dampen the polarity of the words that follow them
+var negations = map[string]bool{
+	"not": true, "no": true, "never": true, "nothing": true, "nobody": true, "none": true,
+	"neither": true, "nor": true, "nowhere": true, "cannot": true, "without": true,
+	"dont": true, "didnt": true, "doesnt": true, "isnt": true, "wasnt": true, "arent": true,
+	"werent": true, "cant": true, "couldnt": true, "wont": true, "wouldnt": true,
+	"shouldnt": true, "havent": true, "hasnt": true, "hadnt": true, "aint": true,
+}
+
+// boosters strengthen (positive values) or soften (negative values) the word that follows
+var boosters = map[string]float64{
+	"absolutely": boostIncrement, "completely": boostIncrement, "deeply": boostIncrement,
+	"especially": boostIncrement, "extremely": boostIncrement, "incredibly": boostIncrement,
+	"really": boostIncrement, "so": boostIncrement, "super": boostIncrement,
+	"totally": boostIncrement, "truly": boostIncrement, "very": boostIncrement,
+	"barely": -boostIncrement, "hardly": -boostIncrement, "kinda": -boostIncrement,
+	"slightly": -boostIncrement, "somewhat": -boostIncrement, "little": -boostIncrement,
+	"bit": -boostIncrement, "sort": -boostIncrement,
+}
@@ -0,0 +1,192 @@
+// Package sentiment scores text for sentiment and basic emotions with a built-in lexicon.
+// Polarity follows the VADER rules (negation, boosters, "but" clauses, capitals and
+// exclamation marks); emotions are counted from an NRC-style word list.
+package sentiment
+
+import (
+	"math"
+	"strings"
+	"time"
+	"unicode"
+
+	"lambda-server/models"
+)
+
+// Version identifies the lexicon and rules; stored results with an older version are re-analyzed
+const Version = 1
+
+// Emotion names
+const (
+	EmotionSadness = "sadness"
+	EmotionAnxiety = "anxiety"
+	EmotionAnger   = "anger"
+	EmotionJoy     = "joy"
+)
+
+// Labels
+const (
+	LabelPositive = "positive"
+	LabelNegative = "negative"
+	LabelNeutral  = "neutral"
+)
+
+const (
+	boostIncrement   = 0.293 // VADER's B_INCR
+	capsIncrement    = 0.733 // VADER's C_INCR
+	negationScalar   = -0.74 // VADER's N_SCALAR
+	exclaimIncrement = 0.292
+	maxExclaims      = 4
+	normalizeAlpha   = 15
+	labelThreshold   = 0.05
+	negationWindow   = 3
+	// An emotion reaches full intensity when one word in emotionSaturation expresses it
+	emotionSaturation = 10
+)
+
+type token struct {
+	word string // Lowercased, apostrophes removed
+	caps bool   // Written in capitals
+}
+
+// Analyze scores a text. Empty or purely neutral text gets a neutral result.
+func Analyze(text string) models.Sentiment {
+	tokens := tokenize(text)
+	result := models.Sentiment{Label: LabelNeutral, Neutral: 1, Version: Version, AnalyzedAt: time.Now().Unix()}
+	if len(tokens) == 0 {
+		return result
+	}
+	mixedCaps := hasMixedCaps(tokens)
+
+	scores := make([]float64, len(tokens))
+	emotionHits := map[string]float64{}
+	butIndex := -1
+	for i, tok := range tokens {
+		if tok.word == "but" && butIndex < 0 {
+			butIndex = i
+		}
+		negated := isNegated(tokens, i)
+		if !negated {
+			for _, emotion := range emotionWords[tok.word] {
+				emotionHits[emotion]++
+			}
+		}
+
+		score, ok := valence[tok.word]
+		if !ok {
+			continue
+		}
+		if tok.caps && mixedCaps {
+			score += math.Copysign(capsIncrement, score)
+		}
+		for back := 1; back <= negationWindow && i-back >= 0; back++ {
+			if boost, ok := boosters[tokens[i-back].word]; ok {
+				scalar := boost * (1 - 0.05*float64(back-1))
+				if score < 0 {
+					scalar = -scalar
+				}
+				score += scalar
+			}
+		}
+		if negated {
+			score *= negationScalar
+		}
+		scores[i] = score
+	}
+
+	// The clause after "but" carries the writer's real feeling
+	if butIndex >= 0 {
+		for i := range scores {
+			if i < butIndex {
+				scores[i] *= 0.5
+			} else if i > butIndex {
+				scores[i] *= 1.5
+			}
+		}
+	}
+
+	sum := 0.0
+	positive, negative, neutral := 0.0, 0.0, 0.0
+	for _, score := range scores {
+		sum += score
+		switch {
+		case score > 0:
+			positive += score + 1
+		case score < 0:
+			negative += -score + 1
+		default:
+			neutral++
+		}
+	}
+	if sum != 0 {
+		exclaims := math.Min(float64(strings.Count(text, "!")), maxExclaims)
+		sum += math.Copysign(exclaims*exclaimIncrement, sum)
+	}
+
+	result.Compound = round(sum / math.Sqrt(sum*sum+normalizeAlpha))
+	if total := positive + negative + neutral; total > 0 {
+		result.Positive = round(positive / total)
+		result.Negative = round(negative / total)
+		result.Neutral = round(neutral / total)
+	}
+	switch {
+	case result.Compound >= labelThreshold:
+		result.Label = LabelPositive
+	case result.Compound <= -labelThreshold:
+		result.Label = LabelNegative
+	}
+
+	intensity := func(emotion string) float64 {
+		return round(math.Min(1, emotionHits[emotion]*emotionSaturation/float64(len(tokens))))
+	}
+	result.Emotions = models.EmotionScores{
+		Sadness: intensity(EmotionSadness),
+		Anxiety: intensity(EmotionAnxiety),
+		Anger:   intensity(EmotionAnger),
+		Joy:     intensity(EmotionJoy),
+	}
+	return result
+}
+
+// tokenize splits text into words, dropping punctuation and apostrophes so "don't" becomes "dont"
+func tokenize(text string) []token {
+	tokens := []token{}
+	for _, field := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\'' && r != '’'
+	}) {
+		word := strings.NewReplacer("'", "", "’", "").Replace(field)
+		if word == "" {
+			continue
+		}
+		tokens = append(tokens, token{
+			word: strings.ToLower(word),
+			caps: len([]rune(word)) > 1 && strings.ToUpper(word) == word,
+		})
+	}
+	return tokens
+}
+
+// hasMixedCaps reports whether some but not all words are capitalized; shouting only
+// adds emphasis when it stands out from the rest of the text
+func hasMixedCaps(tokens []token) bool {
+	caps := 0
+	for _, tok := range tokens {
+		if tok.caps {
+			caps++
+		}
+	}
+	return caps > 0 && caps < len(tokens)
+}
+
+// isNegated reports whether a negation appears shortly before the token at i
+func isNegated(tokens []token, i int) bool {
+	for back := 1; back <= negationWindow && i-back >= 0; back++ {
+		if negations[tokens[i-back].word] {
+			return true
+		}
+	}
+	return false
+}
+
+func round(value float64) float64 {
+	return math.Round(value*1000) / 1000
+}
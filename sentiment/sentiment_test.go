@@ -0,0 +1,124 @@
+package sentiment
+
+import (
+	"testing"
+	"time"
+
+	"lambda-server/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyzePolarity(t *testing.T) {
+	positive := Analyze("Today was a wonderful day, I feel happy and grateful.")
+	assert.Equal(t, LabelPositive, positive.Label)
+	assert.Greater(t, positive.Compound, 0.5)
+	assert.Equal(t, Version, positive.Version)
+
+	negative := Analyze("I feel so lonely and hopeless, everything is terrible.")
+	assert.Equal(t, LabelNegative, negative.Label)
+	assert.Less(t, negative.Compound, -0.5)
+
+	neutral := Analyze("I went to the store and bought bread.")
+	assert.Equal(t, LabelNeutral, neutral.Label)
+	assert.Equal(t, 0.0, neutral.Compound)
+	assert.Equal(t, 1.0, neutral.Neutral)
+}
+
+func TestAnalyzeEmpty(t *testing.T) {
+	result := Analyze("  ...  ")
+	assert.Equal(t, LabelNeutral, result.Label)
+	assert.Equal(t, models.EmotionScores{}, result.Emotions)
+}
+
+func TestAnalyzeNegation(t *testing.T) {
+	assert.Less(t, Analyze("I am not happy").Compound, 0.0)
+	assert.Less(t, Analyze("I don't feel good about this").Compound, 0.0)
+	assert.Equal(t, 0.0, Analyze("I am not happy").Emotions.Joy)
+}
+
+func TestAnalyzeIntensity(t *testing.T) {
+	plain := Analyze("I am happy").Compound
+	assert.Greater(t, Analyze("I am very happy").Compound, plain)
+	assert.Greater(t, Analyze("I am happy!!").Compound, plain)
+	assert.Greater(t, Analyze("I am HAPPY today").Compound, Analyze("I am happy today").Compound)
+	assert.Less(t, Analyze("I am slightly happy").Compound, plain)
+}
+
+func TestAnalyzeBut(t *testing.T) {
+	// The clause after "but" dominates
+	assert.Less(t, Analyze("The food was good but I feel sad and tired").Compound, 0.0)
+	assert.Greater(t, Analyze("Work was bad but dinner with friends was great").Compound, 0.0)
+}
+
+func TestAnalyzeEmotions(t *testing.T) {
+	result := Analyze("I am anxious and worried about tomorrow")
+	assert.Greater(t, result.Emotions.Anxiety, 0.0)
+	assert.Equal(t, 0.0, result.Emotions.Joy)
+
+	result = Analyze("So angry and frustrated with him")
+	assert.Greater(t, result.Emotions.Anger, result.Emotions.Sadness)
+
+	result = Analyze("sad sad sad")
+	assert.Equal(t, 1.0, result.Emotions.Sadness)
+}
+
+func TestTrend(t *testing.T) {
+	loc := time.UTC
+	samples := []Sample{
+		{Time: time.Date(2026, 3, 10, 9, 0, 0, 0, loc), Sentiment: models.Sentiment{Compound: 0.5, Label: LabelPositive, Emotions: models.EmotionScores{Joy: 1}}},
+		{Time: time.Date(2026, 3, 10, 20, 0, 0, 0, loc), Sentiment: models.Sentiment{Compound: -0.3, Label: LabelNegative}},
+		{Time: time.Date(2026, 3, 9, 8, 0, 0, 0, loc), Sentiment: models.Sentiment{Compound: 0, Label: LabelNeutral}},
+		{Time: time.Date(2026, 3, 16, 8, 0, 0, 0, loc), Sentiment: models.Sentiment{Compound: 0.2, Label: LabelPositive}},
+	}
+
+	daily := Trend(samples, IntervalDay, loc)
+	assert.Len(t, daily, 3)
+	assert.Equal(t, "2026-03-09", daily[0].Period)
+	assert.Equal(t, "2026-03-10", daily[1].Period)
+	assert.Equal(t, 2, daily[1].Count)
+	assert.InDelta(t, 0.1, daily[1].Compound, 1e-9)
+	assert.InDelta(t, 0.5, daily[1].Emotions.Joy, 1e-9)
+	assert.Equal(t, 1, daily[1].Positive)
+	assert.Equal(t, 1, daily[1].Negative)
+
+	// 2026-03-09 is a Monday
+	weekly := Trend(samples, IntervalWeek, loc)
+	assert.Len(t, weekly, 2)
+	assert.Equal(t, "2026-03-09", weekly[0].Period)
+	assert.Equal(t, 3, weekly[0].Count)
+	assert.Equal(t, "2026-03-16", weekly[1].Period)
+}
+
+func TestTrendUsesLocation(t *testing.T) {
+	kolkata, _ := time.LoadLocation("Asia/Kolkata")
+	samples := []Sample{{Time: time.Date(2026, 3, 9, 20, 0, 0, 0, time.UTC), Sentiment: models.Sentiment{Label: LabelNeutral}}}
+	assert.Equal(t, "2026-03-10", Trend(samples, IntervalDay, kolkata)[0].Period)
+}
+
+func TestTrendFromDays(t *testing.T) {
+	happy := models.Sentiment{Compound: 0.5, Label: LabelPositive, Emotions: models.EmotionScores{Joy: 1}}
+	sad := models.Sentiment{Compound: -0.3, Label: LabelNegative, Emotions: models.EmotionScores{Sadness: 0.6}}
+
+	monday := models.SentimentDay{Day: "20260309"}
+	AddToDay(&monday, happy, 1)
+	AddToDay(&monday, sad, 1)
+	tuesday := models.SentimentDay{Day: "20260310"}
+	AddToDay(&tuesday, sad, 1)
+	AddToDay(&tuesday, sad, -1) // Re-analyzed or deleted
+
+	daily := TrendFromDays([]models.SentimentDay{tuesday, monday}, IntervalDay)
+	assert.Len(t, daily, 1, "days emptied again are left out")
+	assert.Equal(t, "2026-03-09", daily[0].Period)
+	assert.Equal(t, 2, daily[0].Count)
+	assert.InDelta(t, 0.1, daily[0].Compound, 1e-9)
+	assert.InDelta(t, 0.3, daily[0].Emotions.Sadness, 1e-9)
+	assert.Equal(t, 1, daily[0].Positive)
+	assert.Equal(t, 1, daily[0].Negative)
+
+	AddToDay(&tuesday, happy, 1)
+	weekly := TrendFromDays([]models.SentimentDay{monday, tuesday}, IntervalWeek)
+	assert.Len(t, weekly, 1)
+	assert.Equal(t, 3, weekly[0].Count)
+	assert.Equal(t, 2, weekly[0].Positive)
+}
@@ -0,0 +1,115 @@
+package sentiment
+
+import (
+	"sort"
+	"time"
+
+	"lambda-server/models"
+)
+
+// Trend intervals
+const (
+	IntervalDay  = "day"
+	IntervalWeek = "week" // Weeks start on Monday
+)
+
+// Sample is an analyzed text and the time it was written
+type Sample struct {
+	Time      time.Time
+	Sentiment models.Sentiment
+}
+
+// Trend averages samples per day or week in loc, oldest period first. Periods without
+// samples are left out.
+func Trend(samples []Sample, interval string, loc *time.Location) []models.SentimentTrendPoint {
+	days := map[string]*models.SentimentDay{}
+	for _, sample := range samples {
+		key := sample.Time.In(loc).Format("20060102")
+		if days[key] == nil {
+			days[key] = &models.SentimentDay{Day: key}
+		}
+		AddToDay(days[key], sample.Sentiment, 1)
+	}
+	totals := make([]models.SentimentDay, 0, len(days))
+	for _, day := range days {
+		totals = append(totals, *day)
+	}
+	return TrendFromDays(totals, interval)
+}
+
+// AddToDay adds an analysis to a day's totals (sign 1) or takes it out again (sign -1)
+func AddToDay(day *models.SentimentDay, s models.Sentiment, sign int) {
+	weight := float64(sign)
+	day.Count += sign
+	day.Compound += weight * s.Compound
+	day.Sadness += weight * s.Emotions.Sadness
+	day.Anxiety += weight * s.Emotions.Anxiety
+	day.Anger += weight * s.Emotions.Anger
+	day.Joy += weight * s.Emotions.Joy
+	switch s.Label {
+	case LabelPositive:
+		day.Positive += sign
+	case LabelNegative:
+		day.Negative += sign
+	default:
+		day.Neutral += sign
+	}
+}
+
+// TrendFromDays averages per-day totals per day or week, oldest period first. Days without
+// texts are left out.
+func TrendFromDays(days []models.SentimentDay, interval string) []models.SentimentTrendPoint {
+	buckets := map[string]*models.SentimentDay{}
+	for _, day := range days {
+		date, err := time.Parse("20060102", day.Day)
+		if err != nil || day.Count <= 0 {
+			continue
+		}
+		period := periodStart(date, interval).Format("2006-01-02")
+		b, ok := buckets[period]
+		if !ok {
+			b = &models.SentimentDay{Day: period}
+			buckets[period] = b
+		}
+		b.Count += day.Count
+		b.Compound += day.Compound
+		b.Sadness += day.Sadness
+		b.Anxiety += day.Anxiety
+		b.Anger += day.Anger
+		b.Joy += day.Joy
+		b.Positive += day.Positive
+		b.Negative += day.Negative
+		b.Neutral += day.Neutral
+	}
+
+	points := make([]models.SentimentTrendPoint, 0, len(buckets))
+	for _, b := range buckets {
+		n := float64(b.Count)
+		points = append(points, models.SentimentTrendPoint{
+			Period:   b.Day,
+			Count:    b.Count,
+			Compound: round(b.Compound / n),
+			Emotions: models.EmotionScores{
+				Sadness: round(b.Sadness / n),
+				Anxiety: round(b.Anxiety / n),
+				Anger:   round(b.Anger / n),
+				Joy:     round(b.Joy / n),
+			},
+			Positive: b.Positive,
+			Negative: b.Negative,
+			Neutral:  b.Neutral,
+		})
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Period < points[j].Period })
+	return points
+}
+
+// periodStart returns midnight of the first day of the period containing t
+func periodStart(t time.Time, interval string) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if interval == IntervalWeek {
+		offset := (int(day.Weekday()) + 6) % 7 // days since Monday
+		day = day.AddDate(0, 0, -offset)
+	}
+	return day
+}
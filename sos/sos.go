@@ -0,0 +1,148 @@
+// Package sos is the lifecycle of an SOS alert: the statuses it moves through, who may move it
+// there, and the signed tokens emergency contacts use to acknowledge it.
+//
+// A user's own SOS is raised with a short window to cancel it as a false alarm; when the
+// window passes the system alerts the contacts and it becomes notified. A contact can then
+// acknowledge it, and the user resolves it once they are safe. An SOS raised from a high-risk
+// text alerts the contacts straight away when the user has consented to that.
+package sos
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"lambda-server/constants"
+	"lambda-server/models"
+)
+
+var (
+	// ErrInvalidTransition is returned when an SOS cannot move to a status from its current one
+	ErrInvalidTransition = errors.New("invalid SOS status change")
+	// ErrInvalidToken is returned for an acknowledge token that is malformed, forged or expired
+	ErrInvalidToken = errors.New("invalid or expired acknowledge token")
+)
+
+// transitions maps each status to the statuses it can move to and who may move it there
+var transitions = map[string]map[string]string{
+	constants.SOSStatusRaised: {
+		constants.SOSStatusNotified:  constants.SOSActorSystem,
+		constants.SOSStatusCancelled: constants.SOSActorUser,
+		constants.SOSStatusResolved:  constants.SOSActorUser,
+	},
+	constants.SOSStatusNotified: {
+		constants.SOSStatusAcknowledged: constants.SOSActorContact,
+		constants.SOSStatusResolved:     constants.SOSActorUser,
+	},
+	// Each contact who acknowledges is recorded
+	constants.SOSStatusAcknowledged: {
+		constants.SOSStatusAcknowledged: constants.SOSActorContact,
+		constants.SOSStatusResolved:     constants.SOSActorUser,
+	},
+}
+
+// Status returns the status of an SOS, reading alerts from before the lifecycle as raised
+func Status(alert models.SOS) string {
+	if alert.Status == constants.SOSStatusOpen || alert.Status == "" {
+		return constants.SOSStatusRaised
+	}
+	return alert.Status
+}
+
+// Active reports whether an SOS is still in progress
+func Active(alert models.SOS) bool {
+	_, ok := transitions[Status(alert)]
+	return ok
+}
+
+// From returns the statuses, as stored, from which actor may move an SOS to status. Raised
+// includes "open" so older alerts can be handled too.
+func From(status, actor string) []string {
+	from := []string{}
+	for current, next := range transitions {
+		if next[status] == actor {
+			from = append(from, current)
+			if current == constants.SOSStatusRaised {
+				from = append(from, constants.SOSStatusOpen)
+			}
+		}
+	}
+	return from
+}
+
+// CheckTransition reports whether actor may move the SOS to status
+func CheckTransition(alert models.SOS, status, actor string) error {
+	current := Status(alert)
+	if transitions[current][status] != actor {
+		return fmt.Errorf("%w: %s cannot move an SOS from %s to %s", ErrInvalidTransition, actor, current, status)
+	}
+	return nil
+}
+
+// Event is the audit trail entry of a status change
+func Event(status, actor string, at time.Time, note string) models.SOSEvent {
+	return models.SOSEvent{Status: status, At: at.Unix(), Actor: actor, Note: note}
+}
+
+// Validate checks and trims the body of POST /sos
+func Validate(req models.SOSRequest, messageMaxLen int) (models.SOSRequest, error) {
+	req.Message = strings.TrimSpace(req.Message)
+	if len([]rune(req.Message)) > messageMaxLen {
+		return req, fmt.Errorf("message must be at most %d characters", messageMaxLen)
+	}
+	if loc := req.Location; loc != nil {
+		if loc.Latitude < -90 || loc.Latitude > 90 || loc.Longitude < -180 || loc.Longitude > 180 {
+			return req, errors.New("location must have a latitude between -90 and 90 and a longitude between -180 and 180")
+		}
+		if loc.Accuracy < 0 {
+			return req, errors.New("location accuracy must not be negative")
+		}
+	}
+	return req, nil
+}
+
+// AckClaims identify the SOS and contact an acknowledge token was issued for
+type AckClaims struct {
+	UserId    string `json:"u"`
+	Timestamp int64  `json:"t"`
+	Contact   int    `json:"c"` // Index into the user's emergency contacts
+	ExpiresAt int64  `json:"e"`
+}
+
+// AckToken signs claims into a token for an acknowledge link
+func AckToken(secret []byte, claims AckClaims) string {
+	payload, _ := json.Marshal(claims)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + sign(secret, encoded)
+}
+
+// ParseAckToken verifies a token from AckToken and returns its claims. Without a secret every
+// token is invalid.
+func ParseAckToken(secret []byte, token string, now time.Time) (AckClaims, error) {
+	var claims AckClaims
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || len(secret) == 0 || !hmac.Equal([]byte(signature), []byte(sign(secret, encoded))) {
+		return claims, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || json.Unmarshal(payload, &claims) != nil {
+		return claims, ErrInvalidToken
+	}
+	if now.Unix() > claims.ExpiresAt {
+		return claims, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// sign returns the signature of an acknowledge token's payload. The prefix keeps it from
+// being valid for anything else signed with the same secret.
+func sign(secret []byte, encoded string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("sos-ack:" + encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
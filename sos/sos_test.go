@@ -0,0 +1,91 @@
+package sos
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"lambda-server/constants"
+	"lambda-server/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func alert(status string) models.SOS {
+	return models.SOS{UserID: "u1", Timestamp: 100, Status: status}
+}
+
+func TestCheckTransition(t *testing.T) {
+	raised := alert(constants.SOSStatusRaised)
+	assert.NoError(t, CheckTransition(raised, constants.SOSStatusCancelled, constants.SOSActorUser))
+	assert.NoError(t, CheckTransition(raised, constants.SOSStatusNotified, constants.SOSActorSystem))
+	assert.ErrorIs(t, CheckTransition(raised, constants.SOSStatusNotified, constants.SOSActorUser), ErrInvalidTransition)
+	assert.ErrorIs(t, CheckTransition(raised, constants.SOSStatusAcknowledged, constants.SOSActorContact), ErrInvalidTransition)
+
+	notified := alert(constants.SOSStatusNotified)
+	assert.ErrorIs(t, CheckTransition(notified, constants.SOSStatusCancelled, constants.SOSActorUser), ErrInvalidTransition,
+		"contacts were already alerted")
+	assert.NoError(t, CheckTransition(notified, constants.SOSStatusAcknowledged, constants.SOSActorContact))
+	assert.NoError(t, CheckTransition(alert(constants.SOSStatusAcknowledged), constants.SOSStatusResolved, constants.SOSActorUser))
+
+	for _, status := range []string{constants.SOSStatusResolved, constants.SOSStatusCancelled} {
+		assert.ErrorIs(t, CheckTransition(alert(status), constants.SOSStatusResolved, constants.SOSActorUser), ErrInvalidTransition)
+		assert.False(t, Active(alert(status)))
+	}
+}
+
+func TestOlderAlertsAreRaised(t *testing.T) {
+	open := alert(constants.SOSStatusOpen)
+	assert.Equal(t, constants.SOSStatusRaised, Status(open))
+	assert.True(t, Active(open))
+	assert.NoError(t, CheckTransition(open, constants.SOSStatusResolved, constants.SOSActorUser))
+	assert.ElementsMatch(t, []string{constants.SOSStatusRaised, constants.SOSStatusOpen},
+		From(constants.SOSStatusCancelled, constants.SOSActorUser))
+	assert.ElementsMatch(t, []string{constants.SOSStatusNotified, constants.SOSStatusAcknowledged},
+		From(constants.SOSStatusAcknowledged, constants.SOSActorContact))
+}
+
+func TestValidate(t *testing.T) {
+	req, err := Validate(models.SOSRequest{
+		Message:  "  please call me  ",
+		Location: &models.SOSLocation{Latitude: 12.97, Longitude: 77.59, Accuracy: 20},
+	}, 500)
+	require.NoError(t, err)
+	assert.Equal(t, "please call me", req.Message)
+
+	_, err = Validate(models.SOSRequest{}, 500)
+	assert.NoError(t, err)
+	_, err = Validate(models.SOSRequest{Message: strings.Repeat("a", 501)}, 500)
+	assert.Error(t, err)
+	_, err = Validate(models.SOSRequest{Location: &models.SOSLocation{Latitude: 91}}, 500)
+	assert.Error(t, err)
+	_, err = Validate(models.SOSRequest{Location: &models.SOSLocation{Accuracy: -1}}, 500)
+	assert.Error(t, err)
+}
+
+func TestAckToken(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Unix(1000, 0)
+	claims := AckClaims{UserId: "u1", Timestamp: 100, Contact: 2, ExpiresAt: 2000}
+	token := AckToken(secret, claims)
+
+	parsed, err := ParseAckToken(secret, token, now)
+	require.NoError(t, err)
+	assert.Equal(t, claims, parsed)
+
+	_, err = ParseAckToken(secret, token, time.Unix(2001, 0))
+	assert.ErrorIs(t, err, ErrInvalidToken, "expired")
+	_, err = ParseAckToken([]byte("other"), token, now)
+	assert.ErrorIs(t, err, ErrInvalidToken, "wrong secret")
+	_, err = ParseAckToken(nil, AckToken(nil, claims), now)
+	assert.ErrorIs(t, err, ErrInvalidToken, "no secret")
+
+	forged := AckToken(secret, AckClaims{UserId: "u2", Timestamp: 100, ExpiresAt: 2000})
+	payload, _, _ := strings.Cut(forged, ".")
+	_, signature, _ := strings.Cut(token, ".")
+	_, err = ParseAckToken(secret, payload+"."+signature, now)
+	assert.ErrorIs(t, err, ErrInvalidToken, "payload swapped")
+	_, err = ParseAckToken(secret, "garbage", now)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
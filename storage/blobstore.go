@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"lambda-server/constants"
+	"lambda-server/utils"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ErrBlobNotFound is returned when a blob does not exist in the store
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore stores attachment bytes and hands out time-limited URLs to them
+type BlobStore interface {
+	// Put stores the contents of r under key, replacing any existing blob
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Get opens the blob stored under key
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob stored under key; deleting a missing blob is not an error
+	Delete(ctx context.Context, key string) error
+	// SignedUploadURL returns a URL that accepts a PUT of the blob with contentType until it expires
+	SignedUploadURL(ctx context.Context, key, contentType string, expires time.Duration) (string, error)
+	// SignedDownloadURL returns a URL that serves the blob as contentType until it expires
+	SignedDownloadURL(ctx context.Context, key, contentType string, expires time.Duration) (string, error)
+}
+
+var (
+	defaultStore     BlobStore
+	defaultStoreErr  error
+	defaultStoreOnce sync.Once
+)
+
+// Init configures the default blob store from the environment. It is called at startup so a
+// misconfigured deployment is logged before it serves requests; only the routes that need the
+// store fail, through Default.
+//
+// Blobs are kept in the S3 bucket ATTACHMENT_S3_BUCKET, which hands out presigned URLs. When
+// running locally without a bucket they are kept on disk under ATTACHMENT_STORAGE_DIR instead;
+// on Lambda the bucket is required because each instance's disk is private and does not survive
+// it. The local store serves its blobs through the API at signed URLs rooted at
+// ATTACHMENT_BASE_URL and signed with ATTACHMENT_SIGNING_SECRET, or JWT_SECRET when it is unset;
+// one of them must be set, as an empty key would let anyone sign URLs.
+func Init() error {
+	defaultStoreOnce.Do(func() {
+		defaultStore, defaultStoreErr = newDefaultStore()
+	})
+	return defaultStoreErr
+}
+
+// Default returns the blob store configured by Init, or an error if the configuration is invalid
+func Default() (BlobStore, error) {
+	if err := Init(); err != nil {
+		return nil, fmt.Errorf("blob store is not configured: %w", err)
+	}
+	return defaultStore, nil
+}
+
+func newDefaultStore() (BlobStore, error) {
+	if bucket := os.Getenv(constants.AttachmentS3BucketEnv); bucket != constants.EMPTY_STRING {
+		cfg, err := config.LoadDefaultConfig(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("failed to load AWS config: %w", err)
+		}
+		if cfg.Region == constants.EMPTY_STRING {
+			cfg.Region = "ap-south-1"
+		}
+		return NewS3BlobStore(s3.NewFromConfig(cfg), bucket), nil
+	}
+	if !utils.IsRunningLocally() {
+		return nil, fmt.Errorf("%s must be set: local disk is not shared between Lambda instances", constants.AttachmentS3BucketEnv)
+	}
+
+	secret := os.Getenv(constants.AttachmentSigningSecretEnv)
+	if secret == constants.EMPTY_STRING {
+		secret = os.Getenv("JWT_SECRET")
+	}
+	if secret == constants.EMPTY_STRING {
+		return nil, fmt.Errorf("%s or JWT_SECRET must be set to sign attachment URLs", constants.AttachmentSigningSecretEnv)
+	}
+	baseURL := os.Getenv(constants.AttachmentBaseURLEnv)
+	if baseURL == constants.EMPTY_STRING {
+		baseURL = "http://localhost:8080"
+	}
+
+	dir := os.Getenv(constants.AttachmentStorageDirEnv)
+	if dir == constants.EMPTY_STRING {
+		dir = "attachments"
+	}
+	return NewLocalBlobStore(dir, baseURL, []byte(secret)), nil
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"lambda-server/constants"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDefaultStore(t *testing.T) {
+	t.Setenv(constants.AttachmentSigningSecretEnv, "")
+	t.Setenv("JWT_SECRET", "")
+	t.Setenv(constants.AttachmentS3BucketEnv, "")
+	t.Setenv("AWS_LAMBDA_FUNCTION_NAME", "")
+	_, err := newDefaultStore()
+	assert.Error(t, err, "an empty signing key must be refused")
+
+	t.Setenv("JWT_SECRET", "secret")
+	store, err := newDefaultStore()
+	require.NoError(t, err)
+	assert.IsType(t, &LocalBlobStore{}, store, "local disk is fine when running locally")
+
+	t.Setenv("AWS_LAMBDA_FUNCTION_NAME", "mindmuse")
+	_, err = newDefaultStore()
+	assert.Error(t, err, "Lambda needs a bucket")
+
+	t.Setenv("JWT_SECRET", "")
+	t.Setenv(constants.AttachmentS3BucketEnv, "mindmuse-attachments")
+	store, err = newDefaultStore()
+	require.NoError(t, err, "S3 presigns its own URLs, so no signing key is needed")
+	assert.IsType(t, &S3BlobStore{}, store)
+}
+
+func TestSignedURL(t *testing.T) {
+	signer := newURLSigner("https://api.example.com/", []byte("secret"))
+	_, err := signer.SignedDownloadURL(context.Background(), "../etc/passwd", "image/png", 60)
+	assert.Error(t, err)
+
+	store := NewLocalBlobStore(t.TempDir(), "https://api.example.com", []byte("secret"))
+	assert.Error(t, store.VerifySignature(OpDownload, "a/b", 1, "bad"), "expired")
+	expires := int64(1 << 40)
+	assert.NoError(t, store.VerifySignature(OpDownload, "a/b", expires, store.sign(OpDownload, "a/b", expires)))
+	assert.Error(t, store.VerifySignature(OpUpload, "a/b", expires, store.sign(OpDownload, "a/b", expires)))
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3BlobStore keeps blobs as objects in an S3 bucket, so every Lambda instance sees the same
+// blobs. Its signed URLs are S3 presigned URLs, so the bytes go straight between the client and
+// the bucket instead of through the Lambda, whose request and response bodies are limited to 6 MB.
+type S3BlobStore struct {
+	client    *s3.Client
+	presigner *s3.PresignClient
+	bucket    string
+}
+
+// NewS3BlobStore creates an S3BlobStore for bucket
+func NewS3BlobStore(client *s3.Client, bucket string) *S3BlobStore {
+	return &S3BlobStore{
+		client:    client,
+		presigner: s3.NewPresignClient(client),
+		bucket:    bucket,
+	}
+}
+
+// Put uploads the blob as an object; S3 makes it visible only once it is complete
+func (s *S3BlobStore) Put(ctx context.Context, key string, r io.Reader) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Body:   r,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write blob: %w", err)
+	}
+	return nil
+}
+
+// Get opens the object for reading
+func (s *S3BlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, ErrBlobNotFound
+		}
+		return nil, fmt.Errorf("failed to read blob: %w", err)
+	}
+	return out.Body, nil
+}
+
+// Delete removes the object; S3 does not report missing objects
+func (s *S3BlobStore) Delete(ctx context.Context, key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete blob: %w", err)
+	}
+	return nil
+}
+
+// SignedUploadURL presigns a PUT of the object. The content type is part of the signature,
+// so the upload must send it as its Content-Type header.
+func (s *S3BlobStore) SignedUploadURL(ctx context.Context, key, contentType string, expires time.Duration) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+	req, err := s.presigner.PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", fmt.Errorf("failed to presign upload: %w", err)
+	}
+	return req.URL, nil
+}
+
+// SignedDownloadURL presigns a GET of the object that is served with contentType
+func (s *S3BlobStore) SignedDownloadURL(ctx context.Context, key, contentType string, expires time.Duration) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+	req, err := s.presigner.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket:               aws.String(s.bucket),
+		Key:                  aws.String(key),
+		ResponseContentType:  aws.String(contentType),
+		ResponseCacheControl: aws.String("private, max-age=300"),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", fmt.Errorf("failed to presign download: %w", err)
+	}
+	return req.URL, nil
+}
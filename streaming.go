@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+
+	"lambda-server/constants"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdaurl"
+)
+
+// responseStreamingEnabled reports whether the Function URL uses InvokeMode RESPONSE_STREAM.
+// In that mode every Function URL response must be streamed.
+func responseStreamingEnabled() bool {
+	return os.Getenv(constants.LambdaResponseStreamingEnv) == "true"
+}
+
+// streamFunctionURLRequest serves a Function URL request through the router with Lambda response
+// streaming, so Server-Sent Events reach the client as they are written instead of when the
+// handler returns. Requires the provided.al2 runtime or a build with -tags lambda.norpc.
+func streamFunctionURLRequest(ctx context.Context, event events.LambdaFunctionURLRequest) (interface{}, error) {
+	handler := lambdaurl.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		router.ServeHTTP(flushingWriter{w}, r)
+	}))
+	return handler(ctx, &event)
+}
+
+// flushingWriter gives the streaming writer the Flush method gin expects. The streaming
+// writer is unbuffered, so there is nothing to flush.
+type flushingWriter struct {
+	http.ResponseWriter
+}
+
+// Flush implements http.Flusher
+func (flushingWriter) Flush() {}
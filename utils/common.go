@@ -22,6 +22,12 @@ func GenerateJournalID() string {
 	return fmt.Sprintf("journal_%s", base64.URLEncoding.EncodeToString(bytes)[:22])
 }
 
+func GenerateAttachmentID() string {
+	bytes := make([]byte, 16)
+	rand.Read(bytes)
+	return fmt.Sprintf("attachment_%s", base64.URLEncoding.EncodeToString(bytes)[:22])
+}
+
 // IsRunningLocally checks if the application is running locally
 func IsRunningLocally() bool {
 	return os.Getenv("AWS_LAMBDA_FUNCTION_NAME") == ""
@@ -90,8 +96,32 @@ func ValidateDOBFormat(dob string) error {
 }
 
 // GeneratePasswordResetToken generates a secure random token for password reset
+func GenerateImportJobID() string {
+	bytes := make([]byte, 16)
+	rand.Read(bytes)
+	return fmt.Sprintf("import_%s", base64.URLEncoding.EncodeToString(bytes)[:22])
+}
+
+func GeneratePromptID() string {
+	bytes := make([]byte, 16)
+	rand.Read(bytes)
+	return fmt.Sprintf("prompt_%s", base64.URLEncoding.EncodeToString(bytes)[:22])
+}
+
+func GeneratePersonaID() string {
+	bytes := make([]byte, 16)
+	rand.Read(bytes)
+	return fmt.Sprintf("persona_%s", base64.URLEncoding.EncodeToString(bytes)[:22])
+}
+
 func GeneratePasswordResetToken() string {
 	bytes := make([]byte, 32)
 	rand.Read(bytes)
 	return base64.URLEncoding.EncodeToString(bytes)
 }
+
+func GenerateChatSessionID() string {
+	bytes := make([]byte, 16)
+	rand.Read(bytes)
+	return fmt.Sprintf("session_%s", base64.URLEncoding.EncodeToString(bytes)[:22])
+}
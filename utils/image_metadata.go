@@ -0,0 +1,238 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	exifGPSInfoTag = 0x8825
+	xmpNamespace   = "http://ns.adobe.com/xap/1.0/\x00"
+	xmpExtension   = "http://ns.adobe.com/xmp/extension/\x00"
+	pngXMPKeyword  = "XML:com.adobe.xmp"
+)
+
+var pngSignature = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}
+
+// StripImageLocation removes embedded location data from an uploaded image.
+// For JPEG the GPS IFD of the EXIF block is blanked (other EXIF tags such as orientation are kept),
+// and for every format XMP packets and PNG/WebP EXIF chunks are dropped because they can carry coordinates.
+// Content types other than JPEG, PNG and WebP are returned unchanged.
+func StripImageLocation(data []byte, contentType string) ([]byte, error) {
+	switch contentType {
+	case "image/jpeg":
+		return stripJPEGLocation(data)
+	case "image/png":
+		return stripPNGLocation(data)
+	case "image/webp":
+		return stripWebPLocation(data)
+	default:
+		return data, nil
+	}
+}
+
+// stripJPEGLocation walks the JPEG segments up to the start of scan
+func stripJPEGLocation(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, fmt.Errorf("invalid JPEG data")
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+	pos := 2
+	for pos < len(data) {
+		if data[pos] != 0xFF || pos+1 >= len(data) {
+			return nil, fmt.Errorf("invalid JPEG segment at offset %d", pos)
+		}
+		marker := data[pos+1]
+
+		// Fill bytes and standalone markers carry no length
+		if marker == 0xFF {
+			out.WriteByte(0xFF)
+			pos++
+			continue
+		}
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			out.Write(data[pos : pos+2])
+			pos += 2
+			continue
+		}
+		// Start of scan: the remaining bytes are entropy-coded image data
+		if marker == 0xDA || marker == 0xD9 {
+			out.Write(data[pos:])
+			return out.Bytes(), nil
+		}
+
+		if pos+4 > len(data) {
+			return nil, fmt.Errorf("truncated JPEG segment at offset %d", pos)
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, fmt.Errorf("invalid JPEG segment length at offset %d", pos)
+		}
+		payload := data[pos+4 : end]
+
+		if marker == 0xE1 {
+			switch {
+			case bytes.HasPrefix(payload, []byte("Exif\x00\x00")):
+				segment := append([]byte{}, data[pos:end]...)
+				blankGPSInfo(segment[4+6:])
+				out.Write(segment)
+			case bytes.HasPrefix(payload, []byte(xmpNamespace)), bytes.HasPrefix(payload, []byte(xmpExtension)):
+				// Drop XMP packets entirely
+			default:
+				out.Write(data[pos:end])
+			}
+		} else {
+			out.Write(data[pos:end])
+		}
+		pos = end
+	}
+	return out.Bytes(), nil
+}
+
+// blankGPSInfo empties the GPS IFD referenced from IFD0 of a TIFF/EXIF block in place.
+// Malformed blocks are left untouched.
+func blankGPSInfo(tiff []byte) {
+	if len(tiff) < 8 {
+		return
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return
+	}
+
+	ifd0 := int(order.Uint32(tiff[4:8]))
+	if ifd0+2 > len(tiff) {
+		return
+	}
+	entries := int(order.Uint16(tiff[ifd0 : ifd0+2]))
+	gpsOffset := -1
+	for i := 0; i < entries; i++ {
+		entry := ifd0 + 2 + i*12
+		if entry+12 > len(tiff) {
+			return
+		}
+		if order.Uint16(tiff[entry:entry+2]) == exifGPSInfoTag {
+			gpsOffset = int(order.Uint32(tiff[entry+8 : entry+12]))
+			break
+		}
+	}
+	if gpsOffset < 0 || gpsOffset+2 > len(tiff) {
+		return
+	}
+
+	gpsEntries := int(order.Uint16(tiff[gpsOffset : gpsOffset+2]))
+	for i := 0; i < gpsEntries; i++ {
+		entry := gpsOffset + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		size := tiffTypeSize(order.Uint16(tiff[entry+2:entry+4])) * int(order.Uint32(tiff[entry+4:entry+8]))
+		if size > 4 {
+			valueOffset := int(order.Uint32(tiff[entry+8 : entry+12]))
+			if valueOffset >= 0 && size <= len(tiff)-valueOffset {
+				clear(tiff[valueOffset : valueOffset+size])
+			}
+		}
+		clear(tiff[entry : entry+12])
+	}
+	// An IFD with zero entries (and, thanks to the cleared bytes, no next IFD) is still valid
+	order.PutUint16(tiff[gpsOffset:gpsOffset+2], 0)
+}
+
+// tiffTypeSize returns the byte size of a single value of a TIFF field type
+func tiffTypeSize(fieldType uint16) int {
+	switch fieldType {
+	case 1, 2, 6, 7: // BYTE, ASCII, SBYTE, UNDEFINED
+		return 1
+	case 3, 8: // SHORT, SSHORT
+		return 2
+	case 4, 9, 11: // LONG, SLONG, FLOAT
+		return 4
+	case 5, 10, 12: // RATIONAL, SRATIONAL, DOUBLE
+		return 8
+	default:
+		return 0
+	}
+}
+
+// stripPNGLocation drops eXIf chunks and XMP text chunks from a PNG
+func stripPNGLocation(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, fmt.Errorf("invalid PNG data")
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(pngSignature)
+	pos := len(pngSignature)
+	for pos < len(data) {
+		if pos+8 > len(data) {
+			return nil, fmt.Errorf("truncated PNG chunk at offset %d", pos)
+		}
+		length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		chunkType := string(data[pos+4 : pos+8])
+		end := pos + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, fmt.Errorf("invalid PNG chunk length at offset %d", pos)
+		}
+		payload := data[pos+8 : pos+8+length]
+
+		drop := chunkType == "eXIf"
+		if chunkType == "iTXt" || chunkType == "tEXt" || chunkType == "zTXt" {
+			drop = bytes.HasPrefix(payload, []byte(pngXMPKeyword+"\x00"))
+		}
+		if !drop {
+			out.Write(data[pos:end])
+		}
+		pos = end
+	}
+	return out.Bytes(), nil
+}
+
+// stripWebPLocation drops the EXIF and XMP chunks of a WebP and clears their VP8X flags
+func stripWebPLocation(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, fmt.Errorf("invalid WebP data")
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:12])
+	pos := 12
+	for pos < len(data) {
+		if pos+8 > len(data) {
+			return nil, fmt.Errorf("truncated WebP chunk at offset %d", pos)
+		}
+		fourCC := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		end := pos + 8 + size + size%2 // chunks are padded to an even size
+		if size < 0 || end > len(data) {
+			return nil, fmt.Errorf("invalid WebP chunk size at offset %d", pos)
+		}
+
+		switch fourCC {
+		case "EXIF", "XMP ":
+			// Drop metadata chunks
+		case "VP8X":
+			chunk := append([]byte{}, data[pos:end]...)
+			if len(chunk) > 8 {
+				chunk[8] &^= 0x08 | 0x04 // EXIF and XMP presence flags
+			}
+			out.Write(chunk)
+		default:
+			out.Write(data[pos:end])
+		}
+		pos = end
+	}
+
+	result := out.Bytes()
+	binary.LittleEndian.PutUint32(result[4:8], uint32(len(result)-8))
+	return result, nil
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// buildExifJPEG builds a minimal JPEG whose EXIF block has an orientation tag and a GPS IFD with a latitude
+func buildExifJPEG() []byte {
+	tiff := []byte("II*\x00")
+	tiff = binary.LittleEndian.AppendUint32(tiff, 8)
+	// IFD0: orientation + GPS pointer
+	tiff = binary.LittleEndian.AppendUint16(tiff, 2)
+	tiff = append(tiff, 0x12, 0x01, 3, 0, 1, 0, 0, 0, 6, 0, 0, 0) // Orientation = 6
+	tiff = append(tiff, 0x25, 0x88, 4, 0, 1, 0, 0, 0)
+	tiff = binary.LittleEndian.AppendUint32(tiff, 38) // GPS IFD offset
+	tiff = binary.LittleEndian.AppendUint32(tiff, 0)  // no next IFD
+	// GPS IFD at 38: GPSLatitude as 3 rationals stored at offset 56
+	tiff = binary.LittleEndian.AppendUint16(tiff, 1)
+	tiff = append(tiff, 0x02, 0x00, 5, 0, 3, 0, 0, 0)
+	tiff = binary.LittleEndian.AppendUint32(tiff, 56)
+	tiff = binary.LittleEndian.AppendUint32(tiff, 0)
+	for _, v := range []uint32{28, 1, 36, 1, 5, 1} {
+		tiff = binary.LittleEndian.AppendUint32(tiff, v)
+	}
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	jpeg := []byte{0xFF, 0xD8, 0xFF, 0xE1}
+	jpeg = binary.BigEndian.AppendUint16(jpeg, uint16(len(payload)+2))
+	jpeg = append(jpeg, payload...)
+	jpeg = append(jpeg, 0xFF, 0xDA, 0x00, 0x02, 0x11, 0x22, 0xFF, 0xD9)
+	return jpeg
+}
+
+func TestStripImageLocationJPEG(t *testing.T) {
+	original := buildExifJPEG()
+	stripped, err := StripImageLocation(original, "image/jpeg")
+	assert.Nil(t, err)
+	assert.Equal(t, len(original), len(stripped))
+
+	tiff := stripped[4+2+6:]
+	// Orientation survives
+	assert.Equal(t, uint16(6), binary.LittleEndian.Uint16(tiff[8+2+8:]))
+	// GPS IFD is empty and the latitude values are gone
+	assert.Equal(t, uint16(0), binary.LittleEndian.Uint16(tiff[38:]))
+	assert.Equal(t, make([]byte, 24), tiff[56:80])
+	// Scan data is untouched
+	assert.True(t, bytes.HasSuffix(stripped, []byte{0xFF, 0xDA, 0x00, 0x02, 0x11, 0x22, 0xFF, 0xD9}))
+}
+
+func TestStripImageLocationPNG(t *testing.T) {
+	chunk := func(chunkType string, data []byte) []byte {
+		out := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+		out = append(out, chunkType...)
+		out = append(out, data...)
+		return append(out, 0, 0, 0, 0) // CRC is not checked
+	}
+	png := append([]byte{}, pngSignature...)
+	png = append(png, chunk("IHDR", make([]byte, 13))...)
+	png = append(png, chunk("eXIf", []byte("MM\x00*"))...)
+	png = append(png, chunk("iTXt", []byte(pngXMPKeyword+"\x00\x00\x00\x00\x00<x:xmpmeta/>"))...)
+	png = append(png, chunk("IEND", nil)...)
+
+	stripped, err := StripImageLocation(png, "image/png")
+	assert.Nil(t, err)
+	assert.False(t, bytes.Contains(stripped, []byte("eXIf")))
+	assert.False(t, bytes.Contains(stripped, []byte(pngXMPKeyword)))
+	assert.True(t, bytes.Contains(stripped, []byte("IHDR")))
+	assert.True(t, bytes.Contains(stripped, []byte("IEND")))
+}
+
+func TestStripImageLocationRejectsInvalidData(t *testing.T) {
+	_, err := StripImageLocation([]byte("not an image"), "image/jpeg")
+	assert.NotNil(t, err)
+}
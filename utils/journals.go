@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"lambda-server/constants"
+)
+
+// NormalizeTag lowercases a tag and collapses its whitespace, returning an error if it is empty or too long
+func NormalizeTag(tag string) (string, error) {
+	normalized := strings.Join(strings.Fields(strings.ToLower(tag)), " ")
+	if normalized == "" {
+		return "", fmt.Errorf("tags cannot be empty")
+	}
+	if len([]rune(normalized)) > constants.JournalMaxTagLength {
+		return "", fmt.Errorf("tag %q is longer than %d characters", normalized, constants.JournalMaxTagLength)
+	}
+	return normalized, nil
+}
+
+// NormalizeTags normalizes and de-duplicates a list of tags, keeping their original order.
+// A nil input stays nil so callers can tell "not provided" from "cleared".
+func NormalizeTags(tags []string) ([]string, error) {
+	if tags == nil {
+		return nil, nil
+	}
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		t, err := NormalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[t] {
+			seen[t] = true
+			normalized = append(normalized, t)
+		}
+	}
+	if len(normalized) > constants.JournalMaxTags {
+		return nil, fmt.Errorf("a journal entry can have at most %d tags", constants.JournalMaxTags)
+	}
+	return normalized, nil
+}
+
+// ValidateMood checks that a mood rating is within the allowed range (0 means no mood)
+func ValidateMood(mood int) error {
+	if mood == 0 {
+		return nil
+	}
+	if mood < constants.JournalMinMood || mood > constants.JournalMaxMood {
+		return fmt.Errorf("mood must be between %d and %d", constants.JournalMinMood, constants.JournalMaxMood)
+	}
+	return nil
+}
+
+// NormalizeEmotions lowercases and de-duplicates emotion labels, rejecting unknown ones
+func NormalizeEmotions(emotions []string) ([]string, error) {
+	if emotions == nil {
+		return nil, nil
+	}
+	allowed := map[string]bool{}
+	for _, label := range constants.JournalEmotionLabels {
+		allowed[label] = true
+	}
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, emotion := range emotions {
+		e := strings.ToLower(strings.TrimSpace(emotion))
+		if !allowed[e] {
+			return nil, fmt.Errorf("unknown emotion label %q", emotion)
+		}
+		if !seen[e] {
+			seen[e] = true
+			normalized = append(normalized, e)
+		}
+	}
+	return normalized, nil
+}
+
+// ParseDateParam parses a day given as YYYY-MM-DD or YYYYMMDD, returning midnight of that day in loc
+func ParseDateParam(value string, loc *time.Location) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", "20060102"} {
+		if day, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return day, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", value)
+}
+
+// CountWords returns the number of whitespace-separated words in a text
+func CountWords(text string) int {
+	return len(strings.Fields(text))
+}
+
+// ComputeStreaks returns the current and longest runs of consecutive days in days (YYYYMMDD, any order).
+// The current streak counts back from today, or from yesterday if there is no entry today yet.
+func ComputeStreaks(days []string, today time.Time) (int, int) {
+	active := map[string]bool{}
+	for _, day := range days {
+		active[day] = true
+	}
+
+	longest := 0
+	for day := range active {
+		t, err := time.Parse("20060102", day)
+		if err != nil || active[t.AddDate(0, 0, -1).Format("20060102")] {
+			continue // not the start of a run
+		}
+		length := 0
+		for active[t.Format("20060102")] {
+			length++
+			t = t.AddDate(0, 0, 1)
+		}
+		longest = max(longest, length)
+	}
+
+	// Walk calendar days rather than 24h steps so DST changes do not skip or repeat a day
+	cursor := time.Date(today.Year(), today.Month(), today.Day(), 12, 0, 0, 0, time.UTC)
+	if !active[cursor.Format("20060102")] {
+		cursor = cursor.AddDate(0, 0, -1)
+	}
+	current := 0
+	for active[cursor.Format("20060102")] {
+		current++
+		cursor = cursor.AddDate(0, 0, -1)
+	}
+	return current, longest
+}
+
+// JournalTrashRetentionDays returns how many days trashed journals are kept, falling back to the default
+func JournalTrashRetentionDays() int {
+	days, err := strconv.Atoi(os.Getenv(constants.JournalTrashRetentionDaysEnv))
+	if err != nil || days <= 0 {
+		return constants.JournalTrashRetentionDays
+	}
+	return days
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeStreaks(t *testing.T) {
+	today := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	days := []string{"20260302", "20260301", "20260228", "20260227", "20260210", "20260211", "20260212", "20260213", "20260214", "20260101"}
+
+	current, longest := ComputeStreaks(days, today)
+	assert.Equal(t, 4, current) // crosses the end of February
+	assert.Equal(t, 5, longest)
+
+	// No entry yet today: the streak ending yesterday still counts
+	current, _ = ComputeStreaks(days[1:], today)
+	assert.Equal(t, 3, current)
+
+	current, longest = ComputeStreaks(nil, today)
+	assert.Equal(t, 0, current)
+	assert.Equal(t, 0, longest)
+}
+
+func TestCountWords(t *testing.T) {
+	assert.Equal(t, 0, CountWords("  \n"))
+	assert.Equal(t, 4, CountWords("Slept well,\n  woke up."))
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// SyncToken is the position of a device in the server's change feed.
+// A sync pass reads every record changed at or after Since; when the changes do not fit in
+// one response the pass continues from the cursors on the next request.
+type SyncToken struct {
+	Version       int    `json:"v"`
+	Since         int64  `json:"s"`            // Changes with updatedAt >= Since are returned
+	Started       int64  `json:"t"`            // When the current pass started; the next pass reads from here
+	JournalCursor string `json:"jc,omitempty"` // Set while journal changes are being paged
+	MoodCursor    string `json:"mc,omitempty"` // Set while mood changes are being paged
+	JournalsDone  bool   `json:"jd,omitempty"` // Journal changes of the current pass are exhausted
+	MoodsDone     bool   `json:"md,omitempty"` // Mood changes of the current pass are exhausted
+}
+
+const syncTokenVersion = 1
+
+// EncodeSyncToken turns a token into the opaque string handed to clients
+func EncodeSyncToken(token SyncToken) string {
+	token.Version = syncTokenVersion
+	data, _ := json.Marshal(token)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeSyncToken parses a token string. An empty string is the zero token of a first sync.
+func DecodeSyncToken(value string) (SyncToken, error) {
+	var token SyncToken
+	if value == "" {
+		return token, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return token, fmt.Errorf("invalid sync token")
+	}
+	if err := json.Unmarshal(data, &token); err != nil || token.Version != syncTokenVersion {
+		return token, fmt.Errorf("invalid sync token")
+	}
+	return token, nil
+}
+
+// ResolveSyncConflict applies the sync conflict policy to a client edit of a record.
+// The edit conflicts when the server copy changed after the version the client started from
+// (serverUpdatedAt > baseVersion). Conflicts are settled by last writer wins: the client edit
+// is kept only if it was made strictly after the server's last change, ties go to the server.
+func ResolveSyncConflict(serverUpdatedAt, baseVersion, clientUpdatedAt int64) (conflict bool, clientWins bool) {
+	if serverUpdatedAt <= baseVersion {
+		return false, true
+	}
+	return true, clientUpdatedAt > serverUpdatedAt
+}
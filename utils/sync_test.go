@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncTokenRoundTrip(t *testing.T) {
+	token := SyncToken{Since: 1700000000, Started: 1700000500, JournalCursor: "abc", MoodsDone: true}
+	decoded, err := DecodeSyncToken(EncodeSyncToken(token))
+	require.NoError(t, err)
+	token.Version = syncTokenVersion
+	assert.Equal(t, token, decoded)
+}
+
+func TestDecodeSyncTokenEmpty(t *testing.T) {
+	token, err := DecodeSyncToken("")
+	require.NoError(t, err)
+	assert.Equal(t, SyncToken{}, token)
+}
+
+func TestDecodeSyncTokenInvalid(t *testing.T) {
+	_, err := DecodeSyncToken("not a token")
+	assert.Error(t, err)
+
+	_, err = DecodeSyncToken("eyJ2Ijo5fQ") // {"v":9}
+	assert.Error(t, err)
+}
+
+func TestResolveSyncConflict(t *testing.T) {
+	tests := []struct {
+		name                   string
+		server, base, client   int64
+		wantConflict, wantWins bool
+	}{
+		{"server unchanged", 100, 100, 90, false, true},
+		{"client base newer", 100, 120, 90, false, true},
+		{"client edit newer", 200, 100, 250, true, true},
+		{"server edit newer", 200, 100, 150, true, false},
+		{"tie goes to server", 200, 100, 200, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conflict, clientWins := ResolveSyncConflict(tt.server, tt.base, tt.client)
+			assert.Equal(t, tt.wantConflict, conflict)
+			assert.Equal(t, tt.wantWins, clientWins)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	// Embed the IANA database so time zones resolve on hosts without /usr/share/zoneinfo (e.g. Lambda)
+	_ "time/tzdata"
+
+	"lambda-server/models"
+)
+
+// localePattern accepts BCP 47 style tags such as "en", "hi-IN" or "zh-Hant-TW"
+var localePattern = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+// LoadLocation resolves an IANA time zone name, falling back to UTC when it is empty or unknown
+func LoadLocation(name string) *time.Location {
+	if name == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// ValidateTimeZone checks that name is an IANA time zone such as "Asia/Kolkata".
+// "Local" is rejected because it means the server's zone, not the user's.
+func ValidateTimeZone(name string) error {
+	if name == "" || name == "Local" {
+		return fmt.Errorf("time zone is required")
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return fmt.Errorf("unknown time zone %q", name)
+	}
+	return nil
+}
+
+// NormalizeLocale validates a locale tag and returns it in canonical case ("en-us" becomes "en-US")
+func NormalizeLocale(locale string) (string, error) {
+	locale = strings.ReplaceAll(strings.TrimSpace(locale), "_", "-")
+	if !localePattern.MatchString(locale) {
+		return "", fmt.Errorf("invalid locale %q", locale)
+	}
+	parts := strings.Split(locale, "-")
+	parts[0] = strings.ToLower(parts[0])
+	for i := 1; i < len(parts); i++ {
+		switch len(parts[i]) {
+		case 2:
+			parts[i] = strings.ToUpper(parts[i]) // region
+		case 4:
+			parts[i] = strings.ToUpper(parts[i][:1]) + strings.ToLower(parts[i][1:]) // script
+		}
+	}
+	return strings.Join(parts, "-"), nil
+}
+
+// PreferredLocale returns the first language tag of an Accept-Language header, or "" if there is none
+func PreferredLocale(acceptLanguage string) string {
+	first, _, _ := strings.Cut(acceptLanguage, ",")
+	tag, _, _ := strings.Cut(first, ";")
+	tag = strings.TrimSpace(tag)
+	if tag == "*" {
+		return ""
+	}
+	return tag
+}
+
+// UserLocation returns the user's saved time zone, then the zone the client sent, then UTC
+func UserLocation(user *models.User, clientTimeZone string) *time.Location {
+	if user != nil && user.TimeZone != "" {
+		return LoadLocation(user.TimeZone)
+	}
+	return LoadLocation(clientTimeZone)
+}
+
+// DayKey returns the YYYYMMDD calendar day of t in loc, the format used for journal dates and daily buckets
+func DayKey(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format("20060102")
+}
+
+// StartOfDay returns local midnight of the day t falls on in loc. On days with a DST change
+// the day is 23 or 25 hours long, so callers must not assume 24h steps between midnights.
+func StartOfDay(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+}
+
+// FormatLocalDate formats a day for messages shown to the user, in their time zone and a
+// date order that suits their locale
+func FormatLocalDate(t time.Time, loc *time.Location, locale string) string {
+	local := t.In(loc)
+	switch {
+	case locale == "en-US":
+		return local.Format("January 2, 2006")
+	case locale == "" || strings.HasPrefix(locale, "en"):
+		return local.Format("2 January 2006")
+	default:
+		return local.Format("2006-01-02")
+	}
+}
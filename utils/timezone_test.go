@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"lambda-server/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	assert.Nil(t, err)
+	return loc
+}
+
+func TestDayKeyUsesUserTimeZone(t *testing.T) {
+	// 11pm in India is 17:30 UTC the same day, but 2am in India is still the previous day in UTC
+	kolkata := mustLoad(t, "Asia/Kolkata")
+	written := time.Date(2026, 10, 18, 23, 0, 0, 0, kolkata)
+	assert.Equal(t, "20261018", DayKey(written, kolkata))
+	assert.Equal(t, "20261018", DayKey(written, time.UTC))
+
+	early := time.Date(2026, 10, 19, 2, 0, 0, 0, kolkata)
+	assert.Equal(t, "20261019", DayKey(early, kolkata))
+	assert.Equal(t, "20261018", DayKey(early, time.UTC))
+}
+
+func TestStartOfDayAcrossDST(t *testing.T) {
+	newYork := mustLoad(t, "America/New_York")
+
+	// 8 March 2026: clocks jump from 02:00 to 03:00, so the day is 23 hours long
+	springForward := time.Date(2026, 3, 8, 15, 0, 0, 0, newYork)
+	start := StartOfDay(springForward, newYork)
+	next := StartOfDay(start.AddDate(0, 0, 1), newYork)
+	assert.Equal(t, 23*time.Hour, next.Sub(start))
+
+	// 1 November 2026: 01:00-02:00 happens twice, so the day is 25 hours long
+	fallBack := time.Date(2026, 11, 1, 15, 0, 0, 0, newYork)
+	start = StartOfDay(fallBack, newYork)
+	next = StartOfDay(start.AddDate(0, 0, 1), newYork)
+	assert.Equal(t, 25*time.Hour, next.Sub(start))
+	assert.Equal(t, "20261101", DayKey(start.Add(24*time.Hour+30*time.Minute), newYork), "24.5h after midnight is still the same day")
+}
+
+func TestComputeStreaksAcrossDST(t *testing.T) {
+	newYork := mustLoad(t, "America/New_York")
+	today := time.Date(2026, 3, 9, 0, 30, 0, 0, newYork)
+	current, _ := ComputeStreaks([]string{"20260307", "20260308", "20260309"}, today)
+	assert.Equal(t, 3, current)
+}
+
+func TestUserLocationPrefersSavedZone(t *testing.T) {
+	assert.Equal(t, "Asia/Kolkata", UserLocation(&models.User{TimeZone: "Asia/Kolkata"}, "Europe/Paris").String())
+	assert.Equal(t, "Europe/Paris", UserLocation(&models.User{}, "Europe/Paris").String())
+	assert.Equal(t, "UTC", UserLocation(nil, "Not/AZone").String())
+}
+
+func TestValidateTimeZoneAndLocale(t *testing.T) {
+	assert.Nil(t, ValidateTimeZone("Asia/Kolkata"))
+	assert.NotNil(t, ValidateTimeZone("Local"))
+	assert.NotNil(t, ValidateTimeZone("Mars/Olympus"))
+
+	locale, err := NormalizeLocale("pt_br")
+	assert.Nil(t, err)
+	assert.Equal(t, "pt-BR", locale)
+	locale, _ = NormalizeLocale("zh-hant-tw")
+	assert.Equal(t, "zh-Hant-TW", locale)
+	_, err = NormalizeLocale("english please")
+	assert.NotNil(t, err)
+
+	assert.Equal(t, "pt-BR", PreferredLocale("pt-BR,pt;q=0.9,en;q=0.8"))
+	assert.Equal(t, "", PreferredLocale("*"))
+}
+
+func TestFormatLocalDate(t *testing.T) {
+	kolkata := mustLoad(t, "Asia/Kolkata")
+	lastActive := time.Date(2026, 10, 18, 20, 0, 0, 0, time.UTC) // already the 19th in India
+	assert.Equal(t, "19 October 2026", FormatLocalDate(lastActive, kolkata, "en-IN"))
+	assert.Equal(t, "October 18, 2026", FormatLocalDate(lastActive, time.UTC, "en-US"))
+	assert.Equal(t, "2026-10-19", FormatLocalDate(lastActive, kolkata, "hi-IN"))
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ULIDs are 26-character IDs that sort by creation time: a 48-bit millisecond timestamp
+// followed by 80 random bits, in Crockford's base32. IDs from one process are strictly
+// increasing: within a millisecond, or if the clock steps back, the previous ID's random part
+// is incremented instead of drawing a new one.
+const (
+	ulidLength   = 26
+	ulidAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+)
+
+var ulidState struct {
+	sync.Mutex
+	ms      uint64
+	entropy [10]byte
+}
+
+// NewULID returns a ULID for the given time, greater than every ULID returned before it
+func NewULID(now time.Time) string {
+	ms := uint64(max(now.UnixMilli(), 0))
+	ulidState.Lock()
+	defer ulidState.Unlock()
+	if ms > ulidState.ms {
+		ulidState.ms = ms
+		rand.Read(ulidState.entropy[:])
+	} else if !incrementEntropy(&ulidState.entropy) {
+		ulidState.ms++ // The random part overflowed; move to the next millisecond
+	}
+	return encodeULID(ulidState.ms, ulidState.entropy)
+}
+
+// incrementEntropy adds one to the random part, reporting false when it wraps around to zero
+func incrementEntropy(entropy *[10]byte) bool {
+	for i := len(entropy) - 1; i >= 0; i-- {
+		entropy[i]++
+		if entropy[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func encodeULID(ms uint64, entropy [10]byte) string {
+	var b [ulidLength]byte
+	for i := 9; i >= 0; i-- { // 10 characters of timestamp, 5 bits each
+		b[i] = ulidAlphabet[ms&31]
+		ms >>= 5
+	}
+	// 80 bits of entropy as 16 characters
+	hi := uint64(entropy[0])<<32 | uint64(entropy[1])<<24 | uint64(entropy[2])<<16 | uint64(entropy[3])<<8 | uint64(entropy[4])
+	lo := uint64(entropy[5])<<32 | uint64(entropy[6])<<24 | uint64(entropy[7])<<16 | uint64(entropy[8])<<8 | uint64(entropy[9])
+	for i := 17; i >= 10; i-- {
+		b[i] = ulidAlphabet[hi&31]
+		hi >>= 5
+	}
+	for i := 25; i >= 18; i-- {
+		b[i] = ulidAlphabet[lo&31]
+		lo >>= 5
+	}
+	return string(b[:])
+}
+
+// ULIDTime returns the time encoded in a ULID, to the millisecond
+func ULIDTime(id string) (time.Time, error) {
+	if len(id) != ulidLength {
+		return time.Time{}, fmt.Errorf("invalid ULID %q", id)
+	}
+	var ms uint64
+	for i, c := range strings.ToUpper(id) {
+		value := strings.IndexRune(ulidAlphabet, c)
+		if value < 0 || (i == 0 && value > 7) { // The first character only holds 3 bits
+			return time.Time{}, fmt.Errorf("invalid ULID %q", id)
+		}
+		if i < 10 {
+			ms = ms<<5 | uint64(value)
+		}
+	}
+	return time.UnixMilli(int64(ms)), nil
+}
+
+// NewChatMessageID returns the ID of a chat message sent at now, a ULID, and the unix time in
+// seconds it encodes, which becomes the message's timestamp
+func NewChatMessageID(now time.Time) (string, int64) {
+	id := NewULID(now)
+	at, _ := ULIDTime(id)
+	return id, at.Unix()
+}
+
+// ChatMessageSortKey returns the chat table sort key of a message. Messages with an ID are
+// keyed "<sessionId>#<unix seconds>#<ULID>", so they sort by time among themselves and among
+// older messages keyed "<sessionId>#<unix seconds>", whose ID is their timestamp.
+func ChatMessageSortKey(sessionId, messageId string) (string, error) {
+	if at, err := ULIDTime(messageId); err == nil {
+		return fmt.Sprintf("%s#%d#%s", sessionId, at.Unix(), strings.ToUpper(messageId)), nil
+	}
+	if timestamp, err := strconv.ParseInt(messageId, 10, 64); err == nil && timestamp > 0 {
+		return fmt.Sprintf("%s#%d", sessionId, timestamp), nil
+	}
+	return "", fmt.Errorf("invalid message ID %q", messageId)
+}
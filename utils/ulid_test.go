@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewULIDEncodesTime(t *testing.T) {
+	now := time.UnixMilli(1_700_000_000_123)
+	id := NewULID(now)
+
+	require.Len(t, id, 26)
+	parsed, err := ULIDTime(id)
+	require.NoError(t, err)
+	assert.True(t, parsed.Equal(now) || parsed.After(now)) // Later only if an earlier test used a later time
+}
+
+func TestNewULIDIsMonotonic(t *testing.T) {
+	now := time.Now()
+	previous := NewULID(now)
+	for i := 0; i < 1000; i++ {
+		id := NewULID(now) // Same millisecond
+		assert.Greater(t, id, previous)
+		previous = id
+	}
+	// The clock stepping back does not break the order
+	assert.Greater(t, NewULID(now.Add(-time.Hour)), previous)
+}
+
+func TestIncrementEntropyWraps(t *testing.T) {
+	entropy := [10]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 255}
+	assert.True(t, incrementEntropy(&entropy))
+	assert.Equal(t, [10]byte{0, 0, 0, 0, 0, 0, 0, 0, 1, 0}, entropy)
+
+	full := [10]byte{255, 255, 255, 255, 255, 255, 255, 255, 255, 255}
+	assert.False(t, incrementEntropy(&full))
+	assert.Equal(t, [10]byte{}, full)
+}
+
+func TestEncodeULID(t *testing.T) {
+	assert.Equal(t, "0000000000"+"0000000000000000", encodeULID(0, [10]byte{}))
+	assert.Equal(t, "7ZZZZZZZZZ"+"ZZZZZZZZZZZZZZZZ", encodeULID(1<<48-1, [10]byte{255, 255, 255, 255, 255, 255, 255, 255, 255, 255}))
+}
+
+func TestULIDTimeRejectsInvalid(t *testing.T) {
+	for _, id := range []string{"", "1700000000", "8ZZZZZZZZZZZZZZZZZZZZZZZZZ", "0000000000000000000000000U"} {
+		_, err := ULIDTime(id)
+		assert.Error(t, err, id)
+	}
+}
+
+func TestChatMessageSortKey(t *testing.T) {
+	id, timestamp := NewChatMessageID(time.Unix(1_700_000_000, 500_000_000))
+	key, err := ChatMessageSortKey("s1", id)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("s1#%d#%s", timestamp, id), key)
+
+	legacy, err := ChatMessageSortKey("s1", "1700000000")
+	require.NoError(t, err)
+	assert.Equal(t, "s1#1700000000", legacy)
+	// Keyed messages sort after older ones and before newer ones
+	assert.Greater(t, key, "s1#1699999999")
+	assert.Less(t, key, fmt.Sprintf("s1#%d", timestamp+1))
+
+	_, err = ChatMessageSortKey("s1", "nope")
+	assert.Error(t, err)
+}